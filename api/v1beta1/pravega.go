@@ -66,6 +66,14 @@ const (
 	// DefaultSegmentStoreLimitMemory is the default memory limit for Pravega
 	DefaultSegmentStoreLimitMemory = "2Gi"
 
+	// DefaultSegmentStoreServicePort is the default port on which the Segment Store
+	// listens for client connections
+	DefaultSegmentStoreServicePort = 12345
+
+	// DefaultSegmentStoreAdminPort is the default port on which the Segment Store
+	// listens for admin connections
+	DefaultSegmentStoreAdminPort = 9999
+
 	// DefaultInfluxDBSecretMountDir is the default mountpath of influxdb secret
 	DefaultInfluxDBSecretMountDir = "/etc/influxdb-secret-volume"
 
@@ -150,6 +158,22 @@ const (
 	DefaultSegmentStoreLivenessProbeTimeoutSeconds = 5
 )
 
+// sharedOptionDefaults are the defaults of the Segment Store options which the operator sets in
+// the shared options when they are not set by the user
+var sharedOptionDefaults = map[string]string{
+	"bookkeeper.ensemble.size":                          "3",
+	"bookkeeper.write.quorum.size":                      "3",
+	"bookkeeper.ack.quorum.size":                        "3",
+	"bookkeeper.write.quorum.racks.minimumCount.enable": "true",
+}
+
+// IsDefaultedOption returns whether the option holds the value the operator sets in the spec when
+// the user does not
+func IsDefaultedOption(name, value string) bool {
+	defaultValue, ok := sharedOptionDefaults[name]
+	return ok && value == defaultValue
+}
+
 // PravegaSpec defines the configuration of Pravega
 type PravegaSpec struct {
 	// ControllerReplicas defines the number of Controller replicas.
@@ -189,9 +213,21 @@ type PravegaSpec struct {
 	// Options is the Pravega configuration that is passed to the Pravega processes
 	// as JAVA_OPTS. See the following file for a complete list of options:
 	// https://github.com/pravega/pravega/blob/master/config/config.properties
+	// The options known to the operator are only passed to the components reading them,
+	// the others are passed to both the Controller and the Segment Store
 	// +optional
 	Options map[string]string `json:"options"`
 
+	// ControllerOptions is the Pravega configuration that is only passed to the Controller.
+	// It takes precedence over the shared options
+	// +optional
+	ControllerOptions map[string]string `json:"controllerOptions,omitempty"`
+
+	// SegmentStoreOptions is the Pravega configuration that is only passed to the Segment Store.
+	// It takes precedence over the shared options
+	// +optional
+	SegmentStoreOptions map[string]string `json:"segmentStoreOptions,omitempty"`
+
 	// ControllerJvmOptions is the JVM options for controller. It will be passed to the JVM
 	// for performance tuning. If this field is not specified, the operator will use a set of default
 	// options that is good enough for general deployment.
@@ -232,6 +268,10 @@ type PravegaSpec struct {
 	// SegmentStoreResources includes CPU and memory resources
 	SegmentStoreResources *v1.ResourceRequirements `json:"segmentStoreResources,omitempty"`
 
+	// SegmentStoreMemory configures how the memory settings of the Segment Store JVM are set
+	// +optional
+	SegmentStoreMemory *SegmentStoreMemorySpec `json:"segmentStoreMemory,omitempty"`
+
 	// Provides the name of the configmap created by the user to provide additional key-value pairs
 	// that need to be configured into the ss pod as environmental variables
 	SegmentStoreEnvVars string `json:"segmentStoreEnvVars,omitempty"`
@@ -337,6 +377,32 @@ type PravegaSpec struct {
 	// Tolerations for the SegmentStore pods.
 	SegmentStorePodTolerations []corev1.Toleration `json:"segmentStorePodTolerations,omitempty"`
 
+	// TopologySpreadConstraints for the Controller pods.
+	ControllerTopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"controllerTopologySpreadConstraints,omitempty"`
+
+	// TopologySpreadConstraints for the SegmentStore pods.
+	SegmentStoreTopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"segmentStoreTopologySpreadConstraints,omitempty"`
+
+	// PriorityClassName for the Controller pods.
+	ControllerPriorityClassName string `json:"controllerPriorityClassName,omitempty"`
+
+	// PriorityClassName for the SegmentStore pods.
+	SegmentStorePriorityClassName string `json:"segmentStorePriorityClassName,omitempty"`
+
+	// NodeSelector for the Controller pods.
+	ControllerNodeSelector map[string]string `json:"controllerNodeSelector,omitempty"`
+
+	// NodeSelector for the SegmentStore pods.
+	SegmentStoreNodeSelector map[string]string `json:"segmentStoreNodeSelector,omitempty"`
+
+	// SegmentStoreZoneAware spreads the SegmentStore pods evenly across the
+	// "topology.kubernetes.io/zone" topology domain and, unless explicitly set,
+	// enables bookkeeper.write.quorum.racks.minimumCount.enable so that ledger
+	// writes span more than one zone.
+	// Defaults to false.
+	// +optional
+	SegmentStoreZoneAware bool `json:"segmentStoreZoneAware,omitempty"`
+
 	// Containers defines to support multi containers
 	SegmentStoreContainers []v1.Container `json:"segmentStoreContainers,omitempty"`
 
@@ -396,19 +462,15 @@ func (s *PravegaSpec) withDefaults() (changed bool) {
 		s.Options = map[string]string{}
 	}
 
-	if s.Options["bookkeeper.ensemble.size"] == "" {
-		changed = true
-		s.Options["bookkeeper.ensemble.size"] = "3"
-	}
-
-	if s.Options["bookkeeper.write.quorum.size"] == "" {
-		changed = true
-		s.Options["bookkeeper.write.quorum.size"] = "3"
+	for _, name := range []string{"bookkeeper.ensemble.size", "bookkeeper.write.quorum.size", "bookkeeper.ack.quorum.size"} {
+		if s.segmentStoreOptionWithDefault(name, sharedOptionDefaults[name]) {
+			changed = true
+		}
 	}
 
-	if s.Options["bookkeeper.ack.quorum.size"] == "" {
+	if ensembleSize, _ := s.SegmentStoreOption("bookkeeper.ensemble.size"); s.SegmentStoreZoneAware && ensembleSize != "1" &&
+		s.segmentStoreOptionWithDefault("bookkeeper.write.quorum.racks.minimumCount.enable", sharedOptionDefaults["bookkeeper.write.quorum.racks.minimumCount.enable"]) {
 		changed = true
-		s.Options["bookkeeper.ack.quorum.size"] = "3"
 	}
 
 	if s.ControllerJvmOptions == nil {
@@ -584,6 +646,29 @@ func (s *PravegaSpec) withDefaults() (changed bool) {
 	return changed
 }
 
+// segmentStoreOptionWithDefault sets the default value of a Segment Store option left empty,
+// in the Segment Store options when it is set there and else in the shared options
+func (s *PravegaSpec) segmentStoreOptionWithDefault(name string, value string) (changed bool) {
+	if current, _ := s.SegmentStoreOption(name); current != "" {
+		return false
+	}
+	if _, ok := s.SegmentStoreOptions[name]; ok {
+		s.SegmentStoreOptions[name] = value
+	} else {
+		s.Options[name] = value
+	}
+	return true
+}
+
+// SegmentStoreMemorySpec configures the memory settings of the Segment Store JVM
+type SegmentStoreMemorySpec struct {
+	// AutoSize derives the JVM heap (-Xmx), the JVM direct memory (-XX:MaxDirectMemorySize)
+	// and the cache size (pravegaservice.cache.size.max) left unset from the memory limit
+	// of the Segment Store. The computed values are reported in status.segmentStoreMemory
+	// +optional
+	AutoSize bool `json:"autoSize,omitempty"`
+}
+
 // SegmentStoreSecret defines the configuration of the secret for the Segment Store
 type SegmentStoreSecret struct {
 	// Secret specifies the name of Secret which needs to be configured
@@ -688,6 +773,21 @@ func (s *LongTermStorageSpec) withDefaults() (changed bool) {
 	return changed
 }
 
+// Kind returns the kind of the long term storage: filesystem, ecs, hdfs or custom
+func (s *LongTermStorageSpec) Kind() string {
+	switch {
+	case s.FileSystem != nil:
+		return "filesystem"
+	case s.Ecs != nil:
+		return "ecs"
+	case s.Hdfs != nil:
+		return "hdfs"
+	case s.Custom != nil:
+		return "custom"
+	}
+	return ""
+}
+
 // FileSystemSpec contains the reference to a PVC.
 type FileSystemSpec struct {
 	// +optional
@@ -0,0 +1,178 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pravega/pravega-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultBookkeeperEnsembleSize is the ensemble size used by Pravega when the option
+	// bookkeeper.ensemble.size is not set
+	DefaultBookkeeperEnsembleSize = 3
+
+	bookkeeperEnsembleSizeOption = "bookkeeper.ensemble.size"
+)
+
+// BookieCount is the number of bookies found behind spec.bookkeeperUri
+// +kubebuilder:object:generate=false
+type BookieCount struct {
+	// Total is the number of bookie pods, ready or not
+	Total int
+	// Ready is the number of bookie pods ready
+	Ready int
+}
+
+// BookkeeperEnsembleSize returns the value of the option bookkeeper.ensemble.size passed to
+// the Segment Store, or the Pravega default when it is not set
+func (p *PravegaCluster) BookkeeperEnsembleSize() (int, error) {
+	if p.Spec.Pravega == nil {
+		return DefaultBookkeeperEnsembleSize, nil
+	}
+	value, _ := p.Spec.Pravega.SegmentStoreOption(bookkeeperEnsembleSizeOption)
+	if value == "" {
+		return DefaultBookkeeperEnsembleSize, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot convert ensemble size from string to integer: %v", err)
+	}
+	return size, nil
+}
+
+// BookkeeperServices returns the services the hosts of spec.bookkeeperUri are resolved through.
+// The hosts are expected to be Kubernetes DNS names, either of a service, e.g.
+// bookkeeper-bookie-headless.default:3181, or of a bookie behind a headless service, e.g.
+// bookkeeper-bookie-0.bookkeeper-bookie-headless.default.svc.cluster.local:3181. A host made
+// of a single label is looked up in the namespace of the cluster. IP addresses are skipped
+func (p *PravegaCluster) BookkeeperServices() []types.NamespacedName {
+	var services []types.NamespacedName
+	seen := make(map[types.NamespacedName]bool)
+	for _, address := range strings.Split(p.BookkeeperUri(), ",") {
+		host := strings.TrimSpace(address)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		service, ok := bookkeeperServiceForHost(host, p.Namespace)
+		if !ok || seen[service] {
+			continue
+		}
+		seen[service] = true
+		services = append(services, service)
+	}
+	return services
+}
+
+// bookkeeperServiceForHost returns the service a Kubernetes DNS name is resolved through
+func bookkeeperServiceForHost(host string, namespace string) (types.NamespacedName, bool) {
+	names := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for i, name := range names {
+		if name == "svc" {
+			// <service>.<namespace>.svc.<cluster domain>, possibly prefixed by the pod name
+			if i < 2 {
+				return types.NamespacedName{}, false
+			}
+			return types.NamespacedName{Name: names[i-2], Namespace: names[i-1]}, true
+		}
+	}
+	switch len(names) {
+	case 1:
+		return types.NamespacedName{Name: names[0], Namespace: namespace}, true
+	case 2:
+		return types.NamespacedName{Name: names[0], Namespace: names[1]}, true
+	case 3:
+		return types.NamespacedName{Name: names[1], Namespace: names[2]}, true
+	}
+	return types.NamespacedName{}, false
+}
+
+// CountBookies counts the pods selected by the services of spec.bookkeeperUri. It returns nil
+// when none of the services exists, e.g. when BookKeeper is deployed outside of Kubernetes or
+// not deployed yet, in which case the bookies cannot be counted
+func (p *PravegaCluster) CountBookies(c client.Reader) (*BookieCount, error) {
+	var count *BookieCount
+	for _, name := range p.BookkeeperServices() {
+		service := &corev1.Service{}
+		err := c.Get(context.TODO(), name, service)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get service (%s): %v", name, err)
+		}
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		podList := &corev1.PodList{}
+		err = c.List(context.TODO(), podList, &client.ListOptions{
+			Namespace:     name.Namespace,
+			LabelSelector: labels.SelectorFromSet(service.Spec.Selector),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the pods of service (%s): %v", name, err)
+		}
+		if count == nil {
+			count = &BookieCount{}
+		}
+		for i := range podList.Items {
+			count.Total++
+			if util.IsPodReady(&podList.Items[i]) {
+				count.Ready++
+			}
+		}
+	}
+	return count, nil
+}
+
+// ValidateBookieCount checks that there are at least as many bookies as the ensemble size,
+// since the Segment Store cannot create ledgers otherwise. The check is skipped when the
+// bookies cannot be counted
+func (p *PravegaCluster) ValidateBookieCount(count *BookieCount) error {
+	if count == nil {
+		return nil
+	}
+	ensembleSize, err := p.BookkeeperEnsembleSize()
+	if err != nil {
+		// Reported by ValidateBookkeperSettings
+		return nil
+	}
+	if ensembleSize > count.Total {
+		return fmt.Errorf("The value provided for the option bookkeeper.ensemble.size (%d) should be less than or equal to the number of bookies found behind spec.bookkeeperUri (%d)", ensembleSize, count.Total)
+	}
+	return nil
+}
+
+// BookiesDegradedMessage returns why the cluster is degraded when fewer bookies are ready than
+// the ensemble size, or an empty string otherwise
+func (p *PravegaCluster) BookiesDegradedMessage(count *BookieCount) string {
+	if count == nil {
+		return ""
+	}
+	ensembleSize, err := p.BookkeeperEnsembleSize()
+	if err != nil || count.Ready >= ensembleSize {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d bookies are ready, fewer than bookkeeper.ensemble.size (%d)", count.Ready, count.Total, ensembleSize)
+}
@@ -0,0 +1,230 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func bookie(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "bk",
+			Labels:    map[string]string{"app": "bookkeeper-cluster", "kind": "bookie"},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+var _ = Describe("BookKeeper", func() {
+	var (
+		p *v1beta1.PravegaCluster
+	)
+
+	BeforeEach(func() {
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "default",
+				Namespace: "pravega",
+			},
+		}
+		p.WithDefaults()
+	})
+
+	Context("BookkeeperServices", func() {
+		It("should resolve the default uri to the headless service of the bookies", func() {
+			Ω(p.BookkeeperServices()).Should(Equal([]types.NamespacedName{
+				{Name: "bookkeeper-bookie-headless", Namespace: "default"},
+			}))
+		})
+
+		It("should resolve the forms of the Kubernetes DNS names", func() {
+			p.Spec.BookkeeperUri = "bookie:3181, bookie.bk:3181,bookie-0.bookie.bk:3181,bookie.other.svc.cluster.local:3181,bookie-0.bookie.third.svc:3181"
+			Ω(p.BookkeeperServices()).Should(Equal([]types.NamespacedName{
+				{Name: "bookie", Namespace: "pravega"},
+				{Name: "bookie", Namespace: "bk"},
+				{Name: "bookie", Namespace: "other"},
+				{Name: "bookie", Namespace: "third"},
+			}))
+		})
+
+		It("should skip the IP addresses and the external hosts", func() {
+			p.Spec.BookkeeperUri = "10.0.0.1:3181,[fd00::1]:3181,bookie-0.bookies.example.com:3181,"
+			Ω(p.BookkeeperServices()).Should(BeEmpty())
+		})
+	})
+
+	Context("BookkeeperEnsembleSize", func() {
+		It("should default to 3", func() {
+			delete(p.Spec.Pravega.Options, "bookkeeper.ensemble.size")
+			Ω(p.BookkeeperEnsembleSize()).Should(Equal(3))
+		})
+
+		It("should read the option of the segment store", func() {
+			p.Spec.Pravega.SegmentStoreOptions = map[string]string{"bookkeeper.ensemble.size": "5"}
+			Ω(p.BookkeeperEnsembleSize()).Should(Equal(5))
+		})
+
+		It("should fail on a malformed value", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "three"
+			_, err := p.BookkeeperEnsembleSize()
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("CountBookies", func() {
+		var (
+			c client.Client
+		)
+
+		BeforeEach(func() {
+			p.Spec.BookkeeperUri = "bookie-0.bookie-headless.bk:3181,bookie-1.bookie-headless.bk:3181"
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "bookie-headless", Namespace: "bk"},
+				Spec: corev1.ServiceSpec{
+					Selector: map[string]string{"app": "bookkeeper-cluster", "kind": "bookie"},
+				},
+			}
+			other := bookie("other", true)
+			other.Labels = map[string]string{"app": "other"}
+			c = fake.NewClientBuilder().WithRuntimeObjects(service, bookie("bookie-0", true), bookie("bookie-1", false), other).Build()
+		})
+
+		It("should count the pods selected by the service", func() {
+			Ω(p.CountBookies(c)).Should(Equal(&v1beta1.BookieCount{Total: 2, Ready: 1}))
+		})
+
+		It("should return nil when the service does not exist", func() {
+			p.Spec.BookkeeperUri = "bookie-0.missing.bk:3181"
+			Ω(p.CountBookies(c)).Should(BeNil())
+		})
+	})
+
+	Context("ValidateBookieCount", func() {
+		It("should accept an ensemble size up to the number of bookies", func() {
+			Ω(p.ValidateBookieCount(&v1beta1.BookieCount{Total: 3})).Should(Succeed())
+		})
+
+		It("should reject an ensemble size above the number of bookies", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "4"
+			p.Spec.Pravega.Options["bookkeeper.write.quorum.size"] = "3"
+			err := p.ValidateBookieCount(&v1beta1.BookieCount{Total: 3, Ready: 3})
+			Ω(err).Should(MatchError(ContainSubstring("bookkeeper.ensemble.size (4)")))
+			Ω(err).Should(MatchError(ContainSubstring("bookies found behind spec.bookkeeperUri (3)")))
+		})
+
+		It("should skip the check when the bookies cannot be counted", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "4"
+			Ω(p.ValidateBookieCount(nil)).Should(Succeed())
+		})
+	})
+
+	Context("validating webhook", func() {
+		var (
+			validator webhook.CustomValidator
+			old       *v1beta1.PravegaCluster
+		)
+
+		BeforeEach(func() {
+			p.Spec.BookkeeperUri = "bookie-0.bookie-headless.bk:3181,bookie-1.bookie-headless.bk:3181"
+			p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "1610612736"
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1g", "-XX:MaxDirectMemorySize=2560m"}
+			p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2000m"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "bookie-headless", Namespace: "bk"},
+				Spec: corev1.ServiceSpec{
+					Selector: map[string]string{"app": "bookkeeper-cluster", "kind": "bookie"},
+				},
+			}
+			// Only 2 of the 3 bookies of the default ensemble size are left
+			c := fake.NewClientBuilder().WithRuntimeObjects(service, bookie("bookie-0", true), bookie("bookie-1", true)).Build()
+			validator = v1beta1.NewPravegaClusterValidator(c)
+			old = p.DeepCopy()
+		})
+
+		It("should reject a new cluster with fewer bookies than the ensemble size", func() {
+			err := validator.ValidateCreate(context.TODO(), p)
+			Ω(err).Should(MatchError(ContainSubstring("bookies found behind spec.bookkeeperUri (2)")))
+		})
+
+		It("should accept an update which does not change the BookKeeper settings", func() {
+			p.Spec.Pravega.ControllerReplicas = 2
+			Ω(validator.ValidateUpdate(context.TODO(), old, p)).Should(Succeed())
+		})
+
+		It("should accept the finalizer patches while bookies are missing", func() {
+			p.Finalizers = []string{util.ZkFinalizer}
+			Ω(validator.ValidateUpdate(context.TODO(), old, p)).Should(Succeed())
+
+			old = p.DeepCopy()
+			now := metav1.Now()
+			old.DeletionTimestamp = &now
+			p = old.DeepCopy()
+			p.Finalizers = nil
+			Ω(validator.ValidateUpdate(context.TODO(), old, p)).Should(Succeed())
+		})
+
+		It("should reject an update of the ensemble size above the number of bookies", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "2"
+			p.Spec.Pravega.Options["bookkeeper.write.quorum.size"] = "2"
+			p.Spec.Pravega.Options["bookkeeper.ack.quorum.size"] = "2"
+			Ω(validator.ValidateUpdate(context.TODO(), old, p)).Should(Succeed())
+
+			old = p.DeepCopy()
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "3"
+			err := validator.ValidateUpdate(context.TODO(), old, p)
+			Ω(err).Should(MatchError(ContainSubstring("bookkeeper.ensemble.size (3)")))
+		})
+
+		It("should reject an update of spec.bookkeeperUri to fewer bookies than the ensemble size", func() {
+			old.Spec.BookkeeperUri = "bookie-0.other.bk:3181"
+			err := validator.ValidateUpdate(context.TODO(), old, p)
+			Ω(err).Should(MatchError(ContainSubstring("bookies found behind spec.bookkeeperUri (2)")))
+		})
+	})
+
+	Context("BookiesDegradedMessage", func() {
+		It("should be empty while enough bookies are ready", func() {
+			Ω(p.BookiesDegradedMessage(&v1beta1.BookieCount{Total: 4, Ready: 3})).Should(BeEmpty())
+			Ω(p.BookiesDegradedMessage(nil)).Should(BeEmpty())
+		})
+
+		It("should explain why the cluster is degraded", func() {
+			Ω(p.BookiesDegradedMessage(&v1beta1.BookieCount{Total: 3, Ready: 2})).Should(Equal(fmt.Sprintf("%d of %d bookies are ready, fewer than bookkeeper.ensemble.size (%d)", 2, 3, 3)))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pravega/pravega-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// MinAutoSizeMemoryLimit is the smallest Segment Store memory limit the memory settings
+	// can be derived from
+	MinAutoSizeMemoryLimit int64 = 1 << 30
+
+	// MaxAutoSizeHeap caps the heap derived from the memory limit, since the Segment Store
+	// keeps its cache in direct memory
+	MaxAutoSizeHeap int64 = 4 << 30
+
+	// The ratios used to derive the memory settings from the memory limit L:
+	//   reserved      = L / 10, left to the JVM metaspace, threads and the OS
+	//   heap          = min(L / 4, 4Gi)
+	//   direct memory = L - heap - reserved
+	//   cache size    = direct memory * 3 / 4
+	autoSizeReservedRatio    = 10
+	autoSizeHeapRatio        = 4
+	autoSizeCacheNumerator   = 3
+	autoSizeCacheDenominator = 4
+
+	heapJVMOption         = "-Xmx"
+	directMemoryJVMOption = "-XX:MaxDirectMemorySize="
+	cacheSizeOption       = "pravegaservice.cache.size.max"
+)
+
+// SegmentStoreMemorySizes is the memory settings of the Segment Store JVM in bytes, zero
+// when unset
+// +kubebuilder:object:generate=false
+type SegmentStoreMemorySizes struct {
+	Limit        int64
+	Heap         int64
+	DirectMemory int64
+	CacheSize    int64
+}
+
+// IsSegmentStoreMemoryAutoSized returns true when the memory settings of the Segment Store are
+// derived from its memory limit
+func (p *PravegaCluster) IsSegmentStoreMemoryAutoSized() bool {
+	return p.Spec.Pravega != nil && p.Spec.Pravega.SegmentStoreMemory != nil && p.Spec.Pravega.SegmentStoreMemory.AutoSize
+}
+
+// SegmentStoreMemorySizes returns the memory limit of the Segment Store and the memory settings
+// set in its JVM options and options. When autoSize is enabled, the settings left unset are
+// derived from the memory limit
+func (p *PravegaCluster) SegmentStoreMemorySizes() (SegmentStoreMemorySizes, error) {
+	sizes := SegmentStoreMemorySizes{}
+	if p.Spec.Pravega == nil {
+		return sizes, nil
+	}
+	if resources := p.Spec.Pravega.SegmentStoreResources; resources != nil {
+		if limit, ok := resources.Limits[corev1.ResourceMemory]; ok {
+			sizes.Limit = limit.Value()
+		}
+	}
+
+	var err error
+	if value, ok := segmentStoreJVMOption(p.Spec.Pravega.SegmentStoreJVMOptions, heapJVMOption); ok {
+		if sizes.Heap, err = util.ParseMemorySize(value); err != nil {
+			return sizes, fmt.Errorf("invalid value for Segment Store JVM option %s: %v", heapJVMOption, err)
+		}
+	}
+	if value, ok := segmentStoreJVMOption(p.Spec.Pravega.SegmentStoreJVMOptions, directMemoryJVMOption); ok {
+		if sizes.DirectMemory, err = util.ParseMemorySize(value); err != nil {
+			return sizes, fmt.Errorf("invalid value for Segment Store JVM option -XX:MaxDirectMemorySize: %v", err)
+		}
+	}
+	if value, ok := FindOption(p.Spec.Pravega.ComponentOptions(OptionComponentSegmentStore), cacheSizeOption); ok && value != "" {
+		if sizes.CacheSize, err = util.ParseMemorySize(value); err != nil {
+			return sizes, fmt.Errorf("invalid value for option %s: %v", cacheSizeOption, err)
+		}
+	}
+
+	if !p.IsSegmentStoreMemoryAutoSized() {
+		return sizes, nil
+	}
+	if sizes.Limit < MinAutoSizeMemoryLimit {
+		return sizes, fmt.Errorf("spec.pravega.segmentStoreResources.limits.memory must be at least %v when spec.pravega.segmentStoreMemory.autoSize is enabled", resource.NewQuantity(MinAutoSizeMemoryLimit, resource.BinarySI))
+	}
+	if sizes.Heap == 0 {
+		sizes.Heap = roundDownToMebibytes(min64(sizes.Limit/autoSizeHeapRatio, MaxAutoSizeHeap))
+	}
+	if sizes.DirectMemory == 0 {
+		sizes.DirectMemory = roundDownToMebibytes(sizes.Limit - sizes.Heap - sizes.Limit/autoSizeReservedRatio)
+		if sizes.DirectMemory <= 0 {
+			return sizes, fmt.Errorf("JVM Xmx value(%v B) leaves no direct memory out of the total available memory(%v B)", sizes.Heap, sizes.Limit)
+		}
+	}
+	if sizes.CacheSize == 0 {
+		sizes.CacheSize = roundDownToMebibytes(sizes.DirectMemory * autoSizeCacheNumerator / autoSizeCacheDenominator)
+	}
+	return sizes, nil
+}
+
+// SegmentStoreMemoryJVMOptions returns the JVM options of the Segment Store, along with the heap
+// and direct memory sizes derived from the memory limit when autoSize is enabled and they are
+// not set. The JVM options are returned unchanged when the sizes cannot be derived
+func (p *PravegaCluster) SegmentStoreMemoryJVMOptions() []string {
+	jvmOptions := p.Spec.Pravega.SegmentStoreJVMOptions
+	if !p.IsSegmentStoreMemoryAutoSized() {
+		return jvmOptions
+	}
+	sizes, err := p.SegmentStoreMemorySizes()
+	if err != nil {
+		return jvmOptions
+	}
+	options := append([]string{}, jvmOptions...)
+	if _, ok := segmentStoreJVMOption(jvmOptions, heapJVMOption); !ok {
+		options = append(options, fmt.Sprintf("%s%dm", heapJVMOption, sizes.Heap>>20))
+	}
+	if _, ok := segmentStoreJVMOption(jvmOptions, directMemoryJVMOption); !ok {
+		options = append(options, fmt.Sprintf("%s%dm", directMemoryJVMOption, sizes.DirectMemory>>20))
+	}
+	return options
+}
+
+// SegmentStoreMemoryStatus returns the memory settings of the Segment Store reported in the
+// status, nil unless autoSize is enabled and the settings can be derived
+func (p *PravegaCluster) SegmentStoreMemoryStatus() *SegmentStoreMemoryStatus {
+	if !p.IsSegmentStoreMemoryAutoSized() {
+		return nil
+	}
+	sizes, err := p.SegmentStoreMemorySizes()
+	if err != nil {
+		return nil
+	}
+	return &SegmentStoreMemoryStatus{
+		Heap:         *resource.NewQuantity(sizes.Heap, resource.BinarySI),
+		DirectMemory: *resource.NewQuantity(sizes.DirectMemory, resource.BinarySI),
+		CacheSize:    *resource.NewQuantity(sizes.CacheSize, resource.BinarySI),
+	}
+}
+
+// segmentStoreJVMOption returns the value of the last JVM option with the prefix, the one the
+// JVM applies
+func segmentStoreJVMOption(jvmOptions []string, prefix string) (string, bool) {
+	value, found := "", false
+	for _, option := range jvmOptions {
+		if strings.HasPrefix(option, prefix) {
+			value, found = strings.TrimPrefix(option, prefix), true
+		}
+	}
+	return value, found
+}
+
+func roundDownToMebibytes(size int64) int64 {
+	return size >> 20 << 20
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,161 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Segment Store Memory", func() {
+	var (
+		p *v1beta1.PravegaCluster
+	)
+
+	BeforeEach(func() {
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "default",
+			},
+		}
+		p.WithDefaults()
+		p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1000m"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2000m"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		}
+	})
+
+	Context("autoSize disabled", func() {
+		BeforeEach(func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1g"}
+		})
+
+		It("should only return the sizes set", func() {
+			sizes, err := p.SegmentStoreMemorySizes()
+			Ω(err).Should(BeNil())
+			Ω(sizes).Should(Equal(v1beta1.SegmentStoreMemorySizes{Limit: 8 << 30, Heap: 1 << 30}))
+		})
+
+		It("should not change the JVM options", func() {
+			Ω(p.SegmentStoreMemoryJVMOptions()).Should(Equal([]string{"-Xmx1g"}))
+		})
+
+		It("should not report the sizes in status", func() {
+			Ω(p.SegmentStoreMemoryStatus()).Should(BeNil())
+		})
+	})
+
+	Context("autoSize enabled", func() {
+		BeforeEach(func() {
+			p.Spec.Pravega.SegmentStoreMemory = &v1beta1.SegmentStoreMemorySpec{AutoSize: true}
+		})
+
+		It("should derive the sizes from the memory limit", func() {
+			sizes, err := p.SegmentStoreMemorySizes()
+			Ω(err).Should(BeNil())
+			Ω(sizes.Heap).Should(Equal(int64(2048 << 20)))
+			Ω(sizes.DirectMemory).Should(Equal(int64(5324 << 20)))
+			Ω(sizes.CacheSize).Should(Equal(int64(3993 << 20)))
+		})
+
+		It("should add the JVM options", func() {
+			Ω(p.SegmentStoreMemoryJVMOptions()).Should(ConsistOf("-Xmx2048m", "-XX:MaxDirectMemorySize=5324m"))
+			Ω(p.Spec.Pravega.SegmentStoreJVMOptions).Should(BeEmpty())
+		})
+
+		It("should report the sizes in status", func() {
+			status := p.SegmentStoreMemoryStatus()
+			Ω(status).ShouldNot(BeNil())
+			Ω(status.Heap.String()).Should(Equal("2Gi"))
+			Ω(status.DirectMemory.String()).Should(Equal("5324Mi"))
+			Ω(status.CacheSize.String()).Should(Equal("3993Mi"))
+		})
+
+		It("should pass the memory settings validation", func() {
+			Ω(p.ValidateSegmentStoreMemorySettings()).Should(BeNil())
+		})
+
+		It("should keep the sizes set", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xms1g", "-Xmx1g"}
+			p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.cache.size.max": "1073741824"}
+			sizes, err := p.SegmentStoreMemorySizes()
+			Ω(err).Should(BeNil())
+			Ω(sizes.Heap).Should(Equal(int64(1 << 30)))
+			Ω(sizes.DirectMemory).Should(Equal(int64(6348 << 20)))
+			Ω(sizes.CacheSize).Should(Equal(int64(1 << 30)))
+			Ω(p.SegmentStoreMemoryJVMOptions()).Should(ConsistOf("-Xms1g", "-Xmx1g", "-XX:MaxDirectMemorySize=6348m"))
+		})
+
+		It("should fail when the memory limit is too small", func() {
+			p.Spec.Pravega.SegmentStoreResources.Requests[corev1.ResourceMemory] = resource.MustParse("512Mi")
+			p.Spec.Pravega.SegmentStoreResources.Limits[corev1.ResourceMemory] = resource.MustParse("512Mi")
+			_, err := p.SegmentStoreMemorySizes()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("must be at least 1Gi"))
+			Ω(p.ValidateSegmentStoreMemorySettings()).ShouldNot(BeNil())
+			Ω(p.SegmentStoreMemoryStatus()).Should(BeNil())
+		})
+
+		It("should fail when the heap leaves no direct memory", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx8g"}
+			_, err := p.SegmentStoreMemorySizes()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("leaves no direct memory"))
+		})
+	})
+
+	Context("memory size formats", func() {
+		BeforeEach(func() {
+			p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "1610612736"
+		})
+
+		It("should accept the JVM suffixes in either case", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1G", "-XX:MaxDirectMemorySize=2621440k"}
+			sizes, err := p.SegmentStoreMemorySizes()
+			Ω(err).Should(BeNil())
+			Ω(sizes.Heap).Should(Equal(int64(1 << 30)))
+			Ω(sizes.DirectMemory).Should(Equal(int64(2560 << 20)))
+			Ω(p.ValidateSegmentStoreMemorySettings()).Should(BeNil())
+		})
+
+		It("should accept sizes in bytes and Kubernetes quantities", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1073741824", "-XX:MaxDirectMemorySize=2560Mi"}
+			Ω(p.ValidateSegmentStoreMemorySettings()).Should(BeNil())
+		})
+
+		It("should reject invalid sizes without panicking", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmxlarge", "-XX:MaxDirectMemorySize=2560m"}
+			var err error
+			Ω(func() { err = p.ValidateSegmentStoreMemorySettings() }).ShouldNot(Panic())
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("invalid value for Segment Store JVM option -Xmx"))
+		})
+
+		It("should reject an invalid cache size without panicking", func() {
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1g", "-XX:MaxDirectMemorySize=2560m"}
+			p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "1.5 GB"
+			var err error
+			Ω(func() { err = p.ValidateSegmentStoreMemorySettings() }).ShouldNot(Panic())
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("invalid value for option pravegaservice.cache.size.max"))
+		})
+	})
+})
@@ -0,0 +1,593 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pravega/pravega-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// OptionType is the type of the value of a Pravega property
+type OptionType string
+
+const (
+	OptionTypeString OptionType = "string"
+	OptionTypeInt    OptionType = "int"
+	OptionTypeBool   OptionType = "bool"
+)
+
+// OptionComponent is the Pravega component reading a property
+type OptionComponent string
+
+const (
+	OptionComponentController   OptionComponent = "controller"
+	OptionComponentSegmentStore OptionComponent = "segmentStore"
+	OptionComponentAll          OptionComponent = "all"
+)
+
+// OptionDefinition describes a Pravega property of the options catalog
+// +kubebuilder:object:generate=false
+type OptionDefinition struct {
+	Name            string          `json:"name"`
+	Type            OptionType      `json:"type"`
+	Component       OptionComponent `json:"component"`
+	Mutable         bool            `json:"mutable"`
+	DeprecatedNames []string        `json:"deprecatedNames,omitempty"`
+	RenamedIn       string          `json:"renamedIn,omitempty"`
+	Minimum         *int64          `json:"minimum,omitempty"`
+	Maximum         *int64          `json:"maximum,omitempty"`
+	Values          []string        `json:"values,omitempty"`
+}
+
+// OptionsCatalog is the catalog of the Pravega properties known by the operator
+// +kubebuilder:object:generate=false
+type OptionsCatalog struct {
+	// PravegaVersion is the version of Pravega the catalog describes
+	PravegaVersion string             `json:"pravegaVersion"`
+	Options        []OptionDefinition `json:"options"`
+
+	byName map[string]*OptionDefinition
+}
+
+//go:embed pravega_options.yaml
+var optionsCatalogData []byte
+
+var optionsCatalog = mustLoadOptionsCatalog(optionsCatalogData)
+
+// PravegaOptions returns the catalog of the Pravega properties known by the operator
+func PravegaOptions() *OptionsCatalog {
+	return optionsCatalog
+}
+
+func mustLoadOptionsCatalog(data []byte) *OptionsCatalog {
+	catalog, err := LoadOptionsCatalog(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid Pravega options catalog: %v", err))
+	}
+	return catalog
+}
+
+// LoadOptionsCatalog parses a catalog of Pravega properties
+func LoadOptionsCatalog(data []byte) (*OptionsCatalog, error) {
+	catalog := &OptionsCatalog{}
+	if err := yaml.UnmarshalStrict(data, catalog); err != nil {
+		return nil, err
+	}
+	catalog.byName = map[string]*OptionDefinition{}
+	for i := range catalog.Options {
+		o := &catalog.Options[i]
+		switch o.Type {
+		case OptionTypeString, OptionTypeInt, OptionTypeBool:
+		default:
+			return nil, fmt.Errorf("option %s has an invalid type %q", o.Name, o.Type)
+		}
+		switch o.Component {
+		case OptionComponentController, OptionComponentSegmentStore, OptionComponentAll:
+		default:
+			return nil, fmt.Errorf("option %s has an invalid component %q", o.Name, o.Component)
+		}
+		if o.RenamedIn != "" {
+			if len(o.DeprecatedNames) == 0 {
+				return nil, fmt.Errorf("option %s is renamed without deprecated names", o.Name)
+			}
+			if _, err := util.NormalizeVersion(o.RenamedIn); err != nil {
+				return nil, fmt.Errorf("option %s has an invalid rename version: %v", o.Name, err)
+			}
+		}
+		for _, name := range append([]string{o.Name}, o.DeprecatedNames...) {
+			if _, ok := catalog.byName[name]; ok {
+				return nil, fmt.Errorf("option %s is defined twice", name)
+			}
+			catalog.byName[name] = o
+		}
+	}
+	return catalog, nil
+}
+
+// Lookup returns the definition of a property from its name or one of its deprecated names
+func (c *OptionsCatalog) Lookup(name string) (*OptionDefinition, bool) {
+	o, ok := c.byName[name]
+	return o, ok
+}
+
+// Names returns the name and the deprecated names of the property
+func (o *OptionDefinition) Names() []string {
+	return append([]string{o.Name}, o.DeprecatedNames...)
+}
+
+// NameFor returns the name under which the given Pravega version reads the property
+func (o *OptionDefinition) NameFor(version string) string {
+	if o.RenamedIn == "" || version == "" {
+		return o.Name
+	}
+	if util.IsVersionBelow(version, o.RenamedIn) {
+		return o.DeprecatedNames[0]
+	}
+	return o.Name
+}
+
+// IsReadBy returns whether the property is read by the given component
+func (o *OptionDefinition) IsReadBy(component OptionComponent) bool {
+	return o.Component == OptionComponentAll || o.Component == component
+}
+
+// Validate checks the value of the property against its type and valid values
+func (o *OptionDefinition) Validate(value string) error {
+	switch o.Type {
+	case OptionTypeInt:
+		v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("option %s should be an integer, found %q", o.Name, value)
+		}
+		if o.Minimum != nil && v < *o.Minimum {
+			return fmt.Errorf("option %s should be at least %d, found %d", o.Name, *o.Minimum, v)
+		}
+		if o.Maximum != nil && v > *o.Maximum {
+			return fmt.Errorf("option %s should be at most %d, found %d", o.Name, *o.Maximum, v)
+		}
+	case OptionTypeBool:
+		if !strings.EqualFold(value, "true") && !strings.EqualFold(value, "false") {
+			return fmt.Errorf("option %s should be true or false, found %q", o.Name, value)
+		}
+	}
+	if len(o.Values) > 0 {
+		for _, valid := range o.Values {
+			if value == valid {
+				return nil
+			}
+		}
+		return fmt.Errorf("option %s should be one of %s, found %q", o.Name, strings.Join(o.Values, ", "), value)
+	}
+	return nil
+}
+
+// OptionsFor returns the options read by the given component. The options unknown to the
+// catalog are read by all the components
+func OptionsFor(options map[string]string, component OptionComponent) map[string]string {
+	selected := map[string]string{}
+	for name, value := range options {
+		if o, ok := optionsCatalog.Lookup(name); ok && !o.IsReadBy(component) {
+			continue
+		}
+		selected[name] = value
+	}
+	return selected
+}
+
+// OptionRename is an option set in the spec under a name the Pravega version does not read
+// +kubebuilder:object:generate=false
+type OptionRename struct {
+	From string
+	To   string
+}
+
+// RenameOptions returns the options under the names the given Pravega version reads, along with
+// the options which had to be renamed. An option set under several names is only kept once
+func RenameOptions(options map[string]string, version string) (map[string]string, []OptionRename) {
+	renamed := map[string]string{}
+	var renames []OptionRename
+	for _, name := range sortedNames(options) {
+		target := name
+		if o, ok := optionsCatalog.Lookup(name); ok {
+			target = o.NameFor(version)
+		}
+		if target != name {
+			renames = append(renames, OptionRename{From: name, To: target})
+			if _, ok := options[target]; ok {
+				continue
+			}
+		}
+		if _, ok := renamed[target]; ok {
+			continue
+		}
+		renamed[target] = options[name]
+	}
+	return renamed, renames
+}
+
+// FindOption returns the value of a property in the options, under any of its names
+func FindOption(options map[string]string, name string) (string, bool) {
+	names := []string{name}
+	if o, ok := optionsCatalog.Lookup(name); ok {
+		names = o.Names()
+	}
+	for _, n := range names {
+		if value, ok := options[n]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// JavaOption returns the value of a property in the JVM options of the config map, under any
+// of its names
+func JavaOption(configMap *corev1.ConfigMap, name string) (string, bool) {
+	return FindOption(parseJavaOptions(configMap.Data["JAVA_OPTS"]), name)
+}
+
+// ControllerOption returns the value of the option passed to the Controller, as set in the
+// Controller options or else in the shared options
+func (s *PravegaSpec) ControllerOption(name string) (string, bool) {
+	return componentOption(s.ControllerOptions, s.Options, name)
+}
+
+// SegmentStoreOption returns the value of the option passed to the Segment Store, as set in the
+// Segment Store options or else in the shared options
+func (s *PravegaSpec) SegmentStoreOption(name string) (string, bool) {
+	return componentOption(s.SegmentStoreOptions, s.Options, name)
+}
+
+func componentOption(componentOptions map[string]string, options map[string]string, name string) (string, bool) {
+	if value, ok := componentOptions[name]; ok {
+		return value, true
+	}
+	value, ok := options[name]
+	return value, ok
+}
+
+// ComponentOptions returns the options passed to the component: the shared options read by the
+// component, overridden by the options of the component whichever name they are set under
+func (s *PravegaSpec) ComponentOptions(component OptionComponent) map[string]string {
+	options := OptionsFor(s.Options, component)
+	var componentOptions map[string]string
+	switch component {
+	case OptionComponentController:
+		componentOptions = s.ControllerOptions
+	case OptionComponentSegmentStore:
+		componentOptions = s.SegmentStoreOptions
+	}
+	for name := range componentOptions {
+		if o, ok := optionsCatalog.Lookup(name); ok {
+			for _, n := range o.Names() {
+				delete(options, n)
+			}
+		}
+	}
+	for name, value := range componentOptions {
+		options[name] = value
+	}
+	return options
+}
+
+// UnknownOptions returns the sorted names of the options missing from the catalog
+func UnknownOptions(options ...map[string]string) []string {
+	found := map[string]bool{}
+	var unknown []string
+	for _, m := range options {
+		for name := range m {
+			if _, ok := optionsCatalog.Lookup(name); !ok && !found[name] {
+				found[name] = true
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// UnknownOptions returns the sorted names of the options of the spec missing from the catalog
+func (s *PravegaSpec) UnknownOptions() []string {
+	return UnknownOptions(s.Options, s.ControllerOptions, s.SegmentStoreOptions)
+}
+
+// OptionWarnings returns the issues of the options which do not prevent deploying the
+// cluster: the options missing from the catalog, and the shared options read by a single
+// component which belong to the options of that component. The defaults the operator sets
+// in the shared options are not reported
+func (s *PravegaSpec) OptionWarnings() []string {
+	var warnings []string
+	if unknown := s.UnknownOptions(); len(unknown) > 0 {
+		warnings = append(warnings, fmt.Sprintf("options %s are unknown and passed to all the components", strings.Join(unknown, ", ")))
+	}
+	for _, name := range sortedNames(s.Options) {
+		o, ok := optionsCatalog.Lookup(name)
+		if !ok {
+			continue
+		}
+		if value, ok := sharedOptionDefaults[name]; ok && s.Options[name] == value {
+			continue
+		}
+		switch o.Component {
+		case OptionComponentController:
+			warnings = append(warnings, fmt.Sprintf("option %s is only read by the controller and should be set in spec.pravega.controllerOptions", name))
+		case OptionComponentSegmentStore:
+			warnings = append(warnings, fmt.Sprintf("option %s is only read by the segment store and should be set in spec.pravega.segmentStoreOptions", name))
+		}
+	}
+	return warnings
+}
+
+// ValidatePravegaOptions checks the values of the options known to the catalog, and that a
+// property is not set under several of its names with different values. Empty values keep the
+// Pravega default. The unknown options are accepted, as they may be understood by another
+// version of Pravega, but are logged with the other option warnings
+func (p *PravegaCluster) ValidatePravegaOptions() error {
+	return p.validatePravegaOptionFields().ToAggregate()
+}
+
+func (p *PravegaCluster) validatePravegaOptionFields() field.ErrorList {
+	if p.Spec.Pravega == nil {
+		return nil
+	}
+	for _, warning := range p.Spec.Pravega.OptionWarnings() {
+		pravegaclusterlog.Info("option warning", "name", p.Name, "warning", warning)
+	}
+	path := field.NewPath("spec", "pravega")
+	var errs field.ErrorList
+	errs = append(errs, validateOptions(path.Child("options"), p.Spec.Pravega.Options)...)
+	errs = append(errs, validateOptions(path.Child("controllerOptions"), p.Spec.Pravega.ControllerOptions)...)
+	errs = append(errs, validateOptions(path.Child("segmentStoreOptions"), p.Spec.Pravega.SegmentStoreOptions)...)
+	return errs
+}
+
+func validateOptions(path *field.Path, options map[string]string) field.ErrorList {
+	var errs field.ErrorList
+	set := map[string]string{}
+	for _, name := range sortedNames(options) {
+		value := options[name]
+		if _, err := ParseVolumeMounts(name, value); err != nil {
+			errs = append(errs, field.Invalid(path.Key(name), value, err.Error()))
+		}
+		o, ok := optionsCatalog.Lookup(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := o.Validate(value); err != nil {
+			errs = append(errs, field.Invalid(path.Key(name), value, err.Error()))
+			continue
+		}
+		if previous, ok := set[o.Name]; ok && previous != value {
+			errs = append(errs, field.Invalid(path.Key(name), value, fmt.Sprintf("option %s is set with different values under the names %s", o.Name, strings.Join(o.Names(), ", "))))
+		}
+		set[o.Name] = value
+	}
+	return errs
+}
+
+// validateJVMOptionFields checks the format of the "-XX:" JVM options and the memory sizes of
+// the Segment Store JVM options
+func (p *PravegaCluster) validateJVMOptionFields() field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec", "pravega")
+	jvmOptions := map[string][]string{
+		"controllerjvmOptions":   p.Spec.Pravega.ControllerJvmOptions,
+		"segmentStoreJVMOptions": p.Spec.Pravega.SegmentStoreJVMOptions,
+	}
+	for _, name := range []string{"controllerjvmOptions", "segmentStoreJVMOptions"} {
+		for i, option := range jvmOptions[name] {
+			if !util.IsValidJVMOption(option) {
+				errs = append(errs, field.Invalid(path.Child(name).Index(i), option, `options starting with "-XX:" should be "-XX:+Name", "-XX:-Name" or "-XX:Name=value"`))
+			}
+		}
+	}
+	for i, option := range p.Spec.Pravega.SegmentStoreJVMOptions {
+		for _, prefix := range []string{heapJVMOption, directMemoryJVMOption} {
+			if !strings.HasPrefix(option, prefix) {
+				continue
+			}
+			if _, err := util.ParseMemorySize(strings.TrimPrefix(option, prefix)); err != nil {
+				errs = append(errs, field.Invalid(path.Child("segmentStoreJVMOptions").Index(i), option, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// VolumeMountOption is a volume set with the hostPathVolumeMounts, emptyDirVolumeMounts or
+// configMapVolumeMounts options, which the operator mounts in the pods of the components
+// +kubebuilder:object:generate=false
+type VolumeMountOption struct {
+	// Name is the name of the volume, the name of the config map for configMapVolumeMounts
+	Name string
+	// Key is the key of the config map mounted, only set for configMapVolumeMounts
+	Key string
+	// MountPath is the path of the volume in the containers
+	MountPath string
+}
+
+// ParseVolumeMounts parses the value of a volume mount option, made of comma separated
+// "name=path" entries, or "configmap:key=path" entries for configMapVolumeMounts. Empty entries
+// are skipped. The well-formed entries are returned along with an error for the malformed ones.
+// Other options have no volume mounts
+func ParseVolumeMounts(option string, value string) ([]VolumeMountOption, error) {
+	if option != "hostPathVolumeMounts" && option != "emptyDirVolumeMounts" && option != "configMapVolumeMounts" {
+		return nil, nil
+	}
+	var mounts []VolumeMountOption
+	var malformed []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry == "" {
+			continue
+		}
+		name, path, found := strings.Cut(entry, "=")
+		mount := VolumeMountOption{Name: name, MountPath: path}
+		if option == "configMapVolumeMounts" {
+			mount.Name, mount.Key, _ = strings.Cut(name, ":")
+		}
+		if !found || mount.Name == "" || mount.MountPath == "" || (option == "configMapVolumeMounts" && mount.Key == "") {
+			malformed = append(malformed, fmt.Sprintf("%q", entry))
+			continue
+		}
+		mounts = append(mounts, mount)
+	}
+	if len(malformed) > 0 {
+		format := "name=path"
+		if option == "configMapVolumeMounts" {
+			format = "configmap:key=path"
+		}
+		return mounts, fmt.Errorf("option %s should be a list of %q entries, found %s", option, format, strings.Join(malformed, ", "))
+	}
+	return mounts, nil
+}
+
+func sortedNames(options map[string]string) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewImmutableSettings returns the settings which cannot be changed once the cluster is
+// deployed. The immutable options of a component are read from its config map when it exists,
+// so that the clusters deployed before the settings were recorded keep their deployed values,
+// and from the spec otherwise
+func (p *PravegaCluster) NewImmutableSettings(controllerConfigMap *corev1.ConfigMap, segmentStoreConfigMap *corev1.ConfigMap) *ImmutableSettings {
+	settings := &ImmutableSettings{}
+	if p.Spec.Pravega == nil {
+		return settings
+	}
+	settings.ControllerOptions = p.immutableOptions(OptionComponentController, controllerConfigMap)
+	settings.SegmentStoreOptions = p.immutableOptions(OptionComponentSegmentStore, segmentStoreConfigMap)
+	if p.Spec.Pravega.LongTermStorage != nil {
+		settings.LongTermStorage = p.Spec.Pravega.LongTermStorage.Kind()
+	}
+	return settings
+}
+
+// immutableOptions returns the values of the immutable options read by the component, under
+// their current name
+func (p *PravegaCluster) immutableOptions(component OptionComponent, configMap *corev1.ConfigMap) map[string]string {
+	options := p.Spec.Pravega.jvmOptions(component)
+	if configMap != nil {
+		options = parseJavaOptions(configMap.Data["JAVA_OPTS"])
+	}
+	immutable := map[string]string{}
+	for i := range optionsCatalog.Options {
+		o := &optionsCatalog.Options[i]
+		if o.Mutable || !o.IsReadBy(component) {
+			continue
+		}
+		if value, ok := FindOption(options, o.Name); ok && value != "" {
+			immutable[o.Name] = value
+		}
+	}
+	if len(immutable) == 0 {
+		return nil
+	}
+	return immutable
+}
+
+// ValidateImmutableSettings checks that the settings which cannot be changed once the cluster
+// is deployed have the values recorded in its status. Setting or removing an immutable option
+// is a change, since Pravega would then use another value than its default or the other way
+// around. The check is skipped when no settings were recorded yet
+func (p *PravegaCluster) ValidateImmutableSettings(settings *ImmutableSettings) field.ErrorList {
+	if settings == nil || p.Spec.Pravega == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	recorded := map[OptionComponent]map[string]string{
+		OptionComponentController:   settings.ControllerOptions,
+		OptionComponentSegmentStore: settings.SegmentStoreOptions,
+	}
+	for _, component := range []OptionComponent{OptionComponentController, OptionComponentSegmentStore} {
+		options := p.Spec.Pravega.jvmOptions(component)
+		for i := range optionsCatalog.Options {
+			o := &optionsCatalog.Options[i]
+			if o.Mutable || !o.IsReadBy(component) {
+				continue
+			}
+			name, value := o.Name, ""
+			for _, n := range o.Names() {
+				if v, ok := options[n]; ok && v != "" {
+					name, value = n, v
+					break
+				}
+			}
+			if deployed := recorded[component][o.Name]; value != deployed {
+				errs = append(errs, field.Forbidden(p.Spec.Pravega.optionPath(component, name), fmt.Sprintf("%s should not be modified", name)))
+			}
+		}
+	}
+	if lts := p.Spec.Pravega.LongTermStorage; lts != nil && settings.LongTermStorage != "" && lts.Kind() != settings.LongTermStorage {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "pravega", "longtermStorage"), fmt.Sprintf("the long term storage should not be changed from %s to %s", settings.LongTermStorage, lts.Kind())))
+	}
+	return errs
+}
+
+// jvmOptions returns the properties set in the JVM options of the component, as in its config
+// map: its options, and the options of the custom long term storage for the Segment Store
+func (s *PravegaSpec) jvmOptions(component OptionComponent) map[string]string {
+	options := s.ComponentOptions(component)
+	if component == OptionComponentSegmentStore && s.LongTermStorage != nil && s.LongTermStorage.Custom != nil {
+		for name, value := range s.LongTermStorage.Custom.Options {
+			options[name] = value
+		}
+	}
+	return options
+}
+
+// optionPath returns the path of the option passed to the component, in the options of the
+// component when set there or else in the shared options
+func (s *PravegaSpec) optionPath(component OptionComponent, name string) *field.Path {
+	path := field.NewPath("spec", "pravega")
+	switch component {
+	case OptionComponentController:
+		if _, ok := FindOption(s.ControllerOptions, name); ok {
+			return path.Child("controllerOptions").Key(name)
+		}
+	case OptionComponentSegmentStore:
+		if s.LongTermStorage != nil && s.LongTermStorage.Custom != nil {
+			if _, ok := FindOption(s.LongTermStorage.Custom.Options, name); ok {
+				return path.Child("longtermStorage", "custom", "options").Key(name)
+			}
+		}
+		if _, ok := FindOption(s.SegmentStoreOptions, name); ok {
+			return path.Child("segmentStoreOptions").Key(name)
+		}
+	}
+	return path.Child("options").Key(name)
+}
+
+// parseJavaOptions returns the system properties set with -D in the JVM options
+func parseJavaOptions(javaOpts string) map[string]string {
+	properties := map[string]string{}
+	for _, opt := range strings.Fields(javaOpts) {
+		if !strings.HasPrefix(opt, "-D") {
+			continue
+		}
+		kv := strings.SplitN(strings.TrimPrefix(opt, "-D"), "=", 2)
+		if len(kv) == 2 {
+			properties[kv[0]] = kv[1]
+		}
+	}
+	return properties
+}
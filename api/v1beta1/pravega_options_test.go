@@ -0,0 +1,480 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Pravega Options Catalog", func() {
+
+	Context("Embedded catalog", func() {
+		It("should be loaded", func() {
+			catalog := v1beta1.PravegaOptions()
+			Ω(catalog.PravegaVersion).ShouldNot(BeEmpty())
+			Ω(catalog.Options).ShouldNot(BeEmpty())
+		})
+
+		It("should find an option from its name and its deprecated names", func() {
+			o, ok := v1beta1.PravegaOptions().Lookup("controller.container.count")
+			Ω(ok).Should(BeTrue())
+			Ω(o.Type).Should(Equal(v1beta1.OptionTypeInt))
+			Ω(o.Component).Should(Equal(v1beta1.OptionComponentController))
+			Ω(o.Mutable).Should(BeFalse())
+			alias, ok := v1beta1.PravegaOptions().Lookup("controller.containerCount")
+			Ω(ok).Should(BeTrue())
+			Ω(alias).Should(Equal(o))
+		})
+
+		It("should not find an unknown option", func() {
+			_, ok := v1beta1.PravegaOptions().Lookup("foo.bar")
+			Ω(ok).Should(BeFalse())
+		})
+	})
+
+	Context("LoadOptionsCatalog", func() {
+		It("should reject an invalid type", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: float\n  component: all\n"))
+			Ω(err).Should(MatchError(ContainSubstring("invalid type")))
+		})
+
+		It("should reject an invalid component", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: int\n  component: bookie\n"))
+			Ω(err).Should(MatchError(ContainSubstring("invalid component")))
+		})
+
+		It("should reject an option defined twice", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: int\n  component: all\n- name: b\n  type: int\n  component: all\n  deprecatedNames: [a]\n"))
+			Ω(err).Should(MatchError(ContainSubstring("defined twice")))
+		})
+
+		It("should reject a rename without deprecated names", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: int\n  component: all\n  renamedIn: 0.7.0\n"))
+			Ω(err).Should(MatchError(ContainSubstring("without deprecated names")))
+		})
+
+		It("should reject an invalid rename version", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: int\n  component: all\n  deprecatedNames: [b]\n  renamedIn: latest\n"))
+			Ω(err).Should(MatchError(ContainSubstring("invalid rename version")))
+		})
+
+		It("should reject an unknown field", func() {
+			_, err := v1beta1.LoadOptionsCatalog([]byte("options:\n- name: a\n  type: int\n  component: all\n  default: 1\n"))
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Validate", func() {
+		var catalog *v1beta1.OptionsCatalog
+
+		BeforeEach(func() {
+			var err error
+			catalog, err = v1beta1.LoadOptionsCatalog([]byte(`
+options:
+- name: count
+  type: int
+  component: all
+  minimum: 1
+  maximum: 10
+- name: enabled
+  type: bool
+  component: all
+- name: layout
+  type: string
+  component: all
+  values: [A, B]
+`))
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should check the integers and their range", func() {
+			o, _ := catalog.Lookup("count")
+			Ω(o.Validate("5")).Should(Succeed())
+			Ω(o.Validate("five")).Should(MatchError(ContainSubstring("should be an integer")))
+			Ω(o.Validate("0")).Should(MatchError(ContainSubstring("at least 1")))
+			Ω(o.Validate("11")).Should(MatchError(ContainSubstring("at most 10")))
+		})
+
+		It("should check the booleans", func() {
+			o, _ := catalog.Lookup("enabled")
+			Ω(o.Validate("true")).Should(Succeed())
+			Ω(o.Validate("False")).Should(Succeed())
+			Ω(o.Validate("yes")).Should(HaveOccurred())
+		})
+
+		It("should check the valid values", func() {
+			o, _ := catalog.Lookup("layout")
+			Ω(o.Validate("A")).Should(Succeed())
+			Ω(o.Validate("C")).Should(MatchError(ContainSubstring("should be one of A, B")))
+		})
+	})
+
+	Context("OptionsFor", func() {
+		var options map[string]string
+
+		BeforeEach(func() {
+			options = map[string]string{
+				"controller.retention.bucket.count": "10",
+				"pravegaservice.container.count":    "4",
+				"metrics.statistics.enable":         "true",
+				"foo.bar":                           "baz",
+			}
+		})
+
+		It("should route the controller options to the controller only", func() {
+			Ω(v1beta1.OptionsFor(options, v1beta1.OptionComponentController)).Should(Equal(map[string]string{
+				"controller.retention.bucket.count": "10",
+				"metrics.statistics.enable":         "true",
+				"foo.bar":                           "baz",
+			}))
+		})
+
+		It("should route the segment store options to the segment store only", func() {
+			Ω(v1beta1.OptionsFor(options, v1beta1.OptionComponentSegmentStore)).Should(Equal(map[string]string{
+				"pravegaservice.container.count": "4",
+				"metrics.statistics.enable":      "true",
+				"foo.bar":                        "baz",
+			}))
+		})
+
+		It("should list the unknown options", func() {
+			Ω(v1beta1.UnknownOptions(options)).Should(Equal([]string{"foo.bar"}))
+		})
+	})
+
+	Context("RenameOptions", func() {
+		var options map[string]string
+
+		BeforeEach(func() {
+			options = map[string]string{
+				"pravegaservice.containerCount":        "4",
+				"pravegaservice.service.listener.port": "443",
+				"pravegaservice.admin.listener.port":   "9999",
+				"foo.bar":                              "baz",
+			}
+		})
+
+		It("should name the options as the versions below the rename read them", func() {
+			renamed, renames := v1beta1.RenameOptions(options, "0.6.2")
+			Ω(renamed).Should(Equal(map[string]string{
+				"pravegaservice.containerCount":      "4",
+				"pravegaservice.listeningPort":       "443",
+				"pravegaservice.admin.listener.port": "9999",
+				"foo.bar":                            "baz",
+			}))
+			Ω(renames).Should(Equal([]v1beta1.OptionRename{
+				{From: "pravegaservice.service.listener.port", To: "pravegaservice.listeningPort"},
+			}))
+		})
+
+		It("should name the options as the versions from the rename read them", func() {
+			renamed, renames := v1beta1.RenameOptions(options, "0.7.0")
+			Ω(renamed).Should(Equal(map[string]string{
+				"pravegaservice.container.count":       "4",
+				"pravegaservice.service.listener.port": "443",
+				"pravegaservice.admin.listener.port":   "9999",
+				"foo.bar":                              "baz",
+			}))
+			Ω(renames).Should(Equal([]v1beta1.OptionRename{
+				{From: "pravegaservice.containerCount", To: "pravegaservice.container.count"},
+			}))
+		})
+
+		It("should keep an option set under several names once", func() {
+			options["pravegaservice.container.count"] = "4"
+			renamed, _ := v1beta1.RenameOptions(options, "0.10.0")
+			Ω(renamed).Should(HaveKeyWithValue("pravegaservice.container.count", "4"))
+			Ω(renamed).ShouldNot(HaveKey("pravegaservice.containerCount"))
+		})
+
+		It("should find an option in a config map under any of its names", func() {
+			cm := &corev1.ConfigMap{
+				Data: map[string]string{
+					"JAVA_OPTS": "-Xmx1g -Dpravegaservice.listeningPort=443",
+				},
+			}
+			value, ok := v1beta1.JavaOption(cm, "pravegaservice.service.listener.port")
+			Ω(ok).Should(BeTrue())
+			Ω(value).Should(Equal("443"))
+			_, ok = v1beta1.JavaOption(cm, "pravegaservice.admin.listener.port")
+			Ω(ok).Should(BeFalse())
+		})
+	})
+
+	Context("ValidatePravegaOptions", func() {
+		var p *v1beta1.PravegaCluster
+
+		BeforeEach(func() {
+			p = &v1beta1.PravegaCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+				},
+				Spec: v1beta1.ClusterSpec{
+					Pravega: &v1beta1.PravegaSpec{
+						Options: map[string]string{},
+					},
+				},
+			}
+		})
+
+		It("should accept valid and unknown options", func() {
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "4"
+			p.Spec.Pravega.Options["foo.bar"] = "baz"
+			Ω(p.ValidatePravegaOptions()).Should(Succeed())
+		})
+
+		It("should accept empty values", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = ""
+			Ω(p.ValidatePravegaOptions()).Should(Succeed())
+		})
+
+		It("should reject a value of the wrong type", func() {
+			p.Spec.Pravega.Options["controller.security.auth.enable"] = "yes"
+			Ω(p.ValidatePravegaOptions()).Should(MatchError(ContainSubstring("should be true or false")))
+		})
+
+		It("should validate the deprecated names", func() {
+			p.Spec.Pravega.Options["pravegaservice.containerCount"] = "0"
+			Ω(p.ValidatePravegaOptions()).Should(MatchError(ContainSubstring("pravegaservice.container.count should be at least 1")))
+		})
+
+		It("should reject different values under the name and a deprecated name", func() {
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "4"
+			p.Spec.Pravega.Options["pravegaservice.containerCount"] = "8"
+			Ω(p.ValidatePravegaOptions()).Should(MatchError(ContainSubstring("different values")))
+		})
+
+		It("should accept the same value under the name and a deprecated name", func() {
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "4"
+			p.Spec.Pravega.Options["pravegaservice.containerCount"] = "4"
+			Ω(p.ValidatePravegaOptions()).Should(Succeed())
+		})
+	})
+
+	Context("Component options", func() {
+		var s *v1beta1.PravegaSpec
+
+		BeforeEach(func() {
+			s = &v1beta1.PravegaSpec{
+				Options: map[string]string{
+					"pravegaservice.containerCount":     "4",
+					"metrics.statistics.enable":         "true",
+					"controller.retention.bucket.count": "10",
+				},
+				ControllerOptions: map[string]string{
+					"metrics.statistics.enable": "false",
+				},
+				SegmentStoreOptions: map[string]string{
+					"pravegaservice.container.count":       "8",
+					"pravegaservice.service.listener.port": "443",
+				},
+			}
+		})
+
+		It("should override the shared options with the options of the component", func() {
+			Ω(s.ComponentOptions(v1beta1.OptionComponentController)).Should(Equal(map[string]string{
+				"metrics.statistics.enable":         "false",
+				"controller.retention.bucket.count": "10",
+			}))
+			Ω(s.ComponentOptions(v1beta1.OptionComponentSegmentStore)).Should(Equal(map[string]string{
+				"metrics.statistics.enable":            "true",
+				"pravegaservice.container.count":       "8",
+				"pravegaservice.service.listener.port": "443",
+			}))
+		})
+
+		It("should look up an option of a component", func() {
+			value, ok := s.ControllerOption("metrics.statistics.enable")
+			Ω(ok).Should(BeTrue())
+			Ω(value).Should(Equal("false"))
+			value, ok = s.SegmentStoreOption("metrics.statistics.enable")
+			Ω(ok).Should(BeTrue())
+			Ω(value).Should(Equal("true"))
+			_, ok = s.ControllerOption("pravegaservice.container.count")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("should read the Segment Store ports from the Segment Store options", func() {
+			p := &v1beta1.PravegaCluster{Spec: v1beta1.ClusterSpec{Pravega: s}}
+			servicePort, adminPort := p.SegmentStoreBasePorts()
+			Ω(servicePort).Should(Equal(int32(443)))
+			Ω(adminPort).Should(Equal(int32(v1beta1.DefaultSegmentStoreAdminPort)))
+		})
+
+		It("should warn about the shared options read by a single component", func() {
+			s.Options["foo.bar"] = "baz"
+			Ω(s.OptionWarnings()).Should(Equal([]string{
+				"options foo.bar are unknown and passed to all the components",
+				"option controller.retention.bucket.count is only read by the controller and should be set in spec.pravega.controllerOptions",
+				"option pravegaservice.containerCount is only read by the segment store and should be set in spec.pravega.segmentStoreOptions",
+			}))
+		})
+
+		It("should not warn about the defaults of the shared options", func() {
+			s = &v1beta1.PravegaSpec{}
+			p := &v1beta1.PravegaCluster{Spec: v1beta1.ClusterSpec{Pravega: s}}
+			p.WithDefaults()
+			Ω(s.Options).Should(HaveKeyWithValue("bookkeeper.ensemble.size", "3"))
+			Ω(s.OptionWarnings()).Should(BeEmpty())
+		})
+
+		It("should keep the defaults in the Segment Store options when set there", func() {
+			s.SegmentStoreOptions["bookkeeper.ensemble.size"] = ""
+			p := &v1beta1.PravegaCluster{Spec: v1beta1.ClusterSpec{Pravega: s}}
+			p.WithDefaults()
+			Ω(s.SegmentStoreOptions).Should(HaveKeyWithValue("bookkeeper.ensemble.size", "3"))
+			Ω(s.Options).ShouldNot(HaveKey("bookkeeper.ensemble.size"))
+		})
+
+		It("should validate the options of the components", func() {
+			s.SegmentStoreOptions["bookkeeper.ensemble.size"] = "three"
+			p := &v1beta1.PravegaCluster{Spec: v1beta1.ClusterSpec{Pravega: s}}
+			Ω(p.ValidatePravegaOptions()).Should(MatchError(ContainSubstring("should be an integer")))
+		})
+
+		It("should record the immutable options of each component", func() {
+			s.SegmentStoreOptions["pravegaservice.container.count"] = "4"
+			p := &v1beta1.PravegaCluster{Spec: v1beta1.ClusterSpec{Pravega: s}}
+			settings := p.NewImmutableSettings(nil, nil)
+			Ω(settings.SegmentStoreOptions).Should(Equal(map[string]string{"pravegaservice.container.count": "4"}))
+			Ω(p.ValidateImmutableSettings(settings)).Should(BeEmpty())
+			s.SegmentStoreOptions["pravegaservice.container.count"] = "8"
+			errs := p.ValidateImmutableSettings(settings)
+			Ω(errs).Should(HaveLen(1))
+			Ω(errs[0].Field).Should(Equal("spec.pravega.segmentStoreOptions[pravegaservice.container.count]"))
+		})
+	})
+
+	Context("ImmutableSettings", func() {
+		var (
+			p                     *v1beta1.PravegaCluster
+			controllerConfigMap   *corev1.ConfigMap
+			segmentStoreConfigMap *corev1.ConfigMap
+			settings              *v1beta1.ImmutableSettings
+		)
+
+		BeforeEach(func() {
+			p = &v1beta1.PravegaCluster{
+				Spec: v1beta1.ClusterSpec{
+					Pravega: &v1beta1.PravegaSpec{
+						Options: map[string]string{
+							"controller.container.count":       "8",
+							"pravegaservice.containerCount":    "4",
+							"pravegaservice.cache.size.max":    "1024",
+							"controller.retention.bucketCount": "10",
+						},
+						LongTermStorage: &v1beta1.LongTermStorageSpec{
+							FileSystem: &v1beta1.FileSystemSpec{},
+						},
+					},
+				},
+			}
+			controllerConfigMap = &corev1.ConfigMap{
+				Data: map[string]string{
+					"JAVA_OPTS": "-Dcontroller.containerCount=8 -Dcontroller.retention.bucket.count=10",
+				},
+			}
+			segmentStoreConfigMap = &corev1.ConfigMap{
+				Data: map[string]string{
+					"JAVA_OPTS": "-Dpravegaservice.container.count=4 -Dpravegaservice.cache.size.max=512",
+				},
+			}
+			settings = p.NewImmutableSettings(nil, nil)
+		})
+
+		It("should record the immutable options under their current name", func() {
+			Ω(settings).Should(Equal(&v1beta1.ImmutableSettings{
+				ControllerOptions: map[string]string{
+					"controller.container.count":        "8",
+					"controller.retention.bucket.count": "10",
+				},
+				SegmentStoreOptions: map[string]string{
+					"pravegaservice.container.count": "4",
+				},
+				LongTermStorage: "filesystem",
+			}))
+		})
+
+		It("should read the options of a deployed cluster from its config maps", func() {
+			p.Spec.Pravega.Options["controller.container.count"] = "16"
+			Ω(p.NewImmutableSettings(controllerConfigMap, segmentStoreConfigMap)).Should(Equal(settings))
+		})
+
+		It("should accept unchanged options under any of their names", func() {
+			delete(p.Spec.Pravega.Options, "controller.container.count")
+			p.Spec.Pravega.Options["controller.containerCount"] = "8"
+			Ω(p.ValidateImmutableSettings(settings)).Should(BeEmpty())
+		})
+
+		It("should accept changes of the mutable options", func() {
+			p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "2048"
+			Ω(p.ValidateImmutableSettings(settings)).Should(BeEmpty())
+		})
+
+		It("should reject a change of an immutable option", func() {
+			p.Spec.Pravega.Options["controller.container.count"] = "16"
+			errs := p.ValidateImmutableSettings(settings)
+			Ω(errs).Should(HaveLen(1))
+			Ω(errs[0].Field).Should(Equal("spec.pravega.options[controller.container.count]"))
+			Ω(errs[0].Detail).Should(Equal("controller.container.count should not be modified"))
+		})
+
+		It("should reject an immutable option which is set or removed", func() {
+			delete(p.Spec.Pravega.Options, "pravegaservice.containerCount")
+			p.Spec.Pravega.Options["controller.watermarking.bucket.count"] = "20"
+			errs := p.ValidateImmutableSettings(settings)
+			Ω(errs).Should(HaveLen(2))
+			Ω(errs.ToAggregate().Error()).Should(ContainSubstring("controller.watermarking.bucket.count should not be modified"))
+			Ω(errs.ToAggregate().Error()).Should(ContainSubstring("pravegaservice.container.count should not be modified"))
+		})
+
+		It("should reject a change of the long term storage", func() {
+			p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{Ecs: &v1beta1.ECSSpec{}}
+			errs := p.ValidateImmutableSettings(settings)
+			Ω(errs).Should(HaveLen(1))
+			Ω(errs[0].Field).Should(Equal("spec.pravega.longtermStorage"))
+			Ω(errs[0].Detail).Should(Equal("the long term storage should not be changed from filesystem to ecs"))
+		})
+
+		It("should compare the options of the custom long term storage with the ones of the config map", func() {
+			p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{
+				Custom: &v1beta1.CustomSpec{
+					Options: map[string]string{
+						"pravegaservice.storage.layout":    "CHUNKED_STORAGE",
+						"pravegaservice.storage.impl.name": "S3",
+						"s3.bucket":                        "aws-sdk-test",
+					},
+				},
+			}
+			segmentStoreConfigMap.Data["JAVA_OPTS"] += " -Dpravegaservice.storage.impl.name=S3 -Dpravegaservice.storage.layout=CHUNKED_STORAGE -Ds3.bucket=aws-sdk-test"
+			settings = p.NewImmutableSettings(nil, segmentStoreConfigMap)
+			Ω(settings.SegmentStoreOptions).Should(HaveKeyWithValue("pravegaservice.storage.impl.name", "S3"))
+			Ω(p.NewImmutableSettings(nil, nil).SegmentStoreOptions).Should(Equal(settings.SegmentStoreOptions))
+
+			p.Spec.Pravega.SegmentStoreReplicas = 5
+			Ω(p.ValidateImmutableSettings(settings)).Should(BeEmpty())
+
+			p.Spec.Pravega.LongTermStorage.Custom.Options["pravegaservice.storage.layout"] = "ROLLING_STORAGE"
+			errs := p.ValidateImmutableSettings(settings)
+			Ω(errs).Should(HaveLen(1))
+			Ω(errs[0].Field).Should(Equal("spec.pravega.longtermStorage.custom.options[pravegaservice.storage.layout]"))
+		})
+
+		It("should skip the check when no settings were recorded", func() {
+			p.Spec.Pravega.Options["controller.container.count"] = "16"
+			Ω(p.ValidateImmutableSettings(nil)).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,83 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	"testing"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FuzzValidateSpec checks that the webhook validation never panics on user input and reports
+// every error with a field path. Run with: go test ./api/v1beta1 -run '^$' -fuzz FuzzValidateSpec
+func FuzzValidateSpec(f *testing.F) {
+	f.Add("3", "-Xmx1g", "heap-dump=/tmp/dumpfile/heap", int64(4<<30), false)
+	f.Add("three", "-Xmx1gb", "heap-dump", int64(4e9), true)
+	f.Add("", "-Xmx", "=", int64(0), true)
+	f.Add("-1", "-XX:MaxDirectMemorySize=", ":=,,", int64(1), true)
+	f.Add("9223372036854775807", "-Xmx99999999999999999999t", "prvg:logback=", int64(1<<60), true)
+	f.Add("1e-99999999", "-Xmx9223372036854775807k", "a:b=c,,=d", int64(-4<<30), false)
+	f.Fuzz(func(t *testing.T, option string, jvmOption string, volumeMounts string, memoryLimit int64, autoSize bool) {
+		p := &v1beta1.PravegaCluster{ObjectMeta: metav1.ObjectMeta{Name: "fuzz"}}
+		p.WithDefaults()
+		p.Spec.Version = option
+		for _, name := range []string{"bookkeeper.ensemble.size", "bookkeeper.write.quorum.racks.minimumCount.enable", "pravegaservice.cache.size.max", "pravegaservice.storage.layout", "unknown.option"} {
+			p.Spec.Pravega.Options[name] = option
+		}
+		p.Spec.Pravega.ControllerOptions = map[string]string{
+			"hostPathVolumeMounts":  volumeMounts,
+			"configMapVolumeMounts": volumeMounts,
+		}
+		p.Spec.Pravega.SegmentStoreOptions = map[string]string{
+			"emptyDirVolumeMounts":           volumeMounts,
+			"pravegaservice.cacheMaxSize":    option,
+			"bookkeeper.ack.quorum.size":     option,
+			"pravegaservice.container.count": option,
+		}
+		p.Spec.Pravega.SegmentStoreJVMOptions = []string{jvmOption, "-XX:MaxDirectMemorySize=" + option}
+		p.Spec.Pravega.SegmentStoreMemory = &v1beta1.SegmentStoreMemorySpec{AutoSize: autoSize}
+		p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: *resource.NewQuantity(memoryLimit, resource.BinarySI),
+			},
+		}
+
+		for _, err := range p.ValidateSpec() {
+			if err.Field == "" {
+				t.Errorf("error without a field path: %v", err)
+			}
+		}
+		_ = p.SegmentStoreMemoryJVMOptions()
+		_ = p.SegmentStoreMemoryStatus()
+	})
+}
+
+// FuzzParseVolumeMounts checks that the volume mount options never panic and only return
+// complete volume mounts
+func FuzzParseVolumeMounts(f *testing.F) {
+	f.Add("heap-dump=/tmp/dumpfile/heap,log=/opt/pravega/logs")
+	f.Add("prvg-logback:logback.xml=/opt/pravega/conf/logback.xml")
+	f.Add(",=,:,:=,a:=b,=b,a=")
+	f.Fuzz(func(t *testing.T, value string) {
+		for _, option := range []string{"hostPathVolumeMounts", "emptyDirVolumeMounts", "configMapVolumeMounts"} {
+			mounts, _ := v1beta1.ParseVolumeMounts(option, value)
+			for _, mount := range mounts {
+				if mount.Name == "" || mount.MountPath == "" || (option == "configMapVolumeMounts" && mount.Key == "") {
+					t.Errorf("incomplete volume mount %+v for option %s", mount, option)
+				}
+			}
+		}
+	})
+}
@@ -14,20 +14,19 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pravega/pravega-operator/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-var Mgr manager.Manager
-
 const (
 	// DefaultZookeeperUri is the default ZooKeeper URI in the form of "hostname:port"
 	DefaultZookeeperUri = "zookeeper-client:2181"
@@ -44,6 +43,35 @@ const (
 
 	// OperatorNameEnvVar is env variable for operator name
 	OperatorNameEnvVar = "OPERATOR_NAME"
+
+	// DryRunAnnotationKey is the annotation which, when set to "true", makes the operator
+	// report the changes it would make to the cluster in the status instead of applying them
+	DryRunAnnotationKey = "pravega.pravega.io/dry-run"
+
+	// PasswordAuthChecksumAnnotationKey is the Controller pod annotation holding the checksum
+	// of the password file managed by the operator, so that the pods restart when it changes
+	PasswordAuthChecksumAnnotationKey = "pravega.pravega.io/password-auth-checksum"
+
+	// RestartedAtAnnotationKey is the pod template annotation set by the operator to restart
+	// the pods, e.g. when their configuration changed
+	RestartedAtAnnotationKey = "pravega.pravega.io/restarted-at"
+
+	// ForceDeleteAnnotationKey is the annotation which, when set to "true" on a deleted
+	// cluster, removes its finalizer without cleaning up its data once the cleanup failed
+	// ForceDeleteAfterFailures times. It also allows the deletion of a cluster being upgraded
+	// or rolled back
+	ForceDeleteAnnotationKey = "pravega.pravega.io/force-delete"
+
+	// ForceDeleteAfterFailures is the number of failed cleanups after which the
+	// force-delete annotation is honoured
+	ForceDeleteAfterFailures = 3
+
+	// DeletionProtectionAnnotationKey is the annotation which, when set to "true", makes the
+	// webhook reject the deletion of the cluster until it is removed
+	DeletionProtectionAnnotationKey = "pravega.pravega.io/deletion-protection"
+
+	// maxPort is the highest port that can be assigned to a service
+	maxPort int32 = 65535
 )
 
 func init() {
@@ -95,6 +123,17 @@ type ClusterSpec struct {
 	// +optional
 	ZookeeperUri string `json:"zookeeperUri"`
 
+	// ZookeeperRef refers to a ZookeeperCluster of the Zookeeper operator. When set,
+	// the connection string read from the ZookeeperCluster is used in place of
+	// zookeeperUri and the cluster is only deployed once the ZookeeperCluster is ready
+	// +optional
+	ZookeeperRef *ClusterReference `json:"zookeeperRef,omitempty"`
+
+	// Zookeeper configures the connection to a ZooKeeper ensemble requiring TLS,
+	// authentication or a chroot. Its hosts take precedence over zookeeperUri
+	// +optional
+	Zookeeper *ZookeeperSpec `json:"zookeeper,omitempty"`
+
 	// ExternalAccess specifies whether or not to allow external access
 	// to clients and the service type to use to achieve it
 	// By default, external access is not enabled
@@ -132,16 +171,76 @@ type ClusterSpec struct {
 	// +optional
 	BookkeeperUri string `json:"bookkeeperUri"`
 
+	// BookkeeperRef refers to a BookkeeperCluster of the Bookkeeper operator. When set,
+	// the connection string read from the BookkeeperCluster is used in place of
+	// bookkeeperUri and the cluster is only deployed once the BookkeeperCluster is ready
+	// +optional
+	BookkeeperRef *ClusterReference `json:"bookkeeperRef,omitempty"`
+
+	// UpgradeAfterDependencies holds back the upgrades of the cluster while the
+	// ZookeeperCluster or the BookkeeperCluster it refers to is being upgraded or is not
+	// ready, so that ZooKeeper, BookKeeper and Pravega are upgraded in this order
+	// +optional
+	UpgradeAfterDependencies bool `json:"upgradeAfterDependencies,omitempty"`
+
 	// Reserved ports
 	ReservedPortList []int32 `json:"reservedPortList,omitempty"`
 
 	// Pravega configuration
 	// +optional
 	Pravega *PravegaSpec `json:"pravega"`
+
+	// RestoreFrom restores the ZooKeeper metadata of a PravegaMetadataBackup before the
+	// cluster starts. It only applies to a cluster which was never deployed
+	// +optional
+	RestoreFrom *MetadataRestoreSpec `json:"restoreFrom,omitempty"`
+
+	// DeletionPolicy is what happens to the data of the cluster when it is deleted:
+	// "Delete" removes the ZooKeeper metadata, the cache volumes and the long term storage data,
+	// "Retain" keeps all of them, and "Snapshot" backs up the ZooKeeper metadata to
+	// deletionSnapshot, then removes the metadata and the cache volumes.
+	// When unset, only the ZooKeeper metadata are removed
+	// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DeletionSnapshot is where the "Snapshot" deletion policy stores the metadata backup
+	// +optional
+	DeletionSnapshot *MetadataBackupStorage `json:"deletionSnapshot,omitempty"`
+}
+
+// ClusterReference refers to a custom resource managed by another operator
+type ClusterReference struct {
+	// Name of the custom resource
+	Name string `json:"name"`
+
+	// Namespace of the custom resource, the namespace of the Pravega cluster when empty
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamespacedName returns the name of the referred custom resource, looked up in the given
+// namespace when the reference has none
+func (r *ClusterReference) NamespacedName(namespace string) types.NamespacedName {
+	if r.Namespace != "" {
+		namespace = r.Namespace
+	}
+	return types.NamespacedName{Name: r.Name, Namespace: namespace}
 }
 
+// DeletionPolicy is what happens to the data of a cluster when it is deleted
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete   DeletionPolicy = "Delete"
+	DeletionPolicyRetain   DeletionPolicy = "Retain"
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
 func (s *ClusterSpec) withDefaults(p *PravegaCluster) (changed bool) {
-	if s.ZookeeperUri == "" {
+	// With a reference, the connection string is read from the referred cluster by the
+	// operator and the spec is kept as it was written
+	if s.ZookeeperRef == nil && s.ZookeeperUri == "" {
 		changed = true
 		s.ZookeeperUri = DefaultZookeeperUri
 	}
@@ -172,7 +271,7 @@ func (s *ClusterSpec) withDefaults(p *PravegaCluster) (changed bool) {
 		changed = true
 	}
 
-	if s.BookkeeperUri == "" {
+	if s.BookkeeperRef == nil && s.BookkeeperUri == "" {
 		s.BookkeeperUri = DefaultBookkeeperUri
 		changed = true
 	}
@@ -226,6 +325,81 @@ type ExternalAccess struct {
 	// Domain Name to be used for External Access
 	// This value is ignored if External Access is disabled
 	DomainName string `json:"domainName,omitempty"`
+
+	// ControllerRoute exposes the Controller through an existing Gateway API gateway
+	// or Ingress controller instead of a dedicated load balancer.
+	// This value is ignored if External Access is disabled
+	// +optional
+	ControllerRoute *ControllerRouteSpec `json:"controllerRoute,omitempty"`
+
+	// ResolveAddresses makes the operator resolve the external address and port of
+	// each Segment Store service and publish them on the Segment Store pod, instead of
+	// the Segment Store looking them up from the Kubernetes API on start up.
+	// This value is ignored if External Access is disabled
+	// +optional
+	ResolveAddresses bool `json:"resolveAddresses,omitempty"`
+}
+
+// ControllerRouteType is the kind of objects generated to route external traffic
+// to the Controller
+type ControllerRouteType string
+
+const (
+	// ControllerRouteGatewayAPI generates Gateway API routes attached to an existing gateway
+	ControllerRouteGatewayAPI ControllerRouteType = "GatewayAPI"
+
+	// ControllerRouteIngress generates Ingress objects
+	ControllerRouteIngress ControllerRouteType = "Ingress"
+)
+
+type ControllerRouteSpec struct {
+	// Type is the kind of objects generated to route traffic to the Controller.
+	// Options are "GatewayAPI" and "Ingress"
+	// +kubebuilder:validation:Enum=GatewayAPI;Ingress
+	Type ControllerRouteType `json:"type"`
+
+	// GrpcHostname is the host name on which the Controller gRPC endpoint (port 9090)
+	// is exposed
+	GrpcHostname string `json:"grpcHostname"`
+
+	// RestHostname is the host name on which the Controller REST endpoint (port 10080)
+	// is exposed. The REST endpoint is not exposed if it is empty
+	// +optional
+	RestHostname string `json:"restHostname,omitempty"`
+
+	// GatewayName is the name of the gateway the routes are attached to.
+	// It is required when the type is "GatewayAPI"
+	// +optional
+	GatewayName string `json:"gatewayName,omitempty"`
+
+	// GatewayNamespace is the namespace of the gateway the routes are attached to.
+	// Defaults to the namespace of the Pravega cluster
+	// +optional
+	GatewayNamespace string `json:"gatewayNamespace,omitempty"`
+
+	// GatewaySectionName is the name of the gateway listener the routes are attached to.
+	// When Controller TLS is enabled, the listener must be in TLS "Passthrough" mode
+	// +optional
+	GatewaySectionName string `json:"gatewaySectionName,omitempty"`
+
+	// IngressClassName is the ingress class of the generated Ingress objects
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// Annotations to be added to the generated routes or Ingress objects
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IsAddressResolutionEnabled returns whether the operator publishes the external
+// address of each Segment Store
+func (e *ExternalAccess) IsAddressResolutionEnabled() bool {
+	return e != nil && e.Enabled && e.ResolveAddresses
+}
+
+// IsControllerRouteEnabled returns whether the Controller is exposed through routes
+func (e *ExternalAccess) IsControllerRouteEnabled() bool {
+	return e != nil && e.Enabled && e.ControllerRoute != nil
 }
 
 func (e *ExternalAccess) withDefaults() (changed bool) {
@@ -293,6 +467,79 @@ func (ap *AuthenticationParameters) IsEnabled() bool {
 	return ap.Enabled
 }
 
+// ZookeeperSpec is the connection of the operator and of the Pravega processes to ZooKeeper
+type ZookeeperSpec struct {
+	// Hosts are the "hostname:port" of the servers of the ensemble
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+
+	// Chroot is the znode under which the metadata of Pravega are stored, for example "/pravega-prod".
+	// It must exist in ZooKeeper
+	// +optional
+	Chroot string `json:"chroot,omitempty"`
+
+	// TLSSecret is the name of a Secret holding the PEM encoded CA certificate of the
+	// ZooKeeper servers under the key "ca.crt". When set, the connections use TLS
+	// +optional
+	TLSSecret string `json:"tlsSecret,omitempty"`
+
+	// AuthSecret is the name of a Secret holding the "username" and "password" of the
+	// SASL digest authentication to ZooKeeper
+	// +optional
+	AuthSecret string `json:"authSecret,omitempty"`
+}
+
+func (z *ZookeeperSpec) IsTLSEnabled() bool {
+	return z != nil && z.TLSSecret != ""
+}
+
+func (z *ZookeeperSpec) IsAuthEnabled() bool {
+	return z != nil && z.AuthSecret != ""
+}
+
+// ZookeeperUri returns the connection string of the ZooKeeper ensemble, read from the
+// ZookeeperCluster of spec.zookeeperRef when set, or else spec.zookeeperUri
+func (p *PravegaCluster) ZookeeperUri() string {
+	if p.Spec.ZookeeperRef != nil {
+		if uri := p.Status.Zookeeper.connectionURI(); uri != "" {
+			return uri
+		}
+	}
+	return p.Spec.ZookeeperUri
+}
+
+// ZookeeperServers returns the servers of the ZooKeeper ensemble
+func (p *PravegaCluster) ZookeeperServers() []string {
+	if z := p.Spec.Zookeeper; z != nil && len(z.Hosts) > 0 {
+		return z.Hosts
+	}
+	return util.ParseZookeeperUri(p.ZookeeperUri()).Servers
+}
+
+// ZookeeperChroot returns the chroot of the metadata of Pravega, empty when at the root
+func (p *PravegaCluster) ZookeeperChroot() string {
+	if z := p.Spec.Zookeeper; z != nil && z.Chroot != "" {
+		return z.Chroot
+	}
+	return util.ParseZookeeperUri(p.ZookeeperUri()).Chroot
+}
+
+// ZookeeperConnectString returns the connection string passed to the Pravega processes
+func (p *PravegaCluster) ZookeeperConnectString() string {
+	return strings.Join(p.ZookeeperServers(), ",") + p.ZookeeperChroot()
+}
+
+// BookkeeperUri returns the comma separated list of the bookies, read from the
+// BookkeeperCluster of spec.bookkeeperRef when set, or else spec.bookkeeperUri
+func (p *PravegaCluster) BookkeeperUri() string {
+	if p.Spec.BookkeeperRef != nil {
+		if uri := p.Status.Bookkeeper.connectionURI(); uri != "" {
+			return uri
+		}
+	}
+	return p.Spec.BookkeeperUri
+}
+
 // ImageSpec defines the fields needed for a Docker repository image
 type ImageSpec struct {
 	// +optional
@@ -301,6 +548,23 @@ type ImageSpec struct {
 	PullPolicy corev1.PullPolicy `json:"pullPolicy"`
 }
 
+// IsDryRun returns whether changes to the cluster should only be planned and not applied
+func (p *PravegaCluster) IsDryRun() bool {
+	return p.GetAnnotations()[DryRunAnnotationKey] == "true"
+}
+
+// IsForceDeleteAllowed returns whether the cleanup of the data of the deleted cluster
+// can be skipped
+func (p *PravegaCluster) IsForceDeleteAllowed() bool {
+	return p.GetAnnotations()[ForceDeleteAnnotationKey] == "true" &&
+		p.Status.Cleanup != nil && p.Status.Cleanup.Failures >= ForceDeleteAfterFailures
+}
+
+// IsDeletionProtected returns whether the deletion of the cluster is rejected
+func (p *PravegaCluster) IsDeletionProtected() bool {
+	return p.GetAnnotations()[DeletionProtectionAnnotationKey] == "true"
+}
+
 func (p *PravegaCluster) PdbNameForController() string {
 	return fmt.Sprintf("%s-pravega-controller", p.Name)
 }
@@ -331,6 +595,16 @@ func (p *PravegaCluster) PravegaControllerServiceURL() string {
 	return fmt.Sprintf("tcp://%v.%v:%v", p.ServiceNameForController(), p.Namespace, "9090")
 }
 
+// PravegaControllerRestURL returns the URL of the REST API of the Controller, served
+// over https when Controller TLS is enabled
+func (p *PravegaCluster) PravegaControllerRestURL() string {
+	scheme := "http"
+	if p.Spec.TLS.IsSecureController() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%v://%v.%v:%v", scheme, p.ServiceNameForController(), p.Namespace, "10080")
+}
+
 func (p *PravegaCluster) LabelsForController() map[string]string {
 	labels := p.LabelsForPravegaCluster()
 	if p.Spec.Pravega != nil && p.Spec.Pravega.ControllerPodLabels != nil {
@@ -360,6 +634,9 @@ func (p *PravegaCluster) AnnotationsForController() map[string]string {
 			annotations[k] = v
 		}
 	}
+	if p.Status.PasswordAuthChecksum != "" {
+		annotations[PasswordAuthChecksumAnnotationKey] = p.Status.PasswordAuthChecksum
+	}
 	return annotations
 }
 
@@ -407,6 +684,24 @@ func (p *PravegaCluster) HeadlessServiceNameForBookie() string {
 	return fmt.Sprintf("%s-bookie-headless", p.Name)
 }
 
+func (p *PravegaCluster) RouteNameForController(endpoint string) string {
+	return fmt.Sprintf("%s-%s", p.ServiceNameForController(), endpoint)
+}
+
+// DeletionSnapshotName returns the name of the PravegaMetadataBackup taken by the "Snapshot"
+// deletion policy. It is unique to this incarnation of the cluster
+func (p *PravegaCluster) DeletionSnapshotName() string {
+	uid := string(p.UID)
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	return fmt.Sprintf("%s-deletion-%s", p.Name, uid)
+}
+
+func (p *PravegaCluster) SecretNameForZookeeperJaas() string {
+	return fmt.Sprintf("%s-zookeeper-jaas", p.Name)
+}
+
 func (p *PravegaCluster) DeploymentNameForController() string {
 	return fmt.Sprintf("%s-pravega-controller", p.Name)
 }
@@ -434,31 +729,88 @@ func (p *PravegaCluster) PravegaTargetImage() (string, error) {
 	return fmt.Sprintf("%s:%s", p.Spec.Pravega.Image.Repository, p.Status.TargetVersion), nil
 }
 
-// Wait for pods in cluster to be terminated
-func (p *PravegaCluster) WaitForClusterToTerminate(kubeClient client.Client) (err error) {
-	listOptions := &client.ListOptions{
-		LabelSelector: labels.SelectorFromSet(p.LabelsForPravegaCluster()),
+// SegmentStoreBasePorts returns the Segment Store service and admin ports configured
+// in the Pravega options, falling back to the defaults when unset
+func (p *PravegaCluster) SegmentStoreBasePorts() (servicePort int32, adminPort int32) {
+	servicePort = DefaultSegmentStoreServicePort
+	adminPort = DefaultSegmentStoreAdminPort
+	options := p.Spec.Pravega.ComponentOptions(OptionComponentSegmentStore)
+	if value, ok := FindOption(options, "pravegaservice.service.listener.port"); ok {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 {
+			servicePort = int32(port)
+		}
 	}
-	err = wait.Poll(5*time.Second, 2*time.Minute, func() (done bool, err error) {
-		podList := &corev1.PodList{}
-		err = kubeClient.List(context.TODO(), podList, listOptions)
-		if err != nil {
-			return false, err
+	if value, ok := FindOption(options, "pravegaservice.admin.listener.port"); ok {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 {
+			adminPort = int32(port)
 		}
+	}
+	return servicePort, adminPort
+}
+
+// AllocateSegmentStorePorts returns the port allocation table for the external services
+// of the Segment Stores sharing the segmentStoreLoadBalancerIP. Existing allocations
+// recorded in the status are preserved, including those of ordinals that have been
+// scaled down, and new ordinals get the lowest free ports at or above the configured
+// base ports, skipping the reserved port list
+func (p *PravegaCluster) AllocateSegmentStorePorts() ([]SegmentStorePortAllocation, error) {
+	used := make(map[int32]bool)
+	for _, port := range p.Spec.ReservedPortList {
+		used[port] = true
+	}
+	var allocations []SegmentStorePortAllocation
+	for _, allocation := range p.Status.SegmentStorePortAllocations {
+		allocations = append(allocations, allocation)
+		used[allocation.ServicePort] = true
+		used[allocation.AdminPort] = true
+	}
 
-		var names []string
-		for i := range podList.Items {
-			pod := &podList.Items[i]
-			names = append(names, pod.Name)
+	nextServicePort, nextAdminPort := p.SegmentStoreBasePorts()
+	nextFreePort := func(port int32) (int32, error) {
+		for ; port <= maxPort; port++ {
+			if !used[port] {
+				used[port] = true
+				return port, nil
+			}
 		}
-
-		if len(names) != 0 {
-			return false, nil
+		return 0, fmt.Errorf("no free port left to allocate to the segment store external services")
+	}
+	for i := int32(0); i < p.Spec.Pravega.SegmentStoreReplicas; i++ {
+		if p.Status.GetSegmentStorePortAllocation(i) != nil {
+			continue
+		}
+		servicePort, err := nextFreePort(nextServicePort)
+		if err != nil {
+			return nil, err
 		}
-		return true, nil
+		adminPort, err := nextFreePort(nextAdminPort)
+		if err != nil {
+			return nil, err
+		}
+		nextServicePort, nextAdminPort = servicePort+1, adminPort+1
+		allocations = append(allocations, SegmentStorePortAllocation{
+			Ordinal:     i,
+			ServicePort: servicePort,
+			AdminPort:   adminPort,
+		})
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Ordinal < allocations[j].Ordinal
 	})
+	return allocations, nil
+}
 
-	return err
+// IsClusterTerminated returns whether all the pods of the cluster are gone
+func (p *PravegaCluster) IsClusterTerminated(kubeClient client.Client) (bool, error) {
+	podList := &corev1.PodList{}
+	err := kubeClient.List(context.TODO(), podList, &client.ListOptions{
+		Namespace:     p.Namespace,
+		LabelSelector: labels.SelectorFromSet(p.LabelsForPravegaCluster()),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(podList.Items) == 0, nil
 }
 
 func (p *PravegaCluster) NewEvent(name string, reason string, message string, eventType string) *corev1.Event {
@@ -19,8 +19,10 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/pravega/pravega-operator/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -100,6 +102,52 @@ var _ = Describe("PravegaCluster Types Spec", func() {
 		})
 	})
 
+	Context("WithDefaults and zone awareness", func() {
+		It("should enable the rack aware write quorum", func() {
+			p.Spec.Pravega = &v1beta1.PravegaSpec{SegmentStoreZoneAware: true}
+			p.WithDefaults()
+			Ω(p.Spec.Pravega.Options["bookkeeper.write.quorum.racks.minimumCount.enable"]).Should(Equal("true"))
+		})
+
+		It("should keep the rack aware write quorum set by the user", func() {
+			p.Spec.Pravega = &v1beta1.PravegaSpec{
+				SegmentStoreZoneAware: true,
+				Options: map[string]string{
+					"bookkeeper.write.quorum.racks.minimumCount.enable": "false",
+				},
+			}
+			p.WithDefaults()
+			Ω(p.Spec.Pravega.Options["bookkeeper.write.quorum.racks.minimumCount.enable"]).Should(Equal("false"))
+		})
+
+		It("should not enable the rack aware write quorum for a single bookie ensemble", func() {
+			p.Spec.Pravega = &v1beta1.PravegaSpec{
+				SegmentStoreZoneAware: true,
+				Options: map[string]string{
+					"bookkeeper.ensemble.size": "1",
+				},
+			}
+			p.WithDefaults()
+			Ω(p.Spec.Pravega.Options["bookkeeper.write.quorum.racks.minimumCount.enable"]).Should(Equal(""))
+		})
+	})
+
+	Context("Default", func() {
+		It("should apply the defaults through the mutating webhook", func() {
+			p.Default()
+			Ω(p.Spec.Version).Should(Equal(v1beta1.DefaultPravegaVersion))
+			Ω(p.Spec.ZookeeperUri).Should(Equal(v1beta1.DefaultZookeeperUri))
+			Ω(p.Spec.Pravega).ShouldNot(BeNil())
+			Ω(p.WithDefaults()).Should(BeFalse())
+		})
+
+		It("should keep the settings of the user", func() {
+			p.Spec.Version = "0.10.0"
+			p.Default()
+			Ω(p.Spec.Version).Should(Equal("0.10.0"))
+		})
+	})
+
 	Context("ValidatePravegaVersion", func() {
 		var (
 			p     *v1beta1.PravegaCluster
@@ -442,11 +490,12 @@ var _ = Describe("PravegaCluster Types Spec", func() {
 		})
 	})
 
-	Context("WaitForClusterToTerminate", func() {
+	Context("IsClusterTerminated", func() {
 		var (
-			client client.Client
-			err    error
-			p1     *v1beta1.PravegaCluster
+			client     client.Client
+			err        error
+			terminated bool
+			p1         *v1beta1.PravegaCluster
 		)
 
 		BeforeEach(func() {
@@ -459,11 +508,14 @@ var _ = Describe("PravegaCluster Types Spec", func() {
 			s := scheme.Scheme
 			s.AddKnownTypes(v1beta1.GroupVersion, p1)
 			client = fake.NewFakeClient(p1)
-			err = p1.WaitForClusterToTerminate(client)
+			terminated, err = p1.IsClusterTerminated(client)
 		})
 		It("should  be nil", func() {
 			Ω(err).Should(BeNil())
 		})
+		It("should be terminated without pods", func() {
+			Ω(terminated).Should(BeTrue())
+		})
 	})
 
 	Context("Validate Segment Store Memory Settings", func() {
@@ -1247,4 +1299,451 @@ var _ = Describe("PravegaCluster Types Spec", func() {
 			})
 		})
 	})
+
+	Context("Segment Store port allocations", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Spec.Pravega.SegmentStoreLoadBalancerIP = "10.240.12.18"
+			p.Spec.Pravega.SegmentStoreReplicas = 3
+			p.Spec.ReservedPortList = []int32{12346, 10000}
+		})
+
+		It("should allocate the lowest free ports skipping reserved ones", func() {
+			allocations, err := p.AllocateSegmentStorePorts()
+			Ω(err).Should(BeNil())
+			Ω(allocations).Should(Equal([]v1beta1.SegmentStorePortAllocation{
+				{Ordinal: 0, ServicePort: 12345, AdminPort: 9999},
+				{Ordinal: 1, ServicePort: 12347, AdminPort: 10001},
+				{Ordinal: 2, ServicePort: 12348, AdminPort: 10002},
+			}))
+			Ω(p.ValidateSegmentStorePortAllocations()).Should(BeNil())
+		})
+
+		It("should keep existing allocations when scaling down and up", func() {
+			p.Status.SegmentStorePortAllocations = []v1beta1.SegmentStorePortAllocation{
+				{Ordinal: 0, ServicePort: 12345, AdminPort: 9999},
+				{Ordinal: 2, ServicePort: 12400, AdminPort: 10400},
+			}
+			p.Spec.Pravega.SegmentStoreReplicas = 1
+			allocations, err := p.AllocateSegmentStorePorts()
+			Ω(err).Should(BeNil())
+			Ω(allocations).Should(Equal(p.Status.SegmentStorePortAllocations))
+
+			p.Spec.Pravega.SegmentStoreReplicas = 4
+			allocations, err = p.AllocateSegmentStorePorts()
+			Ω(err).Should(BeNil())
+			Ω(allocations).Should(Equal([]v1beta1.SegmentStorePortAllocation{
+				{Ordinal: 0, ServicePort: 12345, AdminPort: 9999},
+				{Ordinal: 1, ServicePort: 12347, AdminPort: 10001},
+				{Ordinal: 2, ServicePort: 12400, AdminPort: 10400},
+				{Ordinal: 3, ServicePort: 12348, AdminPort: 10002},
+			}))
+		})
+
+		It("should reject reserving an allocated port", func() {
+			p.Status.SegmentStorePortAllocations = []v1beta1.SegmentStorePortAllocation{
+				{Ordinal: 0, ServicePort: 12345, AdminPort: 9999},
+			}
+			p.Spec.ReservedPortList = []int32{12345}
+			err := p.ValidateSegmentStorePortAllocations()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("cannot be added to spec.reservedPortList"))
+		})
+
+		It("should reject colliding allocations", func() {
+			p.Status.SegmentStorePortAllocations = []v1beta1.SegmentStorePortAllocation{
+				{Ordinal: 0, ServicePort: 12345, AdminPort: 9999},
+				{Ordinal: 1, ServicePort: 12345, AdminPort: 10001},
+			}
+			err := p.ValidateSegmentStorePortAllocations()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("is allocated to both segment store 0 and segment store 1"))
+		})
+
+		It("should reject when ports are exhausted", func() {
+			p.Spec.Pravega.Options["pravegaservice.service.listener.port"] = "65534"
+			err := p.ValidateSegmentStorePortAllocations()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("no free port left"))
+		})
+
+		It("should skip the checks without a load balancer IP", func() {
+			p.Spec.Pravega.SegmentStoreLoadBalancerIP = ""
+			p.Spec.Pravega.Options["pravegaservice.service.listener.port"] = "65534"
+			Ω(p.ValidateSegmentStorePortAllocations()).Should(BeNil())
+		})
+	})
+
+	Context("Validate Controller Route", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Spec.ExternalAccess.Enabled = true
+			p.Spec.ExternalAccess.ControllerRoute = &v1beta1.ControllerRouteSpec{
+				Type:         v1beta1.ControllerRouteGatewayAPI,
+				GrpcHostname: "grpc.pravega.example.com",
+				GatewayName:  "shared-gateway",
+			}
+		})
+
+		It("should accept a gateway route", func() {
+			Ω(p.ValidateControllerRoute()).Should(BeNil())
+		})
+
+		It("should require the gateway name", func() {
+			p.Spec.ExternalAccess.ControllerRoute.GatewayName = ""
+			err := p.ValidateControllerRoute()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("gatewayName cannot be empty"))
+		})
+
+		It("should require the grpc hostname", func() {
+			p.Spec.ExternalAccess.ControllerRoute.Type = v1beta1.ControllerRouteIngress
+			p.Spec.ExternalAccess.ControllerRoute.GrpcHostname = ""
+			err := p.ValidateControllerRoute()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("grpcHostname cannot be empty"))
+		})
+
+		It("should reject the same hostname for grpc and rest", func() {
+			p.Spec.ExternalAccess.ControllerRoute.RestHostname = "grpc.pravega.example.com"
+			Ω(p.ValidateControllerRoute()).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Dry run", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Spec.Pravega.SegmentStoreResources = nil
+		})
+
+		It("should be rejected without the dry run annotation", func() {
+			Ω(p.IsDryRun()).Should(BeFalse())
+			Ω(p.ValidateCreate()).ShouldNot(BeNil())
+		})
+
+		It("should still be rejected with the dry run annotation", func() {
+			p.Annotations = map[string]string{v1beta1.DryRunAnnotationKey: "true"}
+			Ω(p.IsDryRun()).Should(BeTrue())
+			Ω(p.ValidateCreate()).ShouldNot(BeNil())
+		})
+
+		Context("Update", func() {
+			var old *v1beta1.PravegaCluster
+
+			BeforeEach(func() {
+				p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "1610612736"
+				p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1g", "-XX:MaxDirectMemorySize=2560m"}
+				p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2000m"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+				}
+				p.Status.Init()
+				old = p.DeepCopy()
+				p.Annotations = map[string]string{v1beta1.DryRunAnnotationKey: "true"}
+			})
+
+			It("should accept starting a dry run", func() {
+				Ω(p.ValidateUpdate(old)).Should(BeNil())
+			})
+
+			It("should reject the immutable settings changed with the dry run annotation", func() {
+				old.Status.ImmutableSettings = old.NewImmutableSettings(nil, nil)
+				p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.container.count": "8"}
+				err := p.ValidateUpdate(old)
+				Ω(err).ShouldNot(BeNil())
+				Ω(err.Error()).Should(ContainSubstring("pravegaservice.container.count should not be modified"))
+			})
+
+			It("should reject starting a dry run while the cluster is upgraded", func() {
+				old.Status.TargetVersion = "0.10.0"
+				old.Status.SetUpgradingConditionTrue("", "")
+				err := p.ValidateUpdate(old)
+				Ω(err).ShouldNot(BeNil())
+				Ω(err.Error()).Should(ContainSubstring("being upgraded to version 0.10.0"))
+				old.Annotations = p.Annotations
+				Ω(p.ValidateUpdate(old)).Should(BeNil())
+			})
+		})
+	})
+
+	Context("Deletion", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Status.Init()
+		})
+
+		It("should accept the deletion of a cluster", func() {
+			Ω(p.ValidateDelete()).Should(BeNil())
+		})
+
+		It("should reject the deletion of a protected cluster until the annotation is removed", func() {
+			p.Annotations = map[string]string{
+				v1beta1.DeletionProtectionAnnotationKey: "true",
+				v1beta1.ForceDeleteAnnotationKey:        "true",
+			}
+			err := p.ValidateDelete()
+			Ω(apierrors.IsForbidden(err)).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("protected by the annotation pravega.pravega.io/deletion-protection"))
+			p.Annotations[v1beta1.DeletionProtectionAnnotationKey] = "false"
+			Ω(p.ValidateDelete()).Should(BeNil())
+		})
+
+		It("should reject the deletion of a cluster being upgraded unless forced", func() {
+			p.Status.TargetVersion = "0.10.0"
+			p.Status.SetUpgradingConditionTrue("", "")
+			err := p.ValidateDelete()
+			Ω(apierrors.IsForbidden(err)).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("being upgraded to version 0.10.0"))
+			p.Annotations = map[string]string{v1beta1.ForceDeleteAnnotationKey: "true"}
+			Ω(p.ValidateDelete()).Should(BeNil())
+		})
+
+		It("should reject the deletion of a cluster being rolled back unless forced", func() {
+			p.Status.TargetVersion = "0.9.0"
+			p.Status.SetRollbackConditionTrue("", "")
+			err := p.ValidateDelete()
+			Ω(apierrors.IsForbidden(err)).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("being rolled back to version 0.9.0"))
+			p.Annotations = map[string]string{v1beta1.ForceDeleteAnnotationKey: "true"}
+			Ω(p.ValidateDelete()).Should(BeNil())
+		})
+	})
+
+	Context("ZooKeeper and BookKeeper references", func() {
+		BeforeEach(func() {
+			p.Spec.ZookeeperRef = &v1beta1.ClusterReference{Name: "zookeeper", Namespace: "zk"}
+			p.Spec.BookkeeperRef = &v1beta1.ClusterReference{Name: "bookkeeper"}
+		})
+
+		It("should not default the connection strings before they are read", func() {
+			p.WithDefaults()
+			Ω(p.Spec.ZookeeperUri).Should(BeEmpty())
+			Ω(p.Spec.BookkeeperUri).Should(BeEmpty())
+		})
+
+		It("should use the connection strings read from the referred clusters", func() {
+			p.Spec.ZookeeperUri = "zookeeper-client:2181"
+			p.Spec.Zookeeper = &v1beta1.ZookeeperSpec{Chroot: "/pravega"}
+			p.Status.Zookeeper = &v1beta1.DependencyStatus{URI: "zookeeper-client.zk.svc.cluster.local:2181"}
+			p.Status.Bookkeeper = &v1beta1.DependencyStatus{URI: "bookkeeper-bookie-0.bookkeeper-bookie-headless.default.svc.cluster.local:3181"}
+			Ω(p.ZookeeperServers()).Should(Equal([]string{"zookeeper-client.zk.svc.cluster.local:2181"}))
+			Ω(p.ZookeeperConnectString()).Should(Equal("zookeeper-client.zk.svc.cluster.local:2181/pravega"))
+			Ω(p.BookkeeperUri()).Should(Equal("bookkeeper-bookie-0.bookkeeper-bookie-headless.default.svc.cluster.local:3181"))
+		})
+
+		It("should keep the connection strings of the spec as they were written", func() {
+			p.Spec.ZookeeperUri = "zookeeper-client:2181"
+			p.Status.Zookeeper = &v1beta1.DependencyStatus{URI: "zookeeper-client.zk.svc.cluster.local:2181"}
+			p.Status.Bookkeeper = &v1beta1.DependencyStatus{URI: "bookkeeper-bookie-0.bookkeeper-bookie-headless.default.svc.cluster.local:3181"}
+			p.WithDefaults()
+			Ω(p.WithDefaults()).Should(BeFalse())
+			Ω(p.Spec.ZookeeperUri).Should(Equal("zookeeper-client:2181"))
+			Ω(p.Spec.BookkeeperUri).Should(BeEmpty())
+		})
+
+		It("should use the connection strings of the spec until the referred clusters are read", func() {
+			p.Spec.ZookeeperUri = "zookeeper-client:2181"
+			p.Spec.BookkeeperUri = "bookie-0.bookie-headless:3181"
+			Ω(p.ZookeeperConnectString()).Should(Equal("zookeeper-client:2181"))
+			Ω(p.BookkeeperUri()).Should(Equal("bookie-0.bookie-headless:3181"))
+		})
+
+		It("should look up the referred clusters in the namespace of the cluster by default", func() {
+			Ω(p.Spec.ZookeeperRef.NamespacedName("default").String()).Should(Equal("zk/zookeeper"))
+			Ω(p.Spec.BookkeeperRef.NamespacedName("default").String()).Should(Equal("default/bookkeeper"))
+		})
+
+		It("should require the names of the referred clusters", func() {
+			p.WithDefaults()
+			p.Spec.ZookeeperRef.Name = ""
+			fields := []string{}
+			for _, err := range p.ValidateSpec() {
+				fields = append(fields, err.Field)
+			}
+			Ω(fields).Should(ContainElement("spec.zookeeperRef.name"))
+			Ω(fields).ShouldNot(ContainElement("spec.bookkeeperRef.name"))
+		})
+
+		It("should report whether the referred clusters are ready", func() {
+			Ω(p.Status.AreDependenciesReady()).Should(BeTrue())
+			p.Status.Zookeeper = &v1beta1.DependencyStatus{Ready: true}
+			p.Status.Bookkeeper = &v1beta1.DependencyStatus{Ready: false, Upgrading: true}
+			Ω(p.Status.AreDependenciesReady()).Should(BeFalse())
+			Ω(p.Status.AreDependenciesUpgrading()).Should(BeTrue())
+		})
+	})
+
+	Context("ValidateSpec", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "1610612736"
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xmx1g", "-XX:MaxDirectMemorySize=2560m"}
+			p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2000m"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			}
+		})
+
+		It("should accept a valid spec", func() {
+			Ω(p.ValidateSpec()).Should(BeEmpty())
+			Ω(p.ValidateCreate()).Should(BeNil())
+		})
+
+		It("should require spec.pravega", func() {
+			p.Spec.Pravega = nil
+			var errs field.ErrorList
+			Ω(func() { errs = p.ValidateSpec() }).ShouldNot(Panic())
+			Ω(errs).Should(HaveLen(1))
+			Ω(errs[0].Field).Should(Equal("spec.pravega"))
+		})
+
+		It("should report every malformed value with its field path", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "three"
+			p.Spec.Pravega.ControllerOptions = map[string]string{"hostPathVolumeMounts": "heap-dump"}
+			p.Spec.Pravega.SegmentStoreOptions = map[string]string{"configMapVolumeMounts": "prvg-logback=/opt/pravega/conf/logback.xml"}
+			p.Spec.Pravega.SegmentStoreJVMOptions = []string{"-Xms1g", "-Xmx1gb", "-XX:MaxDirectMemorySize=2560m"}
+			p.Spec.Pravega.ControllerJvmOptions = []string{"-XX:+UseG1GC", "-XX:MaxRAMPercentage"}
+			var errs field.ErrorList
+			Ω(func() { errs = p.ValidateSpec() }).ShouldNot(Panic())
+			fields := []string{}
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			Ω(fields).Should(ContainElements(
+				"spec.pravega.options[bookkeeper.ensemble.size]",
+				"spec.pravega.controllerOptions[hostPathVolumeMounts]",
+				"spec.pravega.segmentStoreOptions[configMapVolumeMounts]",
+				"spec.pravega.segmentStoreJVMOptions[1]",
+				"spec.pravega.controllerjvmOptions[1]",
+			))
+		})
+
+		It("should report the broken rules along with the malformed values", func() {
+			p.Spec.Version = "999"
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "0"
+			errs := p.ValidateSpec()
+			Ω(errs.ToAggregate().Error()).Should(ContainSubstring("spec.version"))
+			Ω(errs.ToAggregate().Error()).Should(ContainSubstring("spec.pravega.options[pravegaservice.container.count]"))
+		})
+
+		It("should aggregate the errors in one admission response", func() {
+			p.Spec.Pravega.Options["bookkeeper.ensemble.size"] = "three"
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "-1"
+			err := p.ValidateCreate()
+			Ω(apierrors.IsInvalid(err)).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("Cannot convert ensemble size from string to integer"))
+			Ω(err.Error()).Should(ContainSubstring("spec.pravega.options[bookkeeper.ensemble.size]"))
+			Ω(err.Error()).Should(ContainSubstring("spec.pravega.options[pravegaservice.container.count]"))
+		})
+
+		It("should reject the changes of the immutable settings recorded in the stored cluster", func() {
+			old := p.DeepCopy()
+			old.Status.ImmutableSettings = old.NewImmutableSettings(nil, nil)
+			Ω(p.ValidateUpdate(old)).Should(BeNil())
+			p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.container.count": "8"}
+			err := p.ValidateUpdate(old)
+			Ω(apierrors.IsInvalid(err)).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("spec.pravega.segmentStoreOptions[pravegaservice.container.count]: Forbidden: pravegaservice.container.count should not be modified"))
+		})
+
+		It("should accept any setting before the immutable settings are recorded", func() {
+			old := p.DeepCopy()
+			p.Spec.Pravega.Options["pravegaservice.container.count"] = "8"
+			Ω(p.ValidateUpdate(old)).Should(BeNil())
+		})
+	})
+
+	Context("ParseVolumeMounts", func() {
+		It("should parse the volume mounts", func() {
+			mounts, err := v1beta1.ParseVolumeMounts("hostPathVolumeMounts", "heap-dump=/tmp/dumpfile/heap,log=/opt/pravega/logs,")
+			Ω(err).Should(BeNil())
+			Ω(mounts).Should(Equal([]v1beta1.VolumeMountOption{
+				{Name: "heap-dump", MountPath: "/tmp/dumpfile/heap"},
+				{Name: "log", MountPath: "/opt/pravega/logs"},
+			}))
+		})
+
+		It("should parse the config map volume mounts", func() {
+			mounts, err := v1beta1.ParseVolumeMounts("configMapVolumeMounts", "prvg-logback:logback.xml=/opt/pravega/conf/logback.xml")
+			Ω(err).Should(BeNil())
+			Ω(mounts).Should(Equal([]v1beta1.VolumeMountOption{
+				{Name: "prvg-logback", Key: "logback.xml", MountPath: "/opt/pravega/conf/logback.xml"},
+			}))
+		})
+
+		It("should return the well-formed entries along with an error", func() {
+			mounts, err := v1beta1.ParseVolumeMounts("emptyDirVolumeMounts", "heap-dump,=/tmp,log=/opt/pravega/logs")
+			Ω(err).Should(MatchError(ContainSubstring(`found "heap-dump", "=/tmp"`)))
+			Ω(mounts).Should(Equal([]v1beta1.VolumeMountOption{{Name: "log", MountPath: "/opt/pravega/logs"}}))
+		})
+
+		It("should ignore the other options", func() {
+			mounts, err := v1beta1.ParseVolumeMounts("bookkeeper.ensemble.size", "3")
+			Ω(err).Should(BeNil())
+			Ω(mounts).Should(BeEmpty())
+		})
+	})
+
+	Context("ZooKeeper connection", func() {
+		It("should split an ensemble with a chroot", func() {
+			p.Spec.ZookeeperUri = "zk-0:2181, zk-1:2181,zk-2:2181/pravega-prod"
+			Ω(p.ZookeeperServers()).Should(Equal([]string{"zk-0:2181", "zk-1:2181", "zk-2:2181"}))
+			Ω(p.ZookeeperChroot()).Should(Equal("/pravega-prod"))
+			Ω(p.ZookeeperConnectString()).Should(Equal("zk-0:2181,zk-1:2181,zk-2:2181/pravega-prod"))
+		})
+
+		It("should prefer the zookeeper block", func() {
+			p.Spec.ZookeeperUri = "zookeeper-client:2181"
+			p.Spec.Zookeeper = &v1beta1.ZookeeperSpec{
+				Hosts:  []string{"zk-0:2281", "zk-1:2281"},
+				Chroot: "/prod",
+			}
+			Ω(p.ZookeeperConnectString()).Should(Equal("zk-0:2281,zk-1:2281/prod"))
+			Ω(p.ValidateZookeeperSettings()).Should(BeNil())
+		})
+
+		It("should reject an invalid chroot", func() {
+			p.Spec.Zookeeper = &v1beta1.ZookeeperSpec{Chroot: "prod/"}
+			err := p.ValidateZookeeperSettings()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("spec.zookeeper.chroot"))
+		})
+
+		It("should reject a connection string in the hosts", func() {
+			p.Spec.Zookeeper = &v1beta1.ZookeeperSpec{Hosts: []string{"zk-0:2181,zk-1:2181"}}
+			Ω(p.ValidateZookeeperSettings()).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Deletion policy", func() {
+		It("should accept the policies without snapshot", func() {
+			for _, policy := range []v1beta1.DeletionPolicy{"", v1beta1.DeletionPolicyDelete, v1beta1.DeletionPolicyRetain} {
+				p.Spec.DeletionPolicy = policy
+				Ω(p.ValidateDeletionPolicy()).Should(BeNil())
+			}
+		})
+
+		It("should require a storage for the snapshot", func() {
+			p.Spec.DeletionPolicy = v1beta1.DeletionPolicySnapshot
+			Ω(p.ValidateDeletionPolicy()).ShouldNot(BeNil())
+			p.Spec.DeletionSnapshot = &v1beta1.MetadataBackupStorage{
+				PersistentVolumeClaim: &v1beta1.MetadataBackupPVC{ClaimName: "backups"},
+			}
+			Ω(p.ValidateDeletionPolicy()).Should(BeNil())
+		})
+
+		It("should reject an unknown policy", func() {
+			p.Spec.DeletionPolicy = "Archive"
+			Ω(p.ValidateDeletionPolicy()).ShouldNot(BeNil())
+		})
+
+		It("should name the snapshot after the cluster uid", func() {
+			p.UID = "0123456789abcdef"
+			Ω(p.DeletionSnapshotName()).Should(Equal("default-deletion-01234567"))
+		})
+	})
 })
@@ -0,0 +1,126 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validatePath is the path controller-runtime serves the validating webhook of the clusters on
+const validatePath = "/validate-pravega-pravega-io-v1beta1-pravegacluster"
+
+// AdmissionWarnings returns the warnings about the changes of the spec which are accepted but
+// disrupt the cluster, shown by kubectl when the cluster is applied. old is nil when the
+// cluster is created
+func (p *PravegaCluster) AdmissionWarnings(old *PravegaCluster) []string {
+	if p.Spec.Pravega == nil {
+		return nil
+	}
+	warnings := p.Spec.Pravega.OptionWarnings()
+	if p.Spec.Pravega.DebugLogging && (old == nil || old.Spec.Pravega == nil || !old.Spec.Pravega.DebugLogging) {
+		warnings = append(warnings, "spec.pravega.debugLogging makes the controller and the segment store log at debug level, which slows them down and fills up their logs")
+	}
+	if old == nil || old.Spec.Pravega == nil || old.Status.CurrentVersion == "" {
+		// The cluster is not deployed yet
+		return warnings
+	}
+
+	if changed := p.segmentStoreRestartChanges(old); len(changed) > 0 && p.Spec.Version == old.Spec.Version {
+		warnings = append(warnings, fmt.Sprintf("changing %s restarts all the %d segment store pods, one at a time", strings.Join(changed, ", "), p.Spec.Pravega.SegmentStoreReplicas))
+	}
+	if warning := replicasWarning("segmentStoreReplicas", old.Spec.Pravega.SegmentStoreReplicas, p.Spec.Pravega.SegmentStoreReplicas,
+		"maxUnavailableSegmentStoreReplicas", p.Spec.Pravega.MaxUnavailableSegmentStoreReplicas); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if warning := replicasWarning("controllerReplicas", old.Spec.Pravega.ControllerReplicas, p.Spec.Pravega.ControllerReplicas,
+		"maxUnavailableControllerReplicas", p.Spec.Pravega.MaxUnavailableControllerReplicas); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if old.Spec.ExternalAccess != nil && old.Spec.ExternalAccess.Enabled && (p.Spec.ExternalAccess == nil || !p.Spec.ExternalAccess.Enabled) {
+		warnings = append(warnings, "disabling spec.externalAccess removes the external services of the cluster, the clients outside of Kubernetes lose access to it")
+	}
+	return warnings
+}
+
+// segmentStoreRestartChanges returns the settings changed since the old spec which update the
+// Segment Store config map or pod template, after which the operator restarts all its pods
+func (p *PravegaCluster) segmentStoreRestartChanges(old *PravegaCluster) []string {
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{"the segment store options", !reflect.DeepEqual(old.Spec.Pravega.ComponentOptions(OptionComponentSegmentStore), p.Spec.Pravega.ComponentOptions(OptionComponentSegmentStore))},
+		{"spec.pravega.segmentStoreJVMOptions", !reflect.DeepEqual(old.SegmentStoreMemoryJVMOptions(), p.SegmentStoreMemoryJVMOptions())},
+		{"spec.pravega.segmentStoreResources", !reflect.DeepEqual(old.Spec.Pravega.SegmentStoreResources, p.Spec.Pravega.SegmentStoreResources)},
+		{"spec.pravega.segmentStoreContainerEnv", !reflect.DeepEqual(old.Spec.Pravega.SegmentStoreContainerEnv, p.Spec.Pravega.SegmentStoreContainerEnv)},
+		{"spec.pravega.longtermStorage", !reflect.DeepEqual(old.Spec.Pravega.LongTermStorage, p.Spec.Pravega.LongTermStorage)},
+		{"spec.pravega.debugLogging", old.Spec.Pravega.DebugLogging != p.Spec.Pravega.DebugLogging},
+		{"spec.authentication", old.Spec.Authentication.IsEnabled() != p.Spec.Authentication.IsEnabled()},
+		{"spec.zookeeperUri", old.ZookeeperConnectString() != p.ZookeeperConnectString()},
+		{"spec.bookkeeperUri", old.BookkeeperUri() != p.BookkeeperUri()},
+	}
+	var changed []string
+	for _, s := range settings {
+		if s.changed {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
+
+// replicasWarning returns a warning when the replicas are reduced to no more than the pods the
+// pod disruption budget lets be unavailable, or an empty string otherwise
+func replicasWarning(name string, old int32, replicas int32, maxUnavailableName string, maxUnavailable int32) string {
+	if replicas >= old || replicas > maxUnavailable {
+		return ""
+	}
+	return fmt.Sprintf("spec.pravega.%s is reduced from %d to %d, which is not above spec.pravega.%s (%d), so a disruption may leave none of them available", name, old, replicas, maxUnavailableName, maxUnavailable)
+}
+
+// warningHandler adds the warnings of AdmissionWarnings to the responses of the validating
+// webhook, since the validators of controller-runtime cannot return warnings
+type warningHandler struct {
+	admission.Handler
+	decoder *admission.Decoder
+}
+
+// InjectDecoder implements admission.DecoderInjector, for the validating handler too
+func (h *warningHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	_, err := admission.InjectDecoderInto(d, h.Handler)
+	return err
+}
+
+// Handle implements admission.Handler. The warnings are only added to the accepted requests
+func (h *warningHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.Handler.Handle(ctx, req)
+	if !resp.Allowed || req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return resp
+	}
+	p := &PravegaCluster{}
+	if err := h.decoder.DecodeRaw(req.Object, p); err != nil {
+		return resp
+	}
+	var old *PravegaCluster
+	if req.Operation == admissionv1.Update {
+		old = &PravegaCluster{}
+		if err := h.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return resp
+		}
+	}
+	return resp.WithWarnings(p.AdmissionWarnings(old)...)
+}
@@ -0,0 +1,101 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Admission warnings", func() {
+	var (
+		old *v1beta1.PravegaCluster
+		p   *v1beta1.PravegaCluster
+	)
+
+	BeforeEach(func() {
+		old = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "default",
+			},
+		}
+		old.WithDefaults()
+		old.Spec.Pravega.SegmentStoreReplicas = 3
+		old.Spec.Pravega.ControllerReplicas = 2
+		old.Spec.Pravega.MaxUnavailableSegmentStoreReplicas = 1
+		old.Spec.Pravega.MaxUnavailableControllerReplicas = 1
+		old.Status.CurrentVersion = old.Spec.Version
+		p = old.DeepCopy()
+	})
+
+	It("should not warn about an unchanged cluster", func() {
+		Ω(p.AdmissionWarnings(old)).Should(BeEmpty())
+	})
+
+	It("should warn about the changes restarting all the segment store pods", func() {
+		p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.cache.size.max": "1073741824"}
+		p.Spec.Pravega.SegmentStoreResources = &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+		}
+		Ω(p.AdmissionWarnings(old)).Should(Equal([]string{
+			"changing the segment store options, spec.pravega.segmentStoreResources restarts all the 3 segment store pods, one at a time",
+		}))
+	})
+
+	It("should not warn about the restarts of an upgrade", func() {
+		p.Spec.Version = "0.12.0"
+		p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.cache.size.max": "1073741824"}
+		Ω(p.AdmissionWarnings(old)).Should(BeEmpty())
+	})
+
+	It("should not warn about the changes of the controller", func() {
+		p.Spec.Pravega.ControllerOptions = map[string]string{"controller.retention.thread.count": "4"}
+		Ω(p.AdmissionWarnings(old)).Should(BeEmpty())
+	})
+
+	It("should warn about replicas reduced to the max unavailable replicas", func() {
+		p.Spec.Pravega.SegmentStoreReplicas = 2
+		Ω(p.AdmissionWarnings(old)).Should(BeEmpty())
+		p.Spec.Pravega.SegmentStoreReplicas = 1
+		p.Spec.Pravega.ControllerReplicas = 1
+		Ω(p.AdmissionWarnings(old)).Should(Equal([]string{
+			"spec.pravega.segmentStoreReplicas is reduced from 3 to 1, which is not above spec.pravega.maxUnavailableSegmentStoreReplicas (1), so a disruption may leave none of them available",
+			"spec.pravega.controllerReplicas is reduced from 2 to 1, which is not above spec.pravega.maxUnavailableControllerReplicas (1), so a disruption may leave none of them available",
+		}))
+	})
+
+	It("should warn about the external access disabled on a live cluster", func() {
+		old.Spec.ExternalAccess.Enabled = true
+		Ω(p.AdmissionWarnings(old)).Should(ConsistOf(ContainSubstring("disabling spec.externalAccess")))
+	})
+
+	It("should warn about debug logging", func() {
+		p.Spec.Pravega.DebugLogging = true
+		warnings := p.AdmissionWarnings(old)
+		Ω(warnings).Should(HaveLen(2))
+		Ω(warnings[0]).Should(ContainSubstring("spec.pravega.debugLogging makes the controller and the segment store log at debug level"))
+		Ω(warnings[1]).Should(ContainSubstring("changing spec.pravega.debugLogging restarts"))
+		Ω(p.AdmissionWarnings(p)).Should(BeEmpty())
+	})
+
+	It("should only warn about debug logging and the options when the cluster is created", func() {
+		p.Spec.Pravega.DebugLogging = true
+		p.Spec.Pravega.Options["controller.container.count"] = "8"
+		p.Spec.Pravega.SegmentStoreReplicas = 1
+		Ω(p.AdmissionWarnings(nil)).Should(HaveLen(2))
+		old.Status.CurrentVersion = ""
+		Ω(p.AdmissionWarnings(old)).Should(HaveLen(2))
+	})
+})
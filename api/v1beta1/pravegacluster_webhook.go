@@ -28,87 +28,207 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // log is for logging in this package.
 var pravegaclusterlog = logf.Log.WithName("pravegacluster-resource")
 
 func (r *PravegaCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	validator := NewPravegaClusterValidator(mgr.GetAPIReader())
+	// The validating webhook is registered with the warnings before the builder, which then
+	// skips its path
+	mgr.GetWebhookServer().Register(validatePath, &webhook.Admission{
+		Handler: &warningHandler{Handler: admission.WithCustomValidator(r, validator).Handler},
+	})
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithValidator(validator).
 		Complete()
 }
 
+//+kubebuilder:webhook:path=/mutate-pravega-pravega-io-v1beta1-pravegacluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=pravega.pravega.io,resources=pravegaclusters,verbs=create;update,versions=v1beta1,name=mpravegacluster.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &PravegaCluster{}
+
+// Default implements webhook.Defaulter so that the clusters are stored with their defaults,
+// which the operator otherwise only applies in memory
+func (p *PravegaCluster) Default() {
+	if p.WithDefaults() {
+		pravegaclusterlog.Info("default", "name", p.Name)
+	}
+}
+
 // TODO(user): EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
-//+kubebuilder:webhook:path=/validate-pravegaclusters-pravega-pravega-io-v1beta1-pravegacluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=pravegaclusters.pravega.pravega.io,resources=pravegaclusters,verbs=create;update,versions=v1beta1,name=vpravegacluster.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-pravegaclusters-pravega-pravega-io-v1beta1-pravegacluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=pravegaclusters.pravega.pravega.io,resources=pravegaclusters,verbs=create;update;delete,versions=v1beta1,name=vpravegacluster.kb.io,admissionReviewVersions=v1
 
 var _ webhook.Validator = &PravegaCluster{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+// ValidateCreate implements webhook.Validator, without the checks reading other objects
 func (p *PravegaCluster) ValidateCreate() error {
+	return (&pravegaClusterValidator{}).ValidateCreate(context.TODO(), p)
+}
+
+// ValidateUpdate implements webhook.Validator, without the checks reading other objects
+func (p *PravegaCluster) ValidateUpdate(old runtime.Object) error {
+	return (&pravegaClusterValidator{}).ValidateUpdate(context.TODO(), old, p)
+}
+
+// pravegaClusterValidator is the validating webhook of the clusters. Its client reads the
+// objects some checks depend on, such as the bookies, and these checks are skipped without it
+type pravegaClusterValidator struct {
+	client client.Reader
+}
+
+// NewPravegaClusterValidator returns the validating webhook of the clusters, reading the
+// objects the checks depend on with the given client
+func NewPravegaClusterValidator(c client.Reader) webhook.CustomValidator {
+	return &pravegaClusterValidator{client: c}
+}
+
+var _ webhook.CustomValidator = &pravegaClusterValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *pravegaClusterValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	p := obj.(*PravegaCluster)
 	pravegaclusterlog.Info("validate create", "name", p.Name)
-	err := p.ValidatePravegaVersion()
-	if err != nil {
-		return err
-	}
-	err = p.ValidateSegmentStoreMemorySettings()
-	if err != nil {
-		return err
+	errs := p.ValidateSpec()
+	errs = append(errs, p.validateBookies(v.client)...)
+	return p.invalid(errs)
+}
+
+// ValidateUpdate implements webhook.CustomValidator. The immutable settings are compared with
+// the ones recorded in the status of the stored cluster. The bookies are only counted when the
+// update changes the BookKeeper settings, so that the other updates, such as the finalizer
+// patches of the operator, are not rejected while bookies are missing
+func (v *pravegaClusterValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	p := newObj.(*PravegaCluster)
+	pravegaclusterlog.Info("validate update", "name", p.Name)
+	errs := p.ValidateSpec()
+	old, ok := oldObj.(*PravegaCluster)
+	if p.DeletionTimestamp.IsZero() && (!ok || p.bookkeeperChanged(old)) {
+		errs = append(errs, p.validateBookies(v.client)...)
 	}
-	err = p.ValidateBookkeperSettings()
-	if err != nil {
-		return err
+	if ok {
+		errs = append(errs, p.ValidateImmutableSettings(old.Status.ImmutableSettings)...)
+		errs = append(errs, p.validateDryRun(old)...)
 	}
+	return p.invalid(errs)
+}
 
-	err = p.ValidateAuthenticationSettings()
-	if err != nil {
-		return err
+// bookkeeperChanged returns whether the ensemble size or spec.bookkeeperUri differ from the
+// stored cluster
+func (p *PravegaCluster) bookkeeperChanged(old *PravegaCluster) bool {
+	size, err := p.BookkeeperEnsembleSize()
+	oldSize, oldErr := old.BookkeeperEnsembleSize()
+	return size != oldSize || (err == nil) != (oldErr == nil) || p.BookkeeperUri() != old.BookkeeperUri()
+}
+
+// validateDryRun rejects starting a dry run while the cluster is upgraded or rolled back,
+// since the operator does not apply the spec during a dry run and would stall them
+func (p *PravegaCluster) validateDryRun(old *PravegaCluster) field.ErrorList {
+	if !p.IsDryRun() || old.IsDryRun() {
+		return nil
+	}
+	path := field.NewPath("metadata", "annotations").Key(DryRunAnnotationKey)
+	if old.Status.IsClusterInUpgradingState() {
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("the cluster is being upgraded to version %s, start the dry run once the upgrade is completed", old.Status.TargetVersion))}
+	}
+	if old.Status.IsClusterInRollbackState() {
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("the cluster is being rolled back to version %s, start the dry run once the rollback is completed", old.Status.TargetVersion))}
 	}
 	return nil
+}
 
+// ValidateDelete implements webhook.CustomValidator
+func (v *pravegaClusterValidator) ValidateDelete(_ context.Context, obj runtime.Object) error {
+	return obj.(*PravegaCluster).ValidateDelete()
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (p *PravegaCluster) ValidateUpdate(old runtime.Object) error {
-	pravegaclusterlog.Info("validate update", "name", p.Name)
+// ValidateSpec checks the spec and returns all its errors rather than the first one. The
+// malformed values are reported with their field path, e.g. spec.pravega.options[name], and the
+// settings breaking a rule under the part of the spec holding them
+func (p *PravegaCluster) ValidateSpec() field.ErrorList {
+	if p.Spec.Pravega == nil {
+		return field.ErrorList{field.Required(field.NewPath("spec", "pravega"), "")}
+	}
+	var errs field.ErrorList
+	rules := []struct {
+		path     *field.Path
+		validate func() error
+	}{
+		{field.NewPath("spec", "version"), p.ValidatePravegaVersion},
+		{field.NewPath("spec", "pravega"), p.ValidateSegmentStoreMemorySettings},
+		{field.NewPath("spec", "pravega", "options"), p.ValidateBookkeperSettings},
+		{field.NewPath("spec", "authentication"), p.ValidateAuthenticationSettings},
+		{field.NewPath("spec", "pravega", "segmentStoreLoadBalancerIP"), p.ValidateSegmentStorePortAllocations},
+		{field.NewPath("spec", "externalAccess", "controllerRoute"), p.ValidateControllerRoute},
+		{field.NewPath("spec", "zookeeper"), p.ValidateZookeeperSettings},
+		{field.NewPath("spec", "deletionPolicy"), p.ValidateDeletionPolicy},
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, field.Forbidden(rule.path, err.Error()))
+		}
+	}
+	errs = append(errs, p.validatePravegaOptionFields()...)
+	errs = append(errs, p.validateJVMOptionFields()...)
+	errs = append(errs, p.validateClusterReferences()...)
+	return errs
+}
 
-	err := p.ValidatePravegaVersion()
-	if err != nil {
-		return err
-	}
-	err = p.validateConfigMap()
-	if err != nil {
-		return err
-	}
-	err = p.ValidateSegmentStoreMemorySettings()
-	if err != nil {
-		return err
+// validateClusterReferences checks that the ZookeeperCluster and BookkeeperCluster references
+// have a name
+func (p *PravegaCluster) validateClusterReferences() field.ErrorList {
+	var errs field.ErrorList
+	if p.Spec.ZookeeperRef != nil && p.Spec.ZookeeperRef.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "zookeeperRef", "name"), ""))
 	}
-	err = p.ValidateBookkeperSettings()
-	if err != nil {
-		return err
+	if p.Spec.BookkeeperRef != nil && p.Spec.BookkeeperRef.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "bookkeeperRef", "name"), ""))
 	}
-	err = p.ValidateAuthenticationSettings()
-	if err != nil {
-		return err
+	return errs
+}
+
+// invalid returns the errors of the spec as a single admission error, nil when there are none
+func (p *PravegaCluster) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
 	}
-	return nil
+	return errors.NewInvalid(GroupVersion.WithKind("PravegaCluster").GroupKind(), p.Name, errs)
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+// ValidateDelete implements webhook.Validator. The deletion of a cluster is rejected while it is
+// protected by the deletion-protection annotation, and while it is upgraded or rolled back
+// unless the force-delete annotation is set
 func (p *PravegaCluster) ValidateDelete() error {
 	pravegaclusterlog.Info("validate delete", "name", p.Name)
-
-	// TODO(user): fill in your validation logic upon object deletion.
+	if p.IsDeletionProtected() {
+		return p.forbidden(fmt.Errorf("the cluster is protected by the annotation %s, which should be removed first", DeletionProtectionAnnotationKey))
+	}
+	if p.GetAnnotations()[ForceDeleteAnnotationKey] == "true" {
+		return nil
+	}
+	if p.Status.IsClusterInUpgradingState() {
+		return p.forbidden(fmt.Errorf("the cluster is being upgraded to version %s, set the annotation %s to \"true\" to delete it anyway", p.Status.TargetVersion, ForceDeleteAnnotationKey))
+	}
+	if p.Status.IsClusterInRollbackState() {
+		return p.forbidden(fmt.Errorf("the cluster is being rolled back to version %s, set the annotation %s to \"true\" to delete it anyway", p.Status.TargetVersion, ForceDeleteAnnotationKey))
+	}
 	return nil
 }
 
+// forbidden returns the error of a request rejected for the state of the cluster
+func (p *PravegaCluster) forbidden(err error) error {
+	return errors.NewForbidden(GroupVersion.WithResource("pravegaclusters").GroupResource(), p.Name, err)
+}
+
 func (p *PravegaCluster) ValidatePravegaVersion() error {
 	if p.Spec.Version == "" {
 		p.Spec.Version = DefaultPravegaVersion
@@ -166,219 +286,21 @@ func (p *PravegaCluster) ValidatePravegaVersion() error {
 	return nil
 }
 
-func (p *PravegaCluster) validateConfigMap() error {
-	configmap := &corev1.ConfigMap{}
-	err := Mgr.GetClient().Get(context.TODO(),
-		types.NamespacedName{Name: p.ConfigMapNameForController(), Namespace: p.Namespace}, configmap)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return nil
-		} else {
-			return fmt.Errorf("failed to get configmap (%s): %v", configmap.Name, err)
-		}
-	}
-	data := strings.Split(configmap.Data["JAVA_OPTS"], " ")
-	eq := false
-	if val, ok := p.Spec.Pravega.Options["controller.containerCount"]; ok {
-		key := fmt.Sprintf("-Dcontroller.containerCount=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.containerCount should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["controller.container.count"]; ok {
-		old_key := fmt.Sprintf("-Dcontroller.containerCount=%v", val)
-		new_key := fmt.Sprintf("-Dcontroller.container.count=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.container.count should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.containerCount"]; ok {
-		key := fmt.Sprintf("-Dpravegaservice.containerCount=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.containerCount should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.container.count"]; ok {
-		old_key := fmt.Sprintf("-Dpravegaservice.containerCount=%v", val)
-		new_key := fmt.Sprintf("-Dpravegaservice.container.count=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.container.count should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["bookkeeper.bkLedgerPath"]; ok {
-		key := fmt.Sprintf("-Dbookkeeper.bkLedgerPath=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("bookkeeper.bkLedgerPath should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["bookkeeper.ledger.path"]; ok {
-		old_key := fmt.Sprintf("-Dbookkeeper.bkLedgerPath=%v", val)
-		new_key := fmt.Sprintf("-Dbookkeeper.ledger.path=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("bookkeeper.ledger.path should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["controller.retention.bucketCount"]; ok {
-		key := fmt.Sprintf("-Dcontroller.retention.bucketCount=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.retention.bucketCount should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["controller.retention.bucket.count"]; ok {
-		old_key := fmt.Sprintf("-Dcontroller.retention.bucketCount=%v", val)
-		new_key := fmt.Sprintf("-Dcontroller.retention.bucket.count=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.retention.bucket.count should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["controller.watermarking.bucketCount"]; ok {
-		key := fmt.Sprintf("-Dcontroller.watermarking.bucketCount=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.watermarking.bucketCount should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["controller.watermarking.bucket.count"]; ok {
-		old_key := fmt.Sprintf("-Dcontroller.watermarking.bucketCount=%v", val)
-		new_key := fmt.Sprintf("-Dcontroller.watermarking.bucket.count=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("controller.watermarking.bucket.count should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.dataLogImplementation"]; ok {
-		key := fmt.Sprintf("-Dpravegaservice.dataLogImplementation=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.dataLogImplementation should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.dataLog.impl.name"]; ok {
-		old_key := fmt.Sprintf("-Dpravegaservice.dataLogImplementation=%v", val)
-		new_key := fmt.Sprintf("-Dpravegaservice.dataLog.impl.name=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.dataLog.impl.name should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.storageImplementation"]; ok {
-		key := fmt.Sprintf("-Dpravegaservice.storageImplementation=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.storageImplementation should not be modified")
-		}
-	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.storage.impl.name"]; ok {
-		old_key := fmt.Sprintf("-Dpravegaservice.storageImplementation=%v", val)
-		new_key := fmt.Sprintf("-Dpravegaservice.storage.impl.name=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("pravegaservice.storage.impl.name should not be modified")
-		}
+// validateBookies checks the ensemble size against the bookies found behind spec.bookkeeperUri
+func (p *PravegaCluster) validateBookies(c client.Reader) field.ErrorList {
+	if c == nil || p.Spec.Pravega == nil {
+		return nil
 	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["storageextra.storageNoOpMode"]; ok {
-		key := fmt.Sprintf("-Dstorageextra.storageNoOpMode=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("storageextra.storageNoOpMode should not be modified")
-		}
+	count, err := p.CountBookies(c)
+	if err != nil {
+		// The bookies may be counted once the API server is reachable again, so the
+		// configuration is not rejected for it
+		log.Printf("validateBookies:: %v", err)
+		return nil
 	}
-	eq = false
-	if val, ok := p.Spec.Pravega.Options["storageextra.noOp.mode.enable"]; ok {
-		old_key := fmt.Sprintf("-Dstorageextra.storageNoOpMode=%v", val)
-		new_key := fmt.Sprintf("-Dstorageextra.noOp.mode.enable=%v", val)
-		for _, checkstring := range data {
-			if checkstring == old_key || checkstring == new_key {
-				eq = true
-			}
-		}
-		if !eq {
-			return fmt.Errorf("storageextra.noOp.mode.enable should not be modified")
-		}
+	if err := p.ValidateBookieCount(count); err != nil {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "pravega", "options"), err.Error())}
 	}
-	log.Print("validateConfigMap:: No error found...returning...")
 	return nil
 }
 
@@ -386,8 +308,8 @@ func (p *PravegaCluster) validateConfigMap() error {
 // when authentication is enabled/disabled.
 func (p *PravegaCluster) ValidateAuthenticationSettings() error {
 	if p.Spec.Authentication != nil && p.Spec.Authentication.Enabled == true {
-		newkey, _ := p.Spec.Pravega.Options["autoScale.controller.connect.security.auth.enable"]
-		oldkey, _ := p.Spec.Pravega.Options["autoScale.authEnabled"]
+		newkey, _ := p.Spec.Pravega.SegmentStoreOption("autoScale.controller.connect.security.auth.enable")
+		oldkey, _ := p.Spec.Pravega.SegmentStoreOption("autoScale.authEnabled")
 
 		if newkey == "" && oldkey == "" {
 			return fmt.Errorf("autoScale.controller.connect.security.auth.enable field is not present")
@@ -407,16 +329,16 @@ func (p *PravegaCluster) ValidateAuthenticationSettings() error {
 				return fmt.Errorf("Both autoScale.controller.connect.security.auth.enable and autoScale.authEnabled should be set to true")
 			}
 		}
-		signingkey1, ok := p.Spec.Pravega.Options["controller.security.auth.delegationToken.signingKey.basis"]
+		signingkey1, ok := p.Spec.Pravega.ControllerOption("controller.security.auth.delegationToken.signingKey.basis")
 		if !ok {
-			signingkey1, ok = p.Spec.Pravega.Options["controller.auth.tokenSigningKey"]
+			signingkey1, ok = p.Spec.Pravega.ControllerOption("controller.auth.tokenSigningKey")
 		}
 		if !ok {
 			return fmt.Errorf("controller.security.auth.delegationToken.signingKey.basis field is not present")
 		}
-		signingkey2, ok := p.Spec.Pravega.Options["autoScale.security.auth.token.signingKey.basis"]
+		signingkey2, ok := p.Spec.Pravega.SegmentStoreOption("autoScale.security.auth.token.signingKey.basis")
 		if !ok {
-			signingkey2, ok = p.Spec.Pravega.Options["autoScale.tokenSigningKey"]
+			signingkey2, ok = p.Spec.Pravega.SegmentStoreOption("autoScale.tokenSigningKey")
 		}
 		if !ok {
 			return fmt.Errorf("autoScale.security.auth.token.signingKey.basis field is not present")
@@ -425,8 +347,8 @@ func (p *PravegaCluster) ValidateAuthenticationSettings() error {
 			return fmt.Errorf("controller and segmentstore token signing key should have same value")
 		}
 	} else {
-		newkey, _ := p.Spec.Pravega.Options["autoScale.controller.connect.security.auth.enable"]
-		oldkey, _ := p.Spec.Pravega.Options["autoScale.authEnabled"]
+		newkey, _ := p.Spec.Pravega.SegmentStoreOption("autoScale.controller.connect.security.auth.enable")
+		oldkey, _ := p.Spec.Pravega.SegmentStoreOption("autoScale.authEnabled")
 
 		if oldkey == "true" || newkey == "true" {
 			return fmt.Errorf("autoScale.controller.connect.security.auth.enable/autoScale.authEnabled should not be set to true")
@@ -440,6 +362,8 @@ func (p *PravegaCluster) ValidateAuthenticationSettings() error {
 // Once the required values are set, the method also checks whether the following conditions are met:
 // Total Memory > JVM Heap (-Xmx) + JVM Direct Memory (-XX:MaxDirectMemorySize)
 // JVM Direct memory > Segment Store read cache size (pravegaservice.cache.size.max).
+// The sizes may be given in bytes, with a JVM suffix (k, m, g) or as a Kubernetes quantity, and
+// the ones left unset are derived from the memory limit when spec.pravega.segmentStoreMemory.autoSize is enabled.
 func (p *PravegaCluster) ValidateSegmentStoreMemorySettings() error {
 	if p.Spec.Pravega.SegmentStoreResources == nil {
 		return fmt.Errorf("spec.pravega.segmentStoreResources cannot be empty")
@@ -449,13 +373,6 @@ func (p *PravegaCluster) ValidateSegmentStoreMemorySettings() error {
 		return fmt.Errorf("spec.pravega.segmentStoreResources.limits cannot be empty")
 	}
 
-	if p.Spec.Pravega.SegmentStoreResources.Requests == nil {
-		p.Spec.Pravega.SegmentStoreResources.Requests = map[corev1.ResourceName]resource.Quantity{
-			corev1.ResourceCPU:    {},
-			corev1.ResourceMemory: {},
-		}
-	}
-
 	totalMemoryLimitsQuantity := p.Spec.Pravega.SegmentStoreResources.Limits[corev1.ResourceMemory]
 	totalMemoryRequestsQuantity := p.Spec.Pravega.SegmentStoreResources.Requests[corev1.ResourceMemory]
 	totalCpuLimitsQuantity := p.Spec.Pravega.SegmentStoreResources.Limits[corev1.ResourceCPU]
@@ -482,37 +399,27 @@ func (p *PravegaCluster) ValidateSegmentStoreMemorySettings() error {
 		return fmt.Errorf("spec.pravega.segmentStoreResources.requests.cpu value must be less than or equal to spec.pravega.segmentStoreResources.limits.cpu")
 	}
 
-	cacheSizeString := p.Spec.Pravega.Options["pravegaservice.cache.size.max"]
-	if cacheSizeString == "" {
-		return fmt.Errorf("Missing required value for option pravegaservice.cache.size.max")
+	// The sizes left unset are derived from the memory limit when autoSize is enabled
+	sizes, err := p.SegmentStoreMemorySizes()
+	if err != nil {
+		return err
 	}
-	cacheSizeQuantity := resource.MustParse(cacheSizeString)
-	maxDirectMemoryString := ""
-	xmxString := ""
 
-	for _, value := range p.Spec.Pravega.SegmentStoreJVMOptions {
-		if strings.Contains(value, "-Xmx") {
-			xmxString = strings.ToUpper(strings.TrimPrefix(value, "-Xmx")) + "i"
-		}
-
-		if strings.Contains(value, "-XX:MaxDirectMemorySize=") {
-			maxDirectMemoryString = strings.ToUpper(strings.TrimPrefix(value, "-XX:MaxDirectMemorySize=")) + "i"
-		}
+	if sizes.CacheSize == 0 {
+		return fmt.Errorf("Missing required value for option pravegaservice.cache.size.max")
 	}
 
-	if xmxString == "" {
+	if sizes.Heap == 0 {
 		return fmt.Errorf("Missing required value for Segment Store JVM Option -Xmx")
 	}
-	xmxQuantity := resource.MustParse(xmxString)
 
-	if maxDirectMemoryString == "" {
+	if sizes.DirectMemory == 0 {
 		return fmt.Errorf("Missing required value for Segment Store JVM option -XX:MaxDirectMemorySize")
 	}
-	maxDirectMemoryQuantity := resource.MustParse(maxDirectMemoryString)
 
-	xmx := xmxQuantity.Value()
-	maxDirectMemorySize := maxDirectMemoryQuantity.Value()
-	cacheSize := cacheSizeQuantity.Value()
+	xmx := sizes.Heap
+	maxDirectMemorySize := sizes.DirectMemory
+	cacheSize := sizes.CacheSize
 
 	if totalMemoryLimits <= (maxDirectMemorySize + xmx) {
 		return fmt.Errorf("MaxDirectMemorySize(%v B) along with JVM Xmx value(%v B) should be less than the total available memory(%v B)!", maxDirectMemorySize, xmx, totalMemoryLimits)
@@ -534,10 +441,10 @@ func (p *PravegaCluster) ValidateBookkeperSettings() error {
 	ensembleSizeInt, writeQuorumSizeInt, ackQuorumSizeInt := 3, 3, 3
 	var err error
 
-	ensembleSize := p.Spec.Pravega.Options["bookkeeper.ensemble.size"]
-	writeQuorumSize := p.Spec.Pravega.Options["bookkeeper.write.quorum.size"]
-	ackQuorumSize := p.Spec.Pravega.Options["bookkeeper.ack.quorum.size"]
-	writeQuorumRacks := p.Spec.Pravega.Options["bookkeeper.write.quorum.racks.minimumCount.enable"]
+	ensembleSize, _ := p.Spec.Pravega.SegmentStoreOption("bookkeeper.ensemble.size")
+	writeQuorumSize, _ := p.Spec.Pravega.SegmentStoreOption("bookkeeper.write.quorum.size")
+	ackQuorumSize, _ := p.Spec.Pravega.SegmentStoreOption("bookkeeper.ack.quorum.size")
+	writeQuorumRacks, _ := p.Spec.Pravega.SegmentStoreOption("bookkeeper.write.quorum.racks.minimumCount.enable")
 
 	if len(ensembleSize) > 0 {
 		ensembleSizeInt, err = strconv.Atoi(ensembleSize)
@@ -590,3 +497,96 @@ func (p *PravegaCluster) ValidateBookkeperSettings() error {
 
 	return nil
 }
+
+// ValidateSegmentStorePortAllocations checks, when the Segment Store external services share
+// the segmentStoreLoadBalancerIP, that the ports already allocated to them neither collide
+// with each other nor with spec.reservedPortList, and that there are enough free ports left
+// to allocate to every Segment Store replica.
+func (p *PravegaCluster) ValidateSegmentStorePortAllocations() error {
+	if p.Spec.Pravega.SegmentStoreLoadBalancerIP == "" {
+		return nil
+	}
+
+	reserved := make(map[int32]bool)
+	for _, port := range p.Spec.ReservedPortList {
+		reserved[port] = true
+	}
+	owners := make(map[int32]int32)
+	for _, allocation := range p.Status.SegmentStorePortAllocations {
+		for _, port := range []int32{allocation.ServicePort, allocation.AdminPort} {
+			if reserved[port] {
+				return fmt.Errorf("port %d is allocated to segment store %d and cannot be added to spec.reservedPortList", port, allocation.Ordinal)
+			}
+			if owner, ok := owners[port]; ok {
+				return fmt.Errorf("port %d is allocated to both segment store %d and segment store %d", port, owner, allocation.Ordinal)
+			}
+			owners[port] = allocation.Ordinal
+		}
+	}
+
+	if _, err := p.AllocateSegmentStorePorts(); err != nil {
+		return fmt.Errorf("cannot expose %d segment stores on %s: %v", p.Spec.Pravega.SegmentStoreReplicas, p.Spec.Pravega.SegmentStoreLoadBalancerIP, err)
+	}
+	return nil
+}
+
+// ValidateControllerRoute checks that the settings needed to generate the Ingress objects
+// or Gateway API routes exposing the Controller are provided
+func (p *PravegaCluster) ValidateControllerRoute() error {
+	if !p.Spec.ExternalAccess.IsControllerRouteEnabled() {
+		return nil
+	}
+	route := p.Spec.ExternalAccess.ControllerRoute
+	if route.GrpcHostname == "" {
+		return fmt.Errorf("spec.externalAccess.controllerRoute.grpcHostname cannot be empty")
+	}
+	if route.RestHostname == route.GrpcHostname {
+		return fmt.Errorf("spec.externalAccess.controllerRoute.restHostname should be different from grpcHostname")
+	}
+	switch route.Type {
+	case ControllerRouteGatewayAPI:
+		if route.GatewayName == "" {
+			return fmt.Errorf("spec.externalAccess.controllerRoute.gatewayName cannot be empty when the route type is %s", ControllerRouteGatewayAPI)
+		}
+	case ControllerRouteIngress:
+	default:
+		return fmt.Errorf("spec.externalAccess.controllerRoute.type should be either %s or %s", ControllerRouteGatewayAPI, ControllerRouteIngress)
+	}
+	return nil
+}
+
+// ValidateZookeeperSettings checks the hosts and chroot of the ZooKeeper connection
+func (p *PravegaCluster) ValidateZookeeperSettings() error {
+	z := p.Spec.Zookeeper
+	if z == nil {
+		return nil
+	}
+	for _, host := range z.Hosts {
+		if host == "" || strings.ContainsAny(host, ",/ ") {
+			return fmt.Errorf("spec.zookeeper.hosts should only contain \"hostname:port\" entries, found %q", host)
+		}
+	}
+	if z.Chroot != "" && (!strings.HasPrefix(z.Chroot, "/") || strings.HasSuffix(z.Chroot, "/")) {
+		return fmt.Errorf("spec.zookeeper.chroot should start with and not end with a '/'")
+	}
+	return nil
+}
+
+// ValidateDeletionPolicy checks that the "Snapshot" deletion policy has a storage for the backup
+func (p *PravegaCluster) ValidateDeletionPolicy() error {
+	switch p.Spec.DeletionPolicy {
+	case "", DeletionPolicyDelete, DeletionPolicyRetain:
+		return nil
+	case DeletionPolicySnapshot:
+		if p.Spec.DeletionSnapshot == nil {
+			return fmt.Errorf("spec.deletionSnapshot should be set when the deletion policy is %s", DeletionPolicySnapshot)
+		}
+		b := &PravegaMetadataBackup{Spec: MetadataBackupSpec{Storage: *p.Spec.DeletionSnapshot}}
+		if err := b.ValidateStorage(); err != nil {
+			return fmt.Errorf("invalid spec.deletionSnapshot: %v", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("spec.deletionPolicy should be one of %s, %s or %s", DeletionPolicyDelete, DeletionPolicyRetain, DeletionPolicySnapshot)
+	}
+}
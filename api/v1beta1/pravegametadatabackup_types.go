@@ -0,0 +1,207 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultMetadataBackupS3Image is the image uploading and downloading archives to S3
+	DefaultMetadataBackupS3Image = "amazon/aws-cli:2.13.0"
+)
+
+func init() {
+	SchemeBuilder.Register(&PravegaMetadataBackup{}, &PravegaMetadataBackupList{})
+}
+
+// MetadataBackupPhase is the phase of a metadata backup or restore
+type MetadataBackupPhase string
+
+const (
+	MetadataBackupPending   MetadataBackupPhase = "Pending"
+	MetadataBackupRunning   MetadataBackupPhase = "Running"
+	MetadataBackupCompleted MetadataBackupPhase = "Completed"
+	MetadataBackupFailed    MetadataBackupPhase = "Failed"
+	// MetadataBackupSkipped is the phase of a restore requested once the cluster already started
+	MetadataBackupSkipped MetadataBackupPhase = "Skipped"
+)
+
+// +kubebuilder:object:root=true
+
+// PravegaMetadataBackupList contains a list of PravegaMetadataBackup
+type PravegaMetadataBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PravegaMetadataBackup `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The Pravega cluster backed up"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="The phase of the backup"
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,description="The location of the archive"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// PravegaMetadataBackup is the Schema for the pravegametadatabackups API
+// +k8s:openapi-gen=true
+type PravegaMetadataBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetadataBackupSpec   `json:"spec,omitempty"`
+	Status MetadataBackupStatus `json:"status,omitempty"`
+}
+
+// MetadataBackupSpec defines the desired state of PravegaMetadataBackup
+type MetadataBackupSpec struct {
+	// ClusterName is the name of the PravegaCluster, in the namespace of the backup,
+	// whose ZooKeeper metadata is backed up
+	ClusterName string `json:"clusterName"`
+
+	// Storage is where the archive is stored
+	Storage MetadataBackupStorage `json:"storage"`
+}
+
+// MetadataBackupStorage is the location of a metadata archive. Exactly one of
+// persistentVolumeClaim and s3 must be set
+type MetadataBackupStorage struct {
+	// PersistentVolumeClaim stores the archive on a volume
+	// +optional
+	PersistentVolumeClaim *MetadataBackupPVC `json:"persistentVolumeClaim,omitempty"`
+
+	// S3 stores the archive in an S3 compatible object storage
+	// +optional
+	S3 *MetadataBackupS3 `json:"s3,omitempty"`
+}
+
+// MetadataBackupPVC stores the archive on a persistent volume claim
+type MetadataBackupPVC struct {
+	// ClaimName is the name of the persistent volume claim
+	ClaimName string `json:"claimName"`
+
+	// Path is the path of the archive in the volume. Defaults to <backup name>.json
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// MetadataBackupS3 stores the archive in an S3 bucket
+type MetadataBackupS3 struct {
+	// Bucket is the name of the bucket
+	Bucket string `json:"bucket"`
+
+	// Key is the key of the archive in the bucket. Defaults to <namespace>/<backup name>.json
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Endpoint is the URL of an S3 compatible object storage
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region is the region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecret is the name of the Secret holding the AWS_ACCESS_KEY_ID
+	// and AWS_SECRET_ACCESS_KEY of the bucket
+	CredentialsSecret string `json:"credentialsSecret"`
+
+	// Image is the image of the AWS CLI copying the archive
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// MetadataBackupStatus defines the observed state of PravegaMetadataBackup
+type MetadataBackupStatus struct {
+	// Phase is the phase of the backup
+	Phase MetadataBackupPhase `json:"phase,omitempty"`
+
+	// Message explains why the backup failed
+	Message string `json:"message,omitempty"`
+
+	// Location is the location of the archive
+	Location string `json:"location,omitempty"`
+
+	// JobName is the name of the Job taking the backup
+	JobName string `json:"jobName,omitempty"`
+
+	// StartTime is the time the backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the backup completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// MetadataRestoreSpec selects the backup restored before the cluster starts
+type MetadataRestoreSpec struct {
+	// BackupName is the name of a completed PravegaMetadataBackup in the namespace of the cluster
+	BackupName string `json:"backupName"`
+}
+
+// MetadataRestoreStatus is the state of the restore of the metadata of the cluster
+type MetadataRestoreStatus struct {
+	// BackupName is the name of the restored backup
+	BackupName string `json:"backupName,omitempty"`
+
+	// Phase is the phase of the restore
+	Phase MetadataBackupPhase `json:"phase,omitempty"`
+
+	// Message explains why the restore failed or was skipped
+	Message string `json:"message,omitempty"`
+}
+
+// IsFinished returns whether the backup completed or failed
+func (b *PravegaMetadataBackup) IsFinished() bool {
+	return b.Status.Phase == MetadataBackupCompleted || b.Status.Phase == MetadataBackupFailed
+}
+
+// ArchivePath returns the path of the archive in the volume of the backup
+func (b *PravegaMetadataBackup) ArchivePath() string {
+	if pvc := b.Spec.Storage.PersistentVolumeClaim; pvc != nil && pvc.Path != "" {
+		return pvc.Path
+	}
+	return b.Name + ".json"
+}
+
+// ArchiveKey returns the key of the archive in the bucket of the backup
+func (b *PravegaMetadataBackup) ArchiveKey() string {
+	if s3 := b.Spec.Storage.S3; s3 != nil && s3.Key != "" {
+		return s3.Key
+	}
+	return fmt.Sprintf("%s/%s.json", b.Namespace, b.Name)
+}
+
+// ArchiveLocation returns the location of the archive as reported in the status
+func (b *PravegaMetadataBackup) ArchiveLocation() string {
+	if s3 := b.Spec.Storage.S3; s3 != nil {
+		return fmt.Sprintf("s3://%s/%s", s3.Bucket, b.ArchiveKey())
+	}
+	if pvc := b.Spec.Storage.PersistentVolumeClaim; pvc != nil {
+		return fmt.Sprintf("pvc://%s/%s", pvc.ClaimName, b.ArchivePath())
+	}
+	return ""
+}
+
+// ValidateStorage checks that exactly one storage is configured
+func (b *PravegaMetadataBackup) ValidateStorage() error {
+	storage := b.Spec.Storage
+	if (storage.PersistentVolumeClaim == nil) == (storage.S3 == nil) {
+		return fmt.Errorf("exactly one of storage.persistentVolumeClaim and storage.s3 must be set")
+	}
+	if storage.PersistentVolumeClaim != nil && storage.PersistentVolumeClaim.ClaimName == "" {
+		return fmt.Errorf("storage.persistentVolumeClaim.claimName must be set")
+	}
+	if storage.S3 != nil && (storage.S3.Bucket == "" || storage.S3.CredentialsSecret == "") {
+		return fmt.Errorf("storage.s3.bucket and storage.s3.credentialsSecret must be set")
+	}
+	return nil
+}
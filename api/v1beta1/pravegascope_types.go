@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&PravegaScope{}, &PravegaScopeList{})
+}
+
+// +kubebuilder:object:root=true
+
+// PravegaScopeList contains a list of PravegaScope
+type PravegaScopeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PravegaScope `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The Pravega cluster the scope belongs to"
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`,description="Whether the scope exists in the cluster"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// PravegaScope is the Schema for the pravegascopes API
+// +k8s:openapi-gen=true
+type PravegaScope struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PravegaScopeSpec   `json:"spec,omitempty"`
+	Status PravegaScopeStatus `json:"status,omitempty"`
+}
+
+// PravegaScopeSpec defines the desired state of PravegaScope
+type PravegaScopeSpec struct {
+	// ClusterName is the name of the PravegaCluster, in the namespace of the scope,
+	// in which the scope is created
+	ClusterName string `json:"clusterName"`
+
+	// ScopeName is the name of the scope in Pravega. Defaults to the name of the resource
+	// +optional
+	ScopeName string `json:"scopeName,omitempty"`
+
+	// CredentialsSecret is the name of the Secret holding the "username" and "password"
+	// used to authenticate to the Controller when authentication is enabled
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// PravegaScopeStatus defines the observed state of PravegaScope
+type PravegaScopeStatus struct {
+	// Ready is true when the scope exists in the cluster
+	Ready bool `json:"ready"`
+
+	// ObservedGeneration is the generation of the spec last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Message explains why the scope is not ready
+	Message string `json:"message,omitempty"`
+}
+
+// GetScopeName returns the name of the scope in Pravega
+func (s *PravegaScope) GetScopeName() string {
+	if s.Spec.ScopeName != "" {
+		return s.Spec.ScopeName
+	}
+	return s.Name
+}
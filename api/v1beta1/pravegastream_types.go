@@ -0,0 +1,210 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&PravegaStream{}, &PravegaStreamList{})
+}
+
+// StreamScalingType is the type of scaling policy of a stream, as named by the Controller REST API
+type StreamScalingType string
+
+const (
+	// StreamScalingFixed keeps a fixed number of segments
+	StreamScalingFixed StreamScalingType = "FIXED_NUM_SEGMENTS"
+	// StreamScalingByKBytes scales the segments on the rate of ingested kilobytes per second
+	StreamScalingByKBytes StreamScalingType = "BY_RATE_IN_KBYTES_PER_SEC"
+	// StreamScalingByEvents scales the segments on the rate of ingested events per second
+	StreamScalingByEvents StreamScalingType = "BY_RATE_IN_EVENTS_PER_SEC"
+)
+
+// StreamRetentionType is the type of retention policy of a stream, as named by the Controller REST API
+type StreamRetentionType string
+
+const (
+	// StreamRetentionDays retains the data of the stream for a number of days
+	StreamRetentionDays StreamRetentionType = "LIMITED_DAYS"
+	// StreamRetentionSizeMB retains up to a number of megabytes of data of the stream
+	StreamRetentionSizeMB StreamRetentionType = "LIMITED_SIZE_MB"
+)
+
+// +kubebuilder:object:root=true
+
+// PravegaStreamList contains a list of PravegaStream
+type PravegaStreamList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PravegaStream `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The Pravega cluster the stream belongs to"
+// +kubebuilder:printcolumn:name="Scope",type=string,JSONPath=`.spec.scopeName`,description="The scope of the stream"
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`,description="Whether the stream matches the spec"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// PravegaStream is the Schema for the pravegastreams API
+// +k8s:openapi-gen=true
+type PravegaStream struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PravegaStreamSpec   `json:"spec,omitempty"`
+	Status PravegaStreamStatus `json:"status,omitempty"`
+}
+
+// PravegaStreamSpec defines the desired state of PravegaStream
+type PravegaStreamSpec struct {
+	// ClusterName is the name of the PravegaCluster, in the namespace of the stream,
+	// in which the stream is created
+	ClusterName string `json:"clusterName"`
+
+	// ScopeName is the name of the Pravega scope of the stream
+	ScopeName string `json:"scopeName"`
+
+	// StreamName is the name of the stream in Pravega. Defaults to the name of the resource
+	// +optional
+	StreamName string `json:"streamName,omitempty"`
+
+	// ScalingPolicy defines how the segments of the stream scale.
+	// Defaults to a single fixed segment
+	// +optional
+	ScalingPolicy *StreamScalingPolicy `json:"scalingPolicy,omitempty"`
+
+	// RetentionPolicy defines how long the data of the stream is retained.
+	// The data is retained forever when not set
+	// +optional
+	RetentionPolicy *StreamRetentionPolicy `json:"retentionPolicy,omitempty"`
+
+	// Tags are the tags of the stream
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// CredentialsSecret is the name of the Secret holding the "username" and "password"
+	// used to authenticate to the Controller when authentication is enabled
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// StreamScalingPolicy is the scaling policy of a stream
+type StreamScalingPolicy struct {
+	// Type is the type of scaling policy
+	// +kubebuilder:validation:Enum=FIXED_NUM_SEGMENTS;BY_RATE_IN_KBYTES_PER_SEC;BY_RATE_IN_EVENTS_PER_SEC
+	Type StreamScalingType `json:"type"`
+
+	// TargetRate is the rate per segment above which a segment is split
+	// +optional
+	TargetRate int32 `json:"targetRate,omitempty"`
+
+	// ScaleFactor is the number of segments a segment is split into
+	// +optional
+	ScaleFactor int32 `json:"scaleFactor,omitempty"`
+
+	// MinSegments is the minimum number of segments of the stream
+	// +kubebuilder:validation:Minimum=1
+	MinSegments int32 `json:"minSegments"`
+}
+
+// StreamRetentionPolicy is the retention policy of a stream
+type StreamRetentionPolicy struct {
+	// Type is the type of retention policy
+	// +kubebuilder:validation:Enum=LIMITED_DAYS;LIMITED_SIZE_MB
+	Type StreamRetentionType `json:"type"`
+
+	// Value is the number of days or megabytes retained
+	// +kubebuilder:validation:Minimum=1
+	Value int64 `json:"value"`
+}
+
+// PravegaStreamStatus defines the observed state of PravegaStream
+type PravegaStreamStatus struct {
+	// Ready is true when the stream exists in the cluster with the configuration of the spec
+	Ready bool `json:"ready"`
+
+	// ObservedGeneration is the generation of the spec last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Message explains why the stream is not ready
+	Message string `json:"message,omitempty"`
+
+	// ScalingPolicy is the scaling policy of the stream reported by the Controller
+	ScalingPolicy *StreamScalingPolicy `json:"scalingPolicy,omitempty"`
+
+	// RetentionPolicy is the retention policy of the stream reported by the Controller
+	RetentionPolicy *StreamRetentionPolicy `json:"retentionPolicy,omitempty"`
+
+	// Tags are the tags of the stream reported by the Controller
+	Tags []string `json:"tags,omitempty"`
+}
+
+// GetStreamName returns the name of the stream in Pravega
+func (s *PravegaStream) GetStreamName() string {
+	if s.Spec.StreamName != "" {
+		return s.Spec.StreamName
+	}
+	return s.Name
+}
+
+// DesiredScalingPolicy returns the scaling policy of the spec, or a single fixed
+// segment when not set
+func (s *PravegaStream) DesiredScalingPolicy() *StreamScalingPolicy {
+	if s.Spec.ScalingPolicy != nil {
+		return s.Spec.ScalingPolicy
+	}
+	return &StreamScalingPolicy{
+		Type:        StreamScalingFixed,
+		MinSegments: 1,
+	}
+}
+
+// MatchesSpec returns whether the given stream configuration, as reported by the
+// Controller, is the one requested by the spec
+func (s *PravegaStream) MatchesSpec(scaling *StreamScalingPolicy, retention *StreamRetentionPolicy, tags []string) bool {
+	desired := s.DesiredScalingPolicy()
+	if scaling == nil || scaling.Type != desired.Type || scaling.MinSegments != desired.MinSegments {
+		return false
+	}
+	// the rate settings are ignored by fixed scaling policies
+	if desired.Type != StreamScalingFixed &&
+		(scaling.TargetRate != desired.TargetRate || scaling.ScaleFactor != desired.ScaleFactor) {
+		return false
+	}
+	if s.Spec.RetentionPolicy == nil {
+		if retention != nil && retention.Value != 0 {
+			return false
+		}
+	} else if retention == nil || *retention != *s.Spec.RetentionPolicy {
+		return false
+	}
+	return equalStringSets(s.Spec.Tags, tags)
+}
+
+func equalStringSets(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa := append([]string(nil), a...)
+	sb := append([]string(nil), b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
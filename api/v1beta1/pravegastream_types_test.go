@@ -0,0 +1,71 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+)
+
+var _ = Describe("PravegaScope and PravegaStream", func() {
+
+	Context("names", func() {
+		It("should default the scope and stream names to the resource names", func() {
+			scope := &v1beta1.PravegaScope{ObjectMeta: metav1.ObjectMeta{Name: "scope"}}
+			Ω(scope.GetScopeName()).To(Equal("scope"))
+			scope.Spec.ScopeName = "other"
+			Ω(scope.GetScopeName()).To(Equal("other"))
+
+			stream := &v1beta1.PravegaStream{ObjectMeta: metav1.ObjectMeta{Name: "stream"}}
+			Ω(stream.GetStreamName()).To(Equal("stream"))
+			stream.Spec.StreamName = "other"
+			Ω(stream.GetStreamName()).To(Equal("other"))
+		})
+	})
+
+	Context("MatchesSpec", func() {
+		var s *v1beta1.PravegaStream
+
+		BeforeEach(func() {
+			s = &v1beta1.PravegaStream{
+				Spec: v1beta1.PravegaStreamSpec{
+					Tags: []string{"a", "b"},
+				},
+			}
+		})
+
+		It("should default to a single fixed segment", func() {
+			fixed := &v1beta1.StreamScalingPolicy{Type: v1beta1.StreamScalingFixed, MinSegments: 1}
+			Ω(s.DesiredScalingPolicy()).To(Equal(fixed))
+			Ω(s.MatchesSpec(fixed, nil, []string{"b", "a"})).To(BeTrue())
+		})
+
+		It("should ignore the rate settings of fixed scaling policies", func() {
+			fixed := &v1beta1.StreamScalingPolicy{Type: v1beta1.StreamScalingFixed, MinSegments: 1, TargetRate: 10}
+			Ω(s.MatchesSpec(fixed, nil, []string{"a", "b"})).To(BeTrue())
+		})
+
+		It("should detect scaling, retention and tag changes", func() {
+			s.Spec.ScalingPolicy = &v1beta1.StreamScalingPolicy{Type: v1beta1.StreamScalingByKBytes, MinSegments: 2, TargetRate: 10, ScaleFactor: 2}
+			s.Spec.RetentionPolicy = &v1beta1.StreamRetentionPolicy{Type: v1beta1.StreamRetentionSizeMB, Value: 100}
+			scaling := *s.Spec.ScalingPolicy
+			retention := *s.Spec.RetentionPolicy
+			Ω(s.MatchesSpec(&scaling, &retention, []string{"a", "b"})).To(BeTrue())
+			Ω(s.MatchesSpec(&scaling, &retention, []string{"a"})).To(BeFalse())
+			Ω(s.MatchesSpec(&scaling, nil, []string{"a", "b"})).To(BeFalse())
+			scaling.TargetRate = 20
+			Ω(s.MatchesSpec(&scaling, &retention, []string{"a", "b"})).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,174 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&PravegaUser{}, &PravegaUserList{})
+}
+
+// AccessPermission is the permission granted on a resource by an ACL entry
+type AccessPermission string
+
+const (
+	AccessPermissionNone       AccessPermission = "NONE"
+	AccessPermissionRead       AccessPermission = "READ"
+	AccessPermissionReadUpdate AccessPermission = "READ_UPDATE"
+)
+
+// +kubebuilder:object:root=true
+
+// PravegaUserList contains a list of PravegaUser
+type PravegaUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PravegaUser `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`,description="The Pravega cluster of the user"
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`,description="Whether the user is in the password file of the cluster"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// PravegaUser is the Schema for the pravegausers API
+// +k8s:openapi-gen=true
+type PravegaUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PravegaUserSpec   `json:"spec,omitempty"`
+	Status PravegaUserStatus `json:"status,omitempty"`
+}
+
+// PravegaUserSpec defines the desired state of PravegaUser
+type PravegaUserSpec struct {
+	// ClusterName is the name of the PravegaCluster, in the namespace of the user,
+	// whose password file contains the user
+	ClusterName string `json:"clusterName"`
+
+	// Username is the name of the user. Defaults to the name of the resource
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// PasswordSecret selects the key of the Secret holding the clear text password
+	// of the user. The password is hashed in the password file
+	PasswordSecret corev1.SecretKeySelector `json:"passwordSecret"`
+
+	// ACL lists the permissions of the user
+	// +optional
+	ACL []AccessControlEntry `json:"acl,omitempty"`
+}
+
+// AccessControlEntry grants a permission on a scope, or on a stream or reader group of a scope
+type AccessControlEntry struct {
+	// Scope is the name of the scope. An empty scope designates the root, on which the
+	// permission to create scopes is granted, and "*" designates all the resources
+	// +optional
+	Scope string `json:"scope,omitempty"`
+
+	// Stream is the name of a stream of the scope, or "*" for all its streams
+	// +optional
+	Stream string `json:"stream,omitempty"`
+
+	// ReaderGroup is the name of a reader group of the scope, or "*" for all its reader groups
+	// +optional
+	ReaderGroup string `json:"readerGroup,omitempty"`
+
+	// Permission is the permission granted on the resource
+	// +kubebuilder:validation:Enum=NONE;READ;READ_UPDATE
+	Permission AccessPermission `json:"permission"`
+}
+
+// PravegaUserStatus defines the observed state of PravegaUser
+type PravegaUserStatus struct {
+	// Ready is true when the user is in the password file of the cluster
+	Ready bool `json:"ready"`
+
+	// ObservedGeneration is the generation of the spec last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Message explains why the user is not ready
+	Message string `json:"message,omitempty"`
+}
+
+// GetUsername returns the name of the user in the password file
+func (u *PravegaUser) GetUsername() string {
+	if u.Spec.Username != "" {
+		return u.Spec.Username
+	}
+	return u.Name
+}
+
+// Resource returns the resource of the entry in the format of the Pravega ACLs
+func (e *AccessControlEntry) Resource() string {
+	if e.Scope == "" {
+		return "prn::/"
+	}
+	if e.Scope == "*" && e.Stream == "" && e.ReaderGroup == "" {
+		return "prn::*"
+	}
+	resource := "prn::/scope:" + e.Scope
+	if e.Stream != "" {
+		resource += "/stream:" + e.Stream
+	} else if e.ReaderGroup != "" {
+		resource += "/reader-group:" + e.ReaderGroup
+	}
+	return resource
+}
+
+// ACLString returns the ACL of the user as written in the password file
+func (u *PravegaUser) ACLString() string {
+	var sb strings.Builder
+	for i := range u.Spec.ACL {
+		sb.WriteString(u.Spec.ACL[i].Resource())
+		sb.WriteString(",")
+		sb.WriteString(string(u.Spec.ACL[i].Permission))
+		sb.WriteString(";")
+	}
+	return sb.String()
+}
+
+// Validate checks that the username and the ACL can be written in the password file
+func (u *PravegaUser) Validate() error {
+	username := u.GetUsername()
+	if username == "" || strings.ContainsAny(username, ": \t\n") {
+		return fmt.Errorf("invalid username %q: it must not be empty or contain colons or whitespace", username)
+	}
+	if u.Spec.PasswordSecret.Name == "" || u.Spec.PasswordSecret.Key == "" {
+		return fmt.Errorf("the name and key of the password secret must be set")
+	}
+	for i, entry := range u.Spec.ACL {
+		for _, name := range []string{entry.Scope, entry.Stream, entry.ReaderGroup} {
+			if strings.ContainsAny(name, ",;:/ \t\n") {
+				return fmt.Errorf("invalid resource name %q in ACL entry %d", name, i)
+			}
+		}
+		if entry.Stream != "" && entry.ReaderGroup != "" {
+			return fmt.Errorf("ACL entry %d must not set both a stream and a reader group", i)
+		}
+		if entry.Scope == "" && (entry.Stream != "" || entry.ReaderGroup != "") {
+			return fmt.Errorf("ACL entry %d must set the scope of the stream or reader group", i)
+		}
+		switch entry.Permission {
+		case AccessPermissionNone, AccessPermissionRead, AccessPermissionReadUpdate:
+		default:
+			return fmt.Errorf("invalid permission %q in ACL entry %d", entry.Permission, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pravega/pravega-operator/api/v1beta1"
+)
+
+var _ = Describe("PravegaUser", func() {
+	var u *v1beta1.PravegaUser
+
+	BeforeEach(func() {
+		u = &v1beta1.PravegaUser{
+			ObjectMeta: metav1.ObjectMeta{Name: "user"},
+			Spec: v1beta1.PravegaUserSpec{
+				PasswordSecret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "passwords"},
+					Key:                  "user",
+				},
+			},
+		}
+	})
+
+	Context("ACLString", func() {
+		It("should render the resources of the entries", func() {
+			u.Spec.ACL = []v1beta1.AccessControlEntry{
+				{Permission: v1beta1.AccessPermissionReadUpdate},
+				{Scope: "*", Permission: v1beta1.AccessPermissionRead},
+				{Scope: "s1", Permission: v1beta1.AccessPermissionReadUpdate},
+				{Scope: "s1", Stream: "st1", Permission: v1beta1.AccessPermissionRead},
+				{Scope: "s1", ReaderGroup: "*", Permission: v1beta1.AccessPermissionNone},
+			}
+			Ω(u.ACLString()).To(Equal("prn::/,READ_UPDATE;prn::*,READ;prn::/scope:s1,READ_UPDATE;" +
+				"prn::/scope:s1/stream:st1,READ;prn::/scope:s1/reader-group:*,NONE;"))
+		})
+	})
+
+	Context("Validate", func() {
+		It("should accept a valid user", func() {
+			u.Spec.ACL = []v1beta1.AccessControlEntry{{Scope: "s1", Permission: v1beta1.AccessPermissionRead}}
+			Ω(u.Validate()).Should(BeNil())
+		})
+
+		It("should reject usernames with colons", func() {
+			u.Spec.Username = "a:b"
+			Ω(u.Validate()).ShouldNot(BeNil())
+		})
+
+		It("should reject entries with a stream and a reader group", func() {
+			u.Spec.ACL = []v1beta1.AccessControlEntry{{Scope: "s1", Stream: "a", ReaderGroup: "b", Permission: v1beta1.AccessPermissionRead}}
+			Ω(u.Validate()).ShouldNot(BeNil())
+		})
+
+		It("should reject resource names breaking the file format", func() {
+			u.Spec.ACL = []v1beta1.AccessControlEntry{{Scope: "s1;s2", Permission: v1beta1.AccessPermissionRead}}
+			Ω(u.Validate()).ShouldNot(BeNil())
+		})
+	})
+})
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ClusterConditionType string
@@ -24,6 +26,7 @@ const (
 	ClusterConditionUpgrading                      = "Upgrading"
 	ClusterConditionRollback                       = "RollbackInProgress"
 	ClusterConditionError                          = "Error"
+	ClusterConditionDegraded                       = "Degraded"
 
 	// Reasons for cluster upgrading condition
 	UpdatingControllerReason   = "Updating Controller"
@@ -31,6 +34,15 @@ const (
 	UpdatingBookkeeperReason   = "Updating Bookkeeper"
 	UpgradeErrorReason         = "Upgrade Error"
 	RollbackErrorReason        = "Rollback Error"
+
+	// Reasons for cluster degraded condition
+	BookiesUnavailableReason = "Bookies Unavailable"
+
+	// Reason of the events of the failed port allocations
+	PortAllocationFailedReason = "Port Allocation Failed"
+
+	// Reason of the events of the controller routes whose API is not installed
+	ControllerRouteUnsupportedReason = "Controller Route Unsupported"
 )
 
 // ClusterStatus defines the observed state of PravegaCluster
@@ -62,6 +74,183 @@ type ClusterStatus struct {
 	// Members is the Pravega members in the cluster
 	// +optional
 	Members MembersStatus `json:"members"`
+
+	// SegmentStorePortAllocations records the ports assigned to the external service
+	// of each Segment Store when all of them share the segmentStoreLoadBalancerIP.
+	// Allocations are kept on scale down, so a Segment Store gets the same ports back
+	// when the cluster is scaled up again.
+	// +optional
+	SegmentStorePortAllocations []SegmentStorePortAllocation `json:"segmentStorePortAllocations,omitempty"`
+
+	// ControllerRoutes lists the Ingress objects and Gateway API routes created to
+	// expose the Controller, so that they can be removed when no longer configured
+	// +optional
+	ControllerRoutes []corev1.TypedLocalObjectReference `json:"controllerRoutes,omitempty"`
+
+	// DryRun is the plan of the changes the operator would make to the cluster.
+	// It is only set while the cluster has the pravega.pravega.io/dry-run annotation
+	// +optional
+	DryRun *ClusterPlan `json:"dryRun,omitempty"`
+
+	// PasswordAuthChecksum is the checksum of the password file aggregated from the
+	// PravegaUser resources. The Controller pods are restarted when it changes
+	// +optional
+	PasswordAuthChecksum string `json:"passwordAuthChecksum,omitempty"`
+
+	// MetadataRestore is the state of the restore of the metadata requested by spec.restoreFrom
+	// +optional
+	MetadataRestore *MetadataRestoreStatus `json:"metadataRestore,omitempty"`
+
+	// Cleanup is the state of the cleanup of the data of the cluster once it is deleted
+	// +optional
+	Cleanup *ClusterCleanupStatus `json:"cleanup,omitempty"`
+
+	// SegmentStoreMemory is the memory settings of the Segment Store JVM, reported when
+	// spec.pravega.segmentStoreMemory.autoSize is enabled
+	// +optional
+	SegmentStoreMemory *SegmentStoreMemoryStatus `json:"segmentStoreMemory,omitempty"`
+
+	// Zookeeper is the state of the ZookeeperCluster referred to by spec.zookeeperRef
+	// +optional
+	Zookeeper *DependencyStatus `json:"zookeeper,omitempty"`
+
+	// Bookkeeper is the state of the BookkeeperCluster referred to by spec.bookkeeperRef
+	// +optional
+	Bookkeeper *DependencyStatus `json:"bookkeeper,omitempty"`
+
+	// ImmutableSettings are the settings which cannot be changed once the cluster is
+	// deployed, recorded when the operator first deploys it
+	// +optional
+	ImmutableSettings *ImmutableSettings `json:"immutableSettings,omitempty"`
+}
+
+// ImmutableSettings are the settings of a deployed cluster which cannot be changed without
+// losing its data
+type ImmutableSettings struct {
+	// ControllerOptions are the values of the immutable options passed to the Controller,
+	// under their current name. The options which were not set are not recorded
+	// +optional
+	ControllerOptions map[string]string `json:"controllerOptions,omitempty"`
+
+	// SegmentStoreOptions are the values of the immutable options passed to the Segment
+	// Store, under their current name. The options which were not set are not recorded
+	// +optional
+	SegmentStoreOptions map[string]string `json:"segmentStoreOptions,omitempty"`
+
+	// LongTermStorage is the kind of the long term storage: filesystem, ecs, hdfs or custom
+	// +optional
+	LongTermStorage string `json:"longTermStorage,omitempty"`
+}
+
+// DependencyStatus is the state of a ZookeeperCluster or BookkeeperCluster the Pravega
+// cluster depends on
+type DependencyStatus struct {
+	// Name of the custom resource
+	Name string `json:"name"`
+
+	// Namespace of the custom resource
+	Namespace string `json:"namespace"`
+
+	// URI is the connection string read from the custom resource
+	// +optional
+	URI string `json:"uri,omitempty"`
+
+	// Version is the current version of the cluster
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Ready is true when all the pods of the cluster are ready
+	Ready bool `json:"ready"`
+
+	// Upgrading is true while the cluster is being upgraded
+	// +optional
+	Upgrading bool `json:"upgrading,omitempty"`
+
+	// Message explains why the cluster is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+func (d *DependencyStatus) connectionURI() string {
+	if d == nil {
+		return ""
+	}
+	return d.URI
+}
+
+// SegmentStoreMemoryStatus is the memory settings of the Segment Store JVM, either set by the
+// user or derived from the memory limit
+type SegmentStoreMemoryStatus struct {
+	// Heap is the maximum size of the JVM heap (-Xmx)
+	Heap resource.Quantity `json:"heap"`
+
+	// DirectMemory is the maximum size of the JVM direct memory (-XX:MaxDirectMemorySize)
+	DirectMemory resource.Quantity `json:"directMemory"`
+
+	// CacheSize is the maximum size of the cache (pravegaservice.cache.size.max)
+	CacheSize resource.Quantity `json:"cacheSize"`
+}
+
+// ClusterCleanupPhase is the phase of the cleanup of the data of a deleted cluster
+type ClusterCleanupPhase string
+
+const (
+	// ClusterCleanupWaitingForPods is the phase until the pods of the cluster terminated
+	ClusterCleanupWaitingForPods ClusterCleanupPhase = "WaitingForPods"
+	// ClusterCleanupInProgress is the phase while the cleanup waits for a snapshot or a job
+	ClusterCleanupInProgress ClusterCleanupPhase = "InProgress"
+	// ClusterCleanupFailed is the phase after a failed attempt, until the next one
+	ClusterCleanupFailed ClusterCleanupPhase = "Failed"
+)
+
+// ClusterCleanupStatus is the state of the cleanup of the data of a deleted cluster.
+// The finalizer of the cluster is kept until the cleanup succeeds
+type ClusterCleanupStatus struct {
+	// Phase is the phase of the cleanup
+	Phase ClusterCleanupPhase `json:"phase,omitempty"`
+
+	// Attempts is the number of times the cleanup was run
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// Failures is the number of consecutive failed attempts
+	Failures int32 `json:"failures,omitempty"`
+
+	// LastError is the error of the last failed attempt
+	LastError string `json:"lastError,omitempty"`
+
+	// LastAttemptTime is when the cleanup was last run
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+
+	// NextAttemptTime is when the cleanup will be retried after a failure
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+}
+
+// ClusterPlan describes what applying the current spec would do to the cluster
+type ClusterPlan struct {
+	// ObservedGeneration is the generation of the spec the plan was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ComputedTime is the last time the plan was computed
+	ComputedTime string `json:"computedTime,omitempty"`
+
+	// PodRestarts is the number of pods that would be restarted
+	PodRestarts int32 `json:"podRestarts"`
+
+	// Changes lists the changes that would be made to the cluster objects
+	// +optional
+	Changes []string `json:"changes,omitempty"`
+
+	// Rejections lists the reasons why the spec would be rejected
+	// +optional
+	Rejections []string `json:"rejections,omitempty"`
+}
+
+// SegmentStorePortAllocation is the pair of ports assigned to the external service
+// of the Segment Store with the given ordinal
+type SegmentStorePortAllocation struct {
+	Ordinal     int32 `json:"ordinal"`
+	ServicePort int32 `json:"servicePort"`
+	AdminPort   int32 `json:"adminPort"`
 }
 
 // MembersStatus is the status of the members of the cluster with both
@@ -159,6 +348,16 @@ func (ps *ClusterStatus) SetRollbackConditionFalse() {
 	ps.setClusterCondition(*c)
 }
 
+func (ps *ClusterStatus) SetDegradedConditionTrue(reason, message string) {
+	c := newClusterCondition(ClusterConditionDegraded, corev1.ConditionTrue, reason, message)
+	ps.setClusterCondition(*c)
+}
+
+func (ps *ClusterStatus) SetDegradedConditionFalse() {
+	c := newClusterCondition(ClusterConditionDegraded, corev1.ConditionFalse, "", "")
+	ps.setClusterCondition(*c)
+}
+
 func newClusterCondition(condType ClusterConditionType, status corev1.ConditionStatus, reason, message string) *ClusterCondition {
 	return &ClusterCondition{
 		Type:               condType,
@@ -216,6 +415,33 @@ func (ps *ClusterStatus) GetLastVersion() (previousVersion string) {
 	return ps.VersionHistory[len-1]
 }
 
+func (ps *ClusterStatus) IsClusterDegraded() bool {
+	_, degradedCondition := ps.GetClusterCondition(ClusterConditionDegraded)
+	return degradedCondition != nil && degradedCondition.Status == corev1.ConditionTrue
+}
+
+// AreDependenciesReady returns true unless the ZookeeperCluster or the BookkeeperCluster the
+// cluster refers to is not ready
+func (ps *ClusterStatus) AreDependenciesReady() bool {
+	for _, d := range []*DependencyStatus{ps.Zookeeper, ps.Bookkeeper} {
+		if d != nil && !d.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// AreDependenciesUpgrading returns true while the ZookeeperCluster or the BookkeeperCluster
+// the cluster refers to is being upgraded
+func (ps *ClusterStatus) AreDependenciesUpgrading() bool {
+	for _, d := range []*DependencyStatus{ps.Zookeeper, ps.Bookkeeper} {
+		if d != nil && d.Upgrading {
+			return true
+		}
+	}
+	return false
+}
+
 func (ps *ClusterStatus) IsClusterInErrorState() bool {
 	_, errorCondition := ps.GetClusterCondition(ClusterConditionError)
 	if errorCondition != nil && errorCondition.Status == corev1.ConditionTrue {
@@ -303,3 +529,12 @@ func (ps *ClusterStatus) GetLastCondition() (lastCondition *ClusterCondition) {
 	// nothing to do if we are neither upgrading nor rolling back,
 	return nil
 }
+
+func (ps *ClusterStatus) GetSegmentStorePortAllocation(ordinal int32) *SegmentStorePortAllocation {
+	for i := range ps.SegmentStorePortAllocations {
+		if ps.SegmentStorePortAllocations[i].Ordinal == ordinal {
+			return &ps.SegmentStorePortAllocations[i]
+		}
+	}
+	return nil
+}
@@ -153,6 +153,23 @@ var _ = Describe("PravegaCluster Status", func() {
 				Ω(condition.LastTransitionTime).NotTo(Equal(""))
 			})
 		})
+		Context("set degraded condition", func() {
+			BeforeEach(func() {
+				p.Status.SetDegradedConditionTrue(v1beta1.BookiesUnavailableReason, "2 of 3 bookies are ready")
+			})
+			It("should have degraded condition with true status and reason", func() {
+				_, condition := p.Status.GetClusterCondition(v1beta1.ClusterConditionDegraded)
+				Ω(condition.Status).To(Equal(corev1.ConditionTrue))
+				Ω(condition.Reason).To(Equal(v1beta1.BookiesUnavailableReason))
+				Ω(p.Status.IsClusterDegraded()).To(Equal(true))
+			})
+			It("should not be degraded once the condition is false", func() {
+				p.Status.SetDegradedConditionFalse()
+				_, condition := p.Status.GetClusterCondition(v1beta1.ClusterConditionDegraded)
+				Ω(condition.Message).To(Equal(""))
+				Ω(p.Status.IsClusterDegraded()).To(Equal(false))
+			})
+		})
 		Context("set conditions for upgrade", func() {
 			Context("set pods upgrade condition to be true", func() {
 				BeforeEach(func() {
@@ -10,6 +10,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlEntry) DeepCopyInto(out *AccessControlEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlEntry.
+func (in *AccessControlEntry) DeepCopy() *AccessControlEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthHandlerSpec) DeepCopyInto(out *AuthHandlerSpec) {
 	*out = *in
@@ -60,6 +75,29 @@ func (in *AuthenticationParameters) DeepCopy() *AuthenticationParameters {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCleanupStatus) DeepCopyInto(out *ClusterCleanupStatus) {
+	*out = *in
+	if in.LastAttemptTime != nil {
+		in, out := &in.LastAttemptTime, &out.LastAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCleanupStatus.
+func (in *ClusterCleanupStatus) DeepCopy() *ClusterCleanupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCleanupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterCondition) DeepCopyInto(out *ClusterCondition) {
 	*out = *in
@@ -75,13 +113,63 @@ func (in *ClusterCondition) DeepCopy() *ClusterCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPlan) DeepCopyInto(out *ClusterPlan) {
+	*out = *in
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rejections != nil {
+		in, out := &in.Rejections, &out.Rejections
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPlan.
+func (in *ClusterPlan) DeepCopy() *ClusterPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterReference) DeepCopyInto(out *ClusterReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterReference.
+func (in *ClusterReference) DeepCopy() *ClusterReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 	*out = *in
+	if in.ZookeeperRef != nil {
+		in, out := &in.ZookeeperRef, &out.ZookeeperRef
+		*out = new(ClusterReference)
+		**out = **in
+	}
+	if in.Zookeeper != nil {
+		in, out := &in.Zookeeper, &out.Zookeeper
+		*out = new(ZookeeperSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExternalAccess != nil {
 		in, out := &in.ExternalAccess, &out.ExternalAccess
 		*out = new(ExternalAccess)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
@@ -93,11 +181,31 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(AuthenticationParameters)
 		**out = **in
 	}
+	if in.BookkeeperRef != nil {
+		in, out := &in.BookkeeperRef, &out.BookkeeperRef
+		*out = new(ClusterReference)
+		**out = **in
+	}
+	if in.ReservedPortList != nil {
+		in, out := &in.ReservedPortList, &out.ReservedPortList
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 	if in.Pravega != nil {
 		in, out := &in.Pravega, &out.Pravega
 		*out = new(PravegaSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RestoreFrom != nil {
+		in, out := &in.RestoreFrom, &out.RestoreFrom
+		*out = new(MetadataRestoreSpec)
+		**out = **in
+	}
+	if in.DeletionSnapshot != nil {
+		in, out := &in.DeletionSnapshot, &out.DeletionSnapshot
+		*out = new(MetadataBackupStorage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSpec.
@@ -124,6 +232,53 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		copy(*out, *in)
 	}
 	in.Members.DeepCopyInto(&out.Members)
+	if in.SegmentStorePortAllocations != nil {
+		in, out := &in.SegmentStorePortAllocations, &out.SegmentStorePortAllocations
+		*out = make([]SegmentStorePortAllocation, len(*in))
+		copy(*out, *in)
+	}
+	if in.ControllerRoutes != nil {
+		in, out := &in.ControllerRoutes, &out.ControllerRoutes
+		*out = make([]v1.TypedLocalObjectReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(ClusterPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MetadataRestore != nil {
+		in, out := &in.MetadataRestore, &out.MetadataRestore
+		*out = new(MetadataRestoreStatus)
+		**out = **in
+	}
+	if in.Cleanup != nil {
+		in, out := &in.Cleanup, &out.Cleanup
+		*out = new(ClusterCleanupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SegmentStoreMemory != nil {
+		in, out := &in.SegmentStoreMemory, &out.SegmentStoreMemory
+		*out = new(SegmentStoreMemoryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Zookeeper != nil {
+		in, out := &in.Zookeeper, &out.Zookeeper
+		*out = new(DependencyStatus)
+		**out = **in
+	}
+	if in.Bookkeeper != nil {
+		in, out := &in.Bookkeeper, &out.Bookkeeper
+		*out = new(DependencyStatus)
+		**out = **in
+	}
+	if in.ImmutableSettings != nil {
+		in, out := &in.ImmutableSettings, &out.ImmutableSettings
+		*out = new(ImmutableSettings)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterStatus.
@@ -136,6 +291,33 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerRouteSpec) DeepCopyInto(out *ControllerRouteSpec) {
+	*out = *in
+	if in.IngressClassName != nil {
+		in, out := &in.IngressClassName, &out.IngressClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerRouteSpec.
+func (in *ControllerRouteSpec) DeepCopy() *ControllerRouteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerRouteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CustomSpec) DeepCopyInto(out *CustomSpec) {
 	*out = *in
@@ -165,6 +347,21 @@ func (in *CustomSpec) DeepCopy() *CustomSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyStatus) DeepCopyInto(out *DependencyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyStatus.
+func (in *DependencyStatus) DeepCopy() *DependencyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECSSpec) DeepCopyInto(out *ECSSpec) {
 	*out = *in
@@ -183,6 +380,11 @@ func (in *ECSSpec) DeepCopy() *ECSSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalAccess) DeepCopyInto(out *ExternalAccess) {
 	*out = *in
+	if in.ControllerRoute != nil {
+		in, out := &in.ControllerRoute, &out.ControllerRoute
+		*out = new(ControllerRouteSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalAccess.
@@ -245,6 +447,35 @@ func (in *ImageSpec) DeepCopy() *ImageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImmutableSettings) DeepCopyInto(out *ImmutableSettings) {
+	*out = *in
+	if in.ControllerOptions != nil {
+		in, out := &in.ControllerOptions, &out.ControllerOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SegmentStoreOptions != nil {
+		in, out := &in.SegmentStoreOptions, &out.SegmentStoreOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImmutableSettings.
+func (in *ImmutableSettings) DeepCopy() *ImmutableSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ImmutableSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InfluxDBSecret) DeepCopyInto(out *InfluxDBSecret) {
 	*out = *in
@@ -320,6 +551,130 @@ func (in *MembersStatus) DeepCopy() *MembersStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataBackupPVC) DeepCopyInto(out *MetadataBackupPVC) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataBackupPVC.
+func (in *MetadataBackupPVC) DeepCopy() *MetadataBackupPVC {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataBackupPVC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataBackupS3) DeepCopyInto(out *MetadataBackupS3) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataBackupS3.
+func (in *MetadataBackupS3) DeepCopy() *MetadataBackupS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataBackupS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataBackupSpec) DeepCopyInto(out *MetadataBackupSpec) {
+	*out = *in
+	in.Storage.DeepCopyInto(&out.Storage)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataBackupSpec.
+func (in *MetadataBackupSpec) DeepCopy() *MetadataBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataBackupStatus) DeepCopyInto(out *MetadataBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataBackupStatus.
+func (in *MetadataBackupStatus) DeepCopy() *MetadataBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataBackupStorage) DeepCopyInto(out *MetadataBackupStorage) {
+	*out = *in
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(MetadataBackupPVC)
+		**out = **in
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(MetadataBackupS3)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataBackupStorage.
+func (in *MetadataBackupStorage) DeepCopy() *MetadataBackupStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataBackupStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataRestoreSpec) DeepCopyInto(out *MetadataRestoreSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataRestoreSpec.
+func (in *MetadataRestoreSpec) DeepCopy() *MetadataRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataRestoreStatus) DeepCopyInto(out *MetadataRestoreStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataRestoreStatus.
+func (in *MetadataRestoreStatus) DeepCopy() *MetadataRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PravegaCluster) DeepCopyInto(out *PravegaCluster) {
 	*out = *in
@@ -380,27 +735,189 @@ func (in *PravegaClusterList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PravegaSpec) DeepCopyInto(out *PravegaSpec) {
+func (in *PravegaMetadataBackup) DeepCopyInto(out *PravegaMetadataBackup) {
 	*out = *in
-	if in.Image != nil {
-		in, out := &in.Image, &out.Image
-		*out = new(ImageSpec)
-		**out = **in
-	}
-	if in.Options != nil {
-		in, out := &in.Options, &out.Options
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaMetadataBackup.
+func (in *PravegaMetadataBackup) DeepCopy() *PravegaMetadataBackup {
+	if in == nil {
+		return nil
 	}
-	if in.ControllerJvmOptions != nil {
-		in, out := &in.ControllerJvmOptions, &out.ControllerJvmOptions
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	out := new(PravegaMetadataBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaMetadataBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	if in.SegmentStoreJVMOptions != nil {
-		in, out := &in.SegmentStoreJVMOptions, &out.SegmentStoreJVMOptions
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaMetadataBackupList) DeepCopyInto(out *PravegaMetadataBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PravegaMetadataBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaMetadataBackupList.
+func (in *PravegaMetadataBackupList) DeepCopy() *PravegaMetadataBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaMetadataBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaMetadataBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaScope) DeepCopyInto(out *PravegaScope) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaScope.
+func (in *PravegaScope) DeepCopy() *PravegaScope {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaScope) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaScopeList) DeepCopyInto(out *PravegaScopeList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PravegaScope, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaScopeList.
+func (in *PravegaScopeList) DeepCopy() *PravegaScopeList {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaScopeList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaScopeList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaScopeSpec) DeepCopyInto(out *PravegaScopeSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaScopeSpec.
+func (in *PravegaScopeSpec) DeepCopy() *PravegaScopeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaScopeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaScopeStatus) DeepCopyInto(out *PravegaScopeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaScopeStatus.
+func (in *PravegaScopeStatus) DeepCopy() *PravegaScopeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaScopeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaSpec) DeepCopyInto(out *PravegaSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageSpec)
+		**out = **in
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ControllerOptions != nil {
+		in, out := &in.ControllerOptions, &out.ControllerOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SegmentStoreOptions != nil {
+		in, out := &in.SegmentStoreOptions, &out.SegmentStoreOptions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ControllerJvmOptions != nil {
+		in, out := &in.ControllerJvmOptions, &out.ControllerJvmOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SegmentStoreJVMOptions != nil {
+		in, out := &in.SegmentStoreJVMOptions, &out.SegmentStoreJVMOptions
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
@@ -424,6 +941,25 @@ func (in *PravegaSpec) DeepCopyInto(out *PravegaSpec) {
 		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SegmentStoreMemory != nil {
+		in, out := &in.SegmentStoreMemory, &out.SegmentStoreMemory
+		*out = new(SegmentStoreMemorySpec)
+		**out = **in
+	}
+	if in.SegmentStoreContainerEnv != nil {
+		in, out := &in.SegmentStoreContainerEnv, &out.SegmentStoreContainerEnv
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ControllerEnvVars != nil {
+		in, out := &in.ControllerEnvVars, &out.ControllerEnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.SegmentStoreSecret != nil {
 		in, out := &in.SegmentStoreSecret, &out.SegmentStoreSecret
 		*out = new(SegmentStoreSecret)
@@ -539,6 +1075,48 @@ func (in *PravegaSpec) DeepCopyInto(out *PravegaSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ControllerTopologySpreadConstraints != nil {
+		in, out := &in.ControllerTopologySpreadConstraints, &out.ControllerTopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SegmentStoreTopologySpreadConstraints != nil {
+		in, out := &in.SegmentStoreTopologySpreadConstraints, &out.SegmentStoreTopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ControllerNodeSelector != nil {
+		in, out := &in.ControllerNodeSelector, &out.ControllerNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SegmentStoreNodeSelector != nil {
+		in, out := &in.SegmentStoreNodeSelector, &out.SegmentStoreNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SegmentStoreContainers != nil {
+		in, out := &in.SegmentStoreContainers, &out.SegmentStoreContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SegmentStoreAdditionalVolumes != nil {
+		in, out := &in.SegmentStoreAdditionalVolumes, &out.SegmentStoreAdditionalVolumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaSpec.
@@ -551,6 +1129,220 @@ func (in *PravegaSpec) DeepCopy() *PravegaSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaStream) DeepCopyInto(out *PravegaStream) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaStream.
+func (in *PravegaStream) DeepCopy() *PravegaStream {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaStream)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaStream) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaStreamList) DeepCopyInto(out *PravegaStreamList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PravegaStream, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaStreamList.
+func (in *PravegaStreamList) DeepCopy() *PravegaStreamList {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaStreamList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaStreamList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaStreamSpec) DeepCopyInto(out *PravegaStreamSpec) {
+	*out = *in
+	if in.ScalingPolicy != nil {
+		in, out := &in.ScalingPolicy, &out.ScalingPolicy
+		*out = new(StreamScalingPolicy)
+		**out = **in
+	}
+	if in.RetentionPolicy != nil {
+		in, out := &in.RetentionPolicy, &out.RetentionPolicy
+		*out = new(StreamRetentionPolicy)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaStreamSpec.
+func (in *PravegaStreamSpec) DeepCopy() *PravegaStreamSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaStreamSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaStreamStatus) DeepCopyInto(out *PravegaStreamStatus) {
+	*out = *in
+	if in.ScalingPolicy != nil {
+		in, out := &in.ScalingPolicy, &out.ScalingPolicy
+		*out = new(StreamScalingPolicy)
+		**out = **in
+	}
+	if in.RetentionPolicy != nil {
+		in, out := &in.RetentionPolicy, &out.RetentionPolicy
+		*out = new(StreamRetentionPolicy)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaStreamStatus.
+func (in *PravegaStreamStatus) DeepCopy() *PravegaStreamStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaStreamStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaUser) DeepCopyInto(out *PravegaUser) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaUser.
+func (in *PravegaUser) DeepCopy() *PravegaUser {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaUser) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaUserList) DeepCopyInto(out *PravegaUserList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PravegaUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaUserList.
+func (in *PravegaUserList) DeepCopy() *PravegaUserList {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaUserList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PravegaUserList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaUserSpec) DeepCopyInto(out *PravegaUserSpec) {
+	*out = *in
+	in.PasswordSecret.DeepCopyInto(&out.PasswordSecret)
+	if in.ACL != nil {
+		in, out := &in.ACL, &out.ACL
+		*out = make([]AccessControlEntry, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaUserSpec.
+func (in *PravegaUserSpec) DeepCopy() *PravegaUserSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaUserSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PravegaUserStatus) DeepCopyInto(out *PravegaUserStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PravegaUserStatus.
+func (in *PravegaUserStatus) DeepCopy() *PravegaUserStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PravegaUserStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Probe) DeepCopyInto(out *Probe) {
 	*out = *in
@@ -591,6 +1383,54 @@ func (in *Probes) DeepCopy() *Probes {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SegmentStoreMemorySpec) DeepCopyInto(out *SegmentStoreMemorySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SegmentStoreMemorySpec.
+func (in *SegmentStoreMemorySpec) DeepCopy() *SegmentStoreMemorySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SegmentStoreMemorySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SegmentStoreMemoryStatus) DeepCopyInto(out *SegmentStoreMemoryStatus) {
+	*out = *in
+	out.Heap = in.Heap.DeepCopy()
+	out.DirectMemory = in.DirectMemory.DeepCopy()
+	out.CacheSize = in.CacheSize.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SegmentStoreMemoryStatus.
+func (in *SegmentStoreMemoryStatus) DeepCopy() *SegmentStoreMemoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SegmentStoreMemoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SegmentStorePortAllocation) DeepCopyInto(out *SegmentStorePortAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SegmentStorePortAllocation.
+func (in *SegmentStorePortAllocation) DeepCopy() *SegmentStorePortAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(SegmentStorePortAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SegmentStoreSecret) DeepCopyInto(out *SegmentStoreSecret) {
 	*out = *in
@@ -621,6 +1461,36 @@ func (in *StaticTLS) DeepCopy() *StaticTLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamRetentionPolicy) DeepCopyInto(out *StreamRetentionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamRetentionPolicy.
+func (in *StreamRetentionPolicy) DeepCopy() *StreamRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamScalingPolicy) DeepCopyInto(out *StreamScalingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamScalingPolicy.
+func (in *StreamScalingPolicy) DeepCopy() *StreamScalingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamScalingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSPolicy) DeepCopyInto(out *TLSPolicy) {
 	*out = *in
@@ -640,3 +1510,23 @@ func (in *TLSPolicy) DeepCopy() *TLSPolicy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZookeeperSpec) DeepCopyInto(out *ZookeeperSpec) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZookeeperSpec.
+func (in *ZookeeperSpec) DeepCopy() *ZookeeperSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ZookeeperSpec)
+	in.DeepCopyInto(out)
+	return out
+}
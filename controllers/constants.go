@@ -29,4 +29,8 @@ const (
 	controllerAuthMountDir   = "/etc/controller-auth-volume"
 	ssAuthMountDir           = "/etc/ss-auth-volume"
 	influxDBSecretVolumeName = "influxdb-secret"
+	zkTLSVolumeName          = "zookeeper-tls"
+	zkTLSMountDir            = "/etc/zookeeper-tls"
+	zkAuthVolumeName         = "zookeeper-auth"
+	zkAuthMountDir           = "/etc/zookeeper-auth"
 )
@@ -62,80 +62,7 @@ func MakeControllerPodTemplate(p *api.PravegaCluster) corev1.PodTemplateSpec {
 }
 
 func makeControllerPodSpec(p *api.PravegaCluster) *corev1.PodSpec {
-	// Parse volumes & volumeMounts parameters first
-	var hostPathVolumeMounts []string
-	var emptyDirVolumeMounts []string
-	var configMapVolumeMounts []string
-	var ok bool
-
-	var volumes []corev1.Volume
-	var volumeMounts []corev1.VolumeMount
-
-	if _, ok = p.Spec.Pravega.Options["hostPathVolumeMounts"]; ok {
-		hostPathVolumeMounts = strings.Split(p.Spec.Pravega.Options["hostPathVolumeMounts"], ",")
-		for _, vm := range hostPathVolumeMounts {
-			s := strings.Split(vm, "=")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					HostPath: &corev1.HostPathVolumeSource{
-						Path: s[1],
-					},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
-	if _, ok = p.Spec.Pravega.Options["emptyDirVolumeMounts"]; ok {
-		emptyDirVolumeMounts = strings.Split(p.Spec.Pravega.Options["emptyDirVolumeMounts"], ",")
-		for _, vm := range emptyDirVolumeMounts {
-			s := strings.Split(vm, "=")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
-	if _, ok = p.Spec.Pravega.Options["configMapVolumeMounts"]; ok {
-		configMapVolumeMounts = strings.Split(p.Spec.Pravega.Options["configMapVolumeMounts"], ",")
-		for _, vm := range configMapVolumeMounts {
-			p := strings.Split(vm, "=")
-			s := strings.Split(p[0], ":")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: s[0],
-						},
-					},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: p[1],
-				SubPath:   s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
+	volumes, volumeMounts := optionVolumes(p.Spec.Pravega.ControllerOption)
 
 	podSpec := &corev1.PodSpec{
 		Containers: []corev1.Container{
@@ -196,9 +123,12 @@ func makeControllerPodSpec(p *api.PravegaCluster) *corev1.PodSpec {
 				},
 			},
 		},
-		Affinity:    p.Spec.Pravega.ControllerPodAffinity,
-		Volumes:     volumes,
-		Tolerations: p.Spec.Pravega.ControllerPodTolerations,
+		Affinity:                  p.Spec.Pravega.ControllerPodAffinity,
+		Volumes:                   volumes,
+		Tolerations:               p.Spec.Pravega.ControllerPodTolerations,
+		TopologySpreadConstraints: p.Spec.Pravega.ControllerTopologySpreadConstraints,
+		PriorityClassName:         p.Spec.Pravega.ControllerPriorityClassName,
+		NodeSelector:              p.Spec.Pravega.ControllerNodeSelector,
 	}
 	if p.Spec.Pravega.ControllerServiceAccountName != "" {
 		podSpec.ServiceAccountName = p.Spec.Pravega.ControllerServiceAccountName
@@ -258,6 +188,7 @@ func makeControllerPodSpec(p *api.PravegaCluster) *corev1.PodSpec {
 	configureAuthSecrets(podSpec, p)
 	configureControllerAuthSecrets(podSpec, p)
 	configureControllerInfluxDBSecrets(podSpec, p)
+	configureZookeeperSecrets(podSpec, p)
 	return podSpec
 }
 
@@ -316,10 +247,11 @@ func MakeControllerConfigMap(p *api.PravegaCluster) *corev1.ConfigMap {
 	javaOpts := []string{
 		"-Dpravegaservice.clusterName=" + p.Name,
 	}
+	javaOpts = append(javaOpts, zookeeperJVMOptions(p)...)
 
 	javaOpts = util.OverrideDefaultJVMOptions(javaOpts, p.Spec.Pravega.ControllerJvmOptions)
 
-	for name, value := range p.Spec.Pravega.Options {
+	for name, value := range pravegaOptions(p, api.OptionComponentController) {
 		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, value))
 	}
 
@@ -328,14 +260,14 @@ func MakeControllerConfigMap(p *api.PravegaCluster) *corev1.ConfigMap {
 	authEnabledStr := fmt.Sprint(p.Spec.Authentication.IsEnabled())
 	configData := map[string]string{
 		"CLUSTER_NAME":           p.Name,
-		"ZK_URL":                 p.Spec.ZookeeperUri,
+		"ZK_URL":                 p.ZookeeperConnectString(),
 		"JAVA_OPTS":              strings.Join(javaOpts, " "),
 		"REST_SERVER_PORT":       "10080",
 		"CONTROLLER_SERVER_PORT": "9090",
 		"AUTHORIZATION_ENABLED":  authEnabledStr,
 		"TOKEN_SIGNING_KEY":      defaultTokenSigningKey,
 		"TLS_ENABLED":            "false",
-		"WAIT_FOR":               p.Spec.ZookeeperUri,
+		"WAIT_FOR":               strings.Join(p.ZookeeperServers(), ","),
 	}
 
 	if p.Spec.Pravega.DebugLogging {
@@ -357,6 +289,10 @@ func MakeControllerConfigMap(p *api.PravegaCluster) *corev1.ConfigMap {
 }
 
 func getControllerServiceType(pravegaCluster *api.PravegaCluster) (serviceType corev1.ServiceType) {
+	// Routes reach the Controller through the cluster IP of the service
+	if pravegaCluster.Spec.ExternalAccess.IsControllerRouteEnabled() {
+		return corev1.ServiceTypeClusterIP
+	}
 	if pravegaCluster.Spec.Pravega.ControllerExternalServiceType == "" {
 		if pravegaCluster.Spec.ExternalAccess.Type == "" {
 			return api.DefaultServiceType
@@ -0,0 +1,207 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	controllerGrpcPort = 9090
+	controllerRestPort = 10080
+
+	gatewayAPIGroup = "gateway.networking.k8s.io"
+
+	ingressBackendProtocolAnnotationKey = "nginx.ingress.kubernetes.io/backend-protocol"
+	ingressSSLPassthroughAnnotationKey  = "nginx.ingress.kubernetes.io/ssl-passthrough"
+)
+
+// controllerRouteKinds maps the kinds of objects generated to route traffic to the
+// Controller to their group version
+var controllerRouteKinds = map[string]schema.GroupVersion{
+	"Ingress":   networkingv1.SchemeGroupVersion,
+	"GRPCRoute": {Group: gatewayAPIGroup, Version: "v1"},
+	"HTTPRoute": {Group: gatewayAPIGroup, Version: "v1"},
+	"TLSRoute":  {Group: gatewayAPIGroup, Version: "v1alpha2"},
+}
+
+// controllerEndpoint is a Controller port exposed through a route
+type controllerEndpoint struct {
+	name     string
+	hostname string
+	port     int32
+}
+
+func controllerRouteEndpoints(p *api.PravegaCluster) []controllerEndpoint {
+	route := p.Spec.ExternalAccess.ControllerRoute
+	endpoints := []controllerEndpoint{
+		{name: "grpc", hostname: route.GrpcHostname, port: controllerGrpcPort},
+	}
+	if route.RestHostname != "" {
+		endpoints = append(endpoints, controllerEndpoint{name: "rest", hostname: route.RestHostname, port: controllerRestPort})
+	}
+	return endpoints
+}
+
+// MakeControllerRoutes returns the Ingress objects or Gateway API routes exposing
+// the Controller service, depending on the configured route type
+func MakeControllerRoutes(p *api.PravegaCluster) []client.Object {
+	if !p.Spec.ExternalAccess.IsControllerRouteEnabled() {
+		return nil
+	}
+	var routes []client.Object
+	for _, endpoint := range controllerRouteEndpoints(p) {
+		if p.Spec.ExternalAccess.ControllerRoute.Type == api.ControllerRouteIngress {
+			routes = append(routes, makeControllerIngress(p, endpoint))
+		} else {
+			routes = append(routes, makeControllerGatewayRoute(p, endpoint))
+		}
+	}
+	return routes
+}
+
+func makeControllerIngress(p *api.PravegaCluster, endpoint controllerEndpoint) *networkingv1.Ingress {
+	route := p.Spec.ExternalAccess.ControllerRoute
+	annotations := cloneMap(route.Annotations)
+	if p.Spec.TLS.IsSecureController() {
+		if _, ok := annotations[ingressSSLPassthroughAnnotationKey]; !ok {
+			annotations[ingressSSLPassthroughAnnotationKey] = "true"
+		}
+	} else if endpoint.port == controllerGrpcPort {
+		if _, ok := annotations[ingressBackendProtocolAnnotationKey]; !ok {
+			annotations[ingressBackendProtocolAnnotationKey] = "GRPC"
+		}
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	return &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.RouteNameForController(endpoint.name),
+			Namespace:   p.Namespace,
+			Labels:      p.LabelsForController(),
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: route.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: endpoint.hostname,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: p.ServiceNameForController(),
+											Port: networkingv1.ServiceBackendPort{
+												Number: endpoint.port,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeControllerGatewayRoute returns a GRPCRoute or HTTPRoute for the endpoint, or a
+// TLSRoute when Controller TLS is enabled so that the gateway passes TLS through.
+// Gateway API types are not vendored, so routes are built as unstructured objects
+func makeControllerGatewayRoute(p *api.PravegaCluster, endpoint controllerEndpoint) *unstructured.Unstructured {
+	route := p.Spec.ExternalAccess.ControllerRoute
+	kind := "HTTPRoute"
+	if p.Spec.TLS.IsSecureController() {
+		kind = "TLSRoute"
+	} else if endpoint.port == controllerGrpcPort {
+		kind = "GRPCRoute"
+	}
+
+	parentRef := map[string]interface{}{
+		"name": route.GatewayName,
+	}
+	if route.GatewayNamespace != "" {
+		parentRef["namespace"] = route.GatewayNamespace
+	}
+	if route.GatewaySectionName != "" {
+		parentRef["sectionName"] = route.GatewaySectionName
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(controllerRouteKinds[kind].WithKind(kind))
+	u.SetName(p.RouteNameForController(endpoint.name))
+	u.SetNamespace(p.Namespace)
+	u.SetLabels(p.LabelsForController())
+	if len(route.Annotations) > 0 {
+		u.SetAnnotations(cloneMap(route.Annotations))
+	}
+	u.Object["spec"] = map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"hostnames":  []interface{}{endpoint.hostname},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": p.ServiceNameForController(),
+						"port": int64(endpoint.port),
+					},
+				},
+			},
+		},
+	}
+	return u
+}
+
+// newControllerRouteObject returns an empty object of the given route kind to read
+// or delete the route
+func newControllerRouteObject(kind string) client.Object {
+	if kind == "Ingress" {
+		return &networkingv1.Ingress{}
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(controllerRouteKinds[kind].WithKind(kind))
+	return u
+}
+
+// controllerRouteUpToDate returns whether the live route has the labels, annotations, owner
+// and spec of the desired one. The fields defaulted by the API server in the spec and owner
+// references are ignored, so that the route is only updated when the cluster changes it
+func controllerRouteUpToDate(desired client.Object, current client.Object) bool {
+	if !equality.Semantic.DeepEqual(desired.GetLabels(), current.GetLabels()) ||
+		!equality.Semantic.DeepEqual(desired.GetAnnotations(), current.GetAnnotations()) ||
+		!equality.Semantic.DeepDerivative(desired.GetOwnerReferences(), current.GetOwnerReferences()) {
+		return false
+	}
+	switch d := desired.(type) {
+	case *networkingv1.Ingress:
+		c, ok := current.(*networkingv1.Ingress)
+		return ok && equality.Semantic.DeepDerivative(d.Spec, c.Spec)
+	case *unstructured.Unstructured:
+		c, ok := current.(*unstructured.Unstructured)
+		return ok && equality.Semantic.DeepDerivative(d.Object["spec"], c.Object["spec"])
+	}
+	return false
+}
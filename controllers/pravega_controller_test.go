@@ -17,8 +17,10 @@ import (
 	"github.com/pravega/pravega-operator/api/v1beta1"
 	pravega "github.com/pravega/pravega-operator/controllers"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -136,6 +138,17 @@ var _ = Describe("Controller", func() {
 								Effect:   "NoSchedule",
 							},
 						},
+						ControllerTopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+							{
+								MaxSkew:           1,
+								TopologyKey:       "kubernetes.io/hostname",
+								WhenUnsatisfiable: corev1.ScheduleAnyway,
+							},
+						},
+						ControllerPriorityClassName: "pravega-critical",
+						ControllerNodeSelector: map[string]string{
+							"pravega": "controller",
+						},
 					},
 					TLS: &v1beta1.TLSPolicy{
 						Static: &v1beta1.StaticTLS{
@@ -183,6 +196,30 @@ var _ = Describe("Controller", func() {
 					Ω(strings.Contains(javaOpts, "-XX:MaxRAMPercentage=50.0")).Should(BeTrue())
 				})
 
+				It("should pass the Controller options", func() {
+					p.Spec.Pravega.ControllerOptions = map[string]string{
+						"dummy-key": "controller-value",
+					}
+					p.Spec.Pravega.SegmentStoreOptions = map[string]string{
+						"segment-store-key": "segment-store-value",
+					}
+					cm := pravega.MakeControllerConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).Should(ContainSubstring("-Ddummy-key=controller-value"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("dummy-value"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("segment-store-key"))
+				})
+
+				It("should only pass the options read by the controller", func() {
+					p.Spec.Pravega.Options["controller.retention.thread.count"] = "10"
+					p.Spec.Pravega.Options["pravegaservice.admin.listener.port"] = "9999"
+					cm := pravega.MakeControllerConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).Should(ContainSubstring("-Dcontroller.retention.thread.count=10"))
+					Ω(javaOpts).Should(ContainSubstring("-Ddummy-key=dummy-value"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("pravegaservice.admin.listener.port"))
+				})
+
 				It("should create the deployment", func() {
 					deploy := pravega.MakeControllerDeployment(p)
 					Ω(*deploy.Spec.Replicas).Should(Equal(int32(2)))
@@ -203,6 +240,19 @@ var _ = Describe("Controller", func() {
 
 				})
 
+				It("should skip the malformed volume mounts", func() {
+					p.Spec.Pravega.ControllerOptions = map[string]string{
+						"hostPathVolumeMounts":  "heap-dump,log=/opt/pravega/logs,",
+						"emptyDirVolumeMounts":  "=",
+						"configMapVolumeMounts": "prvg-logback=/opt/pravega/conf/logback.xml",
+					}
+					var podTemplate corev1.PodTemplateSpec
+					Ω(func() { podTemplate = pravega.MakeControllerPodTemplate(p) }).ShouldNot(Panic())
+					Ω(podTemplate.Spec.Volumes).Should(ContainElement(HaveField("Name", "log")))
+					Ω(podTemplate.Spec.Volumes).ShouldNot(ContainElement(HaveField("Name", "heap-dump")))
+					Ω(podTemplate.Spec.Volumes).ShouldNot(ContainElement(HaveField("Name", "prvg-logback")))
+				})
+
 				It("should have VolumeMounts created for influxdb secret", func() {
 					deploy := pravega.MakeControllerPodTemplate(p)
 					mounthostpath := deploy.Spec.Containers[0].VolumeMounts[9].MountPath
@@ -246,6 +296,12 @@ var _ = Describe("Controller", func() {
 					Ω(podTemplate.Spec.Tolerations[0].Value).Should(Equal("val1"))
 					Ω(podTemplate.Spec.Tolerations[0].Effect).Should(Equal(corev1.TaintEffect("NoSchedule")))
 				})
+				It("should have controller pod placement settings", func() {
+					podTemplate := pravega.MakeControllerPodTemplate(p)
+					Ω(podTemplate.Spec.TopologySpreadConstraints[0].TopologyKey).Should(Equal("kubernetes.io/hostname"))
+					Ω(podTemplate.Spec.PriorityClassName).Should(Equal("pravega-critical"))
+					Ω(podTemplate.Spec.NodeSelector["pravega"]).Should(Equal("controller"))
+				})
 			})
 
 			Context("Controller with external service type and external access type empty", func() {
@@ -409,5 +465,126 @@ var _ = Describe("Controller", func() {
 				})
 			})
 		})
+
+		Context("Controller routes", func() {
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.5.0",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled: true,
+						Type:    corev1.ServiceTypeLoadBalancer,
+						ControllerRoute: &v1beta1.ControllerRouteSpec{
+							Type:               v1beta1.ControllerRouteGatewayAPI,
+							GrpcHostname:       "grpc.pravega.example.com",
+							RestHostname:       "rest.pravega.example.com",
+							GatewayName:        "shared-gateway",
+							GatewayNamespace:   "gateways",
+							GatewaySectionName: "pravega",
+						},
+					},
+				}
+				p.WithDefaults()
+			})
+
+			It("should create a cluster IP service", func() {
+				svc := pravega.MakeControllerService(p)
+				Ω(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			})
+
+			It("should create a GRPCRoute and an HTTPRoute", func() {
+				routes := pravega.MakeControllerRoutes(p)
+				Ω(routes).To(HaveLen(2))
+				grpcRoute := routes[0].(*unstructured.Unstructured)
+				Ω(grpcRoute.GetKind()).To(Equal("GRPCRoute"))
+				Ω(grpcRoute.GetName()).To(Equal(p.RouteNameForController("grpc")))
+				parentRefs, _, _ := unstructured.NestedSlice(grpcRoute.Object, "spec", "parentRefs")
+				Ω(parentRefs[0]).To(HaveKeyWithValue("namespace", "gateways"))
+				Ω(parentRefs[0]).To(HaveKeyWithValue("sectionName", "pravega"))
+				hostnames, _, _ := unstructured.NestedStringSlice(grpcRoute.Object, "spec", "hostnames")
+				Ω(hostnames).To(Equal([]string{"grpc.pravega.example.com"}))
+				rules, _, _ := unstructured.NestedSlice(grpcRoute.Object, "spec", "rules")
+				backendRefs, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "backendRefs")
+				Ω(backendRefs[0]).To(HaveKeyWithValue("port", int64(9090)))
+				Ω(routes[1].GetObjectKind().GroupVersionKind().Kind).To(Equal("HTTPRoute"))
+			})
+
+			It("should create TLSRoutes when controller TLS is enabled", func() {
+				p.Spec.TLS = &v1beta1.TLSPolicy{
+					Static: &v1beta1.StaticTLS{
+						ControllerSecret: "controller-secret",
+					},
+				}
+				routes := pravega.MakeControllerRoutes(p)
+				Ω(routes).To(HaveLen(2))
+				for _, route := range routes {
+					Ω(route.GetObjectKind().GroupVersionKind().Kind).To(Equal("TLSRoute"))
+				}
+			})
+
+			It("should create Ingress objects", func() {
+				p.Spec.ExternalAccess.ControllerRoute.Type = v1beta1.ControllerRouteIngress
+				p.Spec.ExternalAccess.ControllerRoute.RestHostname = ""
+				routes := pravega.MakeControllerRoutes(p)
+				Ω(routes).To(HaveLen(1))
+				ingress := routes[0].(*networkingv1.Ingress)
+				Ω(ingress.Spec.Rules[0].Host).To(Equal("grpc.pravega.example.com"))
+				Ω(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(p.ServiceNameForController()))
+				Ω(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number).To(Equal(int32(9090)))
+				Ω(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/backend-protocol", "GRPC"))
+			})
+
+			It("should not create routes when external access is disabled", func() {
+				p.Spec.ExternalAccess.Enabled = false
+				Ω(pravega.MakeControllerRoutes(p)).To(BeEmpty())
+			})
+		})
+
+		Context("Secure ZooKeeper", func() {
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.5.0",
+					Zookeeper: &v1beta1.ZookeeperSpec{
+						Hosts:      []string{"zk-0:2281", "zk-1:2281"},
+						Chroot:     "/prod",
+						TLSSecret:  "zk-tls",
+						AuthSecret: "zk-auth",
+					},
+				}
+				p.WithDefaults()
+			})
+
+			It("should pass the ensemble and chroot", func() {
+				cm := pravega.MakeControllerConfigMap(p)
+				Ω(cm.Data["ZK_URL"]).To(Equal("zk-0:2281,zk-1:2281/prod"))
+				Ω(cm.Data["WAIT_FOR"]).To(Equal("zk-0:2281,zk-1:2281"))
+				Ω(pravega.MakeSegmentstoreConfigMap(p).Data["ZK_URL"]).To(Equal("zk-0:2281,zk-1:2281/prod"))
+			})
+
+			It("should configure the ZooKeeper client of the JVM", func() {
+				for _, cm := range []*corev1.ConfigMap{pravega.MakeControllerConfigMap(p), pravega.MakeSegmentstoreConfigMap(p)} {
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).To(ContainSubstring("-Dzookeeper.client.secure=true"))
+					Ω(javaOpts).To(ContainSubstring("-Dzookeeper.ssl.trustStore.location=/etc/zookeeper-tls/ca.crt"))
+					Ω(javaOpts).To(ContainSubstring("-Djava.security.auth.login.config=/etc/zookeeper-auth/jaas.conf"))
+				}
+			})
+
+			It("should mount the CA certificate and the JAAS configuration", func() {
+				podSpec := pravega.MakeControllerPodTemplate(p).Spec
+				var secrets []string
+				for _, v := range podSpec.Volumes {
+					if v.Secret != nil {
+						secrets = append(secrets, v.Secret.SecretName)
+					}
+				}
+				Ω(secrets).To(ContainElements("zk-tls", p.SecretNameForZookeeperJaas()))
+				Ω(podSpec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: "zookeeper-tls", MountPath: "/etc/zookeeper-tls"}))
+			})
+
+			It("should quote the JAAS credentials", func() {
+				secret := pravega.MakeZookeeperJaasSecret(p, "pravega", `pa"ss`)
+				Ω(string(secret.Data["jaas.conf"])).To(ContainSubstring(`password="pa\"ss";`))
+			})
+		})
 	})
 })
@@ -0,0 +1,359 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// podTerminationPollTime is the delay between two checks of the termination of the
+	// pods of a deleted cluster
+	podTerminationPollTime = 5 * time.Second
+	// cleanupRetryBaseDelay and cleanupRetryMaxDelay bound the exponential backoff between
+	// two failed cleanups of the data of a deleted cluster
+	cleanupRetryBaseDelay = 10 * time.Second
+	cleanupRetryMaxDelay  = 10 * time.Minute
+)
+
+// reconcileClusterCleanup runs the cleanup of the data of a deleted cluster once its pods
+// terminated, records its progress in the status, and returns whether the finalizer can
+// be removed. Failed cleanups are retried with an exponential backoff
+func (r *PravegaClusterReconciler) reconcileClusterCleanup(p *api.PravegaCluster) (bool, error) {
+	if p.Status.Cleanup == nil {
+		p.Status.Cleanup = &api.ClusterCleanupStatus{}
+	}
+	status := p.Status.Cleanup
+	if p.IsForceDeleteAllowed() {
+		log.Printf("force deleting pravega cluster %s without cleaning up its data after %d failures: %s", p.Name, status.Failures, status.LastError)
+		return true, nil
+	}
+	now := metav1.Now()
+	if status.NextAttemptTime != nil && now.Before(status.NextAttemptTime) {
+		return false, nil
+	}
+
+	terminated, err := p.IsClusterTerminated(r.Client)
+	if err != nil {
+		return false, err
+	}
+	if !terminated {
+		// The garbage collector leaves the pods of a cluster deleted in the background while
+		// the cluster exists, so their owners are deleted first
+		if err := r.deleteClusterWorkloads(p); err != nil {
+			return false, err
+		}
+		status.Phase = api.ClusterCleanupWaitingForPods
+		return false, r.updateCleanupStatus(p)
+	}
+
+	status.Attempts++
+	status.LastAttemptTime = &now
+	done, err := r.cleanUpClusterData(p)
+	if err != nil {
+		status.Phase = api.ClusterCleanupFailed
+		status.Failures++
+		status.LastError = err.Error()
+		next := metav1.NewTime(now.Add(cleanupRetryDelay(status.Failures)))
+		status.NextAttemptTime = &next
+		log.Printf("failed to cleanup the data of pravega cluster %s (attempt %d), retrying at %s: %v", p.Name, status.Attempts, next, err)
+		r.publishCleanupFailure(p, fmt.Sprintf("failed to cleanup the data of pravega cluster %s: %v", p.Name, err))
+		return false, r.updateCleanupStatus(p)
+	}
+	status.Failures = 0
+	status.NextAttemptTime = nil
+	if done {
+		return true, nil
+	}
+	status.Phase = api.ClusterCleanupInProgress
+	return false, r.updateCleanupStatus(p)
+}
+
+// deleteClusterWorkloads deletes the Controller Deployment and the Segment Store StatefulSet
+// owned by the cluster, whose pods are then deleted by the garbage collector
+func (r *PravegaClusterReconciler) deleteClusterWorkloads(p *api.PravegaCluster) error {
+	workloads := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: p.DeploymentNameForController(), Namespace: p.Namespace}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: p.StatefulSetNameForSegmentstoreAbove07(), Namespace: p.Namespace}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: p.StatefulSetNameForSegmentstoreBelow07(), Namespace: p.Namespace}},
+	}
+	for _, obj := range workloads {
+		err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get %s: %v", obj.GetName(), err)
+		}
+		if !metav1.IsControlledBy(obj, p) || !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		err = r.Client.Delete(context.TODO(), obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s: %v", obj.GetName(), err)
+		}
+		log.Printf("deleted %s of deleted pravega cluster %s", obj.GetName(), p.Name)
+	}
+	return nil
+}
+
+// cleanupRetryDelay returns the delay before the next cleanup after consecutive failures
+func cleanupRetryDelay(failures int32) time.Duration {
+	delay := cleanupRetryBaseDelay
+	for i := int32(1); i < failures && delay < cleanupRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > cleanupRetryMaxDelay {
+		delay = cleanupRetryMaxDelay
+	}
+	return delay
+}
+
+// cleanupRequeueTime returns when the cleanup of the data of a deleted cluster should be checked again
+func cleanupRequeueTime(p *api.PravegaCluster) time.Duration {
+	status := p.Status.Cleanup
+	if status == nil {
+		return podTerminationPollTime
+	}
+	switch status.Phase {
+	case api.ClusterCleanupFailed:
+		if status.NextAttemptTime != nil {
+			if wait := time.Until(status.NextAttemptTime.Time); wait > 0 {
+				return wait
+			}
+		}
+		return time.Second
+	case api.ClusterCleanupInProgress:
+		return metadataJobPollTime
+	}
+	return podTerminationPollTime
+}
+
+func (r *PravegaClusterReconciler) updateCleanupStatus(p *api.PravegaCluster) error {
+	if err := r.updateClusterStatus(p); err != nil {
+		return fmt.Errorf("failed to update the cleanup status of pravega cluster (%s): %v", p.Name, err)
+	}
+	return nil
+}
+
+// cleanUpClusterData removes the data of a deleted cluster according to its deletion
+// policy, and returns whether it is done. Every step can be repeated until it succeeds
+func (r *PravegaClusterReconciler) cleanUpClusterData(p *api.PravegaCluster) (bool, error) {
+	policy := p.Spec.DeletionPolicy
+	if policy == api.DeletionPolicyRetain {
+		log.Printf("retaining the data of pravega cluster %s", p.Name)
+		return true, nil
+	}
+
+	if policy == api.DeletionPolicySnapshot {
+		done, err := r.takeDeletionSnapshot(p)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+
+	if err := r.cleanUpZookeeperMeta(p); err != nil {
+		return false, err
+	}
+	if policy == "" {
+		return true, nil
+	}
+
+	if err := r.deleteCachePVCs(p); err != nil {
+		return false, fmt.Errorf("failed to delete cache volumes: %v", err)
+	}
+	if policy == api.DeletionPolicyDelete {
+		return r.deleteLongTermStorageData(p)
+	}
+	return true, nil
+}
+
+// takeDeletionSnapshot backs up the metadata of the cluster and returns whether the backup completed
+func (r *PravegaClusterReconciler) takeDeletionSnapshot(p *api.PravegaCluster) (bool, error) {
+	if p.Spec.DeletionSnapshot == nil {
+		return false, fmt.Errorf("spec.deletionSnapshot is not set")
+	}
+	b := &api.PravegaMetadataBackup{}
+	name := p.DeletionSnapshotName()
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, b)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		// The backup outlives the cluster, it has no owner
+		b = &api.PravegaMetadataBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: p.Namespace,
+				Labels:    p.LabelsForPravegaCluster(),
+			},
+			Spec: api.MetadataBackupSpec{
+				ClusterName: p.Name,
+				Storage:     *p.Spec.DeletionSnapshot,
+			},
+		}
+		if err = r.Client.Create(context.TODO(), b); err != nil {
+			return false, fmt.Errorf("failed to create deletion snapshot (%s): %v", name, err)
+		}
+		log.Printf("taking deletion snapshot %s of pravega cluster %s", name, p.Name)
+		return false, nil
+	}
+	switch b.Status.Phase {
+	case api.MetadataBackupCompleted:
+		return true, nil
+	case api.MetadataBackupFailed:
+		return false, fmt.Errorf("deletion snapshot (%s) failed: %s", name, b.Status.Message)
+	}
+	return false, nil
+}
+
+// deleteCachePVCs deletes the cache volumes of the Segment Stores, which the StatefulSet leaves behind
+func (r *PravegaClusterReconciler) deleteCachePVCs(p *api.PravegaCluster) error {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	err := r.Client.List(context.TODO(), pvcList, &client.ListOptions{
+		Namespace:     p.Namespace,
+		LabelSelector: labels.SelectorFromSet(p.LabelsForSegmentStore()),
+	})
+	if err != nil {
+		return err
+	}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if !strings.HasPrefix(pvc.Name, cacheVolumeName+"-") {
+			continue
+		}
+		if err = r.Client.Delete(context.TODO(), pvc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Printf("deleted cache volume %s of pravega cluster %s", pvc.Name, p.Name)
+	}
+	return nil
+}
+
+// deleteLongTermStorageData runs a Job deleting the long term storage data of the cluster,
+// and returns whether it completed
+func (r *PravegaClusterReconciler) deleteLongTermStorageData(p *api.PravegaCluster) (bool, error) {
+	job, err := MakeLongTermStorageCleanupJob(p)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		log.Printf("the long term storage of pravega cluster %s cannot be deleted by the operator, retaining it", p.Name)
+		return true, nil
+	}
+	current := &batchv1.Job{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, current)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		controllerutil.SetControllerReference(p, job, r.Scheme)
+		if err = r.Client.Create(context.TODO(), job); err != nil {
+			return false, fmt.Errorf("failed to create job (%s): %v", job.Name, err)
+		}
+		log.Printf("deleting the long term storage data of pravega cluster %s", p.Name)
+		return false, nil
+	}
+	switch phase, message := metadataJobPhase(current); phase {
+	case api.MetadataBackupCompleted:
+		return true, nil
+	case api.MetadataBackupFailed:
+		return false, fmt.Errorf("failed to delete the long term storage data: %s", message)
+	}
+	return false, nil
+}
+
+// MakeLongTermStorageCleanupJob returns the Job deleting the data of the cluster from the
+// filesystem or ECS long term storage, or nil for the other storages
+func MakeLongTermStorageCleanupJob(p *api.PravegaCluster) (*batchv1.Job, error) {
+	lts := p.Spec.Pravega.LongTermStorage
+	podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+	switch {
+	case lts.FileSystem != nil:
+		podSpec.Containers = []corev1.Container{
+			{
+				Name:    "delete",
+				Image:   p.PravegaImage(),
+				Command: []string{"find", ltsFileMountPoint, "-mindepth", "1", "-delete"},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      ltsVolumeName,
+						MountPath: ltsFileMountPoint,
+					},
+				},
+			},
+		}
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: ltsVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: lts.FileSystem.PersistentVolumeClaim,
+				},
+			},
+		}
+	case lts.Ecs != nil:
+		if strings.Trim(lts.Ecs.Prefix, "/") == "" {
+			return nil, fmt.Errorf("the ECS long term storage has no prefix, refusing to delete the whole bucket")
+		}
+		command := []string{"aws", "s3", "rm", "--recursive", fmt.Sprintf("s3://%s/%s", lts.Ecs.Bucket, strings.Trim(lts.Ecs.Prefix, "/"))}
+		if endpoint := strings.SplitN(lts.Ecs.ConfigUri, "?", 2)[0]; endpoint != "" {
+			command = append(command, "--endpoint-url", endpoint)
+		}
+		credential := func(name string, key string) corev1.EnvVar {
+			return corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: lts.Ecs.Credentials},
+						Key:                  key,
+					},
+				},
+			}
+		}
+		podSpec.Containers = []corev1.Container{
+			{
+				Name:    "delete",
+				Image:   api.DefaultMetadataBackupS3Image,
+				Command: command,
+				Env: []corev1.EnvVar{
+					credential("AWS_ACCESS_KEY_ID", "ACCESS_KEY_ID"),
+					credential("AWS_SECRET_ACCESS_KEY", "SECRET_KEY"),
+				},
+			},
+		}
+	default:
+		return nil, nil
+	}
+	return makeMetadataJob(p.Name+"-lts-cleanup", p.Namespace, p, "lts-cleanup", podSpec), nil
+}
+
+// publishCleanupFailure emits an event for a failed cleanup of the data of a deleted cluster
+func (r *PravegaClusterReconciler) publishCleanupFailure(p *api.PravegaCluster, message string) {
+	event := p.NewApplicationEvent("DATA_CLEANUP_ERROR", "Data Cleanup Failed", message, "Error")
+	if err := r.Client.Create(context.TODO(), event); err != nil {
+		log.Printf("Error publishing data cleanup failure event to k8s. %v", err)
+	}
+}
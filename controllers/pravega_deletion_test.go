@@ -0,0 +1,305 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster deletion", func() {
+	const (
+		Name      = "example"
+		Namespace = "default"
+	)
+
+	var (
+		s                = scheme.Scheme
+		c                client.Client
+		r                *PravegaClusterReconciler
+		p                *v1beta1.PravegaCluster
+		disableFinalizer bool
+	)
+
+	// deleteCluster deletes the cluster, which the finalizer keeps around
+	deleteCluster := func() {
+		Ω(c.Delete(context.TODO(), p)).Should(Succeed())
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, p)).Should(Succeed())
+		Ω(p.DeletionTimestamp.IsZero()).To(BeFalse())
+	}
+
+	clusterExists := func() bool {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, &v1beta1.PravegaCluster{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Ω(err).Should(BeNil())
+		return true
+	}
+
+	BeforeEach(func() {
+		disableFinalizer = config.DisableFinalizer
+		config.DisableFinalizer = false
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       Name,
+				Namespace:  Namespace,
+				UID:        "0123456789abcdef",
+				Finalizers: []string{util.ZkFinalizer},
+			},
+			Spec: v1beta1.ClusterSpec{
+				ZookeeperUri: "zookeeper-client:2181",
+			},
+		}
+		p.WithDefaults()
+		s.AddKnownTypes(v1beta1.GroupVersion, p, &v1beta1.PravegaMetadataBackup{}, &v1beta1.PravegaMetadataBackupList{})
+	})
+
+	AfterEach(func() {
+		config.DisableFinalizer = disableFinalizer
+	})
+
+	JustBeforeEach(func() {
+		c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p.DeepCopy()).Build()
+		r = &PravegaClusterReconciler{Client: c, Scheme: s}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, p)).Should(Succeed())
+	})
+
+	Context("Retain policy", func() {
+		BeforeEach(func() {
+			p.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+		})
+
+		It("should remove the finalizer without touching the data", func() {
+			deleteCluster()
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeFalse())
+		})
+	})
+
+	Context("Delete policy", func() {
+		BeforeEach(func() {
+			p.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+		})
+
+		It("should keep the finalizer while the znodes cannot be deleted", func() {
+			deleteCluster()
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeTrue())
+			Ω(p.Status.Cleanup.Phase).To(Equal(v1beta1.ClusterCleanupFailed))
+			Ω(p.Status.Cleanup.LastError).To(ContainSubstring("zookeeper"))
+
+			events := &corev1.EventList{}
+			Ω(c.List(context.TODO(), events)).Should(Succeed())
+			var reasons []string
+			for _, e := range events.Items {
+				reasons = append(reasons, e.Reason)
+			}
+			Ω(reasons).To(ContainElements("ZK Metadata Cleanup Failed", "Data Cleanup Failed"))
+		})
+
+		It("should delete the cache volumes of the segment stores only", func() {
+			cache := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "cache-example-pravega-segment-store-0", Namespace: Namespace, Labels: p.LabelsForSegmentStore()},
+			}
+			other := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "tier2", Namespace: Namespace, Labels: p.LabelsForSegmentStore()},
+			}
+			Ω(c.Create(context.TODO(), cache)).Should(Succeed())
+			Ω(c.Create(context.TODO(), other)).Should(Succeed())
+			Ω(r.deleteCachePVCs(p)).Should(Succeed())
+			Ω(errors.IsNotFound(c.Get(context.TODO(), types.NamespacedName{Name: cache.Name, Namespace: Namespace}, cache))).To(BeTrue())
+			Ω(c.Get(context.TODO(), types.NamespacedName{Name: other.Name, Namespace: Namespace}, other)).Should(Succeed())
+		})
+	})
+
+	Context("Cleanup tracking", func() {
+		It("should wait for the pods to terminate", func() {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-pravega-controller-0", Namespace: Namespace, Labels: p.LabelsForController()},
+			}
+			Ω(c.Create(context.TODO(), pod)).Should(Succeed())
+			deleteCluster()
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeTrue())
+			Ω(p.Status.Cleanup.Phase).To(Equal(v1beta1.ClusterCleanupWaitingForPods))
+			Ω(p.Status.Cleanup.Attempts).To(BeZero())
+			Ω(cleanupRequeueTime(p)).To(Equal(podTerminationPollTime))
+		})
+
+		It("should delete the workloads whose pods the garbage collector keeps while the cluster exists", func() {
+			p.Spec.DeletionPolicy = v1beta1.DeletionPolicyRetain
+			Ω(c.Update(context.TODO(), p)).Should(Succeed())
+			deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: p.DeploymentNameForController(), Namespace: Namespace}}
+			sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: p.StatefulSetNameForSegmentstore(), Namespace: Namespace}}
+			other := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: p.StatefulSetNameForSegmentstoreBelow07(), Namespace: Namespace}}
+			Ω(controllerutil.SetControllerReference(p, deploy, s)).Should(Succeed())
+			Ω(controllerutil.SetControllerReference(p, sts, s)).Should(Succeed())
+			pods := []*corev1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "example-pravega-controller-0", Namespace: Namespace, Labels: p.LabelsForController()}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "example-pravega-segment-store-0", Namespace: Namespace, Labels: p.LabelsForSegmentStore()}},
+			}
+			for _, obj := range []client.Object{deploy, sts, other, pods[0], pods[1]} {
+				Ω(c.Create(context.TODO(), obj)).Should(Succeed())
+			}
+			deleteCluster()
+
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(p.Status.Cleanup.Phase).To(Equal(v1beta1.ClusterCleanupWaitingForPods))
+			Ω(errors.IsNotFound(c.Get(context.TODO(), client.ObjectKeyFromObject(deploy), deploy))).To(BeTrue())
+			Ω(errors.IsNotFound(c.Get(context.TODO(), client.ObjectKeyFromObject(sts), sts))).To(BeTrue())
+			// A StatefulSet which is not owned by the cluster is left alone
+			Ω(c.Get(context.TODO(), client.ObjectKeyFromObject(other), other)).Should(Succeed())
+
+			// The garbage collector deletes the pods once their owners are gone
+			for _, pod := range pods {
+				Ω(c.Delete(context.TODO(), pod)).Should(Succeed())
+			}
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeFalse())
+		})
+
+		It("should back off after a failure", func() {
+			deleteCluster()
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(p.Status.Cleanup.Attempts).To(BeEquivalentTo(1))
+			Ω(p.Status.Cleanup.Failures).To(BeEquivalentTo(1))
+			Ω(p.Status.Cleanup.NextAttemptTime).ShouldNot(BeNil())
+			Ω(cleanupRequeueTime(p)).To(BeNumerically("~", cleanupRetryBaseDelay, time.Second))
+
+			// No new attempt before the next attempt time
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(p.Status.Cleanup.Attempts).To(BeEquivalentTo(1))
+		})
+
+		It("should double the delay between retries up to a maximum", func() {
+			Ω(cleanupRetryDelay(1)).To(Equal(10 * time.Second))
+			Ω(cleanupRetryDelay(2)).To(Equal(20 * time.Second))
+			Ω(cleanupRetryDelay(4)).To(Equal(80 * time.Second))
+			Ω(cleanupRetryDelay(30)).To(Equal(cleanupRetryMaxDelay))
+		})
+
+		It("should skip the cleanup when forced after enough failures", func() {
+			p.Annotations = map[string]string{v1beta1.ForceDeleteAnnotationKey: "true"}
+			Ω(c.Update(context.TODO(), p)).Should(Succeed())
+			deleteCluster()
+			p.Status.Cleanup = &v1beta1.ClusterCleanupStatus{
+				Phase:    v1beta1.ClusterCleanupFailed,
+				Failures: v1beta1.ForceDeleteAfterFailures - 1,
+			}
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeTrue())
+
+			p.Status.Cleanup.Failures = v1beta1.ForceDeleteAfterFailures
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeFalse())
+		})
+	})
+
+	Context("Snapshot policy", func() {
+		BeforeEach(func() {
+			p.Spec.DeletionPolicy = v1beta1.DeletionPolicySnapshot
+			p.Spec.DeletionSnapshot = &v1beta1.MetadataBackupStorage{
+				PersistentVolumeClaim: &v1beta1.MetadataBackupPVC{ClaimName: "backups"},
+			}
+		})
+
+		It("should back up the metadata before deleting them", func() {
+			deleteCluster()
+			Ω(r.reconcileFinalizers(p)).Should(Succeed())
+			Ω(clusterExists()).To(BeTrue())
+
+			b := &v1beta1.PravegaMetadataBackup{}
+			Ω(c.Get(context.TODO(), types.NamespacedName{Name: "example-deletion-01234567", Namespace: Namespace}, b)).Should(Succeed())
+			Ω(b.Spec.ClusterName).To(Equal(Name))
+			Ω(b.Spec.Storage.PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+			Ω(b.OwnerReferences).To(BeEmpty())
+
+			// The backup is still running
+			done, err := r.takeDeletionSnapshot(p)
+			Ω(err).Should(BeNil())
+			Ω(done).To(BeFalse())
+
+			b.Status.Phase = v1beta1.MetadataBackupCompleted
+			Ω(c.Status().Update(context.TODO(), b)).Should(Succeed())
+			done, err = r.takeDeletionSnapshot(p)
+			Ω(err).Should(BeNil())
+			Ω(done).To(BeTrue())
+
+			b.Status.Phase = v1beta1.MetadataBackupFailed
+			Ω(c.Status().Update(context.TODO(), b)).Should(Succeed())
+			_, err = r.takeDeletionSnapshot(p)
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Long term storage cleanup job", func() {
+		It("should empty the filesystem volume", func() {
+			p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{
+				FileSystem: &v1beta1.FileSystemSpec{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pravega-tier2"},
+				},
+			}
+			job, err := MakeLongTermStorageCleanupJob(p)
+			Ω(err).Should(BeNil())
+			Ω(job.Name).To(Equal("example-lts-cleanup"))
+			spec := job.Spec.Template.Spec
+			Ω(spec.Containers[0].Command).To(Equal([]string{"find", "/mnt/tier2", "-mindepth", "1", "-delete"}))
+			Ω(spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("pravega-tier2"))
+		})
+
+		It("should delete the ECS prefix", func() {
+			p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{
+				Ecs: &v1beta1.ECSSpec{
+					ConfigUri:   "http://ecs.local:9020?namespace=pravega",
+					Bucket:      "shared",
+					Prefix:      "/example",
+					Credentials: "ecs-credentials",
+				},
+			}
+			job, err := MakeLongTermStorageCleanupJob(p)
+			Ω(err).Should(BeNil())
+			container := job.Spec.Template.Spec.Containers[0]
+			Ω(container.Command).To(Equal([]string{"aws", "s3", "rm", "--recursive", "s3://shared/example", "--endpoint-url", "http://ecs.local:9020"}))
+			Ω(container.Env[0].ValueFrom.SecretKeyRef.Name).To(Equal("ecs-credentials"))
+
+			p.Spec.Pravega.LongTermStorage.Ecs.Prefix = "/"
+			_, err = MakeLongTermStorageCleanupJob(p)
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should retain the other storages", func() {
+			p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{
+				Hdfs: &v1beta1.HDFSSpec{Uri: "hdfs://hdfs:8020"},
+			}
+			job, err := MakeLongTermStorageCleanupJob(p)
+			Ω(err).Should(BeNil())
+			Ω(job).To(BeNil())
+		})
+	})
+})
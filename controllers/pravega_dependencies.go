@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	bkapi "github.com/pravega/bookkeeper-operator/api/v1alpha1"
+	bkutil "github.com/pravega/bookkeeper-operator/pkg/util"
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	zkapi "github.com/pravega/zookeeper-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+const bookiePort = 3181
+
+// reconcileDependencies reads the ZookeeperCluster and the BookkeeperCluster the cluster refers
+// to and reports their state in the status, from which the connection strings of the spec are
+// set. It returns false while the cluster was never deployed and one of them is not ready, in
+// which case the cluster is not deployed yet
+func (r *PravegaClusterReconciler) reconcileDependencies(p *api.PravegaCluster) (bool, error) {
+	zookeeper, err := r.zookeeperDependency(p)
+	if err != nil {
+		return false, err
+	}
+	bookkeeper, err := r.bookkeeperDependency(p)
+	if err != nil {
+		return false, err
+	}
+	if !reflect.DeepEqual(zookeeper, p.Status.Zookeeper) || !reflect.DeepEqual(bookkeeper, p.Status.Bookkeeper) {
+		p.Status.Zookeeper = zookeeper
+		p.Status.Bookkeeper = bookkeeper
+		if err := r.updateClusterStatus(p); err != nil {
+			return false, fmt.Errorf("failed to update the dependencies status: %v", err)
+		}
+	}
+
+	if p.Status.CurrentVersion == "" && !p.Status.AreDependenciesReady() {
+		log.Printf("waiting for the dependencies of pravega cluster %s to be ready before deploying it", p.Name)
+		return false, nil
+	}
+	return true, nil
+}
+
+// zookeeperDependency returns the state of the ZookeeperCluster of spec.zookeeperRef, nil when
+// it is not set
+func (r *PravegaClusterReconciler) zookeeperDependency(p *api.PravegaCluster) (*api.DependencyStatus, error) {
+	if p.Spec.ZookeeperRef == nil {
+		return nil, nil
+	}
+	nn := p.Spec.ZookeeperRef.NamespacedName(p.Namespace)
+	dependency := &api.DependencyStatus{Name: nn.Name, Namespace: nn.Namespace}
+	zk := &zkapi.ZookeeperCluster{}
+	err := r.Client.Get(context.TODO(), nn, zk)
+	if err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			dependency.Message = fmt.Sprintf("ZookeeperCluster %s not found", nn)
+			return dependency, nil
+		}
+		return nil, fmt.Errorf("failed to get ZookeeperCluster (%s): %v", nn, err)
+	}
+
+	// The ports and service names are read from the spec with the defaults of the Zookeeper operator
+	zk.WithDefaults()
+	dependency.URI = fmt.Sprintf("%s.%s.svc.%s:%d", zk.GetClientServiceName(), zk.Namespace, zk.GetKubernetesClusterDomain(), zk.ZookeeperPorts().Client)
+	dependency.Version = zk.Status.CurrentVersion
+	dependency.Ready = zk.Status.IsClusterInReadyState()
+	dependency.Upgrading = zk.Status.IsClusterInUpgradingState()
+	if !dependency.Ready {
+		dependency.Message = fmt.Sprintf("%d of %d replicas are ready", zk.Status.ReadyReplicas, zk.Spec.Replicas)
+	}
+	return dependency, nil
+}
+
+// bookkeeperDependency returns the state of the BookkeeperCluster of spec.bookkeeperRef, nil
+// when it is not set
+func (r *PravegaClusterReconciler) bookkeeperDependency(p *api.PravegaCluster) (*api.DependencyStatus, error) {
+	if p.Spec.BookkeeperRef == nil {
+		return nil, nil
+	}
+	nn := p.Spec.BookkeeperRef.NamespacedName(p.Namespace)
+	dependency := &api.DependencyStatus{Name: nn.Name, Namespace: nn.Namespace}
+	bk := &bkapi.BookkeeperCluster{}
+	err := r.Client.Get(context.TODO(), nn, bk)
+	if err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			dependency.Message = fmt.Sprintf("BookkeeperCluster %s not found", nn)
+			return dependency, nil
+		}
+		return nil, fmt.Errorf("failed to get BookkeeperCluster (%s): %v", nn, err)
+	}
+
+	// The bookies are listed, as in the default bookkeeperUri, for the Segment Store to wait for
+	bk.WithDefaults()
+	bookies := make([]string, bk.Spec.Replicas)
+	for i := range bookies {
+		bookies[i] = fmt.Sprintf("%s-%d.%s.%s.svc.cluster.local:%d", bkutil.StatefulSetNameForBookie(bk.Name), i, bk.HeadlessServiceNameForBookie(), bk.Namespace, bookiePort)
+	}
+	dependency.URI = strings.Join(bookies, ",")
+	dependency.Version = bk.Status.CurrentVersion
+	dependency.Ready = bk.Status.IsClusterInReadyState()
+	dependency.Upgrading = bk.Status.IsClusterInUpgradingState()
+	if !dependency.Ready {
+		dependency.Message = fmt.Sprintf("%d of %d replicas are ready", bk.Status.ReadyReplicas, bk.Spec.Replicas)
+	}
+	return dependency, nil
+}
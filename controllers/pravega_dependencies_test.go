@@ -0,0 +1,193 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	bkapi "github.com/pravega/bookkeeper-operator/api/v1alpha1"
+	zkapi "github.com/pravega/zookeeper-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster dependencies", func() {
+	const (
+		Name      = "example"
+		Namespace = "default"
+	)
+
+	var (
+		s  = scheme.Scheme
+		c  client.Client
+		r  *PravegaClusterReconciler
+		p  *v1beta1.PravegaCluster
+		zk *zkapi.ZookeeperCluster
+		bk *bkapi.BookkeeperCluster
+	)
+
+	conditions := func(ready bool, upgrading bool) []corev1.ConditionStatus {
+		status := func(b bool) corev1.ConditionStatus {
+			if b {
+				return corev1.ConditionTrue
+			}
+			return corev1.ConditionFalse
+		}
+		return []corev1.ConditionStatus{status(ready), status(upgrading)}
+	}
+
+	setZookeeperState := func(ready bool, upgrading bool) {
+		status := conditions(ready, upgrading)
+		zk.Status.Conditions = []zkapi.ClusterCondition{
+			{Type: zkapi.ClusterConditionPodsReady, Status: status[0]},
+			{Type: zkapi.ClusterConditionUpgrading, Status: status[1]},
+		}
+	}
+
+	setBookkeeperState := func(ready bool, upgrading bool) {
+		status := conditions(ready, upgrading)
+		bk.Status.Conditions = []bkapi.ClusterCondition{
+			{Type: bkapi.ClusterConditionPodsReady, Status: status[0]},
+			{Type: bkapi.ClusterConditionUpgrading, Status: status[1]},
+		}
+	}
+
+	reconcileCluster := func() *v1beta1.PravegaCluster {
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: Name, Namespace: Namespace}})
+		Ω(err).Should(BeNil())
+		found := &v1beta1.PravegaCluster{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	BeforeEach(func() {
+		Ω(zkapi.AddToScheme(s)).Should(Succeed())
+		Ω(bkapi.AddToScheme(s)).Should(Succeed())
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+			Spec: v1beta1.ClusterSpec{
+				ZookeeperRef:  &v1beta1.ClusterReference{Name: "zookeeper", Namespace: "zk"},
+				BookkeeperRef: &v1beta1.ClusterReference{Name: "bookkeeper"},
+			},
+		}
+		s.AddKnownTypes(v1beta1.GroupVersion, p)
+		zk = &zkapi.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "zookeeper", Namespace: "zk"},
+			Spec:       zkapi.ZookeeperClusterSpec{Replicas: 3},
+		}
+		zk.Status.CurrentVersion = "0.2.15"
+		setZookeeperState(false, false)
+		bk = &bkapi.BookkeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "bookkeeper", Namespace: Namespace},
+			Spec:       bkapi.BookkeeperClusterSpec{Replicas: 2},
+		}
+		setBookkeeperState(true, false)
+	})
+
+	Context("Before the cluster is deployed", func() {
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, zk, bk).Build()
+			r = &PravegaClusterReconciler{Client: c, Scheme: s}
+		})
+
+		It("should report the state of the dependencies", func() {
+			found := reconcileCluster()
+			Ω(found.Status.Zookeeper).Should(Equal(&v1beta1.DependencyStatus{
+				Name:      "zookeeper",
+				Namespace: "zk",
+				URI:       "zookeeper-client.zk.svc.cluster.local:2181",
+				Version:   "0.2.15",
+				Message:   "0 of 3 replicas are ready",
+			}))
+			Ω(found.Status.Bookkeeper.Ready).Should(BeTrue())
+			Ω(found.Status.Bookkeeper.URI).Should(Equal("bookkeeper-bookie-0.bookkeeper-bookie-headless.default.svc.cluster.local:3181,bookkeeper-bookie-1.bookkeeper-bookie-headless.default.svc.cluster.local:3181"))
+		})
+
+		It("should wait for the dependencies to be ready", func() {
+			reconcileCluster()
+			cm := &corev1.ConfigMap{}
+			err := c.Get(context.TODO(), types.NamespacedName{Name: p.ConfigMapNameForController(), Namespace: Namespace}, cm)
+			Ω(errors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should deploy the cluster with the connection strings of the dependencies once they are ready", func() {
+			reconcileCluster()
+			Ω(c.Get(context.TODO(), types.NamespacedName{Name: "zookeeper", Namespace: "zk"}, zk)).Should(Succeed())
+			setZookeeperState(true, false)
+			Ω(c.Update(context.TODO(), zk)).Should(Succeed())
+			found := reconcileCluster()
+			Ω(found.Status.Zookeeper.Ready).Should(BeTrue())
+
+			cm := &corev1.ConfigMap{}
+			Ω(c.Get(context.TODO(), types.NamespacedName{Name: p.ConfigMapNameForSegmentstore(), Namespace: Namespace}, cm)).Should(Succeed())
+			Ω(cm.Data["ZK_URL"]).Should(Equal("zookeeper-client.zk.svc.cluster.local:2181"))
+			Ω(cm.Data["WAIT_FOR"]).Should(Equal(found.Status.Bookkeeper.URI))
+
+			zkConfig, err := zookeeperConfig(c, found)
+			Ω(err).Should(BeNil())
+			Ω(zkConfig.Servers).Should(Equal([]string{"zookeeper-client.zk.svc.cluster.local:2181"}))
+
+			// The connection strings are not written to the spec
+			Ω(found.Spec.ZookeeperUri).Should(BeEmpty())
+			Ω(found.Spec.BookkeeperUri).Should(BeEmpty())
+		})
+
+		It("should report a missing dependency", func() {
+			Ω(c.Delete(context.TODO(), bk)).Should(Succeed())
+			found := reconcileCluster()
+			Ω(found.Status.Bookkeeper.Ready).Should(BeFalse())
+			Ω(found.Status.Bookkeeper.Message).Should(Equal("BookkeeperCluster default/bookkeeper not found"))
+		})
+	})
+
+	Context("Upgrades after the dependencies", func() {
+		BeforeEach(func() {
+			p.WithDefaults()
+			p.Spec.UpgradeAfterDependencies = true
+			p.Spec.Version = "0.10.0"
+			p.Status.CurrentVersion = "0.9.0"
+			p.Status.Init()
+			p.Status.SetPodsReadyConditionTrue()
+			setZookeeperState(true, true)
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, zk, bk).Build()
+			r = &PravegaClusterReconciler{Client: c, Scheme: s}
+			_, err := r.reconcileDependencies(p)
+			Ω(err).Should(BeNil())
+		})
+
+		It("should not start the upgrade while zookeeper is upgrading", func() {
+			Ω(r.syncClusterVersion(p)).Should(Succeed())
+			Ω(p.Status.IsClusterInUpgradingState()).Should(BeFalse())
+		})
+
+		It("should start the upgrade once zookeeper is upgraded", func() {
+			p.Status.Zookeeper.Upgrading = false
+			p.Status.Zookeeper.Ready = true
+			Ω(r.syncClusterVersion(p)).Should(Succeed())
+			Ω(p.Status.IsClusterInUpgradingState()).Should(BeTrue())
+			Ω(p.Status.TargetVersion).Should(Equal("0.10.0"))
+		})
+	})
+})
@@ -0,0 +1,44 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	pravega "github.com/pravega/pravega-operator/controllers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FuzzMakePodTemplates checks that rendering the pods and config maps never panics on the
+// options of the user. Run with: go test ./controllers -run '^$' -fuzz FuzzMakePodTemplates
+func FuzzMakePodTemplates(f *testing.F) {
+	f.Add("heap-dump=/tmp/dumpfile/heap,log=/opt/pravega/logs", "-Xmx1g")
+	f.Add("prvg-logback:logback.xml=/opt/pravega/conf/logback.xml", "-XX:MaxDirectMemorySize=1g")
+	f.Add("heap-dump,=,:,a:=", "-Xmxlarge")
+	f.Add("log=/opt/pravega/logs", "-XX:MaxDirectMemorySize")
+	f.Add("log=/opt/pravega/logs", "-XX:")
+	f.Fuzz(func(t *testing.T, volumeMounts string, jvmOption string) {
+		p := &v1beta1.PravegaCluster{ObjectMeta: metav1.ObjectMeta{Name: "fuzz", Namespace: "default"}}
+		p.WithDefaults()
+		for _, name := range []string{"hostPathVolumeMounts", "emptyDirVolumeMounts", "configMapVolumeMounts"} {
+			p.Spec.Pravega.Options[name] = volumeMounts
+		}
+		p.Spec.Pravega.SegmentStoreOptions = map[string]string{"pravegaservice.service.listener.port": volumeMounts}
+		p.Spec.Pravega.SegmentStoreJVMOptions = []string{jvmOption}
+		p.Spec.Pravega.SegmentStoreMemory = &v1beta1.SegmentStoreMemorySpec{AutoSize: true}
+
+		_ = pravega.MakeControllerPodTemplate(p)
+		_ = pravega.MakeControllerConfigMap(p)
+		_ = pravega.MakeSegmentStoreStatefulSet(p)
+		_ = pravega.MakeSegmentstoreConfigMap(p)
+	})
+}
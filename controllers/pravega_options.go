@@ -0,0 +1,127 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pravegaOptions returns the options of the spec passed to the component, under the names the
+// Pravega version of the spec reads them
+func pravegaOptions(p *api.PravegaCluster, component api.OptionComponent) map[string]string {
+	options, _ := api.RenameOptions(componentOptions(p, component), p.Spec.Version)
+	return options
+}
+
+// componentOptions returns the options of the spec passed to the component, along with the
+// listener ports of the Segment Store which the operator always sets and its cache size when
+// it is derived from the memory limit
+func componentOptions(p *api.PravegaCluster, component api.OptionComponent) map[string]string {
+	options := p.Spec.Pravega.ComponentOptions(component)
+	if component == api.OptionComponentSegmentStore {
+		servicePort, adminPort := p.SegmentStoreBasePorts()
+		if _, ok := api.FindOption(options, "pravegaservice.service.listener.port"); !ok {
+			options["pravegaservice.service.listener.port"] = fmt.Sprint(servicePort)
+		}
+		if _, ok := api.FindOption(options, "pravegaservice.admin.listener.port"); !ok {
+			options["pravegaservice.admin.listener.port"] = fmt.Sprint(adminPort)
+		}
+		if _, ok := api.FindOption(options, "pravegaservice.cache.size.max"); !ok && p.IsSegmentStoreMemoryAutoSized() {
+			if sizes, err := p.SegmentStoreMemorySizes(); err == nil {
+				options["pravegaservice.cache.size.max"] = fmt.Sprint(sizes.CacheSize)
+			}
+		}
+	}
+	return options
+}
+
+// optionVolumes returns the volumes set with the volume mount options of a component, looked
+// up with option, and their mounts. The malformed entries, rejected by the webhook, are skipped
+func optionVolumes(option func(name string) (string, bool)) ([]corev1.Volume, []corev1.VolumeMount) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for _, name := range []string{"hostPathVolumeMounts", "emptyDirVolumeMounts", "configMapVolumeMounts"} {
+		value, ok := option(name)
+		if !ok {
+			continue
+		}
+		mounts, _ := api.ParseVolumeMounts(name, value)
+		for _, mount := range mounts {
+			v := corev1.Volume{Name: mount.Name}
+			switch name {
+			case "hostPathVolumeMounts":
+				v.VolumeSource.HostPath = &corev1.HostPathVolumeSource{
+					Path: mount.MountPath,
+				}
+			case "emptyDirVolumeMounts":
+				v.VolumeSource.EmptyDir = &corev1.EmptyDirVolumeSource{}
+			case "configMapVolumeMounts":
+				v.VolumeSource.ConfigMap = &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: mount.Name,
+					},
+				}
+			}
+			volumes = append(volumes, v)
+			volumeMounts = append(volumeMounts, corev1.VolumeMount{
+				Name:      mount.Name,
+				MountPath: mount.MountPath,
+				SubPath:   mount.Key,
+			})
+		}
+	}
+	return volumes, volumeMounts
+}
+
+// publishUnknownOptions warns that options missing from the catalog are passed to all the
+// components, since the operator cannot tell which one reads them
+func (r *PravegaClusterReconciler) publishUnknownOptions(p *api.PravegaCluster) {
+	unknown := p.Spec.Pravega.UnknownOptions()
+	if len(unknown) == 0 {
+		return
+	}
+	message := fmt.Sprintf("options %s are unknown to Pravega %s and are passed to all the components", strings.Join(unknown, ", "), api.PravegaOptions().PravegaVersion)
+	event := p.NewEvent("UNKNOWN_OPTIONS", "Unknown Options", message, "Warning")
+	if err := r.Client.Create(context.TODO(), event); err != nil {
+		log.Printf("Error publishing unknown options event to k8s. %v", err)
+	}
+}
+
+// publishRenamedOptions warns that options of the component were set under another name than
+// the one the Pravega version of the spec reads. Only the options set by the user are checked,
+// since the ones added or defaulted by the operator are renamed silently
+func (r *PravegaClusterReconciler) publishRenamedOptions(p *api.PravegaCluster, component api.OptionComponent) {
+	options := map[string]string{}
+	for name, value := range p.Spec.Pravega.ComponentOptions(component) {
+		if !api.IsDefaultedOption(name, value) {
+			options[name] = value
+		}
+	}
+	_, renames := api.RenameOptions(options, p.Spec.Version)
+	if len(renames) == 0 {
+		return
+	}
+	var renamed []string
+	for _, rename := range renames {
+		renamed = append(renamed, fmt.Sprintf("%s to %s", rename.From, rename.To))
+	}
+	message := fmt.Sprintf("options not read by Pravega %s under their name are renamed in the %s config map: %s", p.Spec.Version, component, strings.Join(renamed, ", "))
+	event := p.NewEvent("RENAMED_OPTIONS", "Renamed Options", message, "Warning")
+	if err := r.Client.Create(context.TODO(), event); err != nil {
+		log.Printf("Error publishing renamed options event to k8s. %v", err)
+	}
+}
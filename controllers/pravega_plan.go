@@ -0,0 +1,339 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// planner computes the changes a reconcile would make to a cluster, by comparing the
+// objects built from the spec with the live ones, without applying anything
+type planner struct {
+	client client.Client
+	p      *api.PravegaCluster
+	plan   *api.ClusterPlan
+
+	restartControllers   bool
+	restartSegmentStores bool
+	restartedPods        map[string]bool
+}
+
+// PlanClusterChanges returns the plan of the changes applying the spec of the given cluster
+// would make. The cluster is not modified
+func PlanClusterChanges(c client.Client, cluster *api.PravegaCluster) (*api.ClusterPlan, error) {
+	p := cluster.DeepCopy()
+	pl := &planner{
+		client: c,
+		p:      p,
+		plan: &api.ClusterPlan{
+			ObservedGeneration: cluster.Generation,
+			ComputedTime:       time.Now().Format(time.RFC3339),
+		},
+		restartedPods: map[string]bool{},
+	}
+
+	// The defaults are only applied in memory, as by the operator
+	p.WithDefaults()
+	if err := pl.validate(); err != nil {
+		return nil, err
+	}
+	if err := pl.planConfigMaps(); err != nil {
+		return nil, err
+	}
+	if err := pl.planServices(); err != nil {
+		return nil, err
+	}
+	if err := pl.planPdbs(); err != nil {
+		return nil, err
+	}
+	if err := pl.planController(); err != nil {
+		return nil, err
+	}
+	if err := pl.planSegmentStore(); err != nil {
+		return nil, err
+	}
+	if err := pl.countRestarts(); err != nil {
+		return nil, err
+	}
+	return pl.plan, nil
+}
+
+func (pl *planner) change(format string, args ...interface{}) {
+	pl.plan.Changes = append(pl.plan.Changes, fmt.Sprintf(format, args...))
+}
+
+func (pl *planner) get(name string, obj client.Object) (found bool, err error) {
+	err = pl.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: pl.p.Namespace}, obj)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isUpgrading returns whether the spec requests a version different from the deployed one,
+// in which case the upgrade rolls the pods instead of the configuration changes
+func (pl *planner) isUpgrading() bool {
+	return pl.p.Status.CurrentVersion != "" && pl.p.Status.CurrentVersion != pl.p.Spec.Version
+}
+
+// validate runs the checks of the admission webhook and records why it would reject the spec
+func (pl *planner) validate() error {
+	p := pl.p.DeepCopy()
+	for _, err := range p.ValidateSpec() {
+		pl.plan.Rejections = append(pl.plan.Rejections, err.Error())
+	}
+	count, err := p.CountBookies(pl.client)
+	if err != nil {
+		return err
+	}
+	if err := p.ValidateBookieCount(count); err != nil {
+		pl.plan.Rejections = append(pl.plan.Rejections, err.Error())
+	}
+
+	settings := p.Status.ImmutableSettings
+	if settings == nil {
+		// The settings of a cluster deployed before they were recorded are read from its config maps
+		if settings, err = immutableSettings(pl.client, pl.p); err != nil {
+			return err
+		}
+	}
+	for _, err := range p.ValidateImmutableSettings(settings) {
+		pl.plan.Rejections = append(pl.plan.Rejections, err.Error())
+	}
+	return nil
+}
+
+func (pl *planner) planConfigMaps() error {
+	p := pl.p
+	if pl.isUpgrading() {
+		pl.change("will upgrade the cluster from version %s to %s", p.Status.CurrentVersion, p.Spec.Version)
+		pl.restartControllers = true
+		pl.restartSegmentStores = true
+	}
+
+	cm := &corev1.ConfigMap{}
+	found, err := pl.get(p.ConfigMapNameForController(), cm)
+	if err != nil {
+		return err
+	}
+	if !found {
+		pl.change("will create configmap %s", p.ConfigMapNameForController())
+	} else if !util.CompareConfigMap(cm, MakeControllerConfigMap(p)) {
+		pl.change("will update configmap %s and restart the controller pods", p.ConfigMapNameForController())
+		pl.restartControllers = true
+	}
+
+	cm = &corev1.ConfigMap{}
+	found, err = pl.get(p.ConfigMapNameForSegmentstore(), cm)
+	if err != nil {
+		return err
+	}
+	// MakeSegmentstoreConfigMap sets the default ports in the options of the spec
+	desired := MakeSegmentstoreConfigMap(p)
+	if !found {
+		pl.change("will create configmap %s", p.ConfigMapNameForSegmentstore())
+	} else if !util.CompareConfigMap(cm, desired) {
+		r := &PravegaClusterReconciler{}
+		if r.checkSegmentStorePortUpdated(p, cm) {
+			pl.change("will update configmap %s, the new segment store port is applied with the statefulset", p.ConfigMapNameForSegmentstore())
+		} else {
+			pl.change("will update configmap %s and restart the segment store pods", p.ConfigMapNameForSegmentstore())
+			pl.restartSegmentStores = true
+		}
+	}
+	return nil
+}
+
+func (pl *planner) planServices() error {
+	p := pl.p
+	svc := &corev1.Service{}
+	desired := MakeControllerService(p)
+	found, err := pl.get(desired.Name, svc)
+	if err != nil {
+		return err
+	}
+	if !found {
+		pl.change("will create service %s", desired.Name)
+	} else if !equality.Semantic.DeepEqual(svc.Labels, desired.Labels) ||
+		!equality.Semantic.DeepEqual(svc.Spec.Selector, desired.Spec.Selector) ||
+		!mapsEqual(svc.Annotations, desired.Annotations) {
+		pl.change("will update service %s", desired.Name)
+	}
+
+	headless := MakeSegmentStoreHeadlessService(p)
+	svc = &corev1.Service{}
+	found, err = pl.get(headless.Name, svc)
+	if err != nil {
+		return err
+	}
+	if !found {
+		pl.change("will create service %s", headless.Name)
+	} else if !servicePortsEqual(svc, headless) {
+		pl.change("will update the ports of service %s", headless.Name)
+	}
+
+	if !p.Spec.ExternalAccess.Enabled {
+		return nil
+	}
+	services, err := MakeSegmentStoreExternalServices(p)
+	if err != nil {
+		return err
+	}
+	for _, desired := range services {
+		svc := &corev1.Service{}
+		found, err := pl.get(desired.Name, svc)
+		if err != nil {
+			return err
+		}
+		if !found {
+			pl.change("will create service %s", desired.Name)
+			continue
+		}
+		if svc.Annotations[externalDNSAnnotationKey] != desired.Annotations[externalDNSAnnotationKey] {
+			pl.change("will recreate service %s and restart pod %s", desired.Name, desired.Name)
+			pl.restartedPods[desired.Name] = true
+		} else if !servicePortsEqual(svc, desired) {
+			pl.change("will update the ports of service %s", desired.Name)
+		}
+	}
+	return nil
+}
+
+func (pl *planner) planPdbs() error {
+	for _, desired := range []*policyv1.PodDisruptionBudget{
+		MakeControllerPodDisruptionBudget(pl.p),
+		MakeSegmentstorePodDisruptionBudget(pl.p),
+	} {
+		pdb := &policyv1.PodDisruptionBudget{}
+		found, err := pl.get(desired.Name, pdb)
+		if err != nil {
+			return err
+		}
+		if !found {
+			pl.change("will create poddisruptionbudget %s", desired.Name)
+		} else if !equality.Semantic.DeepEqual(pdb.Spec.MaxUnavailable, desired.Spec.MaxUnavailable) {
+			pl.change("will update poddisruptionbudget %s", desired.Name)
+		}
+	}
+	return nil
+}
+
+func (pl *planner) planController() error {
+	p := pl.p
+	desired := MakeControllerDeployment(p)
+	deploy := &appsv1.Deployment{}
+	found, err := pl.get(desired.Name, deploy)
+	if err != nil {
+		return err
+	}
+	if !found {
+		pl.change("will create deployment %s", desired.Name)
+		return nil
+	}
+	if deploy.Spec.Replicas != nil && *deploy.Spec.Replicas != p.Spec.Pravega.ControllerReplicas {
+		pl.change("will scale the controller from %d to %d replicas", *deploy.Spec.Replicas, p.Spec.Pravega.ControllerReplicas)
+	}
+	if !pl.isUpgrading() && !equality.Semantic.DeepDerivative(desired.Spec.Template, deploy.Spec.Template) {
+		pl.change("will update the pod template of deployment %s and restart the controller pods", desired.Name)
+		pl.restartControllers = true
+	}
+	return nil
+}
+
+func (pl *planner) planSegmentStore() error {
+	p := pl.p
+	desired := MakeSegmentStoreStatefulSet(p)
+	sts := &appsv1.StatefulSet{}
+	found, err := pl.get(desired.Name, sts)
+	if err != nil {
+		return err
+	}
+	if !found {
+		pl.change("will create statefulset %s", desired.Name)
+		return nil
+	}
+	if sts.Spec.Replicas != nil && *sts.Spec.Replicas != p.Spec.Pravega.SegmentStoreReplicas {
+		pl.change("will scale the segment store from %d to %d replicas", *sts.Spec.Replicas, p.Spec.Pravega.SegmentStoreReplicas)
+	}
+	if !pl.isUpgrading() && !equality.Semantic.DeepDerivative(desired.Spec.Template, sts.Spec.Template) {
+		pl.change("will update the pod template of statefulset %s and restart the segment store pods", desired.Name)
+		pl.restartSegmentStores = true
+	}
+	return nil
+}
+
+// countRestarts counts the live pods that would be restarted, each pod being counted once
+func (pl *planner) countRestarts() error {
+	components := map[string]bool{
+		"pravega-controller":   pl.restartControllers,
+		"pravega-segmentstore": pl.restartSegmentStores,
+	}
+	for component, restart := range components {
+		if !restart {
+			continue
+		}
+		podLabels := pl.p.LabelsForPravegaCluster()
+		podLabels["component"] = component
+		podList := &corev1.PodList{}
+		err := pl.client.List(context.TODO(), podList, &client.ListOptions{
+			Namespace:     pl.p.Namespace,
+			LabelSelector: labels.SelectorFromSet(podLabels),
+		})
+		if err != nil {
+			return err
+		}
+		for _, pod := range podList.Items {
+			pl.restartedPods[pod.Name] = true
+		}
+	}
+	pl.plan.PodRestarts = int32(len(pl.restartedPods))
+	return nil
+}
+
+func servicePortsEqual(current *corev1.Service, desired *corev1.Service) bool {
+	if len(current.Spec.Ports) != len(desired.Spec.Ports) {
+		return false
+	}
+	for i := range desired.Spec.Ports {
+		if current.Spec.Ports[i].Port != desired.Spec.Ports[i].Port {
+			return false
+		}
+	}
+	return true
+}
+
+func mapsEqual(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	restClientTimeout = 10 * time.Second
+
+	credentialsUsernameKey = "username"
+	credentialsPasswordKey = "password"
+	controllerCACertKey    = "ca-cert"
+)
+
+// controllerRestError is returned when the Controller REST API answers with an error status
+type controllerRestError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *controllerRestError) Error() string {
+	return fmt.Sprintf("controller REST API returned %d: %s", e.StatusCode, e.Message)
+}
+
+func isRestStatus(err error, status int) bool {
+	restErr, ok := err.(*controllerRestError)
+	return ok && restErr.StatusCode == status
+}
+
+// streamConfig is the stream configuration sent to and read from the Controller REST API
+type streamConfig struct {
+	ScopeName       string                     `json:"scopeName,omitempty"`
+	StreamName      string                     `json:"streamName,omitempty"`
+	ScalingPolicy   *api.StreamScalingPolicy   `json:"scalingPolicy,omitempty"`
+	RetentionPolicy *api.StreamRetentionPolicy `json:"retentionPolicy,omitempty"`
+	StreamTags      []string                   `json:"streamTags,omitempty"`
+	Tags            []string                   `json:"tags,omitempty"`
+}
+
+// controllerRestClient manages scopes and streams through the REST API of a Controller
+type controllerRestClient struct {
+	baseURL    string
+	httpClient *http.Client
+	username   string
+	password   string
+}
+
+// newControllerRestClient returns a client of the Controller REST API of the given cluster.
+// The CA of the Controller is read from the CA bundle or the Controller TLS secret, and the
+// credentials from the given secret. An empty baseURL selects the Controller service
+func newControllerRestClient(c client.Client, p *api.PravegaCluster, credentialsSecret string, baseURL string) (*controllerRestClient, error) {
+	if baseURL == "" {
+		baseURL = p.PravegaControllerRestURL()
+	}
+	rc := &controllerRestClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: restClientTimeout},
+	}
+
+	if strings.HasPrefix(rc.baseURL, "https://") {
+		pool, err := controllerCertPool(c, p)
+		if err != nil {
+			return nil, err
+		}
+		rc.httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	if credentialsSecret != "" {
+		secret := &corev1.Secret{}
+		err := c.Get(context.TODO(), types.NamespacedName{Name: credentialsSecret, Namespace: p.Namespace}, secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials secret (%s): %v", credentialsSecret, err)
+		}
+		rc.username = string(secret.Data[credentialsUsernameKey])
+		rc.password = string(secret.Data[credentialsPasswordKey])
+		if rc.username == "" || rc.password == "" {
+			return nil, fmt.Errorf("credentials secret (%s) must contain the keys %s and %s", credentialsSecret, credentialsUsernameKey, credentialsPasswordKey)
+		}
+	}
+	return rc, nil
+}
+
+// controllerCertPool returns the certificates trusted to verify the Controller: the CA bundle
+// when configured, otherwise the CA certificate of the Controller TLS secret
+func controllerCertPool(c client.Client, p *api.PravegaCluster) (*x509.CertPool, error) {
+	secretName := p.Spec.TLS.Static.ControllerSecret
+	if p.Spec.TLS.IsCaBundlePresent() {
+		secretName = p.Spec.TLS.Static.CaBundle
+	}
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: p.Namespace}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the CA certificate secret (%s): %v", secretName, err)
+	}
+
+	pool := x509.NewCertPool()
+	found := false
+	if p.Spec.TLS.IsCaBundlePresent() {
+		for _, data := range secret.Data {
+			found = pool.AppendCertsFromPEM(data) || found
+		}
+	} else {
+		found = pool.AppendCertsFromPEM(secret.Data[controllerCACertKey])
+	}
+	if !found {
+		return nil, fmt.Errorf("no CA certificate found in secret (%s)", secretName)
+	}
+	return pool, nil
+}
+
+func (rc *controllerRestClient) do(method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, rc.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if rc.username != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+
+	resp, err := rc.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call the controller REST API (%s %s): %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &controllerRestError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+func scopePath(scope string) string {
+	return "/v1/scopes/" + url.PathEscape(scope)
+}
+
+func streamPath(scope string, stream string) string {
+	return scopePath(scope) + "/streams/" + url.PathEscape(stream)
+}
+
+// ScopeExists returns whether the scope exists
+func (rc *controllerRestClient) ScopeExists(scope string) (bool, error) {
+	err := rc.do(http.MethodGet, scopePath(scope), nil, nil)
+	if isRestStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateScope creates the scope, an existing scope is not an error
+func (rc *controllerRestClient) CreateScope(scope string) error {
+	err := rc.do(http.MethodPost, "/v1/scopes", map[string]string{"scopeName": scope}, nil)
+	if isRestStatus(err, http.StatusConflict) {
+		return nil
+	}
+	return err
+}
+
+// DeleteScope deletes the scope, which fails while the scope has streams.
+// A missing scope is not an error
+func (rc *controllerRestClient) DeleteScope(scope string) error {
+	err := rc.do(http.MethodDelete, scopePath(scope), nil, nil)
+	if isRestStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// GetStream returns the configuration of the stream, or nil when the stream does not exist
+func (rc *controllerRestClient) GetStream(scope string, stream string) (*streamConfig, error) {
+	config := &streamConfig{}
+	err := rc.do(http.MethodGet, streamPath(scope, stream), nil, config)
+	if isRestStatus(err, http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// CreateStream creates the stream with the configuration of the spec
+func (rc *controllerRestClient) CreateStream(s *api.PravegaStream) error {
+	config := &streamConfig{
+		StreamName:      s.GetStreamName(),
+		ScalingPolicy:   s.DesiredScalingPolicy(),
+		RetentionPolicy: s.Spec.RetentionPolicy,
+		StreamTags:      s.Spec.Tags,
+	}
+	err := rc.do(http.MethodPost, scopePath(s.Spec.ScopeName)+"/streams", config, nil)
+	if isRestStatus(err, http.StatusConflict) {
+		return nil
+	}
+	return err
+}
+
+// UpdateStream applies the configuration of the spec to the stream
+func (rc *controllerRestClient) UpdateStream(s *api.PravegaStream) error {
+	config := &streamConfig{
+		ScalingPolicy:   s.DesiredScalingPolicy(),
+		RetentionPolicy: s.Spec.RetentionPolicy,
+		StreamTags:      s.Spec.Tags,
+	}
+	return rc.do(http.MethodPut, streamPath(s.Spec.ScopeName, s.GetStreamName()), config, nil)
+}
+
+// SealStream seals the stream so that no more events can be written to it.
+// A missing stream is not an error
+func (rc *controllerRestClient) SealStream(scope string, stream string) error {
+	err := rc.do(http.MethodPut, streamPath(scope, stream)+"/state", map[string]string{"streamState": "SEALED"}, nil)
+	if isRestStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// DeleteStream deletes a sealed stream. A missing stream is not an error
+func (rc *controllerRestClient) DeleteStream(scope string, stream string) error {
+	err := rc.do(http.MethodDelete, streamPath(scope, stream), nil, nil)
+	if isRestStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
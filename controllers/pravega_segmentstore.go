@@ -13,7 +13,6 @@ package controllers
 import (
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 
 	api "github.com/pravega/pravega-operator/api/v1beta1"
@@ -29,6 +28,14 @@ import (
 const (
 	externalDNSAnnotationKey = "external-dns.alpha.kubernetes.io/hostname"
 	dot                      = "."
+
+	// publishedAddressAnnotationKey and publishedPortAnnotationKey hold the external
+	// address and port of the Segment Store pod, as resolved by the operator
+	publishedAddressAnnotationKey = "pravega.pravega.io/published-address"
+	publishedPortAnnotationKey    = "pravega.pravega.io/published-port"
+
+	publishedAddressVolumeName = "published-address"
+	publishedAddressMountDir   = "/etc/pravega/published-address"
 )
 
 func MakeSegmentStoreStatefulSet(p *api.PravegaCluster) *appsv1.StatefulSet {
@@ -105,80 +112,7 @@ func makeSegmentstorePodSpec(p *api.PravegaCluster) corev1.PodSpec {
 
 	environment = configureTier2Secrets(environment, p.Spec.Pravega)
 
-	// Parse volumes & volumeMounts parameters
-	var hostPathVolumeMounts []string
-	var emptyDirVolumeMounts []string
-	var configMapVolumeMounts []string
-	var ok bool
-
-	var volumes []corev1.Volume
-	var volumeMounts []corev1.VolumeMount
-
-	if _, ok = p.Spec.Pravega.Options["hostPathVolumeMounts"]; ok {
-		hostPathVolumeMounts = strings.Split(p.Spec.Pravega.Options["hostPathVolumeMounts"], ",")
-		for _, vm := range hostPathVolumeMounts {
-			s := strings.Split(vm, "=")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					HostPath: &corev1.HostPathVolumeSource{
-						Path: s[1],
-					},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
-	if _, ok = p.Spec.Pravega.Options["emptyDirVolumeMounts"]; ok {
-		emptyDirVolumeMounts = strings.Split(p.Spec.Pravega.Options["emptyDirVolumeMounts"], ",")
-		for _, vm := range emptyDirVolumeMounts {
-			s := strings.Split(vm, "=")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
-	if _, ok = p.Spec.Pravega.Options["configMapVolumeMounts"]; ok {
-		configMapVolumeMounts = strings.Split(p.Spec.Pravega.Options["configMapVolumeMounts"], ",")
-		for _, vm := range configMapVolumeMounts {
-			p := strings.Split(vm, "=")
-			s := strings.Split(p[0], ":")
-			v := corev1.Volume{
-				Name: s[0],
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: s[0],
-						},
-					},
-				},
-			}
-			volumes = append(volumes, v)
-
-			m := corev1.VolumeMount{
-				Name:      s[0],
-				MountPath: p[1],
-				SubPath:   s[1],
-			}
-			volumeMounts = append(volumeMounts, m)
-		}
-	}
+	volumes, volumeMounts := optionVolumes(p.Spec.Pravega.SegmentStoreOption)
 	if util.IsVersionBelow(p.Spec.Version, "0.7.0") {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      cacheVolumeName,
@@ -186,7 +120,7 @@ func makeSegmentstorePodSpec(p *api.PravegaCluster) corev1.PodSpec {
 		})
 	}
 
-	containerport, _ := strconv.Atoi(p.Spec.Pravega.Options["pravegaservice.service.listener.port"])
+	containerport, _ := p.SegmentStoreBasePorts()
 	podSpec := corev1.PodSpec{
 		Containers: []corev1.Container{
 			{
@@ -238,9 +172,18 @@ func makeSegmentstorePodSpec(p *api.PravegaCluster) corev1.PodSpec {
 				},
 			},
 		},
-		Affinity:    p.Spec.Pravega.SegmentStorePodAffinity,
-		Volumes:     volumes,
-		Tolerations: p.Spec.Pravega.SegmentStorePodTolerations,
+		Affinity:                  p.Spec.Pravega.SegmentStorePodAffinity,
+		Volumes:                   volumes,
+		Tolerations:               p.Spec.Pravega.SegmentStorePodTolerations,
+		TopologySpreadConstraints: p.Spec.Pravega.SegmentStoreTopologySpreadConstraints,
+		PriorityClassName:         p.Spec.Pravega.SegmentStorePriorityClassName,
+		NodeSelector:              p.Spec.Pravega.SegmentStoreNodeSelector,
+	}
+
+	if p.Spec.Pravega.SegmentStoreZoneAware && !util.HasTopologySpreadConstraint(podSpec.TopologySpreadConstraints, util.ZoneTopologyKey) {
+		constraints := make([]corev1.TopologySpreadConstraint, 0, len(podSpec.TopologySpreadConstraints)+1)
+		constraints = append(constraints, podSpec.TopologySpreadConstraints...)
+		podSpec.TopologySpreadConstraints = append(constraints, util.ZoneTopologySpreadConstraint("pravega-segmentstore", p.GetName()))
 	}
 
 	if p.Spec.Pravega.SegmentStoreServiceAccountName != "" {
@@ -274,25 +217,92 @@ func makeSegmentstorePodSpec(p *api.PravegaCluster) corev1.PodSpec {
 
 	configureInfluxDBSecret(&podSpec, p)
 
+	configureSegmentstorePublishedAddress(&podSpec, p)
+
+	configureZookeeperSecrets(&podSpec, p)
+
 	return podSpec
 }
 
+// configureSegmentstorePublishedAddress passes the external address and port published by
+// the operator on the pod annotations to the Segment Store. Environment variables are read
+// from the annotations when the container starts, so an init container waits until the
+// operator has published them
+func configureSegmentstorePublishedAddress(podSpec *corev1.PodSpec, p *api.PravegaCluster) {
+	if !p.Spec.ExternalAccess.IsAddressResolutionEnabled() {
+		return
+	}
+	podSpec.Containers[0].Env = append(podSpec.Containers[0].Env,
+		corev1.EnvVar{
+			Name: "PUBLISHED_ADDRESS",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					APIVersion: "v1",
+					FieldPath:  fmt.Sprintf("metadata.annotations['%s']", publishedAddressAnnotationKey),
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name: "PUBLISHED_PORT",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					APIVersion: "v1",
+					FieldPath:  fmt.Sprintf("metadata.annotations['%s']", publishedPortAnnotationKey),
+				},
+			},
+		},
+	)
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: publishedAddressVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{
+					{
+						Path: "address",
+						FieldRef: &corev1.ObjectFieldSelector{
+							APIVersion: "v1",
+							FieldPath:  fmt.Sprintf("metadata.annotations['%s']", publishedAddressAnnotationKey),
+						},
+					},
+					{
+						Path: "port",
+						FieldRef: &corev1.ObjectFieldSelector{
+							APIVersion: "v1",
+							FieldPath:  fmt.Sprintf("metadata.annotations['%s']", publishedPortAnnotationKey),
+						},
+					},
+				},
+			},
+		},
+	})
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		Name:            "wait-for-published-address",
+		Image:           p.PravegaImage(),
+		ImagePullPolicy: p.Spec.Pravega.Image.PullPolicy,
+		Command: []string{
+			"/bin/sh",
+			"-c",
+			fmt.Sprintf("until [ -s %[1]s/address ] && [ -s %[1]s/port ]; do echo waiting for the operator to publish the external address; sleep 2; done", publishedAddressMountDir),
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      publishedAddressVolumeName,
+				MountPath: publishedAddressMountDir,
+			},
+		},
+	})
+}
+
 func MakeSegmentstoreConfigMap(p *api.PravegaCluster) *corev1.ConfigMap {
 	javaOpts := []string{
 		"-Dpravegaservice.clusterName=" + p.Name,
 	}
+	javaOpts = append(javaOpts, zookeeperJVMOptions(p)...)
 
-	if _, ok := p.Spec.Pravega.Options["pravegaservice.service.listener.port"]; !ok {
-		p.Spec.Pravega.Options["pravegaservice.service.listener.port"] = "12345"
-	}
-
-	if _, ok := p.Spec.Pravega.Options["pravegaservice.admin.listener.port"]; !ok {
-		p.Spec.Pravega.Options["pravegaservice.admin.listener.port"] = "9999"
-	}
-
-	javaOpts = util.OverrideDefaultJVMOptions(javaOpts, p.Spec.Pravega.SegmentStoreJVMOptions)
+	javaOpts = util.OverrideDefaultJVMOptions(javaOpts, p.SegmentStoreMemoryJVMOptions())
 
-	for name, value := range p.Spec.Pravega.Options {
+	for name, value := range pravegaOptions(p, api.OptionComponentSegmentStore) {
 		javaOpts = append(javaOpts, fmt.Sprintf("-D%v=%v", name, value))
 	}
 
@@ -308,15 +318,16 @@ func MakeSegmentstoreConfigMap(p *api.PravegaCluster) *corev1.ConfigMap {
 	configData := map[string]string{
 		"AUTHORIZATION_ENABLED": authEnabledStr,
 		"CLUSTER_NAME":          p.Name,
-		"ZK_URL":                p.Spec.ZookeeperUri,
+		"ZK_URL":                p.ZookeeperConnectString(),
 		"JAVA_OPTS":             strings.Join(javaOpts, " "),
 		"CONTROLLER_URL":        p.PravegaControllerServiceURL(),
 	}
 
 	// Wait for at least 3 Bookies to come up
-	configData["WAIT_FOR"] = p.Spec.BookkeeperUri
+	configData["WAIT_FOR"] = p.BookkeeperUri()
 
-	if p.Spec.ExternalAccess.Enabled {
+	// The Segment Store looks up its external address unless the operator publishes it
+	if p.Spec.ExternalAccess.Enabled && !p.Spec.ExternalAccess.ResolveAddresses {
 		configData["K8_EXTERNAL_ACCESS"] = "true"
 	}
 
@@ -522,8 +533,7 @@ func configureInfluxDBSecret(podSpec *corev1.PodSpec, p *api.PravegaCluster) {
 }
 
 func MakeSegmentStoreHeadlessService(p *api.PravegaCluster) *corev1.Service {
-	serviceport, _ := strconv.Atoi(p.Spec.Pravega.Options["pravegaservice.service.listener.port"])
-	adminPort, _ := strconv.Atoi(p.Spec.Pravega.Options["pravegaservice.admin.listener.port"])
+	serviceport, adminPort := p.SegmentStoreBasePorts()
 	return &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
@@ -587,15 +597,25 @@ func generateDNSAnnotationForSvc(domainName string, podName string) (dnsAnnotati
 	return ssFQDN
 }
 
-func MakeSegmentStoreExternalServices(p *api.PravegaCluster) []*corev1.Service {
+// MakeSegmentStoreExternalServices returns the external services of the Segment Stores. An
+// error is returned when the ports of the services sharing the load balancer IP cannot be
+// allocated, rather than rendering them on ports that may collide
+func MakeSegmentStoreExternalServices(p *api.PravegaCluster) ([]*corev1.Service, error) {
 	var service *corev1.Service
 	serviceType := getSSServiceType(p)
 	services := make([]*corev1.Service, p.Spec.Pravega.SegmentStoreReplicas)
-	serviceport, _ := strconv.Atoi(p.Spec.Pravega.Options["pravegaservice.service.listener.port"])
-	adminPort, _ := strconv.Atoi(p.Spec.Pravega.Options["pravegaservice.admin.listener.port"])
-
-	currentServicePort := int32(serviceport)
-	currentAdminPort := int32(adminPort)
+	serviceport, adminPort := p.SegmentStoreBasePorts()
+
+	// The ports of services sharing the load balancer IP come from the allocation table
+	// persisted in the status, so that they do not shift when the cluster is scaled
+	allocations := &api.ClusterStatus{}
+	if p.Spec.Pravega.SegmentStoreLoadBalancerIP != "" {
+		table, err := p.AllocateSegmentStorePorts()
+		if err != nil {
+			return nil, err
+		}
+		allocations.SegmentStorePortAllocations = table
+	}
 
 	for i := int32(0); i < p.Spec.Pravega.SegmentStoreReplicas; i++ {
 		ssPodName := p.ServiceNameForSegmentStore(i)
@@ -623,13 +643,13 @@ func MakeSegmentStoreExternalServices(p *api.PravegaCluster) []*corev1.Service {
 						Name:       "server",
 						Port:       int32(serviceport),
 						Protocol:   "TCP",
-						TargetPort: intstr.FromInt(serviceport),
+						TargetPort: intstr.FromInt(int(serviceport)),
 					},
 					{
 						Name:       "cli",
 						Port:       int32(adminPort),
 						Protocol:   "TCP",
-						TargetPort: intstr.FromInt(adminPort),
+						TargetPort: intstr.FromInt(int(adminPort)),
 					},
 				},
 				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
@@ -644,22 +664,59 @@ func MakeSegmentStoreExternalServices(p *api.PravegaCluster) []*corev1.Service {
 			service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
 		}
 		if p.Spec.Pravega.SegmentStoreLoadBalancerIP != "" {
-			for util.ContainsElement(p.Spec.ReservedPortList, currentServicePort) {
-				currentServicePort++
+			if allocation := allocations.GetSegmentStorePortAllocation(i); allocation != nil {
+				service.Spec.Ports[0].Port = allocation.ServicePort
+				service.Spec.Ports[1].Port = allocation.AdminPort
 			}
-			service.Spec.Ports[0].Port = currentServicePort
-			for util.ContainsElement(p.Spec.ReservedPortList, currentAdminPort) {
-				currentAdminPort++
-			}
-			service.Spec.Ports[1].Port = currentAdminPort
 			service.Spec.LoadBalancerIP = p.Spec.Pravega.SegmentStoreLoadBalancerIP
-			currentServicePort++
-			currentAdminPort++
 		}
 		services[i] = service
 
 	}
-	return services
+	return services, nil
+}
+
+// ResolveSegmentStorePublishedAddress returns the address and port on which clients reach
+// the Segment Store behind the given external service. The node is the one running the
+// Segment Store pod and is only used for NodePort services. An empty address is returned
+// while the service has not been assigned an external address yet
+func ResolveSegmentStorePublishedAddress(p *api.PravegaCluster, svc *corev1.Service, node *corev1.Node) (address string, port int32) {
+	if len(svc.Spec.Ports) == 0 {
+		return "", 0
+	}
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		port = svc.Spec.Ports[0].Port
+		if dnsName := generateDNSAnnotationForSvc(p.Spec.ExternalAccess.DomainName, svc.Name); dnsName != "" {
+			return strings.TrimSuffix(dnsName, dot), port
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.Hostname != "" {
+				return ingress.Hostname, port
+			}
+			if ingress.IP != "" {
+				return ingress.IP, port
+			}
+		}
+		return "", 0
+	case corev1.ServiceTypeNodePort:
+		if node == nil || svc.Spec.Ports[0].NodePort == 0 {
+			return "", 0
+		}
+		for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+			for _, nodeAddress := range node.Status.Addresses {
+				if nodeAddress.Type == addressType && nodeAddress.Address != "" {
+					return nodeAddress.Address, svc.Spec.Ports[0].NodePort
+				}
+			}
+		}
+		return "", 0
+	default:
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			return "", 0
+		}
+		return svc.Spec.ClusterIP, svc.Spec.Ports[0].Port
+	}
 }
 
 func MakeSegmentstorePodDisruptionBudget(p *api.PravegaCluster) *policyv1.PodDisruptionBudget {
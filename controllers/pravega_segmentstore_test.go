@@ -186,10 +186,59 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(strings.Contains(javaOpts, "-Dpravegaservice.clusterName=default")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxDirectMemorySize=1g")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxRAMPercentage=50.0")).Should(BeTrue())
-					Ω(strings.Contains(javaOpts, "-Dpravegaservice.service.listener.port=12345")).Should(BeTrue())
+					Ω(strings.Contains(javaOpts, "-Dpravegaservice.listeningPort=12345")).Should(BeTrue())
 					Ω(err).Should(BeNil())
 				})
 
+				It("should not add the default listener ports to the spec", func() {
+					_ = pravega.MakeSegmentstoreConfigMap(p)
+					Ω(p.Spec.Pravega.Options).ShouldNot(HaveKey("pravegaservice.service.listener.port"))
+					Ω(p.Spec.Pravega.Options).ShouldNot(HaveKey("pravegaservice.admin.listener.port"))
+				})
+
+				It("should pass the Segment Store options", func() {
+					p.Spec.Pravega.SegmentStoreOptions = map[string]string{
+						"dummy-key":                          "segment-store-value",
+						"pravegaservice.admin.listener.port": "9998",
+					}
+					p.Spec.Pravega.ControllerOptions = map[string]string{
+						"controller-key": "controller-value",
+					}
+					cm := pravega.MakeSegmentstoreConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).Should(ContainSubstring("-Ddummy-key=segment-store-value"))
+					Ω(javaOpts).Should(ContainSubstring("-Dpravegaservice.admin.listener.port=9998"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("controller-key"))
+				})
+
+				It("should only pass the options read by the segment store", func() {
+					p.Spec.Pravega.Options["controller.retention.thread.count"] = "10"
+					p.Spec.Pravega.Options["pravegaservice.admin.listener.port"] = "9999"
+					cm := pravega.MakeSegmentstoreConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).Should(ContainSubstring("-Dpravegaservice.admin.listener.port=9999"))
+					Ω(javaOpts).Should(ContainSubstring("-Ddummy-key=dummy-value"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("controller.retention.thread.count"))
+				})
+
+				It("should derive the memory settings left unset from the memory limit", func() {
+					p.Spec.Pravega.SegmentStoreMemory = &v1beta1.SegmentStoreMemorySpec{AutoSize: true}
+					cm := pravega.MakeSegmentstoreConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).Should(ContainSubstring("-Xmx1536m"))
+					Ω(javaOpts).Should(ContainSubstring("-XX:MaxDirectMemorySize=1g"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("-XX:MaxDirectMemorySize=1024m"))
+					Ω(javaOpts).Should(ContainSubstring("-Dpravegaservice.cacheMaxSize=805306368"))
+					Ω(p.Spec.Pravega.SegmentStoreJVMOptions).ShouldNot(ContainElement("-Xmx1536m"))
+				})
+
+				It("should not derive the memory settings unless autoSize is enabled", func() {
+					cm := pravega.MakeSegmentstoreConfigMap(p)
+					javaOpts := cm.Data["JAVA_OPTS"]
+					Ω(javaOpts).ShouldNot(ContainSubstring("-Xmx"))
+					Ω(javaOpts).ShouldNot(ContainSubstring("cacheMaxSize"))
+				})
+
 				It("should create a config-map with empty tier2", func() {
 					p.Spec.Pravega.LongTermStorage = &v1beta1.LongTermStorageSpec{}
 					cm := pravega.MakeSegmentstoreConfigMap(p)
@@ -356,7 +405,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(strings.Contains(javaOpts, "-Dpravegaservice.clusterName=default")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxDirectMemorySize=1g")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxRAMPercentage=50.0")).Should(BeTrue())
-					Ω(strings.Contains(javaOpts, "-Dpravegaservice.service.listener.port=443")).Should(BeTrue())
+					Ω(strings.Contains(javaOpts, "-Dpravegaservice.listeningPort=443")).Should(BeTrue())
 					Ω(err).Should(BeNil())
 				})
 				It("should create a stateful set with filesystem as longtermstorage", func() {
@@ -484,7 +533,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(strings.Contains(javaOpts, "-Dpravegaservice.clusterName=default")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxDirectMemorySize=1g")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxRAMPercentage=50.0")).Should(BeTrue())
-					Ω(strings.Contains(javaOpts, "-Dpravegaservice.service.listener.port=12345")).Should(BeTrue())
+					Ω(strings.Contains(javaOpts, "-Dpravegaservice.listeningPort=12345")).Should(BeTrue())
 					Ω(err).Should(BeNil())
 				})
 				It("should create a stateful set", func() {
@@ -502,7 +551,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(err).Should(BeNil())
 				})
 				It("should set external access service type to LoadBalancer", func() {
-					_ = pravega.MakeSegmentStoreExternalServices(p)
+					_, err = pravega.MakeSegmentStoreExternalServices(p)
 					Ω(err).Should(BeNil())
 				})
 			})
@@ -514,7 +563,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 
 				})
 				It("should create external service with access type loadbalancer", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
 					Ω(svc[0].Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
 					Ω(err).Should(BeNil())
 				})
@@ -524,7 +573,8 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreExternalTrafficPolicy = "cluster"
 				})
 				It("should create external service with ExternalTrafficPolicy type cluster", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
+					Ω(err).Should(BeNil())
 					Ω(svc[0].Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyTypeCluster))
 				})
 			})
@@ -533,10 +583,25 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreLoadBalancerIP = "10.240.12.18"
 				})
 				It("should create external service with LoadBalancerIP", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
+					Ω(err).Should(BeNil())
 					Ω(svc[0].Spec.LoadBalancerIP).To(Equal("10.240.12.18"))
 				})
 			})
+			Context("Create External service with LoadBalancerIP and persisted port allocations", func() {
+				BeforeEach(func() {
+					p.Spec.Pravega.SegmentStoreLoadBalancerIP = "10.240.12.18"
+					p.Status.SegmentStorePortAllocations = []v1beta1.SegmentStorePortAllocation{
+						{Ordinal: 0, ServicePort: 20000, AdminPort: 21000},
+					}
+				})
+				It("should expose the allocated ports", func() {
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
+					Ω(err).Should(BeNil())
+					Ω(svc[0].Spec.Ports[0].Port).To(Equal(int32(20000)))
+					Ω(svc[0].Spec.Ports[1].Port).To(Equal(int32(21000)))
+				})
+			})
 			Context("Create External service with external service type empty", func() {
 				BeforeEach(func() {
 					m := make(map[string]string)
@@ -544,7 +609,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreExternalServiceType = ""
 				})
 				It("should create the service with external access type ClusterIP", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
 					Ω(svc[0].Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
 					Ω(err).Should(BeNil())
 				})
@@ -654,7 +719,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(strings.Contains(javaOpts, "-Dpravegaservice.clusterName=default")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxDirectMemorySize=1g")).Should(BeTrue())
 					Ω(strings.Contains(javaOpts, "-XX:MaxRAMPercentage=50.0")).Should(BeTrue())
-					Ω(strings.Contains(javaOpts, "-Dpravegaservice.service.listener.port=12345")).Should(BeTrue())
+					Ω(strings.Contains(javaOpts, "-Dpravegaservice.listeningPort=12345")).Should(BeTrue())
 					Ω(err).Should(BeNil())
 				})
 				It("should create a stateful set", func() {
@@ -672,7 +737,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 					Ω(err).Should(BeNil())
 				})
 				It("should set external access service type to LoadBalancer", func() {
-					_ = pravega.MakeSegmentStoreExternalServices(p)
+					_, err = pravega.MakeSegmentStoreExternalServices(p)
 					Ω(err).Should(BeNil())
 				})
 			})
@@ -684,7 +749,7 @@ var _ = Describe("PravegaSegmentstore", func() {
 
 				})
 				It("should create external service with access type loadbalancer", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
 					Ω(svc[0].Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
 					Ω(err).Should(BeNil())
 				})
@@ -694,7 +759,8 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreExternalTrafficPolicy = "cluster"
 				})
 				It("should create external service with ExternalTrafficPolicy type cluster", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
+					Ω(err).Should(BeNil())
 					Ω(svc[0].Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyTypeCluster))
 				})
 			})
@@ -703,7 +769,8 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreLoadBalancerIP = "10.240.12.18"
 				})
 				It("should create external service with LoadBalancerIP", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
+					Ω(err).Should(BeNil())
 					Ω(svc[0].Spec.LoadBalancerIP).To(Equal("10.240.12.18"))
 				})
 			})
@@ -714,11 +781,146 @@ var _ = Describe("PravegaSegmentstore", func() {
 					p.Spec.Pravega.SegmentStoreExternalServiceType = ""
 				})
 				It("should create the service with external access type ClusterIP", func() {
-					svc := pravega.MakeSegmentStoreExternalServices(p)
+					svc, err := pravega.MakeSegmentStoreExternalServices(p)
 					Ω(svc[0].Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
 					Ω(err).Should(BeNil())
 				})
 			})
 		})
+
+		Context("With zone awareness enabled", func() {
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Pravega: &v1beta1.PravegaSpec{
+						SegmentStoreReplicas:          3,
+						SegmentStoreZoneAware:         true,
+						SegmentStorePriorityClassName: "pravega-critical",
+						SegmentStoreNodeSelector: map[string]string{
+							"pravega": "segmentstore",
+						},
+					},
+				}
+				p.WithDefaults()
+			})
+
+			It("should spread the pods across zones", func() {
+				podTemplate := pravega.MakeSegmentStorePodTemplate(p)
+				Ω(podTemplate.Spec.TopologySpreadConstraints).Should(HaveLen(1))
+				constraint := podTemplate.Spec.TopologySpreadConstraints[0]
+				Ω(constraint.TopologyKey).Should(Equal("topology.kubernetes.io/zone"))
+				Ω(constraint.MaxSkew).Should(Equal(int32(1)))
+				Ω(constraint.LabelSelector.MatchLabels["component"]).Should(Equal("pravega-segmentstore"))
+				Ω(constraint.LabelSelector.MatchLabels["pravega_cluster"]).Should(Equal("default"))
+			})
+
+			It("should set the priority class name and node selector", func() {
+				podTemplate := pravega.MakeSegmentStorePodTemplate(p)
+				Ω(podTemplate.Spec.PriorityClassName).Should(Equal("pravega-critical"))
+				Ω(podTemplate.Spec.NodeSelector["pravega"]).Should(Equal("segmentstore"))
+			})
+
+			It("should enable the rack aware write quorum", func() {
+				cm := pravega.MakeSegmentstoreConfigMap(p)
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dbookkeeper.write.quorum.racks.minimumCount.enable=true"))
+			})
+
+			It("should not add a zone constraint when one is already given", func() {
+				p.Spec.Pravega.SegmentStoreTopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+					{
+						MaxSkew:           2,
+						TopologyKey:       "topology.kubernetes.io/zone",
+						WhenUnsatisfiable: corev1.DoNotSchedule,
+					},
+				}
+				podTemplate := pravega.MakeSegmentStorePodTemplate(p)
+				Ω(podTemplate.Spec.TopologySpreadConstraints).Should(HaveLen(1))
+				Ω(podTemplate.Spec.TopologySpreadConstraints[0].MaxSkew).Should(Equal(int32(2)))
+			})
+		})
+
+		Context("With external addresses resolved by the operator", func() {
+			var svc *corev1.Service
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled:          true,
+						Type:             corev1.ServiceTypeLoadBalancer,
+						ResolveAddresses: true,
+					},
+					Pravega: &v1beta1.PravegaSpec{
+						SegmentStoreReplicas: 1,
+					},
+				}
+				p.WithDefaults()
+				svc = &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: p.ServiceNameForSegmentStore(0),
+					},
+					Spec: corev1.ServiceSpec{
+						Type: corev1.ServiceTypeLoadBalancer,
+						Ports: []corev1.ServicePort{
+							{Name: "server", Port: 12345, NodePort: 31234},
+						},
+						ClusterIP: "10.0.0.10",
+					},
+				}
+			})
+
+			It("should read the published address from the pod annotations", func() {
+				podTemplate := pravega.MakeSegmentStorePodTemplate(p)
+				env := podTemplate.Spec.Containers[0].Env
+				Ω(env[len(env)-2].Name).Should(Equal("PUBLISHED_ADDRESS"))
+				Ω(env[len(env)-2].ValueFrom.FieldRef.FieldPath).Should(Equal("metadata.annotations['pravega.pravega.io/published-address']"))
+				Ω(env[len(env)-1].Name).Should(Equal("PUBLISHED_PORT"))
+				Ω(podTemplate.Spec.InitContainers).Should(HaveLen(1))
+				Ω(podTemplate.Spec.InitContainers[0].Name).Should(Equal("wait-for-published-address"))
+			})
+
+			It("should not let the segment store look up its external address", func() {
+				cm := pravega.MakeSegmentstoreConfigMap(p)
+				Ω(cm.Data).ShouldNot(HaveKey("K8_EXTERNAL_ACCESS"))
+			})
+
+			It("should wait for the load balancer address", func() {
+				address, _ := pravega.ResolveSegmentStorePublishedAddress(p, svc, nil)
+				Ω(address).Should(BeEmpty())
+			})
+
+			It("should resolve the load balancer address", func() {
+				svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "34.66.68.236"}}
+				address, port := pravega.ResolveSegmentStorePublishedAddress(p, svc, nil)
+				Ω(address).Should(Equal("34.66.68.236"))
+				Ω(port).Should(Equal(int32(12345)))
+			})
+
+			It("should resolve the DNS name when a domain name is given", func() {
+				p.Spec.ExternalAccess.DomainName = "example.com"
+				address, _ := pravega.ResolveSegmentStorePublishedAddress(p, svc, nil)
+				Ω(address).Should(Equal(p.ServiceNameForSegmentStore(0) + ".example.com"))
+			})
+
+			It("should resolve the node address and node port", func() {
+				svc.Spec.Type = corev1.ServiceTypeNodePort
+				node := &corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							{Type: corev1.NodeInternalIP, Address: "192.168.0.4"},
+							{Type: corev1.NodeExternalIP, Address: "35.1.2.3"},
+						},
+					},
+				}
+				address, port := pravega.ResolveSegmentStorePublishedAddress(p, svc, node)
+				Ω(address).Should(Equal("35.1.2.3"))
+				Ω(port).Should(Equal(int32(31234)))
+			})
+
+			It("should resolve the cluster IP", func() {
+				svc.Spec.Type = corev1.ServiceTypeClusterIP
+				address, port := pravega.ResolveSegmentStorePublishedAddress(p, svc, nil)
+				Ω(address).Should(Equal("10.0.0.10"))
+				Ω(port).Should(Equal(int32(12345)))
+			})
+		})
 	})
 })
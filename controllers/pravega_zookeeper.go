@@ -0,0 +1,153 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const zkJaasKey = "jaas.conf"
+
+// zookeeperConfig returns the configuration of the connection of the operator to the
+// ZooKeeper of the cluster, loading its TLS and auth secrets
+func zookeeperConfig(c client.Client, p *api.PravegaCluster) (*util.ZookeeperConfig, error) {
+	zkConfig := &util.ZookeeperConfig{
+		Servers: p.ZookeeperServers(),
+		Chroot:  p.ZookeeperChroot(),
+	}
+	z := p.Spec.Zookeeper
+	if z.IsTLSEnabled() {
+		secret, err := getZookeeperSecret(c, p, z.TLSSecret)
+		if err != nil {
+			return nil, err
+		}
+		if err = zkConfig.SetTLSCa(secret.Data[util.ZookeeperTLSCaKey]); err != nil {
+			return nil, fmt.Errorf("invalid zookeeper tls secret (%s): %v", z.TLSSecret, err)
+		}
+	}
+	if z.IsAuthEnabled() {
+		secret, err := getZookeeperSecret(c, p, z.AuthSecret)
+		if err != nil {
+			return nil, err
+		}
+		err = zkConfig.SetDigest(string(secret.Data[util.ZookeeperAuthUsernameKey]), string(secret.Data[util.ZookeeperAuthPasswordKey]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zookeeper auth secret (%s): %v", z.AuthSecret, err)
+		}
+	}
+	return zkConfig, nil
+}
+
+func getZookeeperSecret(c client.Client, p *api.PravegaCluster, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zookeeper secret (%s): %v", name, err)
+	}
+	return secret, nil
+}
+
+// zookeeperJVMOptions returns the system properties of the ZooKeeper client of the
+// Controller and Segment Store
+func zookeeperJVMOptions(p *api.PravegaCluster) []string {
+	var javaOpts []string
+	if p.Spec.Zookeeper.IsTLSEnabled() {
+		javaOpts = append(javaOpts,
+			"-Dzookeeper.client.secure=true",
+			"-Dzookeeper.clientCnxnSocket=org.apache.zookeeper.ClientCnxnSocketNetty",
+			fmt.Sprintf("-Dzookeeper.ssl.trustStore.location=%s/%s", zkTLSMountDir, util.ZookeeperTLSCaKey),
+			"-Dzookeeper.ssl.trustStore.type=PEM",
+		)
+	}
+	if p.Spec.Zookeeper.IsAuthEnabled() {
+		javaOpts = append(javaOpts, fmt.Sprintf("-Djava.security.auth.login.config=%s/%s", zkAuthMountDir, zkJaasKey))
+	}
+	return javaOpts
+}
+
+// configureZookeeperSecrets mounts the CA certificate and the JAAS configuration used by
+// the ZooKeeper client
+func configureZookeeperSecrets(podSpec *corev1.PodSpec, p *api.PravegaCluster) {
+	if p.Spec.Zookeeper.IsTLSEnabled() {
+		addSecretVolumeWithMount(podSpec, p, zkTLSVolumeName, p.Spec.Zookeeper.TLSSecret, zkTLSVolumeName, zkTLSMountDir)
+	}
+	if p.Spec.Zookeeper.IsAuthEnabled() {
+		addSecretVolumeWithMount(podSpec, p, zkAuthVolumeName, p.SecretNameForZookeeperJaas(), zkAuthVolumeName, zkAuthMountDir)
+	}
+}
+
+// MakeZookeeperJaasSecret returns the Secret holding the JAAS configuration of the digest
+// authentication of the Pravega processes to ZooKeeper
+func MakeZookeeperJaasSecret(p *api.PravegaCluster, username string, password string) *corev1.Secret {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	jaas := fmt.Sprintf("Client {\n  org.apache.zookeeper.server.auth.DigestLoginModule required\n  username=\"%s\"\n  password=\"%s\";\n};\n",
+		quote.Replace(username), quote.Replace(password))
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.SecretNameForZookeeperJaas(),
+			Namespace: p.Namespace,
+			Labels:    p.LabelsForPravegaCluster(),
+		},
+		Data: map[string][]byte{
+			zkJaasKey: []byte(jaas),
+		},
+	}
+}
+
+// reconcileZookeeperJaasSecret keeps the JAAS configuration in sync with the auth secret.
+// The pods read it when they start
+func (r *PravegaClusterReconciler) reconcileZookeeperJaasSecret(p *api.PravegaCluster) error {
+	if !p.Spec.Zookeeper.IsAuthEnabled() {
+		return nil
+	}
+	auth, err := getZookeeperSecret(r.Client, p, p.Spec.Zookeeper.AuthSecret)
+	if err != nil {
+		return err
+	}
+	username := string(auth.Data[util.ZookeeperAuthUsernameKey])
+	password := string(auth.Data[util.ZookeeperAuthPasswordKey])
+	if username == "" || password == "" {
+		return fmt.Errorf("zookeeper auth secret (%s) should have non empty %s and %s keys", auth.Name,
+			util.ZookeeperAuthUsernameKey, util.ZookeeperAuthPasswordKey)
+	}
+
+	secret := MakeZookeeperJaasSecret(p, username, password)
+	controllerutil.SetControllerReference(p, secret, r.Scheme)
+	current := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: p.Namespace}, current)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return r.Client.Create(context.TODO(), secret)
+		}
+		return err
+	}
+	if !reflect.DeepEqual(current.Data, secret.Data) {
+		current.Data = secret.Data
+		return r.Client.Update(context.TODO(), current)
+	}
+	return nil
+}
@@ -20,20 +20,24 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -52,11 +56,15 @@ const ReconcileTime = 30 * time.Second
 type PravegaClusterReconciler struct {
 	Client client.Client
 	Scheme *runtime.Scheme
+	// MaxConcurrentReconciles is the number of clusters reconciled concurrently, 1 when unset
+	MaxConcurrentReconciles int
 }
 
 //+kubebuilder:rbac:groups=pravegaclusters.pravega.pravega.io,resources=pravegaclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=pravegaclusters.pravega.pravega.io,resources=pravegaclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=pravegaclusters.pravega.pravega.io,resources=pravegaclusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=bookkeeper.pravega.io,resources=bookkeeperclusters,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -86,15 +94,14 @@ func (r *PravegaClusterReconciler) Reconcile(ctx context.Context, request ctrl.R
 		return reconcile.Result{}, err
 	}
 
-	// Set default configuration for unspecified values
-	changed := pravegaCluster.WithDefaults()
-	if changed {
-		log.Printf("Setting default settings for pravega-cluster: %s", request.Name)
-		if err = r.Client.Update(context.TODO(), pravegaCluster); err != nil {
-			log.Printf("Error applying defaults on Pravega Cluster %v", err)
-			return reconcile.Result{}, err
-		}
-		return reconcile.Result{Requeue: true}, nil
+	// The defaults are stored by the mutating webhook. They are only applied in memory for
+	// the clusters created while it was disabled, so that the spec is never written back
+	if pravegaCluster.WithDefaults() {
+		log.Printf("Applying default settings in memory for pravega-cluster: %s", request.Name)
+	}
+
+	if pravegaCluster.IsDryRun() && pravegaCluster.DeletionTimestamp.IsZero() {
+		return r.reconcileDryRun(pravegaCluster)
 	}
 
 	err = r.run(pravegaCluster)
@@ -102,15 +109,60 @@ func (r *PravegaClusterReconciler) Reconcile(ctx context.Context, request ctrl.R
 		log.Printf("failed to reconcile pravega cluster (%s): %v", pravegaCluster.Name, err)
 		return reconcile.Result{}, err
 	}
+	if !pravegaCluster.DeletionTimestamp.IsZero() {
+		return reconcile.Result{RequeueAfter: cleanupRequeueTime(pravegaCluster)}, nil
+	}
+	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+}
+
+// reconcileDryRun reports in the status the changes the operator would make to the cluster,
+// without applying them. The status of the pods and of the bookies is still kept up to date
+func (r *PravegaClusterReconciler) reconcileDryRun(p *pravegav1beta1.PravegaCluster) (ctrl.Result, error) {
+	plan, err := PlanClusterChanges(r.Client, p)
+	if err != nil {
+		log.Printf("failed to plan the changes of pravega cluster (%s): %v", p.Name, err)
+		return reconcile.Result{}, err
+	}
+	p.Status.DryRun = plan
+	err = r.reconcileClusterStatus(p)
+	if err != nil {
+		log.Printf("failed to update the dry run status of pravega cluster (%s): %v", p.Name, err)
+		return reconcile.Result{}, err
+	}
 	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
 }
 
 func (r *PravegaClusterReconciler) run(p *pravegav1beta1.PravegaCluster) (err error) {
+	// The plan of a previous dry run is outdated once the changes get applied
+	p.Status.DryRun = nil
 
 	err = r.reconcileFinalizers(p)
 	if err != nil {
 		return fmt.Errorf("failed to reconcile finalizers %v", err)
 	}
+	// A deleted cluster is not deployed again while its data get cleaned up
+	if !p.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// The ZooKeeper and BookKeeper clusters referred to are ready before the cluster is deployed
+	ready, err := r.reconcileDependencies(p)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile dependencies %v", err)
+	}
+	if !ready {
+		return nil
+	}
+
+	err = r.reconcileZookeeperJaasSecret(p)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile zookeeper jaas secret %v", err)
+	}
+
+	err = r.reconcileImmutableSettings(p)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile immutable settings %v", err)
+	}
 
 	err = r.reconcileConfigMap(p)
 	if err != nil {
@@ -122,16 +174,45 @@ func (r *PravegaClusterReconciler) run(p *pravegav1beta1.PravegaCluster) (err er
 		return fmt.Errorf("failed to reconcile pdb %v", err)
 	}
 
+	err = r.reconcileSegmentStorePortAllocations(p)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile segment store port allocations %v", err)
+	}
+
 	err = r.reconcileService(p)
 	if err != nil {
 		return fmt.Errorf("failed to reconcile service %v", err)
 	}
 
+	err = r.reconcileControllerRoutes(p)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile controller routes %v", err)
+	}
+
+	// The metadata of a backup are restored into an empty ZooKeeper before the cluster starts
+	restored, err := r.reconcileMetadataRestore(p)
+	if err != nil {
+		return fmt.Errorf("failed to restore metadata %v", err)
+	}
+	if !restored {
+		return nil
+	}
+
 	err = r.deployCluster(p)
 	if err != nil {
 		return fmt.Errorf("failed to deploy cluster: %v", err)
 	}
 
+	err = r.reconcileSegmentStorePublishedAddresses(p)
+	if err != nil {
+		return fmt.Errorf("failed to publish segment store addresses: %v", err)
+	}
+
+	err = r.rollSegmentStorePods(p)
+	if err != nil {
+		return fmt.Errorf("failed to restart segment store pods: %v", err)
+	}
+
 	err = r.syncClusterSize(p)
 	if err != nil {
 		return fmt.Errorf("failed to sync cluster size: %v", err)
@@ -165,32 +246,74 @@ func (r *PravegaClusterReconciler) reconcileFinalizers(p *pravegav1beta1.Pravega
 	p.ObjectMeta.ResourceVersion = currentPravegaCluster.ObjectMeta.ResourceVersion
 	if p.DeletionTimestamp.IsZero() && !config.DisableFinalizer {
 		if !util.ContainsString(p.ObjectMeta.Finalizers, util.ZkFinalizer) {
+			// Patched, so that the defaults applied in memory are not stored
+			patch := client.MergeFrom(p.DeepCopy())
 			p.ObjectMeta.Finalizers = append(p.ObjectMeta.Finalizers, util.ZkFinalizer)
-			if err = r.Client.Update(context.TODO(), p); err != nil {
+			if err = r.Client.Patch(context.TODO(), p, patch); err != nil {
 				return fmt.Errorf("failed to add the finalizer (%s): %v", p.Name, err)
 			}
+			p.WithDefaults()
 		}
 	} else {
-		if util.ContainsString(p.ObjectMeta.Finalizers, util.ZkFinalizer) {
-			p.ObjectMeta.Finalizers = util.RemoveString(p.ObjectMeta.Finalizers, util.ZkFinalizer)
-			if err = r.Client.Update(context.TODO(), p); err != nil {
-				return fmt.Errorf("failed to update Pravega object (%s): %v", p.Name, err)
-			}
-			if err = r.cleanUpZookeeperMeta(p); err != nil {
-				// emit an event for zk metadata cleanup failure
-				message := fmt.Sprintf("failed to cleanup pravega metadata from zookeeper (znode path: /pravega/%s): %v", p.Name, err)
-				event := p.NewApplicationEvent("ZKMETA_CLEANUP_ERROR", "ZK Metadata Cleanup Failed", message, "Error")
-				pubErr := r.Client.Create(context.TODO(), event)
-				if pubErr != nil {
-					log.Printf("Error publishing zk metadata cleanup failure event to k8s. %v", pubErr)
-				}
-				return fmt.Errorf(message)
+		if !util.ContainsString(p.ObjectMeta.Finalizers, util.ZkFinalizer) {
+			return nil
+		}
+		if !p.DeletionTimestamp.IsZero() {
+			// The finalizer is kept until the data of the cluster are cleaned up
+			done, err := r.reconcileClusterCleanup(p)
+			if err != nil || !done {
+				return err
 			}
 		}
+		patch := client.MergeFrom(p.DeepCopy())
+		p.ObjectMeta.Finalizers = util.RemoveString(p.ObjectMeta.Finalizers, util.ZkFinalizer)
+		if err = r.Client.Patch(context.TODO(), p, patch); err != nil {
+			return fmt.Errorf("failed to update Pravega object (%s): %v", p.Name, err)
+		}
+		p.WithDefaults()
 	}
 	return nil
 }
 
+// updateClusterStatus updates the status of the cluster. The cluster is overwritten with the
+// response of the API server, so the defaults only applied in memory are applied again
+func (r *PravegaClusterReconciler) updateClusterStatus(p *pravegav1beta1.PravegaCluster) error {
+	err := r.Client.Status().Update(context.TODO(), p)
+	p.WithDefaults()
+	return err
+}
+
+// reconcileImmutableSettings records in the status the settings which cannot be changed once the
+// cluster is deployed, against which the admission webhook validates the updates of the spec
+func (r *PravegaClusterReconciler) reconcileImmutableSettings(p *pravegav1beta1.PravegaCluster) (err error) {
+	if p.Status.ImmutableSettings != nil {
+		return nil
+	}
+	p.Status.ImmutableSettings, err = immutableSettings(r.Client, p)
+	if err != nil {
+		return err
+	}
+	return r.updateClusterStatus(p)
+}
+
+// immutableSettings returns the immutable settings of the cluster, read from its config maps
+// when it was deployed before the settings were recorded
+func immutableSettings(c client.Reader, p *pravegav1beta1.PravegaCluster) (*pravegav1beta1.ImmutableSettings, error) {
+	configMaps := make([]*corev1.ConfigMap, 2)
+	for i, name := range []string{p.ConfigMapNameForController(), p.ConfigMapNameForSegmentstore()} {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, cm)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get configmap (%s): %v", name, err)
+		}
+		configMaps[i] = cm
+	}
+	return p.NewImmutableSettings(configMaps[0], configMaps[1]), nil
+}
+
 func (r *PravegaClusterReconciler) reconcileConfigMap(p *pravegav1beta1.PravegaCluster) (err error) {
 
 	err = r.reconcileControllerConfigMap(p)
@@ -219,6 +342,8 @@ func (r *PravegaClusterReconciler) reconcileControllerConfigMap(p *pravegav1beta
 			if err != nil && !errors.IsAlreadyExists(err) {
 				return err
 			}
+			r.publishUnknownOptions(p)
+			r.publishRenamedOptions(p, pravegav1beta1.OptionComponentController)
 		}
 	} else {
 		currentConfigMap := &corev1.ConfigMap{}
@@ -230,9 +355,11 @@ func (r *PravegaClusterReconciler) reconcileControllerConfigMap(p *pravegav1beta
 			if err != nil {
 				return err
 			}
+			r.publishUnknownOptions(p)
+			r.publishRenamedOptions(p, pravegav1beta1.OptionComponentController)
 			//restarting controller pods
 			if !r.checkVersionUpgradeTriggered(p) {
-				err = r.restartDeploymentPod(p)
+				err = r.restartControllerPods(p)
 				if err != nil {
 					return err
 				}
@@ -254,6 +381,7 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreConfigMap(p *pravegav1be
 			if err != nil && !errors.IsAlreadyExists(err) {
 				return err
 			}
+			r.publishRenamedOptions(p, pravegav1beta1.OptionComponentSegmentStore)
 		}
 	} else {
 		currentConfigMap := &corev1.ConfigMap{}
@@ -266,10 +394,10 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreConfigMap(p *pravegav1be
 			if err != nil {
 				return err
 			}
+			r.publishRenamedOptions(p, pravegav1beta1.OptionComponentSegmentStore)
 			//restarting sts pods
 			if !r.checkVersionUpgradeTriggered(p) && !segmentStorePortUpdated {
-				err = r.restartStsPod(p)
-
+				err = r.restartSegmentStorePods(p)
 				if err != nil {
 					return err
 				}
@@ -279,16 +407,10 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreConfigMap(p *pravegav1be
 	return nil
 }
 func (r *PravegaClusterReconciler) checkSegmentStorePortUpdated(p *pravegav1beta1.PravegaCluster, cm *corev1.ConfigMap) bool {
-	if val, ok := p.Spec.Pravega.Options["pravegaservice.service.listener.port"]; ok {
-		eq := false
-		data := strings.Split(cm.Data["JAVA_OPTS"], " ")
-		key := fmt.Sprintf("-Dpravegaservice.service.listener.port=%v", val)
-		for _, checkstring := range data {
-			if checkstring == key {
-				eq = true
-			}
-		}
-		if !eq {
+	if val, ok := pravegav1beta1.FindOption(p.Spec.Pravega.ComponentOptions(pravegav1beta1.OptionComponentSegmentStore), "pravegaservice.service.listener.port"); ok {
+		// the port may be set under its name of a previous Pravega version
+		deployed, found := pravegav1beta1.JavaOption(cm, "pravegaservice.service.listener.port")
+		if !found || deployed != val {
 			return true
 		}
 	}
@@ -368,6 +490,34 @@ func (r *PravegaClusterReconciler) updatePdb(currentPdb *policyv1.PodDisruptionB
 	return nil
 }
 
+// reconcileSegmentStorePortAllocations persists the ports assigned to the Segment Store
+// external services sharing the load balancer IP before the services get created, so the
+// assignment survives operator restarts
+func (r *PravegaClusterReconciler) reconcileSegmentStorePortAllocations(p *pravegav1beta1.PravegaCluster) (err error) {
+	if !p.Spec.ExternalAccess.Enabled || p.Spec.Pravega.SegmentStoreLoadBalancerIP == "" {
+		return nil
+	}
+	allocations, err := p.AllocateSegmentStorePorts()
+	if err != nil {
+		message := fmt.Sprintf("failed to allocate the ports of the segment store external services: %v", err)
+		event := p.NewEvent("PORT_ALLOCATION_FAILED", pravegav1beta1.PortAllocationFailedReason, message, "Warning")
+		if err := r.Client.Create(context.TODO(), event); err != nil {
+			log.Printf("Error publishing port allocation failed event to k8s. %v", err)
+		}
+		return err
+	}
+	if reflect.DeepEqual(allocations, p.Status.SegmentStorePortAllocations) {
+		return nil
+	}
+	p.Status.SegmentStorePortAllocations = allocations
+	err = r.updateClusterStatus(p)
+	if err != nil {
+		return fmt.Errorf("failed to update segment store port allocations: %v", err)
+	}
+	log.Printf("updated segment store port allocations of pravega cluster %s", p.Name)
+	return nil
+}
+
 func (r *PravegaClusterReconciler) reconcileService(p *pravegav1beta1.PravegaCluster) (err error) {
 
 	err = r.reconcileControllerService(p)
@@ -449,7 +599,10 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreService(p *pravegav1beta
 
 	if p.Spec.ExternalAccess.Enabled {
 		currentservice := &corev1.Service{}
-		services := MakeSegmentStoreExternalServices(p)
+		services, err := MakeSegmentStoreExternalServices(p)
+		if err != nil {
+			return err
+		}
 		for _, service := range services {
 			controllerutil.SetControllerReference(p, service, r.Scheme)
 			err := r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, currentservice)
@@ -483,41 +636,31 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreService(p *pravegav1beta
 				}
 				eq := reflect.DeepEqual(currentservice.Annotations["external-dns.alpha.kubernetes.io/hostname"], service.Annotations["external-dns.alpha.kubernetes.io/hostname"])
 				if !eq {
-					err := r.Client.Delete(context.TODO(), currentservice)
+					restarting, err := r.isSegmentStorePodRestarting(p)
 					if err != nil {
 						return err
 					}
-					err = r.Client.Create(context.TODO(), service)
-					if err != nil && !errors.IsAlreadyExists(err) {
-						return err
+					if restarting {
+						// The service is renamed once the restarted pod is ready
+						continue
 					}
-					pod := &corev1.Pod{}
-					err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, pod)
+					err = r.Client.Delete(context.TODO(), currentservice)
 					if err != nil {
 						return err
 					}
-					err = r.Client.Delete(context.TODO(), pod)
-					if err != nil {
+					err = r.Client.Create(context.TODO(), service)
+					if err != nil && !errors.IsAlreadyExists(err) {
 						return err
 					}
-					start := time.Now()
-					err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, pod)
-					for err == nil && util.IsPodReady(pod) {
-						if time.Since(start) > 10*time.Minute {
-							return fmt.Errorf("failed to delete Segmentstore pod (%s) for 10 mins ", pod.Name)
-						}
-						err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, pod)
-						log.Printf("waiting for %v pod to be deleted", pod.Name)
-					}
-					start = time.Now()
-					err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, pod)
-					for err == nil && !util.IsPodReady(pod) {
-						if time.Since(start) > 10*time.Minute {
-							return fmt.Errorf("failed to get Segmentstore pod (%s) as ready for 10 mins ", pod.Name)
-						}
-						err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: p.Namespace}, pod)
-						log.Printf("waiting for %v pod to be in ready state", pod.Name)
+					// The pod is restarted to advertise the new host name, the next reconcile
+					// renames the service of the next pod once this one is ready again
+					pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: service.Name, Namespace: p.Namespace}}
+					log.Printf("restarting segment store pod %s to advertise %s", pod.Name, service.Annotations["external-dns.alpha.kubernetes.io/hostname"])
+					err = r.Client.Delete(context.TODO(), pod)
+					if err != nil && !errors.IsNotFound(err) {
+						return fmt.Errorf("failed to delete pod (%s): %v", pod.Name, err)
 					}
+					return nil
 				}
 			}
 		}
@@ -526,13 +669,193 @@ func (r *PravegaClusterReconciler) reconcileSegmentStoreService(p *pravegav1beta
 	return nil
 }
 
-func (r *PravegaClusterReconciler) cleanUpZookeeperMeta(p *pravegav1beta1.PravegaCluster) (err error) {
-	if err = p.WaitForClusterToTerminate(r.Client); err != nil {
-		return fmt.Errorf("failed to wait for cluster pods termination (%s): %v", p.Name, err)
+// isSegmentStorePodRestarting returns true when a Segment Store pod is terminating or not ready
+func (r *PravegaClusterReconciler) isSegmentStorePodRestarting(p *pravegav1beta1.PravegaCluster) (bool, error) {
+	podList := &corev1.PodList{}
+	err := r.Client.List(context.TODO(), podList, client.InNamespace(p.Namespace), client.MatchingLabels(p.LabelsForSegmentStore()))
+	if err != nil {
+		return false, err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !pod.DeletionTimestamp.IsZero() || !util.IsPodReady(pod) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reconcileControllerRoutes creates or updates the Ingress objects or Gateway API routes
+// exposing the Controller, and deletes the ones recorded in the status that are no longer
+// configured. The routes are recorded in the status as soon as they change, so that they are
+// not lost when the rest of the reconcile fails
+func (r *PravegaClusterReconciler) reconcileControllerRoutes(p *pravegav1beta1.PravegaCluster) (err error) {
+	var refs []corev1.TypedLocalObjectReference
+	for _, route := range MakeControllerRoutes(p) {
+		controllerutil.SetControllerReference(p, route, r.Scheme)
+		gvk := route.GetObjectKind().GroupVersionKind()
+		current := newControllerRouteObject(gvk.Kind)
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: route.GetName(), Namespace: p.Namespace}, current)
+		if errors.IsNotFound(err) {
+			err = r.Client.Create(context.TODO(), route)
+			if errors.IsAlreadyExists(err) {
+				err = nil
+			} else if err != nil && !meta.IsNoMatchError(err) {
+				return fmt.Errorf("failed to create %s (%s): %v", gvk.Kind, route.GetName(), err)
+			}
+		} else if err == nil && !controllerRouteUpToDate(route, current) {
+			route.SetResourceVersion(current.GetResourceVersion())
+			err = r.Client.Update(context.TODO(), route)
+			if err != nil {
+				return fmt.Errorf("failed to update %s (%s): %v", gvk.Kind, route.GetName(), err)
+			}
+		}
+		if meta.IsNoMatchError(err) {
+			// The API of the route is not installed, e.g. the Gateway API CRDs are missing,
+			// so the route is skipped and the cluster is deployed without it
+			r.publishControllerRouteUnsupported(p, gvk, route.GetName())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		refs = append(refs, corev1.TypedLocalObjectReference{
+			APIGroup: &gvk.Group,
+			Kind:     gvk.Kind,
+			Name:     route.GetName(),
+		})
 	}
 
-	if err = util.DeleteAllZnodes(p.Spec.ZookeeperUri, p.Name); err != nil {
-		return fmt.Errorf("failed to delete zookeeper znodes for (%s): %v", p.Name, err)
+	for _, ref := range p.Status.ControllerRoutes {
+		if containsObjectReference(refs, ref) {
+			continue
+		}
+		stale := newControllerRouteObject(ref.Kind)
+		stale.SetName(ref.Name)
+		stale.SetNamespace(p.Namespace)
+		err = r.Client.Delete(context.TODO(), stale)
+		if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return fmt.Errorf("failed to delete %s (%s): %v", ref.Kind, ref.Name, err)
+		}
+		log.Printf("deleted %s %s which is no longer configured", ref.Kind, ref.Name)
+	}
+	if equality.Semantic.DeepEqual(refs, p.Status.ControllerRoutes) {
+		return nil
+	}
+	p.Status.ControllerRoutes = refs
+	err = r.updateClusterStatus(p)
+	if err != nil {
+		return fmt.Errorf("failed to update controller routes: %v", err)
+	}
+	return nil
+}
+
+// publishControllerRouteUnsupported warns that a controller route cannot be created since its API
+// is not installed in the Kubernetes cluster
+func (r *PravegaClusterReconciler) publishControllerRouteUnsupported(p *pravegav1beta1.PravegaCluster, gvk schema.GroupVersionKind, name string) {
+	message := fmt.Sprintf("%s %s is not created since the %s API is not installed", gvk.Kind, name, gvk.GroupVersion())
+	log.Print(message)
+	event := p.NewEvent("CONTROLLER_ROUTE_UNSUPPORTED", pravegav1beta1.ControllerRouteUnsupportedReason, message, "Warning")
+	if err := r.Client.Create(context.TODO(), event); err != nil {
+		log.Printf("Error publishing controller route unsupported event to k8s. %v", err)
+	}
+}
+
+func containsObjectReference(refs []corev1.TypedLocalObjectReference, ref corev1.TypedLocalObjectReference) bool {
+	for _, r := range refs {
+		if r.Kind == ref.Kind && r.Name == ref.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileSegmentStorePublishedAddresses resolves the external address of each Segment Store
+// service and publishes it on the annotations of the Segment Store pod. A pod that was started
+// with a different address is deleted, so that it gets recreated with the current one
+func (r *PravegaClusterReconciler) reconcileSegmentStorePublishedAddresses(p *pravegav1beta1.PravegaCluster) (err error) {
+	if !p.Spec.ExternalAccess.IsAddressResolutionEnabled() {
+		return nil
+	}
+	for i := int32(0); i < p.Spec.Pravega.SegmentStoreReplicas; i++ {
+		svc := &corev1.Service{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: p.ServiceNameForSegmentStore(i), Namespace: p.Namespace}, svc)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		pod := &corev1.Pod{}
+		podName := fmt.Sprintf("%s-%d", p.StatefulSetNameForSegmentstore(), i)
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: p.Namespace}, pod)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		var node *corev1.Node
+		if svc.Spec.Type == corev1.ServiceTypeNodePort {
+			if pod.Spec.NodeName == "" {
+				continue
+			}
+			node = &corev1.Node{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Spec.NodeName}, node)
+			if err != nil {
+				return fmt.Errorf("failed to get node (%s) of pod (%s): %v", pod.Spec.NodeName, pod.Name, err)
+			}
+		}
+
+		address, port := ResolveSegmentStorePublishedAddress(p, svc, node)
+		if address == "" {
+			log.Printf("waiting for the external address of service %s", svc.Name)
+			continue
+		}
+		publishedPort := strconv.Itoa(int(port))
+		previousAddress := pod.Annotations[publishedAddressAnnotationKey]
+		previousPort := pod.Annotations[publishedPortAnnotationKey]
+		if previousAddress == address && previousPort == publishedPort {
+			continue
+		}
+
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[publishedAddressAnnotationKey] = address
+		pod.Annotations[publishedPortAnnotationKey] = publishedPort
+		err = r.Client.Update(context.TODO(), pod)
+		if err != nil {
+			return fmt.Errorf("failed to publish address of pod (%s): %v", pod.Name, err)
+		}
+		log.Printf("published address %s:%s on pod %s", address, publishedPort, pod.Name)
+
+		if previousAddress != "" || previousPort != "" {
+			log.Printf("restarting pod %s as its published address changed from %s:%s", pod.Name, previousAddress, previousPort)
+			err = r.Client.Delete(context.TODO(), pod)
+			if err != nil {
+				return fmt.Errorf("failed to delete pod (%s): %v", pod.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *PravegaClusterReconciler) cleanUpZookeeperMeta(p *pravegav1beta1.PravegaCluster) (err error) {
+	zkConfig, err := zookeeperConfig(r.Client, p)
+	if err == nil {
+		err = util.DeleteAllZnodes(zkConfig, p.Name)
+	}
+	if err != nil {
+		// emit an event for zk metadata cleanup failure
+		message := fmt.Sprintf("failed to cleanup pravega metadata from zookeeper (znode path: %s/pravega/%s): %v", p.ZookeeperChroot(), p.Name, err)
+		event := p.NewApplicationEvent("ZKMETA_CLEANUP_ERROR", "ZK Metadata Cleanup Failed", message, "Error")
+		pubErr := r.Client.Create(context.TODO(), event)
+		if pubErr != nil {
+			log.Printf("Error publishing zk metadata cleanup failure event to k8s. %v", pubErr)
+		}
+		return fmt.Errorf(message)
 	}
 	return nil
 }
@@ -663,6 +986,7 @@ func (r *PravegaClusterReconciler) deployController(p *pravegav1beta1.PravegaClu
 		}
 
 		if !r.checkVersionUpgradeTriggered(p) && !r.isRollbackTriggered(p) {
+			keepRestartedAt(&deployment.Spec.Template, &foundDeploy.Spec.Template)
 			foundDeploy.Spec.Template = deployment.Spec.Template
 			err = r.Client.Update(context.TODO(), foundDeploy)
 			if err != nil {
@@ -697,19 +1021,13 @@ func (r *PravegaClusterReconciler) deploySegmentStore(p *pravegav1beta1.PravegaC
 			}
 
 			if !r.checkVersionUpgradeTriggered(p) && !r.isRollbackTriggered(p) {
-				originalsts := sts.DeepCopy()
+				// The pods of an updated template are restarted by rollSegmentStorePods
+				keepRestartedAt(&statefulSet.Spec.Template, &sts.Spec.Template)
 				sts.Spec.Template = statefulSet.Spec.Template
 				err = r.Client.Update(context.TODO(), sts)
 				if err != nil {
 					return fmt.Errorf("failed to update stateful set: %v", err)
 				}
-
-				if !reflect.DeepEqual(originalsts.Spec.Template, sts.Spec.Template) {
-					err = r.restartStsPod(p)
-					if err != nil {
-						return err
-					}
-				}
 			}
 
 			owRefs := sts.GetOwnerReferences()
@@ -734,116 +1052,123 @@ func hasOldVersionOwnerReference(ownerreference []metav1.OwnerReference) bool {
 	return false
 }
 
-func (r *PravegaClusterReconciler) restartStsPod(p *pravegav1beta1.PravegaCluster) error {
-
-	currentSts := &appsv1.StatefulSet{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstore(), Namespace: p.Namespace}, currentSts)
+// restartControllerPods makes the Deployment roll the Controller pods
+func (r *PravegaClusterReconciler) restartControllerPods(p *pravegav1beta1.PravegaCluster) error {
+	deploy := &appsv1.Deployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: p.DeploymentNameForController(), Namespace: p.Namespace}, deploy)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			// The pods are created with the current configuration
+			return nil
+		}
 		return err
 	}
-	labels := p.LabelsForPravegaCluster()
-	labels["component"] = "pravega-segmentstore"
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: labels,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to convert label selector: %v", err)
+	setRestartedAt(&deploy.Spec.Template)
+	if err = r.Client.Update(context.TODO(), deploy); err != nil {
+		return fmt.Errorf("failed to restart the controller pods: %v", err)
 	}
-	podList := &corev1.PodList{}
-	podlistOps := &client.ListOptions{
-		Namespace:     currentSts.Namespace,
-		LabelSelector: selector,
-	}
-	err = r.Client.List(context.TODO(), podList, podlistOps)
+	log.Printf("restarting the controller pods of pravega cluster %s", p.Name)
+	return nil
+}
+
+// restartSegmentStorePods updates the pod template of the Segment Stores, whose pods are then
+// restarted one at a time by rollSegmentStorePods
+func (r *PravegaClusterReconciler) restartSegmentStorePods(p *pravegav1beta1.PravegaCluster) error {
+	sts := &appsv1.StatefulSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstore(), Namespace: p.Namespace}, sts)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			// The pods are created with the current configuration
+			return nil
+		}
 		return err
 	}
-
-	for _, podItem := range podList.Items {
-		err := r.Client.Delete(context.TODO(), &podItem)
-		if err != nil {
-			return err
-		} else {
-			start := time.Now()
-			pod := &corev1.Pod{}
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			for util.IsPodReady(pod) {
-				if time.Since(start) > 10*time.Minute {
-					return fmt.Errorf("failed to delete Segmentstore pod (%s) for 10 mins ", podItem.ObjectMeta.Name)
-				}
-				err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			}
-			start = time.Now()
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			for !util.IsPodReady(pod) {
-				if time.Since(start) > 10*time.Minute {
-					return fmt.Errorf("failed to get Segmentstore pod (%s) as ready for 10 mins ", podItem.ObjectMeta.Name)
-				}
-				err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			}
-		}
+	setRestartedAt(&sts.Spec.Template)
+	if err = r.Client.Update(context.TODO(), sts); err != nil {
+		return fmt.Errorf("failed to restart the segment store pods: %v", err)
 	}
+	log.Printf("restarting the segment store pods of pravega cluster %s", p.Name)
 	return nil
 }
 
-func (r *PravegaClusterReconciler) restartDeploymentPod(p *pravegav1beta1.PravegaCluster) error {
-
-	currentDeployment := &appsv1.Deployment{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: p.DeploymentNameForController(), Namespace: p.Namespace}, currentDeployment)
+// rollSegmentStorePods deletes one Segment Store pod of an outdated revision of the pod
+// template once all the pods are ready. The StatefulSet uses the OnDelete update strategy,
+// so the pods are only updated when the operator deletes them. It never waits for the
+// pods, the next pod is deleted by a later reconcile
+func (r *PravegaClusterReconciler) rollSegmentStorePods(p *pravegav1beta1.PravegaCluster) error {
+	if r.checkVersionUpgradeTriggered(p) || r.isRollbackTriggered(p) || r.IsClusterUpgradingTo07(p) || r.IsClusterRollbackingFrom07(p) {
+		// Upgrades and rollbacks roll the pods themselves
+		return nil
+	}
+	sts := &appsv1.StatefulSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstore(), Namespace: p.Namespace}, sts)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
 		return err
 	}
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: currentDeployment.Spec.Template.Labels,
-	})
+	revision := sts.Status.UpdateRevision
+	if revision == "" || revision == sts.Status.CurrentRevision && sts.Status.UpdatedReplicas == sts.Status.Replicas {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
 	if err != nil {
 		return fmt.Errorf("failed to convert label selector: %v", err)
 	}
 	podList := &corev1.PodList{}
-	podlistOps := &client.ListOptions{
-		Namespace:     currentDeployment.Namespace,
-		LabelSelector: selector,
-	}
-	err = r.Client.List(context.TODO(), podList, podlistOps)
+	err = r.Client.List(context.TODO(), podList, &client.ListOptions{Namespace: sts.Namespace, LabelSelector: selector})
 	if err != nil {
 		return err
 	}
-
-	for _, podItem := range podList.Items {
-		err := r.Client.Delete(context.TODO(), &podItem)
-		if err != nil {
-			return err
-		} else {
-			start := time.Now()
-			pod := &corev1.Pod{}
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			for util.IsPodReady(pod) {
-				if time.Since(start) > 10*time.Minute {
-					return fmt.Errorf("failed to delete controller pod (%s) for 10 mins ", podItem.ObjectMeta.Name)
-				}
-				err = r.Client.Get(context.TODO(), types.NamespacedName{Name: podItem.ObjectMeta.Name, Namespace: podItem.ObjectMeta.Namespace}, pod)
-			}
-			deploy := &appsv1.Deployment{}
-			name := p.DeploymentNameForController()
-			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deploy)
-			if err != nil {
-				return fmt.Errorf("failed to get deployment (%s): %v", deploy.Name, err)
-			}
-			start = time.Now()
-			for deploy.Status.ReadyReplicas != deploy.Status.Replicas {
-				if time.Since(start) > 10*time.Minute {
-					return fmt.Errorf("failed to make controller pod ready for 10 mins ")
-				}
-				err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: p.Namespace}, deploy)
-				if err != nil {
-					return fmt.Errorf("failed to get deployment (%s): %v", deploy.Name, err)
-				}
-			}
+	if sts.Spec.Replicas == nil || int32(len(podList.Items)) != *sts.Spec.Replicas {
+		return nil
+	}
+	sort.SliceStable(podList.Items, func(i int, j int) bool {
+		return podList.Items[i].Name < podList.Items[j].Name
+	})
+	var outdated *corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !pod.DeletionTimestamp.IsZero() || !util.IsPodReady(pod) {
+			// A pod is restarting
+			return nil
 		}
+		if outdated == nil && pod.Labels[appsv1.StatefulSetRevisionLabel] != revision {
+			outdated = pod
+		}
+	}
+	if outdated == nil {
+		return nil
+	}
+	log.Printf("restarting segment store pod %s to revision %s", outdated.Name, revision)
+	if err = r.Client.Delete(context.TODO(), outdated); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pod (%s): %v", outdated.Name, err)
 	}
 	return nil
 }
 
+func setRestartedAt(template *corev1.PodTemplateSpec) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[pravegav1beta1.RestartedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// keepRestartedAt keeps the restart time of the current pod template in the desired one,
+// which would otherwise restart the pods again
+func keepRestartedAt(desired *corev1.PodTemplateSpec, current *corev1.PodTemplateSpec) {
+	restartedAt, ok := current.Annotations[pravegav1beta1.RestartedAtAnnotationKey]
+	if !ok {
+		return
+	}
+	if desired.Annotations == nil {
+		desired.Annotations = map[string]string{}
+	}
+	desired.Annotations[pravegav1beta1.RestartedAtAnnotationKey] = restartedAt
+}
+
 func (r *PravegaClusterReconciler) syncClusterSize(p *pravegav1beta1.PravegaCluster) (err error) {
 	/*We skip calling syncSegmentStoreSize() during upgrade/rollback from version 07*/
 	if !r.IsClusterUpgradingTo07(p) && !r.IsClusterRollbackingFrom07(p) {
@@ -1027,14 +1352,41 @@ func (r *PravegaClusterReconciler) reconcileClusterStatus(p *pravegav1beta1.Prav
 	p.Status.ReadyReplicas = int32(len(readyMembers))
 	p.Status.Members.Ready = readyMembers
 	p.Status.Members.Unready = unreadyMembers
+	p.Status.SegmentStoreMemory = p.SegmentStoreMemoryStatus()
+	r.reconcileDegradedCondition(p)
 
-	err = r.Client.Status().Update(context.TODO(), p)
+	err = r.updateClusterStatus(p)
 	if err != nil {
 		return fmt.Errorf("failed to update cluster status: %v", err)
 	}
 	return nil
 }
 
+// reconcileDegradedCondition sets the Degraded condition when fewer bookies are ready than the
+// ensemble size. The condition is left unchanged when the bookies cannot be counted
+func (r *PravegaClusterReconciler) reconcileDegradedCondition(p *pravegav1beta1.PravegaCluster) {
+	count, err := p.CountBookies(r.Client)
+	if err != nil {
+		log.Printf("failed to count the bookies of cluster %s: %v", p.Name, err)
+		return
+	}
+	if count == nil {
+		return
+	}
+	message := p.BookiesDegradedMessage(count)
+	if message == "" {
+		p.Status.SetDegradedConditionFalse()
+		return
+	}
+	if !p.Status.IsClusterDegraded() {
+		event := p.NewEvent("BOOKIES_UNAVAILABLE", pravegav1beta1.BookiesUnavailableReason, message, "Warning")
+		if err := r.Client.Create(context.TODO(), event); err != nil {
+			log.Printf("Error publishing bookies unavailable event to k8s. %v", err)
+		}
+	}
+	p.Status.SetDegradedConditionTrue(pravegav1beta1.BookiesUnavailableReason, message)
+}
+
 func (r *PravegaClusterReconciler) rollbackFailedUpgrade(p *pravegav1beta1.PravegaCluster) error {
 	if r.isRollbackTriggered(p) {
 		// start rollback to previous version
@@ -1065,5 +1417,11 @@ func (r *PravegaClusterReconciler) IsClusterUpgradingTo07(p *pravegav1beta1.Prav
 func (r *PravegaClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&pravegav1beta1.PravegaCluster{}).
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
+
+// controllerOptions returns the options of the controller reconciling the clusters
+func (r *PravegaClusterReconciler) controllerOptions() controller.Options {
+	return controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+}
@@ -17,9 +17,13 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -29,11 +33,47 @@ import (
 
 	"github.com/pravega/pravega-operator/api/v1beta1"
 	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// noGatewayAPIClient fails on the Gateway API objects like the client of a Kubernetes cluster
+// without the Gateway API CRDs, which has no REST mapping for them
+type noGatewayAPIClient struct {
+	client.Client
+}
+
+func (c *noGatewayAPIClient) noMatch(obj client.Object) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Group != "gateway.networking.k8s.io" {
+		return nil
+	}
+	return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+}
+
+func (c *noGatewayAPIClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.noMatch(obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *noGatewayAPIClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.noMatch(obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *noGatewayAPIClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.noMatch(obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
 var _ = Describe("PravegaCluster Controller", func() {
 	const (
 		Name      = "example"
@@ -141,7 +181,7 @@ var _ = Describe("PravegaCluster Controller", func() {
 				foundPravega.WithDefaults()
 				sts = MakeSegmentStoreStatefulSet(foundPravega)
 				extService := &corev1.Service{}
-				MakeSegmentStoreExternalServices(foundPravega)
+				_, _ = MakeSegmentStoreExternalServices(foundPravega)
 				extService.Name = p.ServiceNameForSegmentStoreBelow07(int32(0))
 				r.Client.Create(context.TODO(), extService)
 				svcName := p.ServiceNameForSegmentStoreBelow07(int32(0))
@@ -168,11 +208,11 @@ var _ = Describe("PravegaCluster Controller", func() {
 			)
 
 			BeforeEach(func() {
+				// stored with its defaults by the mutating webhook
+				p.WithDefaults()
 				client = fake.NewFakeClient(p)
 				r = &PravegaClusterReconciler{Client: client, Scheme: s}
-				//1st reconcile
 				foundPravega = &v1beta1.PravegaCluster{}
-				_, _ = r.Reconcile(ctx, req)
 
 				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
 				deploy := &appsv1.Deployment{}
@@ -195,6 +235,166 @@ var _ = Describe("PravegaCluster Controller", func() {
 			})
 		})
 
+		Context("Unknown options", func() {
+			var client client.Client
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				p.Spec.Pravega.Options["foo.bar"] = "baz"
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				Ω(r.reconcileControllerConfigMap(p)).Should(Succeed())
+			})
+
+			It("should pass them to the controller", func() {
+				cm := &corev1.ConfigMap{}
+				nn := types.NamespacedName{Name: p.ConfigMapNameForController(), Namespace: p.Namespace}
+				Ω(client.Get(context.TODO(), nn, cm)).Should(Succeed())
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dfoo.bar=baz"))
+			})
+
+			It("should publish a warning event", func() {
+				events := &corev1.EventList{}
+				Ω(client.List(context.TODO(), events)).Should(Succeed())
+				Ω(events.Items).Should(HaveLen(1))
+				Ω(events.Items[0].Reason).Should(Equal("Unknown Options"))
+				Ω(events.Items[0].Type).Should(Equal("Warning"))
+				Ω(events.Items[0].Message).Should(ContainSubstring("foo.bar"))
+			})
+
+			It("should not publish it again while the config map is unchanged", func() {
+				Ω(r.reconcileControllerConfigMap(p)).Should(Succeed())
+				events := &corev1.EventList{}
+				Ω(client.List(context.TODO(), events)).Should(Succeed())
+				Ω(events.Items).Should(HaveLen(1))
+			})
+		})
+
+		Context("Renamed options", func() {
+			var client client.Client
+
+			events := func(reason string) []corev1.Event {
+				list := &corev1.EventList{}
+				Ω(client.List(context.TODO(), list)).Should(Succeed())
+				var found []corev1.Event
+				for _, e := range list.Items {
+					if e.Reason == reason {
+						found = append(found, e)
+					}
+				}
+				return found
+			}
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				p.Spec.Version = "0.6.0"
+				p.Spec.Pravega.Options["pravegaservice.container.count"] = "4"
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				Ω(r.reconcileSegmentStoreConfigMap(p)).Should(Succeed())
+			})
+
+			It("should set the options under the names read by the version", func() {
+				cm := &corev1.ConfigMap{}
+				nn := types.NamespacedName{Name: p.ConfigMapNameForSegmentstore(), Namespace: p.Namespace}
+				Ω(client.Get(context.TODO(), nn, cm)).Should(Succeed())
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dpravegaservice.containerCount=4"))
+				Ω(cm.Data["JAVA_OPTS"]).ShouldNot(ContainSubstring("pravegaservice.container.count"))
+				Ω(events("Renamed Options")).Should(HaveLen(1))
+			})
+
+			It("should rewrite the options when the version crosses the rename", func() {
+				p.Spec.Version = "0.7.0"
+				Ω(r.reconcileSegmentStoreConfigMap(p)).Should(Succeed())
+				cm := &corev1.ConfigMap{}
+				nn := types.NamespacedName{Name: p.ConfigMapNameForSegmentstore(), Namespace: p.Namespace}
+				Ω(client.Get(context.TODO(), nn, cm)).Should(Succeed())
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dpravegaservice.container.count=4"))
+				Ω(cm.Data["JAVA_OPTS"]).ShouldNot(ContainSubstring("pravegaservice.containerCount"))
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dpravegaservice.service.listener.port=12345"))
+			})
+
+			It("should not warn about the options added by the operator", func() {
+				p = &v1beta1.PravegaCluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: p.Namespace},
+					Spec:       v1beta1.ClusterSpec{Version: "0.6.0"},
+				}
+				p.WithDefaults()
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				Ω(r.reconcileSegmentStoreConfigMap(p)).Should(Succeed())
+				Ω(r.reconcileControllerConfigMap(p)).Should(Succeed())
+				cm := &corev1.ConfigMap{}
+				nn := types.NamespacedName{Name: p.ConfigMapNameForSegmentstore(), Namespace: p.Namespace}
+				Ω(client.Get(context.TODO(), nn, cm)).Should(Succeed())
+				Ω(cm.Data["JAVA_OPTS"]).Should(ContainSubstring("-Dpravegaservice.listeningPort=12345"))
+				Ω(events("Renamed Options")).Should(BeEmpty())
+			})
+		})
+
+		Context("Degraded bookies", func() {
+			var client client.Client
+
+			bookie := func(name string, ready bool) *corev1.Pod {
+				status := corev1.ConditionFalse
+				if ready {
+					status = corev1.ConditionTrue
+				}
+				return &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: Namespace,
+						Labels:    map[string]string{"kind": "bookie"},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+					},
+				}
+			}
+
+			degraded := func() *v1beta1.ClusterCondition {
+				found := &v1beta1.PravegaCluster{}
+				Ω(client.Get(context.TODO(), req.NamespacedName, found)).Should(Succeed())
+				_, condition := found.Status.GetClusterCondition(v1beta1.ClusterConditionDegraded)
+				return condition
+			}
+
+			BeforeEach(func() {
+				p.WithDefaults()
+				service := &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "bookkeeper-bookie-headless", Namespace: Namespace},
+					Spec:       corev1.ServiceSpec{Selector: map[string]string{"kind": "bookie"}},
+				}
+				client = fake.NewFakeClient(p, service, bookie("bookie-0", true), bookie("bookie-1", true), bookie("bookie-2", false))
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				Ω(r.reconcileClusterStatus(p)).Should(Succeed())
+			})
+
+			It("should set the degraded condition when fewer bookies are ready than the ensemble size", func() {
+				condition := degraded()
+				Ω(condition).ShouldNot(BeNil())
+				Ω(condition.Status).Should(Equal(corev1.ConditionTrue))
+				Ω(condition.Reason).Should(Equal(v1beta1.BookiesUnavailableReason))
+				Ω(condition.Message).Should(ContainSubstring("2 of 3 bookies are ready"))
+			})
+
+			It("should publish a warning event once", func() {
+				Ω(r.reconcileClusterStatus(p)).Should(Succeed())
+				events := &corev1.EventList{}
+				Ω(client.List(context.TODO(), events)).Should(Succeed())
+				Ω(events.Items).Should(HaveLen(1))
+				Ω(events.Items[0].Reason).Should(Equal(v1beta1.BookiesUnavailableReason))
+				Ω(events.Items[0].Type).Should(Equal("Warning"))
+			})
+
+			It("should clear the degraded condition when the bookies are ready again", func() {
+				pod := bookie("bookie-2", true)
+				Ω(client.Update(context.TODO(), pod)).Should(Succeed())
+				Ω(r.reconcileClusterStatus(p)).Should(Succeed())
+				Ω(degraded().Status).Should(Equal(corev1.ConditionFalse))
+			})
+		})
+
 		Context("Without spec", func() {
 			var (
 				client       client.Client
@@ -212,22 +412,22 @@ var _ = Describe("PravegaCluster Controller", func() {
 			It("shouldn't error", func() {
 				Ω(err).Should(BeNil())
 			})
-			Context("Before defaults are applied", func() {
-				It("should requeue the request", func() {
-					Ω(res.Requeue).Should(BeTrue())
+			Context("Defaults applied in memory", func() {
+				It("should not requeue the request to store the defaults", func() {
+					Ω(res.Requeue).Should(BeFalse())
+					Ω(res.RequeueAfter).To(Equal(ReconcileTime))
 				})
 
-				It("should set the default cluster spec options", func() {
+				It("should deploy the cluster with the default settings", func() {
 					foundPravega = &v1beta1.PravegaCluster{}
 					err = client.Get(context.TODO(), req.NamespacedName, foundPravega)
 					Ω(err).Should(BeNil())
-					Ω(foundPravega.Spec.Version).Should(Equal(v1beta1.DefaultPravegaVersion))
-					Ω(foundPravega.Spec.ZookeeperUri).Should(Equal(v1beta1.DefaultZookeeperUri))
-					Ω(foundPravega.Spec.BookkeeperUri).Should(Equal(v1beta1.DefaultBookkeeperUri))
-					Ω(foundPravega.Spec.ExternalAccess).ShouldNot(BeNil())
-					Ω(foundPravega.Spec.ExternalAccess.Enabled).Should(Equal(false))
-					Ω(foundPravega.Spec.ExternalAccess.DomainName).Should(Equal(""))
-					Ω(foundPravega.Spec.Pravega).ShouldNot(BeNil())
+					Ω(foundPravega.Status.CurrentVersion).Should(Equal(v1beta1.DefaultPravegaVersion))
+					foundPravega.WithDefaults()
+					foundController := &appsv1.Deployment{}
+					nn := types.NamespacedName{Name: foundPravega.DeploymentNameForController(), Namespace: Namespace}
+					Ω(client.Get(context.TODO(), nn, foundController)).Should(Succeed())
+					Ω(foundController.Spec.Template.Spec.Containers[0].Image).Should(HaveSuffix(":" + v1beta1.DefaultPravegaVersion))
 				})
 			})
 
@@ -454,6 +654,7 @@ var _ = Describe("PravegaCluster Controller", func() {
 						r = &PravegaClusterReconciler{Client: client, Scheme: s}
 						res, err = r.Reconcile(ctx, req)
 
+						p.WithDefaults()
 						ans1 = r.checkVersionUpgradeTriggered(p)
 						p.Spec.Version = "0.8.0"
 						ans2 = r.checkVersionUpgradeTriggered(p)
@@ -478,8 +679,11 @@ var _ = Describe("PravegaCluster Controller", func() {
 						client.Update(context.TODO(), p)
 						err = r.reconcileFinalizers(p)
 					})
-					It("should give error due to failure in connecting to zookeeper", func() {
-						Expect(err).To(HaveOccurred())
+					It("should record the failure to connect to zookeeper and keep the finalizer", func() {
+						Expect(err).NotTo(HaveOccurred())
+						Expect(p.Status.Cleanup.Failures).To(BeEquivalentTo(1))
+						Expect(p.Status.Cleanup.LastError).To(ContainSubstring("zookeeper"))
+						Expect(p.ObjectMeta.Finalizers).To(ContainElement(util.ZkFinalizer))
 					})
 				})
 
@@ -808,6 +1012,494 @@ var _ = Describe("PravegaCluster Controller", func() {
 			})
 		})
 
+		Context("Custom spec with ExternalAccess on a shared load balancer IP", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.3.2-rc2",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled: true,
+						Type:    corev1.ServiceTypeLoadBalancer,
+					},
+					ReservedPortList: []int32{12346},
+					Pravega: &v1beta1.PravegaSpec{
+						ControllerReplicas:         1,
+						SegmentStoreReplicas:       3,
+						SegmentStoreLoadBalancerIP: "10.240.12.18",
+					},
+				}
+				p.WithDefaults()
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				res, err = r.Reconcile(ctx, req)
+			})
+
+			It("should persist the port allocations and use them for the services", func() {
+				Ω(err).Should(BeNil())
+				foundPravega := &v1beta1.PravegaCluster{}
+				err = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(err).Should(BeNil())
+				Ω(foundPravega.Status.SegmentStorePortAllocations).Should(HaveLen(3))
+				allocation := foundPravega.Status.GetSegmentStorePortAllocation(1)
+				Ω(allocation.ServicePort).Should(Equal(int32(12347)))
+
+				foundSegmentStoreSvc := &corev1.Service{}
+				nn := types.NamespacedName{
+					Name:      p.ServiceNameForSegmentStore(1),
+					Namespace: Namespace,
+				}
+				err = client.Get(context.TODO(), nn, foundSegmentStoreSvc)
+				Ω(err).Should(BeNil())
+				Ω(foundSegmentStoreSvc.Spec.Ports[0].Port).Should(Equal(allocation.ServicePort))
+				Ω(foundSegmentStoreSvc.Spec.Ports[1].Port).Should(Equal(allocation.AdminPort))
+			})
+		})
+
+		Context("Custom spec with ExternalAccess on a shared load balancer IP without free ports", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.3.2-rc2",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled: true,
+						Type:    corev1.ServiceTypeLoadBalancer,
+					},
+					Pravega: &v1beta1.PravegaSpec{
+						ControllerReplicas:         1,
+						SegmentStoreReplicas:       3,
+						SegmentStoreLoadBalancerIP: "10.240.12.18",
+						SegmentStoreOptions: map[string]string{
+							"pravegaservice.service.listener.port": "65534",
+						},
+					},
+				}
+				p.WithDefaults()
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				res, err = r.Reconcile(ctx, req)
+			})
+
+			It("should fail without creating the external services", func() {
+				Ω(err).ShouldNot(BeNil())
+				Ω(err.Error()).Should(ContainSubstring("no free port left"))
+				_, err = MakeSegmentStoreExternalServices(p)
+				Ω(err).ShouldNot(BeNil())
+				nn := types.NamespacedName{Name: p.ServiceNameForSegmentStore(0), Namespace: Namespace}
+				Ω(errors.IsNotFound(client.Get(context.TODO(), nn, &corev1.Service{}))).Should(BeTrue())
+			})
+
+			It("should publish a warning event", func() {
+				events := &corev1.EventList{}
+				Ω(client.List(context.TODO(), events)).Should(Succeed())
+				var found []corev1.Event
+				for _, event := range events.Items {
+					if event.Reason == v1beta1.PortAllocationFailedReason {
+						found = append(found, event)
+					}
+				}
+				Ω(found).Should(HaveLen(1))
+				Ω(found[0].Type).Should(Equal("Warning"))
+				Ω(found[0].Message).Should(ContainSubstring("no free port left"))
+			})
+		})
+
+		Context("Custom spec with ExternalAccess through controller routes", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.3.2-rc2",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled: true,
+						Type:    corev1.ServiceTypeLoadBalancer,
+						ControllerRoute: &v1beta1.ControllerRouteSpec{
+							Type:         v1beta1.ControllerRouteIngress,
+							GrpcHostname: "grpc.pravega.example.com",
+							RestHostname: "rest.pravega.example.com",
+						},
+					},
+					Pravega: &v1beta1.PravegaSpec{
+						ControllerReplicas:   1,
+						SegmentStoreReplicas: 1,
+					},
+				}
+				p.WithDefaults()
+				client = fake.NewFakeClient(p)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				res, err = r.Reconcile(ctx, req)
+			})
+
+			It("should create the ingresses and record them in the status", func() {
+				Ω(err).Should(BeNil())
+				ingress := &networkingv1.Ingress{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.RouteNameForController("rest"), Namespace: Namespace}, ingress)
+				Ω(err).Should(BeNil())
+				Ω(ingress.Spec.Rules[0].Host).Should(Equal("rest.pravega.example.com"))
+				foundPravega := &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.ControllerRoutes).Should(HaveLen(2))
+			})
+
+			It("should only update the ingresses when they change", func() {
+				ingress := &networkingv1.Ingress{}
+				nn := types.NamespacedName{Name: p.RouteNameForController("rest"), Namespace: Namespace}
+				Ω(client.Get(context.TODO(), nn, ingress)).Should(Succeed())
+				version := ingress.ResourceVersion
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				Ω(client.Get(context.TODO(), nn, ingress)).Should(Succeed())
+				Ω(ingress.ResourceVersion).Should(Equal(version))
+
+				foundPravega := &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Spec.ExternalAccess.ControllerRoute.RestHostname = "api.pravega.example.com"
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				Ω(client.Get(context.TODO(), nn, ingress)).Should(Succeed())
+				Ω(ingress.ResourceVersion).ShouldNot(Equal(version))
+				Ω(ingress.Spec.Rules[0].Host).Should(Equal("api.pravega.example.com"))
+			})
+
+			It("should record the routes in the status before the rest of the reconcile", func() {
+				foundPravega := &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Spec.ExternalAccess.ControllerRoute.RestHostname = ""
+				foundPravega.WithDefaults()
+				Ω(r.reconcileControllerRoutes(foundPravega)).Should(Succeed())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.ControllerRoutes).Should(HaveLen(1))
+				Ω(foundPravega.Status.ControllerRoutes[0].Name).Should(Equal(p.RouteNameForController("grpc")))
+			})
+
+			It("should delete the routes that are no longer configured", func() {
+				foundPravega := &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Spec.ExternalAccess.ControllerRoute.RestHostname = ""
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+
+				ingress := &networkingv1.Ingress{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.RouteNameForController("rest"), Namespace: Namespace}, ingress)
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.RouteNameForController("grpc"), Namespace: Namespace}, ingress)
+				Ω(err).Should(BeNil())
+			})
+
+			It("should replace the ingresses with gateway routes", func() {
+				foundPravega := &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Spec.ExternalAccess.ControllerRoute.Type = v1beta1.ControllerRouteGatewayAPI
+				foundPravega.Spec.ExternalAccess.ControllerRoute.GatewayName = "shared-gateway"
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+
+				ingress := &networkingv1.Ingress{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.RouteNameForController("grpc"), Namespace: Namespace}, ingress)
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+				route := &unstructured.Unstructured{}
+				route.SetAPIVersion("gateway.networking.k8s.io/v1")
+				route.SetKind("GRPCRoute")
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.RouteNameForController("grpc"), Namespace: Namespace}, route)
+				Ω(err).Should(BeNil())
+			})
+		})
+
+		Context("Custom spec with gateway routes without the Gateway API", func() {
+			var (
+				client client.Client
+				err    error
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.3.2-rc2",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled: true,
+						Type:    corev1.ServiceTypeLoadBalancer,
+						ControllerRoute: &v1beta1.ControllerRouteSpec{
+							Type:        v1beta1.ControllerRouteGatewayAPI,
+							GatewayName: "shared-gateway",
+						},
+					},
+					Pravega: &v1beta1.PravegaSpec{
+						ControllerReplicas:   1,
+						SegmentStoreReplicas: 1,
+					},
+				}
+				p.WithDefaults()
+				client = &noGatewayAPIClient{Client: fake.NewFakeClient(p)}
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				res, err = r.Reconcile(ctx, req)
+			})
+
+			It("should deploy the cluster without the routes", func() {
+				Ω(err).Should(BeNil())
+				deploy := &appsv1.Deployment{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.DeploymentNameForController(), Namespace: Namespace}, deploy)).Should(Succeed())
+				sts := &appsv1.StatefulSet{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstore(), Namespace: Namespace}, sts)).Should(Succeed())
+				foundPravega := &v1beta1.PravegaCluster{}
+				Ω(client.Get(context.TODO(), req.NamespacedName, foundPravega)).Should(Succeed())
+				Ω(foundPravega.Status.ControllerRoutes).Should(BeEmpty())
+			})
+
+			It("should publish a warning event", func() {
+				events := &corev1.EventList{}
+				Ω(client.List(context.TODO(), events)).Should(Succeed())
+				var found []corev1.Event
+				for _, e := range events.Items {
+					if e.Reason == v1beta1.ControllerRouteUnsupportedReason {
+						found = append(found, e)
+					}
+				}
+				Ω(found).ShouldNot(BeEmpty())
+				Ω(found[0].Type).Should(Equal("Warning"))
+				Ω(found[0].Message).Should(ContainSubstring("GRPCRoute"))
+				Ω(found[0].Message).Should(ContainSubstring("gateway.networking.k8s.io/v1 API is not installed"))
+			})
+		})
+
+		Context("Custom spec with ExternalAccess and addresses resolved by the operator", func() {
+			var (
+				client client.Client
+				err    error
+				pod    *corev1.Pod
+				svc    *corev1.Service
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Version: "0.9.0",
+					ExternalAccess: &v1beta1.ExternalAccess{
+						Enabled:          true,
+						Type:             corev1.ServiceTypeLoadBalancer,
+						ResolveAddresses: true,
+					},
+					Pravega: &v1beta1.PravegaSpec{
+						SegmentStoreReplicas: 1,
+					},
+				}
+				p.WithDefaults()
+				svc = &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      p.ServiceNameForSegmentStore(0),
+						Namespace: Namespace,
+					},
+					Spec: corev1.ServiceSpec{
+						Type:  corev1.ServiceTypeLoadBalancer,
+						Ports: []corev1.ServicePort{{Name: "server", Port: 12345}},
+					},
+					Status: corev1.ServiceStatus{
+						LoadBalancer: corev1.LoadBalancerStatus{
+							Ingress: []corev1.LoadBalancerIngress{{IP: "34.66.68.236"}},
+						},
+					},
+				}
+				pod = &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-0", p.StatefulSetNameForSegmentstore()),
+						Namespace: Namespace,
+					},
+				}
+				client = fake.NewFakeClient(p, svc, pod)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				err = r.reconcileSegmentStorePublishedAddresses(p)
+			})
+
+			It("should publish the address on the pod", func() {
+				Ω(err).Should(BeNil())
+				foundPod := &corev1.Pod{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: Namespace}, foundPod)
+				Ω(err).Should(BeNil())
+				Ω(foundPod.Annotations).Should(HaveKeyWithValue("pravega.pravega.io/published-address", "34.66.68.236"))
+				Ω(foundPod.Annotations).Should(HaveKeyWithValue("pravega.pravega.io/published-port", "12345"))
+			})
+
+			It("should restart the pod when the address changes", func() {
+				svc.Status.LoadBalancer.Ingress[0].IP = "34.66.68.237"
+				Ω(client.Update(context.TODO(), svc)).Should(BeNil())
+				err = r.reconcileSegmentStorePublishedAddresses(p)
+				Ω(err).Should(BeNil())
+				foundPod := &corev1.Pod{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: Namespace}, foundPod)
+				Ω(errors.IsNotFound(err)).Should(BeTrue())
+			})
+		})
+
+		Context("ZooKeeper digest authentication", func() {
+			var (
+				client client.Client
+				err    error
+				auth   *corev1.Secret
+			)
+
+			getJaas := func() string {
+				secret := &corev1.Secret{}
+				Ω(client.Get(context.TODO(), types.NamespacedName{Name: p.SecretNameForZookeeperJaas(), Namespace: Namespace}, secret)).Should(Succeed())
+				return string(secret.Data["jaas.conf"])
+			}
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Zookeeper: &v1beta1.ZookeeperSpec{AuthSecret: "zk-auth"},
+				}
+				p.WithDefaults()
+				auth = &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "zk-auth", Namespace: Namespace},
+					Data:       map[string][]byte{"username": []byte("pravega"), "password": []byte("secret")},
+				}
+				client = fake.NewFakeClient(p, auth)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				err = r.reconcileZookeeperJaasSecret(p)
+			})
+
+			It("should write the JAAS configuration", func() {
+				Ω(err).Should(BeNil())
+				Ω(getJaas()).Should(ContainSubstring(`username="pravega"`))
+			})
+
+			It("should follow the auth secret", func() {
+				auth.Data["password"] = []byte("rotated")
+				Ω(client.Update(context.TODO(), auth)).Should(Succeed())
+				Ω(r.reconcileZookeeperJaasSecret(p)).Should(Succeed())
+				Ω(getJaas()).Should(ContainSubstring(`password="rotated"`))
+			})
+
+			It("should use the auth secret to connect", func() {
+				zkConfig, err := zookeeperConfig(client, p)
+				Ω(err).Should(BeNil())
+				Ω(zkConfig.Digest).Should(Equal("pravega:secret"))
+				Ω(zkConfig.Servers).Should(Equal([]string{v1beta1.DefaultZookeeperUri}))
+			})
+
+			It("should fail without credentials", func() {
+				delete(auth.Data, "password")
+				Ω(client.Update(context.TODO(), auth)).Should(Succeed())
+				Ω(r.reconcileZookeeperJaasSecret(p)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("Dry run", func() {
+			var (
+				client       client.Client
+				err          error
+				foundPravega *v1beta1.PravegaCluster
+			)
+
+			BeforeEach(func() {
+				p.Spec = v1beta1.ClusterSpec{
+					Pravega: &v1beta1.PravegaSpec{
+						Options: map[string]string{
+							"pravegaservice.cache.size.max": "536870912",
+						},
+						SegmentStoreJVMOptions: []string{"-Xmx1g", "-XX:MaxDirectMemorySize=768m"},
+					},
+				}
+				p.WithDefaults()
+				ssPod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-0", p.StatefulSetNameForSegmentstore()),
+						Namespace: Namespace,
+						Labels:    p.LabelsForSegmentStore(),
+					},
+				}
+				client = fake.NewFakeClient(p, ssPod)
+				r = &PravegaClusterReconciler{Client: client, Scheme: s}
+				res, err = r.Reconcile(ctx, req)
+				res, err = r.Reconcile(ctx, req)
+
+				foundPravega = &v1beta1.PravegaCluster{}
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Annotations = map[string]string{v1beta1.DryRunAnnotationKey: "true"}
+			})
+
+			It("should not plan any change when the spec is applied", func() {
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.DryRun).ShouldNot(BeNil())
+				Ω(foundPravega.Status.DryRun.Changes).Should(BeEmpty())
+				Ω(foundPravega.Status.DryRun.Rejections).Should(BeEmpty())
+				Ω(foundPravega.Status.DryRun.PodRestarts).Should(Equal(int32(0)))
+			})
+
+			It("should report the restarts without applying the change", func() {
+				foundPravega.Spec.Pravega.Options["pravegaservice.cache.size.max"] = "268435456"
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.DryRun.Changes).Should(ContainElement(
+					fmt.Sprintf("will update configmap %s and restart the segment store pods", p.ConfigMapNameForSegmentstore())))
+				Ω(foundPravega.Status.DryRun.PodRestarts).Should(Equal(int32(1)))
+
+				cm := &corev1.ConfigMap{}
+				err = client.Get(context.TODO(), types.NamespacedName{Name: p.ConfigMapNameForSegmentstore(), Namespace: Namespace}, cm)
+				Ω(err).Should(BeNil())
+				Ω(cm.Data["JAVA_OPTS"]).ShouldNot(ContainSubstring("-Dpravegaservice.cache.size.max=268435456"))
+			})
+
+			It("should report why the change would be rejected", func() {
+				foundPravega.Spec.Pravega.Options["controller.container.count"] = "8"
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.DryRun.Rejections).Should(ContainElement(ContainSubstring("controller.container.count should not be modified")))
+			})
+
+			It("should keep the status of the pods up to date", func() {
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-1", p.StatefulSetNameForSegmentstore()),
+						Namespace: Namespace,
+						Labels:    p.LabelsForSegmentStore(),
+					},
+				}
+				Ω(client.Create(context.TODO(), pod)).Should(Succeed())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.DryRun).ShouldNot(BeNil())
+				Ω(foundPravega.Status.Members.Unready).Should(ContainElement(pod.Name))
+				Ω(foundPravega.Status.CurrentReplicas).Should(Equal(int32(2)))
+			})
+
+			It("should record the immutable settings once the cluster is deployed", func() {
+				Ω(foundPravega.Status.ImmutableSettings).ShouldNot(BeNil())
+				Ω(foundPravega.Status.ImmutableSettings.LongTermStorage).Should(Equal("filesystem"))
+				Ω(foundPravega.Status.ImmutableSettings.ControllerOptions).Should(BeNil())
+			})
+
+			It("should clear the plan once the annotation is removed", func() {
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				foundPravega.Annotations = nil
+				Ω(client.Update(context.TODO(), foundPravega)).Should(BeNil())
+				res, err = r.Reconcile(ctx, req)
+				Ω(err).Should(BeNil())
+				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
+				Ω(foundPravega.Status.DryRun).Should(BeNil())
+			})
+		})
+
 		Context("Custom spec with ExternalAccess and changing the domainName", func() {
 			var (
 				client     client.Client
@@ -874,6 +1566,61 @@ var _ = Describe("PravegaCluster Controller", func() {
 						"external-dns.alpha.kubernetes.io/hostname", svcName1))
 				})
 			})
+
+			Context("Restarting the SegmentStore pods of the renamed services", func() {
+				hostname := func(i int32) string {
+					svc := &corev1.Service{}
+					nn := types.NamespacedName{Name: p.ServiceNameForSegmentStore(i), Namespace: Namespace}
+					Ω(client.Get(context.TODO(), nn, svc)).Should(Succeed())
+					return svc.Annotations["external-dns.alpha.kubernetes.io/hostname"]
+				}
+
+				podExists := func(i int32) bool {
+					nn := types.NamespacedName{Name: p.ServiceNameForSegmentStore(i), Namespace: Namespace}
+					return client.Get(context.TODO(), nn, &corev1.Pod{}) == nil
+				}
+
+				createPods := func(ready ...bool) {
+					for i, r := range ready {
+						status := corev1.ConditionFalse
+						if r {
+							status = corev1.ConditionTrue
+						}
+						pod := &corev1.Pod{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      p.ServiceNameForSegmentStore(int32(i)),
+								Namespace: Namespace,
+								Labels:    p.LabelsForSegmentStore(),
+							},
+							Status: corev1.PodStatus{
+								Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+							},
+						}
+						Ω(client.Create(context.TODO(), pod)).Should(Succeed())
+					}
+				}
+
+				BeforeEach(func() {
+					Ω(r.reconcileSegmentStoreService(p)).Should(Succeed())
+					p.Spec.ExternalAccess.DomainName = "pravega1.com."
+				})
+
+				It("should restart one pod per reconcile without waiting for it", func() {
+					createPods(true, true, true)
+					Ω(r.reconcileSegmentStoreService(p)).Should(Succeed())
+					Ω(hostname(0)).Should(Equal(p.ServiceNameForSegmentStore(0) + ".pravega1.com."))
+					Ω(hostname(1)).Should(Equal(p.ServiceNameForSegmentStore(1) + ".pravega.com."))
+					Ω(podExists(0)).Should(BeFalse())
+					Ω(podExists(1)).Should(BeTrue())
+				})
+
+				It("should not rename the services while a pod is not ready", func() {
+					createPods(true, false, true)
+					Ω(r.reconcileSegmentStoreService(p)).Should(Succeed())
+					Ω(hostname(0)).Should(Equal(p.ServiceNameForSegmentStore(0) + ".pravega.com."))
+					Ω(podExists(0)).Should(BeTrue())
+				})
+			})
 		})
 
 		Context("Custom spec with ExternalAccess with annotations and overridden Service Type", func() {
@@ -1273,3 +2020,164 @@ var _ = Describe("PravegaCluster Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Concurrent reconciliation", func() {
+	const Namespace = "default"
+
+	var (
+		s = scheme.Scheme
+		r *PravegaClusterReconciler
+		c client.Client
+	)
+
+	newCluster := func(name string) *v1beta1.PravegaCluster {
+		return &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+		}
+	}
+
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: Namespace}}
+	}
+
+	Context("Multiple clusters", func() {
+		BeforeEach(func() {
+			s.AddKnownTypes(v1beta1.GroupVersion, &v1beta1.PravegaCluster{})
+			stuck := newCluster("stuck")
+			stuck.Finalizers = []string{util.ZkFinalizer}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "stuck-pravega-segment-store-0", Namespace: Namespace, Labels: stuck.LabelsForPravegaCluster()},
+			}
+			c = fake.NewFakeClient(stuck, pod, newCluster("first"), newCluster("second"))
+			r = &PravegaClusterReconciler{Client: c, Scheme: s, MaxConcurrentReconciles: 3}
+			// The pods of the deleted cluster never terminate
+			Ω(c.Delete(context.TODO(), newCluster("stuck"))).Should(Succeed())
+		})
+
+		It("should requeue a cluster waiting for its pods instead of blocking", func() {
+			res, err := r.Reconcile(context.TODO(), request("stuck"))
+			Ω(err).Should(BeNil())
+			Ω(res.RequeueAfter).Should(Equal(podTerminationPollTime))
+		})
+
+		It("should reconcile the other clusters while a cluster waits for its pods", func() {
+			_, err := r.Reconcile(context.TODO(), request("stuck"))
+			Ω(err).Should(BeNil())
+			for _, name := range []string{"first", "second"} {
+				var res reconcile.Result
+				// defaults are applied by the 1st reconcile, the segment store deployed by the 3rd
+				for i := 0; i < 3; i++ {
+					res, err = r.Reconcile(context.TODO(), request(name))
+					Ω(err).Should(BeNil())
+				}
+				Ω(res.RequeueAfter).Should(Equal(ReconcileTime))
+				p := &v1beta1.PravegaCluster{}
+				Ω(c.Get(context.TODO(), request(name).NamespacedName, p)).Should(Succeed())
+				deploy := &appsv1.Deployment{}
+				Ω(c.Get(context.TODO(), types.NamespacedName{Name: p.DeploymentNameForController(), Namespace: Namespace}, deploy)).Should(Succeed())
+				sts := &appsv1.StatefulSet{}
+				Ω(c.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstore(), Namespace: Namespace}, sts)).Should(Succeed())
+			}
+		})
+
+		It("should pass the max concurrent reconciles to the controller", func() {
+			Ω(r.controllerOptions().MaxConcurrentReconciles).Should(Equal(3))
+		})
+	})
+
+	Context("Segment store restart", func() {
+		var (
+			p   *v1beta1.PravegaCluster
+			sts *appsv1.StatefulSet
+		)
+
+		segmentStorePod := func(name string, revision string, ready bool) *corev1.Pod {
+			status := corev1.ConditionFalse
+			if ready {
+				status = corev1.ConditionTrue
+			}
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: Namespace,
+					Labels: map[string]string{
+						"component":                     "pravega-segmentstore",
+						appsv1.StatefulSetRevisionLabel: revision,
+					},
+				},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+				},
+			}
+		}
+
+		podExists := func(name string) bool {
+			err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: Namespace}, &corev1.Pod{})
+			return err == nil
+		}
+
+		BeforeEach(func() {
+			p = newCluster("example")
+			p.WithDefaults()
+			p.Status.CurrentVersion = p.Spec.Version
+			replicas := int32(2)
+			sts = &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: p.StatefulSetNameForSegmentstore(), Namespace: Namespace},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "pravega-segmentstore"}},
+				},
+				Status: appsv1.StatefulSetStatus{
+					Replicas:        2,
+					CurrentRevision: "rev-1",
+					UpdateRevision:  "rev-2",
+				},
+			}
+			r = &PravegaClusterReconciler{Scheme: s}
+		})
+
+		It("should restart one outdated pod when all the pods are ready", func() {
+			c = fake.NewFakeClient(p, sts, segmentStorePod("example-pravega-segment-store-1", "rev-1", true),
+				segmentStorePod("example-pravega-segment-store-0", "rev-1", true))
+			r.Client = c
+			Ω(r.rollSegmentStorePods(p)).Should(Succeed())
+			Ω(podExists("example-pravega-segment-store-0")).Should(BeFalse())
+			Ω(podExists("example-pravega-segment-store-1")).Should(BeTrue())
+		})
+
+		It("should skip the updated pods", func() {
+			c = fake.NewFakeClient(p, sts, segmentStorePod("example-pravega-segment-store-0", "rev-2", true),
+				segmentStorePod("example-pravega-segment-store-1", "rev-1", true))
+			r.Client = c
+			Ω(r.rollSegmentStorePods(p)).Should(Succeed())
+			Ω(podExists("example-pravega-segment-store-0")).Should(BeTrue())
+			Ω(podExists("example-pravega-segment-store-1")).Should(BeFalse())
+		})
+
+		It("should not wait for a pod which is not ready", func() {
+			c = fake.NewFakeClient(p, sts, segmentStorePod("example-pravega-segment-store-0", "rev-2", false),
+				segmentStorePod("example-pravega-segment-store-1", "rev-1", true))
+			r.Client = c
+			Ω(r.rollSegmentStorePods(p)).Should(Succeed())
+			Ω(podExists("example-pravega-segment-store-1")).Should(BeTrue())
+		})
+
+		It("should not restart the pods during an upgrade", func() {
+			p.Status.CurrentVersion = "0.9.0"
+			c = fake.NewFakeClient(p, sts, segmentStorePod("example-pravega-segment-store-0", "rev-1", true),
+				segmentStorePod("example-pravega-segment-store-1", "rev-1", true))
+			r.Client = c
+			Ω(r.rollSegmentStorePods(p)).Should(Succeed())
+			Ω(podExists("example-pravega-segment-store-0")).Should(BeTrue())
+		})
+
+		It("should keep the restart time of the pod template", func() {
+			current := &corev1.PodTemplateSpec{}
+			setRestartedAt(current)
+			desired := &corev1.PodTemplateSpec{}
+			keepRestartedAt(desired, current)
+			Ω(desired.Annotations[v1beta1.RestartedAtAnnotationKey]).ShouldNot(BeEmpty())
+			Ω(desired.Annotations).Should(Equal(current.Annotations))
+		})
+	})
+})
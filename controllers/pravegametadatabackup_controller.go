@@ -0,0 +1,386 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	metadataArchiveVolumeName = "metadata-archive"
+	metadataArchiveMountDir   = "/archive"
+	// metadataArchiveFile is the name of the archive in the scratch volume of S3 backups
+	metadataArchiveFile = "archive.json"
+
+	metadataJobBackoffLimit int32 = 3
+	// metadataJobPollTime is the delay between two checks of a running backup or restore
+	metadataJobPollTime = 10 * time.Second
+)
+
+var _ reconcile.Reconciler = &PravegaMetadataBackupReconciler{}
+
+// PravegaMetadataBackupReconciler reconciles a PravegaMetadataBackup object
+type PravegaMetadataBackupReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegametadatabackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegametadatabackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile runs a Job copying the ZooKeeper metadata of the cluster to the storage of the
+// backup, and reports its progress in the status. A backup is taken once
+func (r *PravegaMetadataBackupReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	log.Printf("Reconciling PravegaMetadataBackup %s/%s\n", request.Namespace, request.Name)
+
+	b := &api.PravegaMetadataBackup{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, b)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get PravegaMetadataBackup: %v", err)
+		return reconcile.Result{}, err
+	}
+	if b.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+
+	if err = b.ValidateStorage(); err != nil {
+		return r.updateStatus(b, api.MetadataBackupFailed, err.Error())
+	}
+	p := &api.PravegaCluster{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: b.Spec.ClusterName, Namespace: b.Namespace}, p)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return r.updateStatus(b, api.MetadataBackupFailed, fmt.Sprintf("pravega cluster (%s) not found", b.Spec.ClusterName))
+		}
+		return reconcile.Result{}, err
+	}
+	// The defaults of a cluster created without the mutating webhook are not stored
+	p.WithDefaults()
+
+	job := &batchv1.Job{}
+	name := b.Name + "-metadata-backup"
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: b.Namespace}, job)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		job = MakeMetadataBackupJob(b, p)
+		controllerutil.SetControllerReference(b, job, r.Scheme)
+		if err = r.Client.Create(context.TODO(), job); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to create job (%s): %v", name, err)
+		}
+		log.Printf("started the metadata backup of pravega cluster %s to %s", p.Name, b.ArchiveLocation())
+		now := metav1.Now()
+		b.Status.StartTime = &now
+		b.Status.JobName = name
+		b.Status.Location = b.ArchiveLocation()
+	}
+
+	phase, message := metadataJobPhase(job)
+	if phase == api.MetadataBackupCompleted {
+		now := metav1.Now()
+		b.Status.CompletionTime = &now
+	}
+	return r.updateStatus(b, phase, message)
+}
+
+func (r *PravegaMetadataBackupReconciler) updateStatus(b *api.PravegaMetadataBackup, phase api.MetadataBackupPhase, message string) (ctrl.Result, error) {
+	b.Status.Phase = phase
+	b.Status.Message = message
+	if err := r.Client.Status().Update(context.TODO(), b); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update the status of backup (%s): %v", b.Name, err)
+	}
+	if b.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: metadataJobPollTime}, nil
+}
+
+// reconcileMetadataRestore restores the metadata of the backup of spec.restoreFrom before the
+// cluster is deployed for the first time, and returns whether the cluster can be deployed
+func (r *PravegaClusterReconciler) reconcileMetadataRestore(p *api.PravegaCluster) (bool, error) {
+	if p.Spec.RestoreFrom == nil {
+		return true, nil
+	}
+	backupName := p.Spec.RestoreFrom.BackupName
+	status := p.Status.MetadataRestore
+	if status != nil && status.BackupName == backupName &&
+		(status.Phase == api.MetadataBackupCompleted || status.Phase == api.MetadataBackupSkipped) {
+		return true, nil
+	}
+	if status == nil || status.BackupName != backupName {
+		status = &api.MetadataRestoreStatus{BackupName: backupName}
+	}
+	previous := *status
+
+	jobName := p.Name + "-metadata-restore"
+	job := &batchv1.Job{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: p.Namespace}, job)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		deploy := &appsv1.Deployment{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: p.DeploymentNameForController(), Namespace: p.Namespace}, deploy)
+		if err == nil {
+			status.Phase = api.MetadataBackupSkipped
+			status.Message = "the cluster already started, the metadata are only restored before the first deployment"
+			return true, r.updateMetadataRestoreStatus(p, status, previous)
+		}
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+
+		b := &api.PravegaMetadataBackup{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: backupName, Namespace: p.Namespace}, b)
+		if err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		if errors.IsNotFound(err) || b.Status.Phase != api.MetadataBackupCompleted {
+			status.Phase = api.MetadataBackupPending
+			status.Message = fmt.Sprintf("waiting for backup (%s) to complete", backupName)
+			return false, r.updateMetadataRestoreStatus(p, status, previous)
+		}
+
+		job = MakeMetadataRestoreJob(p, b)
+		controllerutil.SetControllerReference(p, job, r.Scheme)
+		if err = r.Client.Create(context.TODO(), job); err != nil {
+			return false, fmt.Errorf("failed to create job (%s): %v", jobName, err)
+		}
+		log.Printf("restoring the metadata of pravega cluster %s from %s", p.Name, b.ArchiveLocation())
+	}
+
+	status.Phase, status.Message = metadataJobPhase(job)
+	err = r.updateMetadataRestoreStatus(p, status, previous)
+	return status.Phase == api.MetadataBackupCompleted, err
+}
+
+func (r *PravegaClusterReconciler) updateMetadataRestoreStatus(p *api.PravegaCluster, status *api.MetadataRestoreStatus, previous api.MetadataRestoreStatus) error {
+	p.Status.MetadataRestore = status
+	if *status == previous {
+		return nil
+	}
+	if err := r.updateClusterStatus(p); err != nil {
+		return fmt.Errorf("failed to update the metadata restore status: %v", err)
+	}
+	return nil
+}
+
+// metadataJobPhase returns the phase of a backup or restore from the conditions of its Job
+func metadataJobPhase(job *batchv1.Job) (api.MetadataBackupPhase, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return api.MetadataBackupCompleted, ""
+		case batchv1.JobFailed:
+			return api.MetadataBackupFailed, fmt.Sprintf("job %s failed: %s", job.Name, condition.Message)
+		}
+	}
+	return api.MetadataBackupRunning, ""
+}
+
+// MakeMetadataBackupJob returns the Job writing the metadata archive of the cluster to the
+// storage of the backup. With S3 storage, the archive is written to a scratch volume by an
+// init container, then uploaded
+func MakeMetadataBackupJob(b *api.PravegaMetadataBackup, p *api.PravegaCluster) *batchv1.Job {
+	archive := path.Join(metadataArchiveMountDir, b.ArchivePath())
+	if b.Spec.Storage.S3 != nil {
+		archive = path.Join(metadataArchiveMountDir, metadataArchiveFile)
+	}
+	tool := metadataToolContainer("backup", p, "-metadata-backup", archive)
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       metadataToolVolumes(b, p),
+	}
+	if s3 := b.Spec.Storage.S3; s3 != nil {
+		podSpec.InitContainers = []corev1.Container{tool}
+		podSpec.Containers = []corev1.Container{
+			awsCliContainer("upload", s3, archive, fmt.Sprintf("s3://%s/%s", s3.Bucket, b.ArchiveKey())),
+		}
+	} else {
+		podSpec.Containers = []corev1.Container{tool}
+	}
+	return makeMetadataJob(b.Name+"-metadata-backup", b.Namespace, p, "metadata-backup", podSpec)
+}
+
+// MakeMetadataRestoreJob returns the Job replaying the archive of the backup into the
+// metadata of the cluster
+func MakeMetadataRestoreJob(p *api.PravegaCluster, b *api.PravegaMetadataBackup) *batchv1.Job {
+	archive := path.Join(metadataArchiveMountDir, b.ArchivePath())
+	if b.Spec.Storage.S3 != nil {
+		archive = path.Join(metadataArchiveMountDir, metadataArchiveFile)
+	}
+	tool := metadataToolContainer("restore", p, "-metadata-restore", archive)
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       metadataToolVolumes(b, p),
+		Containers:    []corev1.Container{tool},
+	}
+	if s3 := b.Spec.Storage.S3; s3 != nil {
+		podSpec.InitContainers = []corev1.Container{
+			awsCliContainer("download", s3, fmt.Sprintf("s3://%s/%s", s3.Bucket, b.ArchiveKey()), archive),
+		}
+	}
+	return makeMetadataJob(p.Name+"-metadata-restore", p.Namespace, p, "metadata-restore", podSpec)
+}
+
+func makeMetadataJob(name string, namespace string, p *api.PravegaCluster, component string, podSpec corev1.PodSpec) *batchv1.Job {
+	labels := p.LabelsForPravegaCluster()
+	labels["component"] = component
+	backoffLimit := metadataJobBackoffLimit
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+func metadataArchiveVolume(b *api.PravegaMetadataBackup) corev1.Volume {
+	volume := corev1.Volume{Name: metadataArchiveVolumeName}
+	if pvc := b.Spec.Storage.PersistentVolumeClaim; pvc != nil {
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: pvc.ClaimName,
+		}
+	} else {
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+	return volume
+}
+
+func metadataArchiveVolumeMount() []corev1.VolumeMount {
+	return []corev1.VolumeMount{
+		{
+			Name:      metadataArchiveVolumeName,
+			MountPath: metadataArchiveMountDir,
+		},
+	}
+}
+
+// metadataToolContainer runs the operator binary to back up or restore the metadata
+func metadataToolContainer(name string, p *api.PravegaCluster, operation string, archive string) corev1.Container {
+	container := corev1.Container{
+		Name:  name,
+		Image: config.MetadataToolImage,
+		Command: []string{
+			"pravega-operator",
+			operation, archive,
+			"-zookeeper-uri", p.ZookeeperConnectString(),
+			"-cluster-name", p.Name,
+		},
+		VolumeMounts: metadataArchiveVolumeMount(),
+	}
+	if p.Spec.Zookeeper.IsTLSEnabled() {
+		container.Command = append(container.Command, "-zookeeper-tls-dir", zkTLSMountDir)
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: zkTLSVolumeName, MountPath: zkTLSMountDir})
+	}
+	if p.Spec.Zookeeper.IsAuthEnabled() {
+		container.Command = append(container.Command, "-zookeeper-auth-dir", zkAuthMountDir)
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: zkAuthVolumeName, MountPath: zkAuthMountDir})
+	}
+	return container
+}
+
+// metadataToolVolumes returns the volumes of the job, along with the ZooKeeper
+// secrets read by the tool
+func metadataToolVolumes(b *api.PravegaMetadataBackup, p *api.PravegaCluster) []corev1.Volume {
+	volumes := []corev1.Volume{metadataArchiveVolume(b)}
+	if p.Spec.Zookeeper.IsTLSEnabled() {
+		volumes = append(volumes, corev1.Volume{
+			Name:         zkTLSVolumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: p.Spec.Zookeeper.TLSSecret}},
+		})
+	}
+	if p.Spec.Zookeeper.IsAuthEnabled() {
+		volumes = append(volumes, corev1.Volume{
+			Name:         zkAuthVolumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: p.Spec.Zookeeper.AuthSecret}},
+		})
+	}
+	return volumes
+}
+
+func awsCliContainer(name string, s3 *api.MetadataBackupS3, source string, destination string) corev1.Container {
+	image := s3.Image
+	if image == "" {
+		image = api.DefaultMetadataBackupS3Image
+	}
+	command := []string{"aws", "s3", "cp", source, destination}
+	if s3.Endpoint != "" {
+		command = append(command, "--endpoint-url", s3.Endpoint)
+	}
+	container := corev1.Container{
+		Name:    name,
+		Image:   image,
+		Command: command,
+		EnvFrom: []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: s3.CredentialsSecret},
+				},
+			},
+		},
+		VolumeMounts: metadataArchiveVolumeMount(),
+	}
+	if s3.Region != "" {
+		container.Env = []corev1.EnvVar{{Name: "AWS_DEFAULT_REGION", Value: s3.Region}}
+	}
+	return container
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PravegaMetadataBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PravegaMetadataBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
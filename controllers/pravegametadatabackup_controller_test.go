@@ -0,0 +1,273 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PravegaMetadataBackup Controller", func() {
+	const (
+		Name      = "example"
+		Namespace = "default"
+	)
+
+	var (
+		s   = scheme.Scheme
+		c   client.Client
+		p   *v1beta1.PravegaCluster
+		b   *v1beta1.PravegaMetadataBackup
+		err error
+	)
+
+	getBackup := func() *v1beta1.PravegaMetadataBackup {
+		found := &v1beta1.PravegaMetadataBackup{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: b.Name, Namespace: Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	getJob := func(name string) *batchv1.Job {
+		found := &batchv1.Job{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	setJobCondition := func(job *batchv1.Job, conditionType batchv1.JobConditionType) {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+		Ω(c.Status().Update(context.TODO(), job)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Namespace: Namespace},
+			Spec: v1beta1.ClusterSpec{
+				ZookeeperUri: "zookeeper-client:2181",
+			},
+		}
+		p.WithDefaults()
+		b = &v1beta1.PravegaMetadataBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: Namespace},
+			Spec: v1beta1.MetadataBackupSpec{
+				ClusterName: Name,
+				Storage: v1beta1.MetadataBackupStorage{
+					PersistentVolumeClaim: &v1beta1.MetadataBackupPVC{ClaimName: "backups"},
+				},
+			},
+		}
+		s.AddKnownTypes(v1beta1.GroupVersion, p, b, &v1beta1.PravegaMetadataBackupList{})
+	})
+
+	Context("Backup and restore jobs", func() {
+		It("should run the tool against the volume claim", func() {
+			job := MakeMetadataBackupJob(b, p)
+			Ω(job.Name).To(Equal("nightly-metadata-backup"))
+			Ω(job.Labels["component"]).To(Equal("metadata-backup"))
+			spec := job.Spec.Template.Spec
+			Ω(spec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+			Ω(spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+			Ω(spec.InitContainers).To(BeEmpty())
+			Ω(spec.Containers[0].Command).To(Equal([]string{
+				"pravega-operator", "-metadata-backup", "/archive/nightly.json",
+				"-zookeeper-uri", "zookeeper-client:2181", "-cluster-name", Name,
+			}))
+		})
+
+		It("should upload the archive to S3 after writing it", func() {
+			b.Spec.Storage = v1beta1.MetadataBackupStorage{
+				S3: &v1beta1.MetadataBackupS3{
+					Bucket:            "bucket",
+					Endpoint:          "http://minio:9000",
+					Region:            "us-east-1",
+					CredentialsSecret: "s3-credentials",
+				},
+			}
+			spec := MakeMetadataBackupJob(b, p).Spec.Template.Spec
+			Ω(spec.Volumes[0].EmptyDir).NotTo(BeNil())
+			Ω(spec.InitContainers[0].Command[2]).To(Equal("/archive/archive.json"))
+			upload := spec.Containers[0]
+			Ω(upload.Image).To(Equal(v1beta1.DefaultMetadataBackupS3Image))
+			Ω(upload.Command).To(Equal([]string{
+				"aws", "s3", "cp", "/archive/archive.json", "s3://bucket/default/nightly.json",
+				"--endpoint-url", "http://minio:9000",
+			}))
+			Ω(upload.EnvFrom[0].SecretRef.Name).To(Equal("s3-credentials"))
+			Ω(upload.Env).To(ContainElement(corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: "us-east-1"}))
+		})
+
+		It("should download the archive before restoring it", func() {
+			b.Spec.Storage = v1beta1.MetadataBackupStorage{
+				S3: &v1beta1.MetadataBackupS3{Bucket: "bucket", Key: "pravega.json", CredentialsSecret: "s3-credentials"},
+			}
+			job := MakeMetadataRestoreJob(p, b)
+			Ω(job.Name).To(Equal("example-metadata-restore"))
+			spec := job.Spec.Template.Spec
+			Ω(spec.InitContainers[0].Command).To(Equal([]string{"aws", "s3", "cp", "s3://bucket/pravega.json", "/archive/archive.json"}))
+			Ω(spec.Containers[0].Command[1:3]).To(Equal([]string{"-metadata-restore", "/archive/archive.json"}))
+		})
+	})
+
+	Context("Reconciling a backup", func() {
+		var (
+			r   *PravegaMetadataBackupReconciler
+			req reconcile.Request
+			res reconcile.Result
+		)
+
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, b).Build()
+			r = &PravegaMetadataBackupReconciler{Client: c, Scheme: s}
+			req = reconcile.Request{NamespacedName: types.NamespacedName{Name: b.Name, Namespace: Namespace}}
+			res, err = r.Reconcile(context.TODO(), req)
+		})
+
+		It("should start the job and report it running", func() {
+			Ω(err).Should(BeNil())
+			Ω(res.RequeueAfter).To(Equal(metadataJobPollTime))
+			found := getBackup()
+			Ω(found.Status.Phase).To(Equal(v1beta1.MetadataBackupRunning))
+			Ω(found.Status.JobName).To(Equal("nightly-metadata-backup"))
+			Ω(found.Status.Location).To(Equal("pvc://backups/nightly.json"))
+			Ω(found.Status.StartTime).NotTo(BeNil())
+			Ω(getJob("nightly-metadata-backup").OwnerReferences[0].Name).To(Equal("nightly"))
+		})
+
+		It("should complete with the job", func() {
+			setJobCondition(getJob("nightly-metadata-backup"), batchv1.JobComplete)
+			res, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			Ω(res.RequeueAfter).To(BeZero())
+			found := getBackup()
+			Ω(found.Status.Phase).To(Equal(v1beta1.MetadataBackupCompleted))
+			Ω(found.Status.CompletionTime).NotTo(BeNil())
+		})
+
+		It("should report a failed job", func() {
+			setJobCondition(getJob("nightly-metadata-backup"), batchv1.JobFailed)
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			found := getBackup()
+			Ω(found.Status.Phase).To(Equal(v1beta1.MetadataBackupFailed))
+			Ω(found.Status.Message).To(ContainSubstring("BackoffLimitExceeded"))
+		})
+
+		It("should fail a backup of a missing cluster", func() {
+			b = &v1beta1.PravegaMetadataBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: Namespace},
+				Spec:       v1beta1.MetadataBackupSpec{ClusterName: "missing", Storage: b.Spec.Storage},
+			}
+			Ω(c.Create(context.TODO(), b)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "orphan", Namespace: Namespace}})
+			Ω(err).Should(BeNil())
+			found := getBackup()
+			Ω(found.Status.Phase).To(Equal(v1beta1.MetadataBackupFailed))
+			Ω(found.Status.Message).To(ContainSubstring("not found"))
+		})
+	})
+
+	Context("Restoring a cluster", func() {
+		var (
+			r        *PravegaClusterReconciler
+			restored bool
+		)
+
+		getRestoreStatus := func() *v1beta1.MetadataRestoreStatus {
+			found := &v1beta1.PravegaCluster{}
+			Ω(c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, found)).Should(Succeed())
+			return found.Status.MetadataRestore
+		}
+
+		BeforeEach(func() {
+			p.Spec.RestoreFrom = &v1beta1.MetadataRestoreSpec{BackupName: b.Name}
+		})
+
+		Context("From a running backup", func() {
+			BeforeEach(func() {
+				c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, b).Build()
+				r = &PravegaClusterReconciler{Client: c, Scheme: s}
+				restored, err = r.reconcileMetadataRestore(p)
+			})
+
+			It("should wait for the backup", func() {
+				Ω(err).Should(BeNil())
+				Ω(restored).To(BeFalse())
+				Ω(getRestoreStatus().Phase).To(Equal(v1beta1.MetadataBackupPending))
+			})
+		})
+
+		Context("From a completed backup", func() {
+			BeforeEach(func() {
+				b.Status.Phase = v1beta1.MetadataBackupCompleted
+				c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, b).Build()
+				r = &PravegaClusterReconciler{Client: c, Scheme: s}
+				restored, err = r.reconcileMetadataRestore(p)
+			})
+
+			It("should run the restore job before deploying", func() {
+				Ω(err).Should(BeNil())
+				Ω(restored).To(BeFalse())
+				Ω(getRestoreStatus().Phase).To(Equal(v1beta1.MetadataBackupRunning))
+				Ω(getJob("example-metadata-restore").OwnerReferences[0].Name).To(Equal(Name))
+			})
+
+			It("should deploy once the job completed", func() {
+				setJobCondition(getJob("example-metadata-restore"), batchv1.JobComplete)
+				restored, err = r.reconcileMetadataRestore(p)
+				Ω(err).Should(BeNil())
+				Ω(restored).To(BeTrue())
+				Ω(getRestoreStatus().Phase).To(Equal(v1beta1.MetadataBackupCompleted))
+				restored, err = r.reconcileMetadataRestore(p)
+				Ω(restored).To(BeTrue())
+			})
+
+			It("should not deploy after a failed restore", func() {
+				setJobCondition(getJob("example-metadata-restore"), batchv1.JobFailed)
+				restored, err = r.reconcileMetadataRestore(p)
+				Ω(err).Should(BeNil())
+				Ω(restored).To(BeFalse())
+				Ω(getRestoreStatus().Phase).To(Equal(v1beta1.MetadataBackupFailed))
+			})
+		})
+
+		Context("Of a started cluster", func() {
+			BeforeEach(func() {
+				b.Status.Phase = v1beta1.MetadataBackupCompleted
+				deploy := &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: p.DeploymentNameForController(), Namespace: Namespace},
+				}
+				c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, b, deploy).Build()
+				r = &PravegaClusterReconciler{Client: c, Scheme: s}
+				restored, err = r.reconcileMetadataRestore(p)
+			})
+
+			It("should skip the restore", func() {
+				Ω(err).Should(BeNil())
+				Ω(restored).To(BeTrue())
+				Ω(getRestoreStatus().Phase).To(Equal(v1beta1.MetadataBackupSkipped))
+				Ω(c.Get(context.TODO(), types.NamespacedName{Name: "example-metadata-restore", Namespace: Namespace}, &batchv1.Job{})).ShouldNot(Succeed())
+			})
+		})
+	})
+})
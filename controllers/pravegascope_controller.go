@@ -0,0 +1,141 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ScopeFinalizer deletes the Pravega scope when the PravegaScope is deleted
+const ScopeFinalizer = "cleanUpPravegaScope"
+
+var _ reconcile.Reconciler = &PravegaScopeReconciler{}
+
+// PravegaScopeReconciler reconciles a PravegaScope object
+type PravegaScopeReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	// ControllerRestURL overrides the URL of the Controller REST API of the clusters
+	ControllerRestURL string
+}
+
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegascopes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegascopes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegascopes/finalizers,verbs=update
+
+// Reconcile creates the scope in the referenced cluster through the Controller REST API,
+// and deletes it when the PravegaScope is deleted
+func (r *PravegaScopeReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	log.Printf("Reconciling PravegaScope %s/%s\n", request.Namespace, request.Name)
+
+	s := &api.PravegaScope{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, s)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get PravegaScope: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	p := &api.PravegaCluster{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: s.Spec.ClusterName, Namespace: s.Namespace}, p)
+	if err != nil {
+		if errors.IsNotFound(err) && !s.DeletionTimestamp.IsZero() {
+			// the scope was deleted with its cluster
+			return reconcile.Result{}, r.removeFinalizer(s)
+		}
+		return r.updateStatus(s, false, fmt.Errorf("failed to get pravega cluster (%s): %v", s.Spec.ClusterName, err))
+	}
+	// The defaults of a cluster created without the mutating webhook are not stored
+	p.WithDefaults()
+
+	rc, err := newControllerRestClient(r.Client, p, s.Spec.CredentialsSecret, r.ControllerRestURL)
+	if err != nil {
+		return r.updateStatus(s, false, err)
+	}
+
+	if !s.DeletionTimestamp.IsZero() {
+		if !util.ContainsString(s.Finalizers, ScopeFinalizer) {
+			return reconcile.Result{}, nil
+		}
+		if err = rc.DeleteScope(s.GetScopeName()); err != nil {
+			return r.updateStatus(s, false, fmt.Errorf("failed to delete scope (%s): %v", s.GetScopeName(), err))
+		}
+		return reconcile.Result{}, r.removeFinalizer(s)
+	}
+
+	if !config.DisableFinalizer && !util.ContainsString(s.Finalizers, ScopeFinalizer) {
+		s.Finalizers = append(s.Finalizers, ScopeFinalizer)
+		if err = r.Client.Update(context.TODO(), s); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add the finalizer (%s): %v", s.Name, err)
+		}
+	}
+
+	exists, err := rc.ScopeExists(s.GetScopeName())
+	if err == nil && !exists {
+		log.Printf("creating scope %s in pravega cluster %s", s.GetScopeName(), p.Name)
+		err = rc.CreateScope(s.GetScopeName())
+	}
+	if err != nil {
+		return r.updateStatus(s, false, fmt.Errorf("failed to create scope (%s): %v", s.GetScopeName(), err))
+	}
+	return r.updateStatus(s, true, nil)
+}
+
+func (r *PravegaScopeReconciler) removeFinalizer(s *api.PravegaScope) error {
+	if !util.ContainsString(s.Finalizers, ScopeFinalizer) {
+		return nil
+	}
+	s.Finalizers = util.RemoveString(s.Finalizers, ScopeFinalizer)
+	if err := r.Client.Update(context.TODO(), s); err != nil {
+		return fmt.Errorf("failed to remove the finalizer (%s): %v", s.Name, err)
+	}
+	return nil
+}
+
+// updateStatus records the result of the reconcile in the status. A reconcile error is
+// returned so that the request is retried with backoff
+func (r *PravegaScopeReconciler) updateStatus(s *api.PravegaScope, ready bool, reconcileErr error) (ctrl.Result, error) {
+	s.Status.Ready = ready
+	s.Status.ObservedGeneration = s.Generation
+	s.Status.Message = ""
+	if reconcileErr != nil {
+		log.Printf("failed to reconcile pravega scope (%s): %v", s.Name, reconcileErr)
+		s.Status.Message = reconcileErr.Error()
+	}
+	if err := r.Client.Status().Update(context.TODO(), s); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update the status of pravega scope (%s): %v", s.Name, err)
+	}
+	if reconcileErr != nil {
+		return reconcile.Result{}, reconcileErr
+	}
+	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PravegaScopeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PravegaScope{}).
+		Complete(r)
+}
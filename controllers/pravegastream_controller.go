@@ -0,0 +1,179 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// StreamFinalizer seals and deletes the Pravega stream when the PravegaStream is deleted
+const StreamFinalizer = "cleanUpPravegaStream"
+
+var _ reconcile.Reconciler = &PravegaStreamReconciler{}
+
+// PravegaStreamReconciler reconciles a PravegaStream object
+type PravegaStreamReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	// ControllerRestURL overrides the URL of the Controller REST API of the clusters
+	ControllerRestURL string
+}
+
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegastreams,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegastreams/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegastreams/finalizers,verbs=update
+
+// Reconcile creates or updates the stream in the referenced cluster through the Controller
+// REST API, mirrors its configuration in the status, and seals and deletes it when the
+// PravegaStream is deleted
+func (r *PravegaStreamReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	log.Printf("Reconciling PravegaStream %s/%s\n", request.Namespace, request.Name)
+
+	s := &api.PravegaStream{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, s)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get PravegaStream: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	p := &api.PravegaCluster{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: s.Spec.ClusterName, Namespace: s.Namespace}, p)
+	if err != nil {
+		if errors.IsNotFound(err) && !s.DeletionTimestamp.IsZero() {
+			// the stream was deleted with its cluster
+			return reconcile.Result{}, r.removeFinalizer(s)
+		}
+		return r.updateStatus(s, nil, fmt.Errorf("failed to get pravega cluster (%s): %v", s.Spec.ClusterName, err))
+	}
+	// The defaults of a cluster created without the mutating webhook are not stored
+	p.WithDefaults()
+
+	rc, err := newControllerRestClient(r.Client, p, s.Spec.CredentialsSecret, r.ControllerRestURL)
+	if err != nil {
+		return r.updateStatus(s, nil, err)
+	}
+
+	scope, stream := s.Spec.ScopeName, s.GetStreamName()
+	if !s.DeletionTimestamp.IsZero() {
+		if !util.ContainsString(s.Finalizers, StreamFinalizer) {
+			return reconcile.Result{}, nil
+		}
+		if err = rc.SealStream(scope, stream); err != nil {
+			return r.updateStatus(s, nil, fmt.Errorf("failed to seal stream (%s/%s): %v", scope, stream, err))
+		}
+		if err = rc.DeleteStream(scope, stream); err != nil {
+			return r.updateStatus(s, nil, fmt.Errorf("failed to delete stream (%s/%s): %v", scope, stream, err))
+		}
+		return reconcile.Result{}, r.removeFinalizer(s)
+	}
+
+	if !config.DisableFinalizer && !util.ContainsString(s.Finalizers, StreamFinalizer) {
+		s.Finalizers = append(s.Finalizers, StreamFinalizer)
+		if err = r.Client.Update(context.TODO(), s); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add the finalizer (%s): %v", s.Name, err)
+		}
+	}
+
+	current, err := r.syncStream(rc, s)
+	return r.updateStatus(s, current, err)
+}
+
+// syncStream creates the stream, or updates its configuration when it differs from the
+// spec, and returns the configuration reported by the Controller
+func (r *PravegaStreamReconciler) syncStream(rc *controllerRestClient, s *api.PravegaStream) (*streamConfig, error) {
+	scope, stream := s.Spec.ScopeName, s.GetStreamName()
+	exists, err := rc.ScopeExists(scope)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("scope (%s) does not exist", scope)
+	}
+
+	current, err := rc.GetStream(scope, stream)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		log.Printf("creating stream %s/%s", scope, stream)
+		if err = rc.CreateStream(s); err != nil {
+			return nil, fmt.Errorf("failed to create stream (%s/%s): %v", scope, stream, err)
+		}
+	} else if !s.MatchesSpec(current.ScalingPolicy, current.RetentionPolicy, current.Tags) {
+		log.Printf("updating the configuration of stream %s/%s", scope, stream)
+		if err = rc.UpdateStream(s); err != nil {
+			return current, fmt.Errorf("failed to update stream (%s/%s): %v", scope, stream, err)
+		}
+	} else {
+		return current, nil
+	}
+	return rc.GetStream(scope, stream)
+}
+
+func (r *PravegaStreamReconciler) removeFinalizer(s *api.PravegaStream) error {
+	if !util.ContainsString(s.Finalizers, StreamFinalizer) {
+		return nil
+	}
+	s.Finalizers = util.RemoveString(s.Finalizers, StreamFinalizer)
+	if err := r.Client.Update(context.TODO(), s); err != nil {
+		return fmt.Errorf("failed to remove the finalizer (%s): %v", s.Name, err)
+	}
+	return nil
+}
+
+// updateStatus mirrors the configuration reported by the Controller in the status. A
+// reconcile error is returned so that the request is retried with backoff
+func (r *PravegaStreamReconciler) updateStatus(s *api.PravegaStream, current *streamConfig, reconcileErr error) (ctrl.Result, error) {
+	s.Status.ObservedGeneration = s.Generation
+	s.Status.Message = ""
+	if current != nil {
+		s.Status.ScalingPolicy = current.ScalingPolicy
+		s.Status.RetentionPolicy = current.RetentionPolicy
+		s.Status.Tags = current.Tags
+	}
+	s.Status.Ready = reconcileErr == nil && current != nil &&
+		s.MatchesSpec(current.ScalingPolicy, current.RetentionPolicy, current.Tags)
+	if reconcileErr != nil {
+		log.Printf("failed to reconcile pravega stream (%s): %v", s.Name, reconcileErr)
+		s.Status.Message = reconcileErr.Error()
+	} else if !s.Status.Ready {
+		s.Status.Message = "the stream configuration does not match the spec"
+	}
+	if err := r.Client.Status().Update(context.TODO(), s); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update the status of pravega stream (%s): %v", s.Name, err)
+	}
+	if reconcileErr != nil {
+		return reconcile.Result{}, reconcileErr
+	}
+	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PravegaStreamReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PravegaStream{}).
+		Complete(r)
+}
@@ -0,0 +1,349 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRestController serves the scope and stream endpoints of the Controller REST API
+type fakeRestController struct {
+	sync.Mutex
+	scopes   map[string]map[string]*streamConfig
+	sealed   map[string]bool
+	requests []string
+	auth     string
+}
+
+func newFakeRestController() *fakeRestController {
+	return &fakeRestController{
+		scopes: map[string]map[string]*streamConfig{},
+		sealed: map[string]bool{},
+	}
+}
+
+func (f *fakeRestController) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, req.Method+" "+req.URL.Path)
+	if user, password, ok := req.BasicAuth(); ok {
+		f.auth = user + ":" + password
+	}
+
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1/scopes"), "/")
+	switch {
+	case len(parts) == 1 && req.Method == http.MethodPost:
+		body := map[string]string{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		if _, ok := f.scopes[body["scopeName"]]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.scopes[body["scopeName"]] = map[string]*streamConfig{}
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 2:
+		streams, ok := f.scopes[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Method == http.MethodDelete {
+			if len(streams) > 0 {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			delete(f.scopes, parts[1])
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"scopeName": parts[1]})
+	case len(parts) == 3 && req.Method == http.MethodPost:
+		streams, ok := f.scopes[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		config := &streamConfig{}
+		_ = json.NewDecoder(req.Body).Decode(config)
+		streams[config.StreamName] = &streamConfig{
+			ScopeName:       parts[1],
+			StreamName:      config.StreamName,
+			ScalingPolicy:   config.ScalingPolicy,
+			RetentionPolicy: config.RetentionPolicy,
+			Tags:            config.StreamTags,
+		}
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) >= 4:
+		stream, ok := f.scopes[parts[1]][parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		id := parts[1] + "/" + parts[3]
+		switch {
+		case len(parts) == 5 && req.Method == http.MethodPut:
+			f.sealed[id] = true
+		case req.Method == http.MethodPut:
+			config := &streamConfig{}
+			_ = json.NewDecoder(req.Body).Decode(config)
+			stream.ScalingPolicy = config.ScalingPolicy
+			stream.RetentionPolicy = config.RetentionPolicy
+			stream.Tags = config.StreamTags
+			_ = json.NewEncoder(w).Encode(stream)
+		case req.Method == http.MethodDelete:
+			if !f.sealed[id] {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			delete(f.scopes[parts[1]], parts[3])
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			_ = json.NewEncoder(w).Encode(stream)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("PravegaScope and PravegaStream Controllers", func() {
+	const (
+		Name      = "example"
+		Namespace = "default"
+	)
+
+	var (
+		s          = scheme.Scheme
+		server     *httptest.Server
+		controller *fakeRestController
+		c          client.Client
+		p          *v1beta1.PravegaCluster
+		scope      *v1beta1.PravegaScope
+		stream     *v1beta1.PravegaStream
+		secret     *corev1.Secret
+		scopeReq   reconcile.Request
+		streamReq  reconcile.Request
+
+		disableFinalizer bool
+	)
+
+	BeforeEach(func() {
+		disableFinalizer = config.DisableFinalizer
+		config.DisableFinalizer = false
+		controller = newFakeRestController()
+		server = httptest.NewServer(controller)
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Namespace: Namespace},
+		}
+		scope = &v1beta1.PravegaScope{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-scope", Namespace: Namespace},
+			Spec: v1beta1.PravegaScopeSpec{
+				ClusterName:       Name,
+				CredentialsSecret: "stream-admin",
+			},
+		}
+		stream = &v1beta1.PravegaStream{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-stream", Namespace: Namespace},
+			Spec: v1beta1.PravegaStreamSpec{
+				ClusterName: Name,
+				ScopeName:   "my-scope",
+				ScalingPolicy: &v1beta1.StreamScalingPolicy{
+					Type:        v1beta1.StreamScalingByEvents,
+					TargetRate:  100,
+					ScaleFactor: 2,
+					MinSegments: 3,
+				},
+				RetentionPolicy: &v1beta1.StreamRetentionPolicy{
+					Type:  v1beta1.StreamRetentionDays,
+					Value: 7,
+				},
+				Tags: []string{"a", "b"},
+			},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "stream-admin", Namespace: Namespace},
+			Data: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("secret"),
+			},
+		}
+		s.AddKnownTypes(v1beta1.GroupVersion, p, scope, stream, &v1beta1.PravegaScopeList{}, &v1beta1.PravegaStreamList{})
+		scopeReq = reconcile.Request{NamespacedName: types.NamespacedName{Name: scope.Name, Namespace: Namespace}}
+		streamReq = reconcile.Request{NamespacedName: types.NamespacedName{Name: stream.Name, Namespace: Namespace}}
+	})
+
+	AfterEach(func() {
+		config.DisableFinalizer = disableFinalizer
+		server.Close()
+	})
+
+	Context("Scope", func() {
+		var (
+			r   *PravegaScopeReconciler
+			res reconcile.Result
+			err error
+		)
+
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, scope, secret).Build()
+			r = &PravegaScopeReconciler{Client: c, Scheme: s, ControllerRestURL: server.URL}
+			res, err = r.Reconcile(context.TODO(), scopeReq)
+		})
+
+		It("should create the scope with the credentials of the secret", func() {
+			Ω(err).Should(BeNil())
+			Ω(res.RequeueAfter).To(Equal(ReconcileTime))
+			Ω(controller.scopes).To(HaveKey("my-scope"))
+			Ω(controller.auth).To(Equal("admin:secret"))
+		})
+
+		It("should add the finalizer and report the scope ready", func() {
+			found := &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(found.Finalizers).To(ContainElement(ScopeFinalizer))
+			Ω(found.Status.Ready).To(BeTrue())
+			Ω(found.Status.Message).To(BeEmpty())
+		})
+
+		It("should delete the scope on removal", func() {
+			found := &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(c.Delete(context.TODO(), found)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), scopeReq)
+			Ω(err).Should(BeNil())
+			Ω(controller.scopes).NotTo(HaveKey("my-scope"))
+			found = &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(found.Finalizers).NotTo(ContainElement(ScopeFinalizer))
+		})
+
+		It("should keep the finalizer while the scope has streams", func() {
+			controller.scopes["my-scope"]["my-stream"] = &streamConfig{}
+			found := &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(c.Delete(context.TODO(), found)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), scopeReq)
+			Ω(err).ShouldNot(BeNil())
+			found = &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(found.Finalizers).To(ContainElement(ScopeFinalizer))
+			Ω(found.Status.Message).To(ContainSubstring("failed to delete scope"))
+		})
+
+		It("should report a missing cluster in the status", func() {
+			Ω(c.Delete(context.TODO(), p)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), scopeReq)
+			Ω(err).ShouldNot(BeNil())
+			found := &v1beta1.PravegaScope{}
+			_ = c.Get(context.TODO(), scopeReq.NamespacedName, found)
+			Ω(found.Status.Ready).To(BeFalse())
+			Ω(found.Status.Message).To(ContainSubstring("failed to get pravega cluster"))
+		})
+	})
+
+	Context("Stream", func() {
+		var (
+			r   *PravegaStreamReconciler
+			res reconcile.Result
+			err error
+		)
+
+		BeforeEach(func() {
+			controller.scopes["my-scope"] = map[string]*streamConfig{}
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, stream).Build()
+			r = &PravegaStreamReconciler{Client: c, Scheme: s, ControllerRestURL: server.URL}
+			res, err = r.Reconcile(context.TODO(), streamReq)
+		})
+
+		It("should create the stream with the configuration of the spec", func() {
+			Ω(err).Should(BeNil())
+			Ω(res.RequeueAfter).To(Equal(ReconcileTime))
+			created := controller.scopes["my-scope"]["my-stream"]
+			Ω(created).NotTo(BeNil())
+			Ω(*created.ScalingPolicy).To(Equal(*stream.Spec.ScalingPolicy))
+			Ω(*created.RetentionPolicy).To(Equal(*stream.Spec.RetentionPolicy))
+			Ω(created.Tags).To(ConsistOf("a", "b"))
+		})
+
+		It("should mirror the stream configuration in the status", func() {
+			found := &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			Ω(found.Finalizers).To(ContainElement(StreamFinalizer))
+			Ω(found.Status.Ready).To(BeTrue())
+			Ω(found.Status.ScalingPolicy.MinSegments).To(BeEquivalentTo(3))
+			Ω(found.Status.RetentionPolicy.Value).To(BeEquivalentTo(7))
+			Ω(found.Status.Tags).To(ConsistOf("a", "b"))
+		})
+
+		It("should update the stream when the spec changes", func() {
+			found := &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			found.Spec.ScalingPolicy.MinSegments = 5
+			found.Spec.Tags = []string{"c"}
+			Ω(c.Update(context.TODO(), found)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), streamReq)
+			Ω(err).Should(BeNil())
+			Ω(controller.requests).To(ContainElement("PUT /v1/scopes/my-scope/streams/my-stream"))
+			found = &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			Ω(found.Status.Ready).To(BeTrue())
+			Ω(found.Status.ScalingPolicy.MinSegments).To(BeEquivalentTo(5))
+			Ω(found.Status.Tags).To(ConsistOf("c"))
+		})
+
+		It("should not update a stream matching the spec", func() {
+			_, err = r.Reconcile(context.TODO(), streamReq)
+			Ω(err).Should(BeNil())
+			Ω(controller.requests).NotTo(ContainElement("PUT /v1/scopes/my-scope/streams/my-stream"))
+		})
+
+		It("should seal and delete the stream on removal", func() {
+			found := &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			Ω(c.Delete(context.TODO(), found)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), streamReq)
+			Ω(err).Should(BeNil())
+			Ω(controller.sealed["my-scope/my-stream"]).To(BeTrue())
+			Ω(controller.scopes["my-scope"]).NotTo(HaveKey("my-stream"))
+			found = &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			Ω(found.Finalizers).NotTo(ContainElement(StreamFinalizer))
+		})
+
+		It("should report a missing scope in the status", func() {
+			delete(controller.scopes, "my-scope")
+			_, err = r.Reconcile(context.TODO(), streamReq)
+			Ω(err).ShouldNot(BeNil())
+			found := &v1beta1.PravegaStream{}
+			_ = c.Get(context.TODO(), streamReq.NamespacedName, found)
+			Ω(found.Status.Ready).To(BeFalse())
+			Ω(found.Status.Message).To(ContainSubstring("scope (my-scope) does not exist"))
+		})
+	})
+})
@@ -0,0 +1,300 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// UserFinalizer removes the user from the password file when the PravegaUser is deleted
+	UserFinalizer = "cleanUpPravegaUser"
+
+	// managedUsersAnnotationKey lists the users of the password file written by the operator,
+	// the other users of the file are left untouched
+	managedUsersAnnotationKey = "pravega.pravega.io/managed-users"
+
+	defaultPasswordFileKey = "userdata.txt"
+)
+
+var _ reconcile.Reconciler = &PravegaUserReconciler{}
+
+// PravegaUserReconciler aggregates the PravegaUser objects of a cluster into the password
+// file of its PasswordAuthHandler
+type PravegaUserReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegausers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegausers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=pravega.pravega.io,resources=pravegausers/finalizers,verbs=update
+
+// Reconcile rewrites the password file of the cluster of the user from all the PravegaUser
+// objects referencing the cluster
+func (r *PravegaUserReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	log.Printf("Reconciling PravegaUser %s/%s\n", request.Namespace, request.Name)
+
+	u := &api.PravegaUser{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, u)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		log.Printf("failed to get PravegaUser: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	if u.DeletionTimestamp.IsZero() && !config.DisableFinalizer && !util.ContainsString(u.Finalizers, UserFinalizer) {
+		u.Finalizers = append(u.Finalizers, UserFinalizer)
+		if err = r.Client.Update(context.TODO(), u); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add the finalizer (%s): %v", u.Name, err)
+		}
+	}
+
+	err = r.syncPasswordFile(u.Namespace, u.Spec.ClusterName)
+	if err != nil {
+		log.Printf("failed to sync the password file of pravega cluster (%s): %v", u.Spec.ClusterName, err)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+}
+
+// syncPasswordFile writes the users of the cluster in its password auth secret, reports the
+// status of each user and releases the deleted ones
+func (r *PravegaUserReconciler) syncPasswordFile(namespace string, clusterName string) error {
+	userList := &api.PravegaUserList{}
+	if err := r.Client.List(context.TODO(), userList, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	var users []*api.PravegaUser
+	for i := range userList.Items {
+		if userList.Items[i].Spec.ClusterName == clusterName {
+			users = append(users, &userList.Items[i])
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	p := &api.PravegaCluster{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: clusterName, Namespace: namespace}, p)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// the users of a deleted cluster are released
+		return r.updateUsers(users, nil, fmt.Errorf("pravega cluster (%s) not found", clusterName))
+	}
+	// The defaults of a cluster created without the mutating webhook are not stored
+	p.WithDefaults()
+	if !p.Spec.Authentication.IsEnabled() || p.Spec.Authentication.PasswordAuthSecret == "" {
+		return r.updateUsers(users, nil, fmt.Errorf("authentication with a passwordAuthSecret is not enabled on pravega cluster (%s)", clusterName))
+	}
+
+	secret := &corev1.Secret{}
+	secretName := p.Spec.Authentication.PasswordAuthSecret
+	found := true
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: namespace}, secret)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		found = false
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: namespace,
+				Labels:    p.LabelsForPravegaCluster(),
+			},
+		}
+	}
+
+	managed := map[string]bool{}
+	for _, name := range strings.Split(secret.Annotations[managedUsersAnnotationKey], ",") {
+		if name != "" {
+			managed[name] = true
+		}
+	}
+	if len(users) == 0 && len(managed) == 0 {
+		return nil
+	}
+
+	key := passwordFileKey(secret)
+	var unmanaged []util.PasswordFileEntry
+	previous := map[string]util.PasswordFileEntry{}
+	for _, entry := range util.ParsePasswordFile(string(secret.Data[key])) {
+		if managed[entry.Username] {
+			previous[entry.Username] = entry
+		} else {
+			unmanaged = append(unmanaged, entry)
+		}
+	}
+	isUnmanaged := map[string]bool{}
+	for _, entry := range unmanaged {
+		isUnmanaged[entry.Username] = true
+	}
+
+	userErrors := map[string]error{}
+	owners := map[string]string{}
+	var entries []util.PasswordFileEntry
+	for _, u := range users {
+		if !u.DeletionTimestamp.IsZero() {
+			continue
+		}
+		entry, err := r.makePasswordFileEntry(u, previous)
+		if err == nil && owners[entry.Username] != "" {
+			err = fmt.Errorf("user %s is already defined by PravegaUser %s", entry.Username, owners[entry.Username])
+		} else if err == nil && isUnmanaged[entry.Username] {
+			err = fmt.Errorf("user %s is defined in secret %s outside of the operator", entry.Username, secretName)
+		}
+		if err != nil {
+			userErrors[u.Name] = err
+			// keep the previous entry rather than locking the user out
+			if old, ok := previous[u.GetUsername()]; ok && owners[old.Username] == "" && !isUnmanaged[old.Username] {
+				entry = &old
+			} else {
+				continue
+			}
+		}
+		owners[entry.Username] = u.Name
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Username < entries[j].Username })
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Username)
+	}
+	content := util.FormatPasswordFile(append(unmanaged, entries...))
+	if !found || content != string(secret.Data[key]) || secret.Annotations[managedUsersAnnotationKey] != strings.Join(names, ",") {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[managedUsersAnnotationKey] = strings.Join(names, ",")
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[key] = []byte(content)
+		if found {
+			err = r.Client.Update(context.TODO(), secret)
+		} else {
+			err = r.Client.Create(context.TODO(), secret)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write the password file in secret (%s): %v", secretName, err)
+		}
+		log.Printf("updated the password file of pravega cluster %s with %d users", p.Name, len(entries))
+	}
+
+	checksum := sha256.Sum256([]byte(content))
+	if p.Status.PasswordAuthChecksum != hex.EncodeToString(checksum[:]) {
+		p.Status.PasswordAuthChecksum = hex.EncodeToString(checksum[:])
+		if err = r.Client.Status().Update(context.TODO(), p); err != nil {
+			return fmt.Errorf("failed to update the password file checksum of pravega cluster (%s): %v", p.Name, err)
+		}
+	}
+	return r.updateUsers(users, userErrors, nil)
+}
+
+// makePasswordFileEntry returns the password file entry of the user. The hash of the previous
+// entry is kept while the password is unchanged, since hashes are salted
+func (r *PravegaUserReconciler) makePasswordFileEntry(u *api.PravegaUser, previous map[string]util.PasswordFileEntry) (*util.PasswordFileEntry, error) {
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	ref := u.Spec.PasswordSecret
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: u.Namespace}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password secret (%s): %v", ref.Name, err)
+	}
+	password, ok := secret.Data[ref.Key]
+	if !ok || len(password) == 0 {
+		return nil, fmt.Errorf("password secret (%s) has no key %s", ref.Name, ref.Key)
+	}
+
+	entry := &util.PasswordFileEntry{Username: u.GetUsername(), ACL: u.ACLString()}
+	if old, ok := previous[entry.Username]; ok && util.VerifyPravegaPassword(old.Hash, string(password)) {
+		entry.Hash = old.Hash
+		return entry, nil
+	}
+	entry.Hash, err = util.HashPravegaPassword(string(password))
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// updateUsers reports the status of the users, and removes the finalizer of the deleted ones.
+// clusterErr applies to all the users, userErrors to each user
+func (r *PravegaUserReconciler) updateUsers(users []*api.PravegaUser, userErrors map[string]error, clusterErr error) error {
+	for _, u := range users {
+		if !u.DeletionTimestamp.IsZero() {
+			if util.ContainsString(u.Finalizers, UserFinalizer) {
+				u.Finalizers = util.RemoveString(u.Finalizers, UserFinalizer)
+				if err := r.Client.Update(context.TODO(), u); err != nil {
+					return fmt.Errorf("failed to remove the finalizer (%s): %v", u.Name, err)
+				}
+			}
+			continue
+		}
+
+		err := clusterErr
+		if err == nil {
+			err = userErrors[u.Name]
+		}
+		u.Status.Ready = err == nil
+		u.Status.ObservedGeneration = u.Generation
+		u.Status.Message = ""
+		if err != nil {
+			u.Status.Message = err.Error()
+		}
+		if err := r.Client.Status().Update(context.TODO(), u); err != nil {
+			return fmt.Errorf("failed to update the status of pravega user (%s): %v", u.Name, err)
+		}
+	}
+	return nil
+}
+
+// passwordFileKey returns the key of the password file in the secret: its only key when
+// the file was created by hand, otherwise userdata.txt
+func passwordFileKey(secret *corev1.Secret) string {
+	if len(secret.Data) == 1 {
+		for key := range secret.Data {
+			return key
+		}
+	}
+	return defaultPasswordFileKey
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PravegaUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PravegaUser{}).
+		Complete(r)
+}
@@ -0,0 +1,259 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/pravega/pravega-operator/api/v1beta1"
+	"github.com/pravega/pravega-operator/pkg/controller/config"
+	"github.com/pravega/pravega-operator/pkg/util"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PravegaUser Controller", func() {
+	const (
+		Name      = "example"
+		Namespace = "default"
+	)
+
+	var (
+		s                = scheme.Scheme
+		c                client.Client
+		r                *PravegaUserReconciler
+		p                *v1beta1.PravegaCluster
+		reader           *v1beta1.PravegaUser
+		writer           *v1beta1.PravegaUser
+		authSecret       *corev1.Secret
+		passwords        *corev1.Secret
+		req              reconcile.Request
+		err              error
+		disableFinalizer bool
+	)
+
+	getFile := func() []util.PasswordFileEntry {
+		found := &corev1.Secret{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: "password-auth", Namespace: Namespace}, found)).Should(Succeed())
+		return util.ParsePasswordFile(string(found.Data["userdata.txt"]))
+	}
+
+	getUser := func(name string) *v1beta1.PravegaUser {
+		found := &v1beta1.PravegaUser{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: Namespace}, found)).Should(Succeed())
+		return found
+	}
+
+	getChecksum := func() string {
+		found := &v1beta1.PravegaCluster{}
+		Ω(c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, found)).Should(Succeed())
+		return found.Status.PasswordAuthChecksum
+	}
+
+	BeforeEach(func() {
+		disableFinalizer = config.DisableFinalizer
+		config.DisableFinalizer = false
+		p = &v1beta1.PravegaCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Namespace: Namespace},
+			Spec: v1beta1.ClusterSpec{
+				Authentication: &v1beta1.AuthenticationParameters{
+					Enabled:            true,
+					PasswordAuthSecret: "password-auth",
+				},
+			},
+		}
+		reader = &v1beta1.PravegaUser{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: Namespace},
+			Spec: v1beta1.PravegaUserSpec{
+				ClusterName: Name,
+				PasswordSecret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "passwords"},
+					Key:                  "reader",
+				},
+				ACL: []v1beta1.AccessControlEntry{
+					{Scope: "scope1", Stream: "*", Permission: v1beta1.AccessPermissionRead},
+					{Scope: "scope1", ReaderGroup: "rg1", Permission: v1beta1.AccessPermissionReadUpdate},
+				},
+			},
+		}
+		writer = &v1beta1.PravegaUser{
+			ObjectMeta: metav1.ObjectMeta{Name: "writer", Namespace: Namespace},
+			Spec: v1beta1.PravegaUserSpec{
+				ClusterName: Name,
+				Username:    "app-writer",
+				PasswordSecret: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "passwords"},
+					Key:                  "writer",
+				},
+				ACL: []v1beta1.AccessControlEntry{
+					{Scope: "*", Permission: v1beta1.AccessPermissionReadUpdate},
+				},
+			},
+		}
+		authSecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "password-auth", Namespace: Namespace},
+			Data: map[string][]byte{
+				"userdata.txt": []byte("admin:abcd:prn::*,READ_UPDATE;\n"),
+			},
+		}
+		passwords = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "passwords", Namespace: Namespace},
+			Data: map[string][]byte{
+				"reader": []byte("reader-password"),
+				"writer": []byte("writer-password"),
+			},
+		}
+		s.AddKnownTypes(v1beta1.GroupVersion, p, reader, &v1beta1.PravegaUserList{})
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Name: reader.Name, Namespace: Namespace}}
+	})
+
+	AfterEach(func() {
+		config.DisableFinalizer = disableFinalizer
+	})
+
+	Context("Aggregating users", func() {
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, reader, writer, authSecret, passwords).Build()
+			r = &PravegaUserReconciler{Client: c, Scheme: s}
+			_, err = r.Reconcile(context.TODO(), req)
+		})
+
+		It("should write every user of the cluster and keep the other entries", func() {
+			Ω(err).Should(BeNil())
+			entries := getFile()
+			Ω(entries).To(HaveLen(3))
+			Ω(entries[0]).To(Equal(util.PasswordFileEntry{Username: "admin", Hash: "abcd", ACL: "prn::*,READ_UPDATE;"}))
+			Ω(entries[1].Username).To(Equal("app-writer"))
+			Ω(entries[1].ACL).To(Equal("prn::*,READ_UPDATE;"))
+			Ω(entries[2].Username).To(Equal("reader"))
+			Ω(entries[2].ACL).To(Equal("prn::/scope:scope1/stream:*,READ;prn::/scope:scope1/reader-group:rg1,READ_UPDATE;"))
+		})
+
+		It("should hash the passwords", func() {
+			entries := getFile()
+			Ω(util.VerifyPravegaPassword(entries[1].Hash, "writer-password")).To(BeTrue())
+			Ω(util.VerifyPravegaPassword(entries[2].Hash, "reader-password")).To(BeTrue())
+		})
+
+		It("should report the users ready and add the finalizer", func() {
+			for _, name := range []string{"reader", "writer"} {
+				u := getUser(name)
+				Ω(u.Status.Ready).To(BeTrue())
+				Ω(u.Status.Message).To(BeEmpty())
+			}
+			Ω(getUser("reader").Finalizers).To(ContainElement(UserFinalizer))
+		})
+
+		It("should set the checksum used to roll the controllers", func() {
+			checksum := getChecksum()
+			Ω(checksum).NotTo(BeEmpty())
+			found := &v1beta1.PravegaCluster{}
+			_ = c.Get(context.TODO(), types.NamespacedName{Name: Name, Namespace: Namespace}, found)
+			found.WithDefaults()
+			template := MakeControllerPodTemplate(found)
+			Ω(template.Annotations[v1beta1.PasswordAuthChecksumAnnotationKey]).To(Equal(checksum))
+		})
+
+		It("should not rewrite the file when nothing changed", func() {
+			before := getFile()
+			checksum := getChecksum()
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			Ω(getFile()).To(Equal(before))
+			Ω(getChecksum()).To(Equal(checksum))
+		})
+
+		It("should rehash a changed password and change the checksum", func() {
+			checksum := getChecksum()
+			passwords.Data["reader"] = []byte("new-password")
+			Ω(c.Update(context.TODO(), passwords)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			Ω(util.VerifyPravegaPassword(getFile()[2].Hash, "new-password")).To(BeTrue())
+			Ω(getChecksum()).NotTo(Equal(checksum))
+		})
+
+		It("should remove a deleted user from the file", func() {
+			Ω(c.Delete(context.TODO(), getUser("reader"))).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			entries := getFile()
+			Ω(entries).To(HaveLen(2))
+			Ω(entries[1].Username).To(Equal("app-writer"))
+			// the user is gone once the finalizer is removed
+			err = c.Get(context.TODO(), req.NamespacedName, &v1beta1.PravegaUser{})
+			Ω(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should reject a user defined outside of the operator", func() {
+			u := getUser("writer")
+			u.Spec.Username = "admin"
+			Ω(c.Update(context.TODO(), u)).Should(Succeed())
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			Ω(getUser("writer").Status.Ready).To(BeFalse())
+			Ω(getUser("writer").Status.Message).To(ContainSubstring("outside of the operator"))
+			Ω(getFile()[0]).To(Equal(util.PasswordFileEntry{Username: "admin", Hash: "abcd", ACL: "prn::*,READ_UPDATE;"}))
+		})
+
+		It("should keep the previous entry when the password is missing", func() {
+			delete(passwords.Data, "reader")
+			Ω(c.Update(context.TODO(), passwords)).Should(Succeed())
+			before := getFile()
+			_, err = r.Reconcile(context.TODO(), req)
+			Ω(err).Should(BeNil())
+			Ω(getFile()).To(Equal(before))
+			Ω(getUser("reader").Status.Ready).To(BeFalse())
+			Ω(getUser("reader").Status.Message).To(ContainSubstring("has no key reader"))
+		})
+	})
+
+	Context("Cluster without password authentication", func() {
+		BeforeEach(func() {
+			p.Spec.Authentication = nil
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, reader, passwords).Build()
+			r = &PravegaUserReconciler{Client: c, Scheme: s}
+			_, err = r.Reconcile(context.TODO(), req)
+		})
+
+		It("should report the error in the user status", func() {
+			Ω(err).Should(BeNil())
+			u := getUser("reader")
+			Ω(u.Status.Ready).To(BeFalse())
+			Ω(u.Status.Message).To(ContainSubstring("authentication with a passwordAuthSecret is not enabled"))
+		})
+	})
+
+	Context("Missing password auth secret", func() {
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(p, reader, passwords).Build()
+			r = &PravegaUserReconciler{Client: c, Scheme: s}
+			_, err = r.Reconcile(context.TODO(), req)
+		})
+
+		It("should create the secret", func() {
+			Ω(err).Should(BeNil())
+			entries := getFile()
+			Ω(entries).To(HaveLen(1))
+			Ω(entries[0].Username).To(Equal("reader"))
+		})
+	})
+})
@@ -38,7 +38,7 @@ type componentSyncVersionFun struct {
 // upgrade
 func (r *PravegaClusterReconciler) syncClusterVersion(p *pravegav1beta1.PravegaCluster) (err error) {
 	defer func() {
-		r.Client.Status().Update(context.TODO(), p)
+		r.updateClusterStatus(p)
 	}()
 
 	// we cannot upgrade if cluster is in UpgradeFailed or Rollback state
@@ -99,6 +99,11 @@ func (r *PravegaClusterReconciler) syncClusterVersion(p *pravegav1beta1.PravegaC
 		return nil
 	}
 
+	if p.Spec.UpgradeAfterDependencies && (p.Status.AreDependenciesUpgrading() || !p.Status.AreDependenciesReady()) {
+		log.Print("cannot trigger upgrade until zookeeper and bookkeeper are upgraded and ready")
+		return nil
+	}
+
 	if !p.Status.IsClusterInRollbackFailedState() {
 		// skip this check when cluster is in RollbackFailed state
 		if readyCondition == nil || readyCondition.Status != corev1.ConditionTrue {
@@ -122,15 +127,17 @@ func (r *PravegaClusterReconciler) syncClusterVersion(p *pravegav1beta1.PravegaC
 }
 
 func (r *PravegaClusterReconciler) clearUpgradeStatus(p *pravegav1beta1.PravegaCluster) (err error) {
+	patch := client.MergeFrom(p.DeepCopy())
 	p.Status.SetUpgradingConditionFalse()
 	p.Status.TargetVersion = ""
 	// need to deep copy the status struct, otherwise it will be overwritten
 	// when updating the CR below
 	status := p.Status.DeepCopy()
 
-	if err := r.Client.Update(context.TODO(), p); err != nil {
+	if err := r.Client.Patch(context.TODO(), p, patch); err != nil {
 		return err
 	}
+	p.WithDefaults()
 
 	p.Status = *status
 	return nil
@@ -138,7 +145,7 @@ func (r *PravegaClusterReconciler) clearUpgradeStatus(p *pravegav1beta1.PravegaC
 
 func (r *PravegaClusterReconciler) rollbackClusterVersion(p *pravegav1beta1.PravegaCluster, version string) (err error) {
 	defer func() {
-		r.Client.Status().Update(context.TODO(), p)
+		r.updateClusterStatus(p)
 	}()
 	_, rollbackCondition := p.Status.GetClusterCondition(pravegav1beta1.ClusterConditionRollback)
 	if rollbackCondition == nil || rollbackCondition.Status != corev1.ConditionTrue {
@@ -147,7 +154,7 @@ func (r *PravegaClusterReconciler) rollbackClusterVersion(p *pravegav1beta1.Prav
 		log.Printf("Updating Target Version to  %v", version)
 		p.Status.TargetVersion = version
 		p.Status.SetRollbackConditionTrue("", "")
-		updateErr := r.Client.Status().Update(context.TODO(), p)
+		updateErr := r.updateClusterStatus(p)
 		if updateErr != nil {
 			p.Status.SetRollbackConditionFalse()
 			log.Printf("Error updating cluster: %v", updateErr.Error())
@@ -187,15 +194,17 @@ func (r *PravegaClusterReconciler) rollbackClusterVersion(p *pravegav1beta1.Prav
 
 func (r *PravegaClusterReconciler) clearRollbackStatus(p *pravegav1beta1.PravegaCluster) (err error) {
 	log.Printf("clearRollbackStatus")
+	patch := client.MergeFrom(p.DeepCopy())
 	p.Status.SetRollbackConditionFalse()
 	p.Status.TargetVersion = ""
 	// need to deep copy the status struct, otherwise it will be overwritten
 	// when updating the CR below
 	status := p.Status.DeepCopy()
 
-	if err := r.Client.Update(context.TODO(), p); err != nil {
+	if err := r.Client.Patch(context.TODO(), p, patch); err != nil {
 		return err
 	}
+	p.WithDefaults()
 
 	p.Status = *status
 	return nil
@@ -463,7 +472,10 @@ func (r *PravegaClusterReconciler) syncStoreVersion(p *pravegav1beta1.PravegaClu
 }
 
 func (r *PravegaClusterReconciler) createExternalServices(p *pravegav1beta1.PravegaCluster) error {
-	services := MakeSegmentStoreExternalServices(p)
+	services, err := MakeSegmentStoreExternalServices(p)
+	if err != nil {
+		return err
+	}
 	for _, service := range services {
 		controllerutil.SetControllerReference(p, service, r.Scheme)
 		err := r.Client.Create(context.TODO(), service)
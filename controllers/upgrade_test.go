@@ -12,6 +12,7 @@ package controllers
 
 import (
 	"context"
+	"strconv"
 	"strings"
 
 	"github.com/pravega/pravega-operator/api/v1beta1"
@@ -279,12 +280,12 @@ var _ = Describe("Pravega Cluster Version Sync", func() {
 				err          error
 				foundPravega *v1beta1.PravegaCluster
 				client       client.Client
-				ctx          context.Context
 			)
 			BeforeEach(func() {
+				// stored with its defaults by the mutating webhook, but not deployed yet
+				p.WithDefaults()
 				client = fake.NewFakeClient(p)
 				r = &PravegaClusterReconciler{Client: client, Scheme: s}
-				_, err = r.Reconcile(ctx, req)
 				foundPravega = &v1beta1.PravegaCluster{}
 				_ = client.Get(context.TODO(), req.NamespacedName, foundPravega)
 				foundPravega.Status.SetUpgradingConditionTrue("UpgradeController", "0")
@@ -499,7 +500,7 @@ var _ = Describe("Pravega Cluster Version Sync", func() {
 				foundPravega.Spec.Version = "0.6.0"
 				foundPravega.Spec.ExternalAccess.Enabled = true
 				r.Client.Update(context.TODO(), foundPravega)
-				svc := MakeSegmentStoreExternalServices(foundPravega)
+				svc, _ := MakeSegmentStoreExternalServices(foundPravega)
 				r.Client.Create(context.TODO(), svc[0])
 				err = r.Client.Get(context.TODO(), types.NamespacedName{Name: p.ServiceNameForSegmentStoreBelow07(0), Namespace: p.Namespace}, svc[0])
 				foundPravega.Spec.Version = "0.7.0"
@@ -542,7 +543,9 @@ var _ = Describe("Pravega Cluster Version Sync", func() {
 				sts1 = MakeSegmentStoreStatefulSet(p)
 				r.Client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstoreBelow07(), Namespace: p.Namespace}, sts)
 				r.Client.Get(context.TODO(), types.NamespacedName{Name: p.StatefulSetNameForSegmentstoreAbove07(), Namespace: p.Namespace}, sts1)
-				sts1.ObjectMeta.ResourceVersion = "2"
+				// Without a stateful set above 0.7, sts1 is the one below 0.7, which is first updated through sts
+				version, _ := strconv.Atoi(sts.ResourceVersion)
+				sts1.ObjectMeta.ResourceVersion = strconv.Itoa(version + 1)
 				err = r.scaleSegmentStoreSTS(p, sts, sts1)
 			})
 			It("Error should be nil", func() {
@@ -4,17 +4,25 @@ module.exports = {
         {
             'Configuration':
             [
+                'operator-configuration',
                 'rbac',
                 'longtermstorage',
                 'pravega-options',
                 'tls',
+                'zookeeper',
+                'dependencies',
                 'auth',
                 'external-access',
                 'webhook',
                 'service-sts-name-configuration',
                 'auth-handlers',
                 'init-containers',
-                'influxdb-auth'
+                'influxdb-auth',
+                'pod-placement',
+                'dry-run',
+                'scopes-and-streams',
+                'metadata-backup',
+                'cluster-deletion'
             ]
         },
         'upgrade-cluster',
@@ -23,8 +23,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -32,26 +34,47 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 
+	bkapi "github.com/pravega/bookkeeper-operator/api/v1alpha1"
 	v1beta1 "github.com/pravega/pravega-operator/api/v1beta1"
 	"github.com/pravega/pravega-operator/controllers"
 	controllerconfig "github.com/pravega/pravega-operator/pkg/controller/config"
 	"github.com/pravega/pravega-operator/pkg/util"
 	"github.com/pravega/pravega-operator/pkg/version"
+	zkapi "github.com/pravega/zookeeper-operator/api/v1beta1"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	//+kubebuilder:scaffold:imports
 )
 
 var (
-	versionFlag bool
-	webhookFlag bool
-	log         = ctrl.Log.WithName("cmd")
-	scheme      = apimachineryruntime.NewScheme()
+	versionFlag      bool
+	webhookFlag      bool
+	leaderElect      bool
+	leaderElectionID string
+	leaseDuration    time.Duration
+	renewDeadline    time.Duration
+	retryPeriod      time.Duration
+	legacyLeaderLock bool
+	maxConcurrent    int
+	metadataBackup   string
+	metadataRestore  string
+	zookeeperUri     string
+	zookeeperTLSDir  string
+	zookeeperAuth    string
+	clusterName      string
+	log              = ctrl.Log.WithName("cmd")
+	scheme           = apimachineryruntime.NewScheme()
 )
 
 func init() {
@@ -59,8 +82,24 @@ func init() {
 	flag.BoolVar(&controllerconfig.TestMode, "test", false, "Enable test mode. Do not use this flag in production")
 	flag.BoolVar(&controllerconfig.DisableFinalizer, "disableFinalizer", false, "Disable finalizers for pravegaclusters. Use this flag with awareness of the consequences")
 	flag.BoolVar(&webhookFlag, "webhook", true, "Enable webhook, the default is enabled.")
+	flag.StringVar(&controllerconfig.MetadataToolImage, "metadata-tool-image", "", "Image of the metadata backup and restore jobs. Defaults to the image of the operator pod")
+	flag.StringVar(&metadataBackup, "metadata-backup", "", "Write the archive of the metadata of -cluster-name to this file and quit")
+	flag.StringVar(&metadataRestore, "metadata-restore", "", "Restore the metadata of -cluster-name from this archive and quit")
+	flag.StringVar(&zookeeperUri, "zookeeper-uri", "", "ZooKeeper connection string used by -metadata-backup and -metadata-restore")
+	flag.StringVar(&zookeeperTLSDir, "zookeeper-tls-dir", "", "Directory of the ca.crt of the ZooKeeper servers, enabling TLS for -metadata-backup and -metadata-restore")
+	flag.StringVar(&zookeeperAuth, "zookeeper-auth-dir", "", "Directory of the username and password of the ZooKeeper digest authentication for -metadata-backup and -metadata-restore")
+	flag.StringVar(&clusterName, "cluster-name", "", "Name of the Pravega cluster used by -metadata-backup and -metadata-restore")
+	flag.BoolVar(&leaderElect, "leader-elect", true, "Enable leader election, so that only one replica of the operator is active")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "pravega-operator-leader", "Name of the Lease used for leader election, in the namespace of the operator")
+	flag.DurationVar(&leaseDuration, "leader-lease-duration", 15*time.Second, "Duration a leader keeps the lease without renewing it, before another replica takes over")
+	flag.DurationVar(&renewDeadline, "leader-renew-deadline", 10*time.Second, "Duration the leader retries renewing the lease before giving up leadership")
+	flag.DurationVar(&retryPeriod, "leader-retry-period", 2*time.Second, "Duration between two attempts of the replicas to acquire or renew the lease")
+	flag.BoolVar(&legacyLeaderLock, "legacy-leader-lock", true, "Wait for operators of previous versions to release their "+util.LegacyLeaderLockName+" ConfigMap lock, and hold it while leader so that they do not start")
+	flag.IntVar(&maxConcurrent, "max-concurrent-reconciles", 1, "Number of Pravega clusters reconciled concurrently")
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(v1beta1.AddToScheme(scheme))
+	utilruntime.Must(zkapi.AddToScheme(scheme))
+	utilruntime.Must(bkapi.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 
 }
@@ -85,6 +124,13 @@ func main() {
 		os.Exit(0)
 	}
 
+	if metadataBackup != "" || metadataRestore != "" {
+		if err := runMetadataTool(); err != nil {
+			logrus.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	if controllerconfig.TestMode {
 		logrus.Warn("----- Running in test mode. Make sure you are NOT in production -----")
 	}
@@ -105,41 +151,121 @@ func main() {
 		os.Exit(1)
 	}
 
-	namespaces, err := getWatchNamespace()
+	watchNamespace, err := getWatchNamespace()
 	if err != nil {
 		log.Error(err, "unable to get WatchNamespace, "+
 			"the manager will watch and manage resources in all namespaces")
 	}
 
-	// Become the leader before proceeding
-	err = util.BecomeLeader(context.TODO(), cfg, "pravega-operator-lock", operatorNs)
+	// The legacy lock and the namespaces are read before the manager and its cache start
+	lockClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "unable to create the leader lock client")
+		os.Exit(1)
+	}
+	if leaderElect && legacyLeaderLock {
+		// Operators of previous versions elect their leader with a ConfigMap lock
+		err = util.WaitForLegacyLeaderLock(context.TODO(), lockClient, operatorNs, retryPeriod)
+		if err != nil {
+			log.Error(err, "failed to wait for the legacy leader lock")
+			os.Exit(1)
+		}
+	}
+
+	// The namespaces matching the selector are resolved once, at startup
+	namespaces, err := util.SelectWatchNamespaces(context.TODO(), lockClient, os.Getenv(watchNamespaceSelectorEnvVar), util.ParseWatchNamespaces(watchNamespace))
 	if err != nil {
-		log.Error(err, "")
+		log.Error(err, "unable to select the watched namespaces")
 		os.Exit(1)
 	}
+	options := ctrl.Options{
+		Scheme:                        scheme,
+		MetricsBindAddress:            metricsAddr,
+		Port:                          9443,
+		LeaderElection:                leaderElect,
+		LeaderElectionID:              leaderElectionID,
+		LeaderElectionNamespace:       operatorNs,
+		LeaderElectionResourceLock:    resourcelock.LeasesResourceLock,
+		LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:                 &leaseDuration,
+		RenewDeadline:                 &renewDeadline,
+		RetryPeriod:                   &retryPeriod,
+	}
+	switch {
+	case len(namespaces) == 1:
+		options.Namespace = namespaces[0]
+	case len(namespaces) > 1:
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	if len(namespaces) == 0 {
+		log.Info("Watching all namespaces")
+	} else {
+		log.Info("Watching namespaces", "namespaces", namespaces)
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		Namespace:          namespaces,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-	})
+	mgr, err := ctrl.NewManager(cfg, options)
 	if err != nil {
 		log.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if leaderElect && legacyLeaderLock {
+		// Runs once elected, stopping the operator if one of a previous version started in the meantime
+		err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return util.ClaimLegacyLeaderLock(ctx, lockClient, operatorNs, leaderElectionID)
+		}))
+		if err != nil {
+			log.Error(err, "unable to claim the legacy leader lock")
+			os.Exit(1)
+		}
+	}
+
+	if controllerconfig.MetadataToolImage == "" {
+		controllerconfig.MetadataToolImage = getOperatorImage(mgr.GetAPIReader(), operatorNs)
+	}
+
 	log.Info("Registering Components")
 
 	if err = (&controllers.PravegaClusterReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: maxConcurrent,
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "PravegaCluster")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.PravegaScopeReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "PravegaCluster")
+		log.Error(err, "unable to create controller", "controller", "PravegaScope")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.PravegaStreamReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "PravegaStream")
 		os.Exit(1)
 	}
 
-	v1beta1.Mgr = mgr
+	if err = (&controllers.PravegaUserReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "PravegaUser")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.PravegaMetadataBackupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "PravegaMetadataBackup")
+		os.Exit(1)
+	}
 
 	if webhookFlag {
 		if err = (&v1beta1.PravegaCluster{}).SetupWebhookWithManager(mgr); err != nil {
@@ -161,6 +287,70 @@ func main() {
 	}
 }
 
+// runMetadataTool backs up or restores the ZooKeeper metadata of a Pravega cluster, as run
+// by the jobs of PravegaMetadataBackup
+func runMetadataTool() error {
+	if zookeeperUri == "" || clusterName == "" {
+		return errors.New("-zookeeper-uri and -cluster-name are required")
+	}
+	zkConfig := util.ParseZookeeperUri(zookeeperUri)
+	if err := zkConfig.LoadSecretFiles(zookeeperTLSDir, zookeeperAuth); err != nil {
+		return err
+	}
+	if metadataBackup != "" {
+		if err := os.MkdirAll(filepath.Dir(metadataBackup), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(metadataBackup)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		count, err := util.BackupClusterMetadata(zkConfig, clusterName, f)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("backed up %d znodes of pravega cluster %s to %s", count, clusterName, metadataBackup)
+		return f.Sync()
+	}
+	f, err := os.Open(metadataRestore)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	count, err := util.RestoreClusterMetadata(zkConfig, clusterName, f)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("restored %d znodes of pravega cluster %s from %s", count, clusterName, metadataRestore)
+	return nil
+}
+
+// getOperatorImage returns the image of the operator pod, falling back to the
+// released image of this version
+func getOperatorImage(reader client.Reader, operatorNs string) string {
+	image := "pravega/pravega-operator:" + version.Version
+	podName := os.Getenv("POD_NAME")
+	if podName == "" || operatorNs == "" {
+		return image
+	}
+	pod := &corev1.Pod{}
+	err := reader.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: operatorNs}, pod)
+	if err != nil {
+		log.Error(err, "failed to get the operator pod, using the default metadata tool image", "image", image)
+		return image
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "pravega-operator" {
+			return container.Image
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return image
+}
+
 func GetOperatorNamespace() (string, error) {
 	nsBytes, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
 	if err != nil {
@@ -173,10 +363,14 @@ func GetOperatorNamespace() (string, error) {
 	return ns, nil
 }
 
-// getWatchNamespace returns the Namespace the operator should be watching for changes
+// watchNamespaceSelectorEnvVar is the env variable holding the optional label selector of
+// the namespaces to watch, restricted to the ones of WATCH_NAMESPACE if set
+const watchNamespaceSelectorEnvVar = "WATCH_NAMESPACE_SELECTOR"
+
+// getWatchNamespace returns the comma separated Namespaces the operator should be watching for changes
 func getWatchNamespace() (string, error) {
 	// WatchNamespaceEnvVar is the constant for env variable WATCH_NAMESPACE
-	// which specifies the Namespace to watch.
+	// which specifies the Namespaces to watch, separated by commas.
 	// An empty value means the operator is running with cluster scope.
 	var watchNamespaceEnvVar = "WATCH_NAMESPACE"
 
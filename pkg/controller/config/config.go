@@ -23,3 +23,7 @@ var TestMode bool
 // NOTE: enabling this flag with caution! It causes stale znode data in zookeeper and
 // leads to conflicts with subsequent Pravega clusters deployments.
 var DisableFinalizer bool
+
+// MetadataToolImage is the image of the operator, run by the Jobs backing up and
+// restoring the ZooKeeper metadata of Pravega clusters
+var MetadataToolImage string
@@ -14,88 +14,134 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"log"
 
-	"github.com/operator-framework/operator-lib/leader"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/rest"
+	"k8s.io/apimachinery/pkg/util/wait"
 	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// BecomeLeader with pre-check cluster status - is there a previous pod in bad state?
-func BecomeLeader(ctx context.Context, cfg *rest.Config, lockName, namespace string) error {
-	client, _ := k8sClient.New(cfg, k8sClient.Options{})
+const (
+	// LegacyLeaderLockName is the ConfigMap "leader for life" lock of the operator versions
+	// electing their leader before the Lease based election
+	LegacyLeaderLockName = "pravega-operator-lock"
 
-	err := precheckLeaderLock(ctx, client, lockName, namespace)
-	if err != nil {
-		log.Printf("Error while pre-checking leader lock: %v", err)
-	}
+	// leaseHolderAnnotationKey marks the legacy lock held on behalf of the Lease of the
+	// current operators, which they hand over to each other
+	leaseHolderAnnotationKey = "pravega.pravega.io/leader-election-lease"
+)
 
-	// pre-checks done, proceed with SDK-provided election procedure
-	err = leader.Become(ctx, lockName)
-	return err
+// WaitForLegacyLeaderLock blocks until no operator of a previous version holds the legacy
+// lock, so that both versions never run concurrently during an upgrade
+func WaitForLegacyLeaderLock(ctx context.Context, client k8sClient.Client, namespace string, interval time.Duration) error {
+	return wait.PollImmediateUntil(interval, func() (bool, error) {
+		holder, err := legacyLeaderLockHolder(ctx, client, namespace)
+		if err != nil {
+			log.Printf("Error while checking the legacy leader lock: %v", err)
+			return false, nil
+		}
+		if holder != "" {
+			log.Printf("Legacy leader lock %s is held by pod %s of a previous operator version, waiting", LegacyLeaderLockName, holder)
+			return false, nil
+		}
+		return true, nil
+	}, ctx.Done())
 }
 
-func precheckLeaderLock(ctx context.Context, client k8sClient.Client, lockName, ns string) error {
-	existingConfigMap, e := getConfigMapWithLock(ctx, client, lockName, ns)
-	if existingConfigMap == nil || e != nil {
-		return e
+// ClaimLegacyLeaderLock makes the current pod the owner of the legacy lock, so that an
+// operator of a previous version does not start while this one is the leader. It fails
+// if an operator of a previous version took the lock in the meantime
+func ClaimLegacyLeaderLock(ctx context.Context, client k8sClient.Client, namespace string, leaseName string) error {
+	podName := os.Getenv("POD_NAME")
+	if podName == "" {
+		return fmt.Errorf("required env POD_NAME not set")
 	}
+	pod := &corev1.Pod{}
+	if err := client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: podName}, pod); err != nil {
+		return fmt.Errorf("failed to get the operator pod %s: %v", podName, err)
+	}
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: pod.Name, UID: pod.UID}
 
-	currentPod := os.Getenv("POD_NAME")
-	if currentPod == "" {
-		return fmt.Errorf("required env POD_NAME not set")
+	lock, err := getConfigMapWithLock(ctx, client, LegacyLeaderLockName, namespace)
+	if err != nil {
+		return err
 	}
+	if lock == nil {
+		lock = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            LegacyLeaderLockName,
+				Namespace:       namespace,
+				Annotations:     map[string]string{leaseHolderAnnotationKey: leaseName},
+				OwnerReferences: []metav1.OwnerReference{owner},
+			},
+		}
+		return client.Create(ctx, lock)
+	}
+	holder, err := legacyLeaderLockHolder(ctx, client, namespace)
+	if err != nil {
+		return err
+	}
+	if holder != "" {
+		return fmt.Errorf("legacy leader lock %s is held by pod %s of a previous operator version", LegacyLeaderLockName, holder)
+	}
+	if lock.Annotations == nil {
+		lock.Annotations = map[string]string{}
+	}
+	lock.Annotations[leaseHolderAnnotationKey] = leaseName
+	// The lock is garbage collected with its owner
+	lock.OwnerReferences = []metav1.OwnerReference{owner}
+	return client.Update(ctx, lock)
+}
 
-	log.Printf("Current pod name: %s", currentPod)
+// legacyLeaderLockHolder returns the name of the running pod of a previous operator version
+// holding the legacy lock, if any
+func legacyLeaderLockHolder(ctx context.Context, client k8sClient.Client, ns string) (string, error) {
+	lock, err := getConfigMapWithLock(ctx, client, LegacyLeaderLockName, ns)
+	if lock == nil || err != nil {
+		return "", err
+	}
+	if lock.Annotations[leaseHolderAnnotationKey] != "" {
+		return "", nil
+	}
 
-	for _, lockOwner := range existingConfigMap.GetOwnerReferences() {
+	currentPod := os.Getenv("POD_NAME")
+	for _, lockOwner := range lock.GetOwnerReferences() {
+		if lockOwner.Kind != "Pod" {
+			log.Printf("Existing lock references non-pod object! Kind: %s", lockOwner.Kind)
+			continue
+		}
 		if lockOwner.Name == currentPod {
-			log.Printf("Leader lock is owned by current pod - am I restarted?")
-			return nil
+			continue
+		}
+		running, err := isLeaderPodRunning(ctx, client, lockOwner.Name, ns)
+		if err != nil {
+			return "", err
 		}
-		log.Printf("Leader lock owner is %s %s", lockOwner.Kind, lockOwner.Name)
-		e := checkupLeaderPodStatus(ctx, client, lockOwner, existingConfigMap, ns)
-		if e != nil {
-			return e
+		if running {
+			return lockOwner.Name, nil
 		}
 	}
-
-	return nil
+	return "", nil
 }
 
-// checkupLeaderPodStatus checks if leader pod status is marked with VMware-specific reason 'ProviderFailed'
-// then deletes lock and pod
-func checkupLeaderPodStatus(ctx context.Context, client k8sClient.Client, leaderRef metav1.OwnerReference, existingLock *corev1.ConfigMap, ns string) error {
-	if leaderRef.Kind != "Pod" {
-		log.Printf("Existing lock references non-pod object! Kind: %s", leaderRef.Kind)
-		return nil
-	}
-
+// isLeaderPodRunning returns whether the pod owning the legacy lock exists and did not fail.
+// The lock of a failed pod, e.g. evicted, would only be released once the pod gets deleted
+func isLeaderPodRunning(ctx context.Context, client k8sClient.Client, name string, ns string) (bool, error) {
 	leaderPod := &corev1.Pod{}
-	err := client.Get(ctx, k8sClient.ObjectKey{Namespace: ns, Name: leaderRef.Name}, leaderPod)
+	err := client.Get(ctx, k8sClient.ObjectKey{Namespace: ns, Name: name}, leaderPod)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			log.Printf("Leader pod %s not found in namespace %s", leaderRef.Name, ns)
-			return nil
+			log.Printf("Leader pod %s not found in namespace %s", name, ns)
+			return false, nil
 		}
-		log.Printf("Error while reading leader pod: %v", err)
-		return err
+		return false, err
 	}
-
 	log.Printf("Leader pod is in %s:%s status", leaderPod.Status.Phase, leaderPod.Status.Reason)
-
-	if leaderPod.Status.Reason == "ProviderFailed" {
-		log.Printf("Leader pod status reason is '%s' - deleting pod and lock config map to unblock leader election", leaderPod.Status.Reason)
-		if err := deleteLeader(ctx, client, leaderPod, existingLock); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return leaderPod.Status.Phase != corev1.PodFailed, nil
 }
 
 func getConfigMapWithLock(ctx context.Context, client k8sClient.Client, lockName, ns string) (*corev1.ConfigMap, error) {
@@ -103,7 +149,6 @@ func getConfigMapWithLock(ctx context.Context, client k8sClient.Client, lockName
 	e := client.Get(ctx, k8sClient.ObjectKey{Namespace: ns, Name: lockName}, existingConfigMap)
 	if e != nil {
 		if apierrors.IsNotFound(e) {
-			log.Printf("Leader lock %s not found in namespace %s", lockName, ns)
 			return nil, nil
 		}
 		log.Printf("Unknown error trying to get lock config map: %v", e)
@@ -111,23 +156,3 @@ func getConfigMapWithLock(ctx context.Context, client k8sClient.Client, lockName
 	}
 	return existingConfigMap, nil
 }
-
-// deleteLeader tries to delete pod and config map
-func deleteLeader(ctx context.Context, client k8sClient.Client, leaderPod *corev1.Pod, configMapWithLock *corev1.ConfigMap) error {
-	err := client.Delete(ctx, leaderPod)
-	if err != nil {
-		log.Printf("Error deleting leader pod %s: %v", leaderPod.Name, err)
-		return err
-	}
-
-	err = client.Delete(ctx, configMapWithLock)
-	switch {
-	case apierrors.IsNotFound(err):
-		log.Printf("Config map has already been deleted")
-		return nil
-	case err != nil:
-		return err
-	}
-
-	return nil
-}
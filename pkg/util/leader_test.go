@@ -13,11 +13,11 @@ package util
 import (
 	"context"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientscheme "k8s.io/client-go/kubernetes/scheme"
@@ -26,22 +26,44 @@ import (
 )
 
 const (
-	configmapName  = "test-map"
 	namespace      = "ns-1"
 	currentPodName = "current-pod"
 	otherPodName   = "some-other-pod"
 )
 
 var _ = Describe("Leader election utils", func() {
-	Context("Election prechecks", func() {
+	Context("Legacy leader lock", func() {
 		var (
-			client        k8sClient.Client
-			err           error
-			ctx           context.Context
-			lockConfigMap *corev1.ConfigMap
-			currentPod    *corev1.Pod
-			otherPod      *corev1.Pod
+			client     k8sClient.Client
+			err        error
+			ctx        context.Context
+			currentPod *corev1.Pod
+			otherPod   *corev1.Pod
 		)
+
+		lockOwnedBy := func(podName string, annotations map[string]string) *corev1.ConfigMap {
+			return &corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        LegacyLeaderLockName,
+					Namespace:   namespace,
+					Annotations: annotations,
+					OwnerReferences: []metav1.OwnerReference{
+						{Name: podName, Kind: "Pod"},
+					},
+				},
+			}
+		}
+
+		getLock := func() *corev1.ConfigMap {
+			cm := &corev1.ConfigMap{}
+			Expect(client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: LegacyLeaderLockName}, cm)).Should(Succeed())
+			return cm
+		}
+
 		BeforeEach(func() {
 			currentPod = &corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
@@ -61,84 +83,70 @@ var _ = Describe("Leader election utils", func() {
 			ctx = context.TODO()
 		})
 
-		When("leader lock owned by current pod", func() {
+		When("there is no lock", func() {
 			BeforeEach(func() {
-				lockConfigMap = &corev1.ConfigMap{
-					TypeMeta: metav1.TypeMeta{
-						Kind:       "ConfigMap",
-						APIVersion: "v1",
-					},
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      configmapName,
-						Namespace: namespace,
-						OwnerReferences: []metav1.OwnerReference{
-							{Name: currentPodName, Kind: "Pod"},
-						},
-					},
-				}
-
-				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod, lockConfigMap}...)
-
-				err = precheckLeaderLock(ctx, client, configmapName, namespace)
+				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod}...)
 			})
-			It(" must do nothing", func() {
-				Expect(err).ShouldNot(HaveOccurred())
+			It("must not wait and create the lock on claim", func() {
+				Expect(WaitForLegacyLeaderLock(ctx, client, namespace, time.Millisecond)).Should(Succeed())
+				Expect(ClaimLegacyLeaderLock(ctx, client, namespace, "pravega-operator-leader")).Should(Succeed())
+
+				cm := getLock()
+				Expect(cm.Annotations[leaseHolderAnnotationKey]).To(Equal("pravega-operator-leader"))
+				Expect(cm.OwnerReferences).To(HaveLen(1))
+				Expect(cm.OwnerReferences[0].Name).To(Equal(currentPodName))
+				Expect(cm.OwnerReferences[0].UID).To(BeEquivalentTo("Uid-" + currentPodName))
 			})
 		})
 
-		When("leader lock owned by other pod", func() {
+		When("the lock is owned by a running operator of a previous version", func() {
 			BeforeEach(func() {
-				lockConfigMap = &corev1.ConfigMap{
-					TypeMeta: metav1.TypeMeta{
-						Kind:       "ConfigMap",
-						APIVersion: "v1",
-					},
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      configmapName,
-						Namespace: namespace,
-						OwnerReferences: []metav1.OwnerReference{
-							{Name: otherPodName, Kind: "Pod"},
-						},
-					},
-				}
-				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod, lockConfigMap}...)
-				err = precheckLeaderLock(ctx, client, configmapName, namespace)
+				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod, lockOwnedBy(otherPodName, nil)}...)
 			})
-
-			Context("when that node is Ready", func() {
-				It(" must do nothing", func() {
-					Expect(err).ShouldNot(HaveOccurred())
-
-					pod := &corev1.Pod{}
-					err = client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: otherPodName}, pod)
-					Expect(err).Should(BeNil())
-
-					cm := &corev1.ConfigMap{}
-					err = client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: configmapName}, cm)
-					Expect(err).Should(BeNil())
-				})
+			It("must wait for the lock", func() {
+				ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				defer cancel()
+				err = WaitForLegacyLeaderLock(ctx, client, namespace, 10*time.Millisecond)
+				Expect(err).Should(HaveOccurred())
 			})
+			It("must not claim the lock", func() {
+				err = ClaimLegacyLeaderLock(ctx, client, namespace, "pravega-operator-leader")
+				Expect(err).Should(HaveOccurred())
+				Expect(getLock().OwnerReferences[0].Name).To(Equal(otherPodName))
+			})
+		})
 
-			Context("when that node is in ProviderFailed state", func() {
-				BeforeEach(func() {
-					otherPod.Status.Reason = "ProviderFailed"
-					_ = client.Update(ctx, otherPod)
-
-					err = precheckLeaderLock(ctx, client, configmapName, namespace)
-				})
-				It(" must delete otherPod and config map", func() {
-					Expect(err).ShouldNot(HaveOccurred())
+		When("the lock is owned by a failed operator of a previous version", func() {
+			BeforeEach(func() {
+				otherPod.Status.Phase = corev1.PodFailed
+				otherPod.Status.Reason = "ProviderFailed"
+				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod, lockOwnedBy(otherPodName, nil)}...)
+			})
+			It("must take over the lock", func() {
+				Expect(WaitForLegacyLeaderLock(ctx, client, namespace, time.Millisecond)).Should(Succeed())
+				Expect(ClaimLegacyLeaderLock(ctx, client, namespace, "pravega-operator-leader")).Should(Succeed())
+				Expect(getLock().OwnerReferences[0].Name).To(Equal(currentPodName))
+			})
+		})
 
-					pod := &corev1.Pod{}
-					err = client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: otherPodName}, pod)
-					Expect(err).ShouldNot(BeNil())
-					Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		When("the lock is owned by a deleted pod", func() {
+			BeforeEach(func() {
+				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, lockOwnedBy(otherPodName, nil)}...)
+			})
+			It("must not wait", func() {
+				Expect(WaitForLegacyLeaderLock(ctx, client, namespace, time.Millisecond)).Should(Succeed())
+			})
+		})
 
-					cm := &corev1.ConfigMap{}
-					err = client.Get(ctx, k8sClient.ObjectKey{Namespace: namespace, Name: configmapName}, cm)
-					Expect(err).ShouldNot(BeNil())
-					Expect(apierrors.IsNotFound(err)).To(BeTrue())
-				})
+		When("the lock is held for the lease by the previous leader", func() {
+			BeforeEach(func() {
+				lock := lockOwnedBy(otherPodName, map[string]string{leaseHolderAnnotationKey: "pravega-operator-leader"})
+				client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{currentPod, otherPod, lock}...)
+			})
+			It("must hand over the lock", func() {
+				Expect(WaitForLegacyLeaderLock(ctx, client, namespace, time.Millisecond)).Should(Succeed())
+				Expect(ClaimLegacyLeaderLock(ctx, client, namespace, "pravega-operator-leader")).Should(Succeed())
+				Expect(getLock().OwnerReferences[0].Name).To(Equal(currentPodName))
 			})
 		})
 	})
@@ -0,0 +1,70 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ParseWatchNamespaces returns the sorted namespaces of a comma separated list, or nil
+// when the list is empty, meaning all the namespaces
+func ParseWatchNamespaces(value string) []string {
+	set := map[string]bool{}
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			set[ns] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	namespaces := make([]string, 0, len(set))
+	for ns := range set {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// SelectWatchNamespaces returns the namespaces matching the label selector, restricted to
+// the given namespaces if any. It fails when no namespace matches, so that the operator
+// does not fall back to watching all the namespaces
+func SelectWatchNamespaces(ctx context.Context, reader k8sClient.Reader, selector string, namespaces []string) ([]string, error) {
+	if selector == "" {
+		return namespaces, nil
+	}
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector %q: %v", selector, err)
+	}
+	list := &corev1.NamespaceList{}
+	if err = reader.List(ctx, list, &k8sClient.ListOptions{LabelSelector: s}); err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces matching %q: %v", selector, err)
+	}
+	var selected []string
+	for _, ns := range list.Items {
+		if len(namespaces) == 0 || ContainsString(namespaces, ns.Name) {
+			selected = append(selected, ns.Name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no namespace matches the selector %q", selector)
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
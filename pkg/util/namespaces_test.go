@@ -0,0 +1,77 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientscheme "k8s.io/client-go/kubernetes/scheme"
+	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Watch namespaces", func() {
+	Context("ParseWatchNamespaces", func() {
+		It("should watch all the namespaces when empty", func() {
+			Ω(ParseWatchNamespaces("")).To(BeNil())
+			Ω(ParseWatchNamespaces(" , ")).To(BeNil())
+		})
+
+		It("should split, trim and deduplicate the list", func() {
+			Ω(ParseWatchNamespaces("tenant-b, tenant-a,tenant-b,")).To(Equal([]string{"tenant-a", "tenant-b"}))
+		})
+	})
+
+	Context("SelectWatchNamespaces", func() {
+		var client k8sClient.Client
+
+		namespace := func(name string, tenant bool) *corev1.Namespace {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			if tenant {
+				ns.Labels = map[string]string{"pravega.io/tenant": "true"}
+			}
+			return ns
+		}
+
+		BeforeEach(func() {
+			client = fake.NewFakeClientWithScheme(clientscheme.Scheme, []runtime.Object{
+				namespace("tenant-a", true), namespace("tenant-b", true), namespace("default", false),
+			}...)
+		})
+
+		It("should keep the list without selector", func() {
+			Ω(SelectWatchNamespaces(context.TODO(), client, "", []string{"default"})).To(Equal([]string{"default"}))
+		})
+
+		It("should select the labeled namespaces", func() {
+			Ω(SelectWatchNamespaces(context.TODO(), client, "pravega.io/tenant=true", nil)).To(Equal([]string{"tenant-a", "tenant-b"}))
+		})
+
+		It("should restrict the selected namespaces to the list", func() {
+			Ω(SelectWatchNamespaces(context.TODO(), client, "pravega.io/tenant=true", []string{"tenant-b", "default"})).To(Equal([]string{"tenant-b"}))
+		})
+
+		It("should fail when no namespace matches", func() {
+			_, err := SelectWatchNamespaces(context.TODO(), client, "pravega.io/tenant=false", nil)
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should reject an invalid selector", func() {
+			_, err := SelectWatchNamespaces(context.TODO(), client, "pravega.io/tenant in", nil)
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+})
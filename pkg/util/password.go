@@ -0,0 +1,118 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The password hashing parameters of the Pravega PasswordAuthHandler (StrongPasswordProcessor).
+// The hashes of the Pravega PasswordCreatorTool, such as the one of the admin user in
+// doc/auth.md, are derived with PBKDF2 and HMAC-SHA256 rather than HMAC-SHA1
+const (
+	passwordHashIterations = 5000
+	passwordSaltLength     = 32
+	passwordKeyLength      = 64
+)
+
+// PasswordFileEntry is a line of the password file of the PasswordAuthHandler,
+// in the format "<user>:<password hash>:<acl>"
+type PasswordFileEntry struct {
+	Username string
+	Hash     string
+	ACL      string
+}
+
+func (e PasswordFileEntry) String() string {
+	return e.Username + ":" + e.Hash + ":" + e.ACL
+}
+
+// HashPravegaPassword hashes the password with a random salt the way the Pravega
+// PasswordCreatorTool does
+func HashPravegaPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hashPravegaPassword(password, salt, passwordHashIterations, passwordKeyLength), nil
+}
+
+// hashPravegaPassword returns the hex encoding of "<iterations>:<hex salt>:<hex key>", the
+// format of the hashes of the password file
+func hashPravegaPassword(password string, salt []byte, iterations int, keyLength int) string {
+	key := pbkdf2.Key([]byte(password), salt, iterations, keyLength, sha256.New)
+	encoded := fmt.Sprintf("%d:%s:%s", iterations, hex.EncodeToString(salt), hex.EncodeToString(key))
+	return hex.EncodeToString([]byte(encoded))
+}
+
+// VerifyPravegaPassword returns whether the hash, as stored in the password file,
+// is the hash of the password
+func VerifyPravegaPassword(hash string, password string) bool {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	fields := strings.Split(string(decoded), ":")
+	if len(fields) != 3 {
+		return false
+	}
+	iterations, err := strconv.Atoi(fields[0])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return false
+	}
+	expected := hashPravegaPassword(password, salt, iterations, len(fields[2])/2)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1
+}
+
+// ParsePasswordFile returns the entries of a password file. Blank lines and
+// comments are skipped
+func ParsePasswordFile(data string) []PasswordFileEntry {
+	var entries []PasswordFileEntry
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the ACL may contain colons, so only the first two separate fields
+		fields := strings.SplitN(line, ":", 3)
+		entry := PasswordFileEntry{Username: fields[0]}
+		if len(fields) > 1 {
+			entry.Hash = fields[1]
+		}
+		if len(fields) > 2 {
+			entry.ACL = fields[2]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// FormatPasswordFile returns the content of a password file with the given entries
+func FormatPasswordFile(entries []PasswordFileEntry) string {
+	var sb strings.Builder
+	for _, entry := range entries {
+		sb.WriteString(entry.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package util
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var _ = Describe("password", func() {
+
+	// the hash of "1111_aaaa" generated by the Pravega PasswordCreatorTool, from the Pravega documentation
+	const toolHash = "353030303a633132666135376233353937356534613430383430373939343839333733616463363433616532363238653930346230333035393666643961316264616661393a3639376330623663396634343864643262663335326463653062613965336439613864306264323839633037626166663563613166333733653631383732353134643961303435613237653130353633633031653364366565316434626534656565636335663666306465663064376165313765646263656638373764396361"
+
+	Context("VerifyPravegaPassword", func() {
+		It("should verify hashes of the Pravega tool", func() {
+			Ω(VerifyPravegaPassword(toolHash, "1111_aaaa")).To(BeTrue())
+			Ω(VerifyPravegaPassword(toolHash, "1111_aaab")).To(BeFalse())
+		})
+		It("should hash the passwords as the Pravega tool does", func() {
+			salt, _ := hex.DecodeString("c12fa57b35975e4a40840799489373adc643ae2628e904b030596fd9a1bdafa9")
+			Ω(salt).To(HaveLen(passwordSaltLength))
+			Ω(hashPravegaPassword("1111_aaaa", salt, passwordHashIterations, passwordKeyLength)).To(Equal(toolHash))
+		})
+		It("should not verify the hashes derived with HMAC-SHA1", func() {
+			salt, _ := hex.DecodeString("c12fa57b35975e4a40840799489373adc643ae2628e904b030596fd9a1bdafa9")
+			key := pbkdf2.Key([]byte("1111_aaaa"), salt, passwordHashIterations, passwordKeyLength, sha1.New)
+			sha1Hash := hex.EncodeToString([]byte(fmt.Sprintf("%d:%x:%x", passwordHashIterations, salt, key)))
+			Ω(sha1Hash).NotTo(Equal(toolHash))
+			Ω(VerifyPravegaPassword(sha1Hash, "1111_aaaa")).To(BeFalse())
+		})
+		It("should reject malformed hashes", func() {
+			Ω(VerifyPravegaPassword("not-hex", "1111_aaaa")).To(BeFalse())
+			Ω(VerifyPravegaPassword("3130", "1111_aaaa")).To(BeFalse())
+		})
+	})
+
+	Context("HashPravegaPassword", func() {
+		It("should salt each hash", func() {
+			h1, err := HashPravegaPassword("secret")
+			Ω(err).Should(BeNil())
+			h2, _ := HashPravegaPassword("secret")
+			Ω(h1).NotTo(Equal(h2))
+			Ω(VerifyPravegaPassword(h1, "secret")).To(BeTrue())
+			Ω(VerifyPravegaPassword(h2, "secret")).To(BeTrue())
+		})
+	})
+
+	Context("ParsePasswordFile", func() {
+		It("should keep the colons of the ACL", func() {
+			entries := ParsePasswordFile("# users\nadmin:abc:prn::*,READ_UPDATE;\n\nreader:def:prn::/scope:s1,READ;\n")
+			Ω(entries).To(HaveLen(2))
+			Ω(entries[0]).To(Equal(PasswordFileEntry{Username: "admin", Hash: "abc", ACL: "prn::*,READ_UPDATE;"}))
+			Ω(entries[1].ACL).To(Equal("prn::/scope:s1,READ;"))
+			Ω(FormatPasswordFile(entries)).To(Equal("admin:abc:prn::*,READ_UPDATE;\nreader:def:prn::/scope:s1,READ;\n"))
+		})
+	})
+})
@@ -12,6 +12,7 @@ package util
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -20,6 +21,7 @@ import (
 	v "github.com/hashicorp/go-version"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +32,7 @@ var (
 const (
 	healthcheckVersion      string = "0.10.0"
 	MajorMinorVersionRegexp string = `^v?(?P<Version>[0-9]+\.[0-9]+\.[0-9]+)`
+	ZoneTopologyKey         string = "topology.kubernetes.io/zone"
 )
 
 func init() {
@@ -219,7 +222,7 @@ func UpdateOneJVMOption(arg string, om *OrderedMap) {
 	}
 
 	// Parse option starting with "-XX"
-	if strings.HasPrefix(arg, "-XX:") {
+	if strings.HasPrefix(arg, "-XX:") && len(arg) > 5 {
 		if arg[4] == '+' || arg[4] == '-' {
 			if _, ok := om.m[arg[5:]]; !ok {
 				om.keys = append(om.keys, arg[5:])
@@ -227,12 +230,13 @@ func UpdateOneJVMOption(arg string, om *OrderedMap) {
 			om.m[arg[5:]] = string(arg[4])
 			return
 		}
-		s := strings.Split(arg[4:], "=")
-		if _, ok := om.m[s[0]]; !ok {
-			om.keys = append(om.keys, s[0])
+		if name, value, found := strings.Cut(arg[4:], "="); found && name != "" && value != "" {
+			if _, ok := om.m[name]; !ok {
+				om.keys = append(om.keys, name)
+			}
+			om.m[name] = value
+			return
 		}
-		om.m[s[0]] = s[1]
-		return
 	}
 
 	// Not in those formats, just keep the option as a key
@@ -243,6 +247,19 @@ func UpdateOneJVMOption(arg string, om *OrderedMap) {
 	return
 }
 
+// IsValidJVMOption returns whether an option starting with "-XX:" is either "-XX:+Name",
+// "-XX:-Name" or "-XX:Name=value". The other options are not checked
+func IsValidJVMOption(arg string) bool {
+	if !strings.HasPrefix(arg, "-XX:") {
+		return true
+	}
+	if len(arg) > 5 && (arg[4] == '+' || arg[4] == '-') {
+		return true
+	}
+	name, value, found := strings.Cut(arg[4:], "=")
+	return found && name != "" && value != ""
+}
+
 // Concatenate the key value pair to be a JVM option string.
 func GenerateJVMOption(k, v string) string {
 	if v == "" {
@@ -292,6 +309,38 @@ func OverrideDefaultJVMOptions(defaultOpts []string, customOpts []string) []stri
 	return jvmOpts
 }
 
+var memorySizeExponentRegexp = regexp.MustCompile(`[eE][+-]?\d{3,}$`)
+
+// ParseMemorySize parses a memory size given in bytes, with a JVM suffix (k, m, g or t in
+// either case, as binary multiples) or as a Kubernetes quantity, e.g. 1073741824, 1g, 1024M or 1Gi
+func ParseMemorySize(size string) (int64, error) {
+	s := strings.TrimSpace(size)
+	if s == "" {
+		return 0, fmt.Errorf("memory size is empty")
+	}
+	multipliers := map[string]int64{"k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}
+	if multiplier, ok := multipliers[strings.ToLower(s[len(s)-1:])]; ok {
+		if n, err := strconv.ParseInt(s[:len(s)-1], 10, 64); err == nil && n >= 0 {
+			if n > math.MaxInt64/multiplier {
+				return 0, fmt.Errorf("memory size %q is too large", size)
+			}
+			return n * multiplier, nil
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		return n, nil
+	}
+	// Parsing a quantity with a large decimal exponent takes minutes
+	if memorySizeExponentRegexp.MatchString(s) {
+		return 0, fmt.Errorf("invalid memory size %q", size)
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil || q.Sign() < 0 {
+		return 0, fmt.Errorf("invalid memory size %q", size)
+	}
+	return q.Value(), nil
+}
+
 func IsPodReady(pod *corev1.Pod) bool {
 	for _, condition := range pod.Status.Conditions {
 		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
@@ -366,3 +415,29 @@ func PodAntiAffinity(component string, clusterName string) *corev1.Affinity {
 		},
 	}
 }
+
+// ZoneTopologySpreadConstraint returns a constraint that spreads the pods of the
+// given component evenly across availability zones
+func ZoneTopologySpreadConstraint(component string, clusterName string) corev1.TopologySpreadConstraint {
+	return corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       ZoneTopologyKey,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"component":       component,
+				"pravega_cluster": clusterName,
+			},
+		},
+	}
+}
+
+// HasTopologySpreadConstraint checks whether a constraint on the given topology key is present
+func HasTopologySpreadConstraint(constraints []corev1.TopologySpreadConstraint, topologyKey string) bool {
+	for _, constraint := range constraints {
+		if constraint.TopologyKey == topologyKey {
+			return true
+		}
+	}
+	return false
+}
@@ -118,6 +118,53 @@ var _ = Describe("pravegacluster", func() {
 		})
 	})
 
+	Context("Malformed JVM options", func() {
+		It("should be kept as they are without panicking", func() {
+			var result []string
+			Ω(func() {
+				result = OverrideDefaultJVMOptions([]string{"-XX:MaxDirectMemorySize=1g"}, []string{"-XX:", "-XX:+", "-XX:MaxRAMPercentage", "-XX:=1"})
+			}).ShouldNot(Panic())
+			Ω(result).Should(Equal([]string{"-XX:MaxDirectMemorySize=1g", "-XX:", "-XX:+", "-XX:MaxRAMPercentage", "-XX:=1"}))
+		})
+
+		It("should be reported as invalid", func() {
+			for _, option := range []string{"-XX:", "-XX:+", "-XX:MaxRAMPercentage", "-XX:=1", "-XX:MaxRAMPercentage="} {
+				Ω(IsValidJVMOption(option)).Should(BeFalse(), option)
+			}
+			for _, option := range []string{"-Xmx1g", "-XX:+UseG1GC", "-XX:-UseG1GC", "-XX:MaxRAMPercentage=50.0", "-Dfoo"} {
+				Ω(IsValidJVMOption(option)).Should(BeTrue(), option)
+			}
+		})
+	})
+
+	Context("ParseMemorySize", func() {
+		It("should parse the JVM suffixes in either case", func() {
+			for size, expected := range map[string]int64{
+				"1073741824": 1073741824,
+				"4k":         4096,
+				"4K":         4096,
+				"512m":       512 << 20,
+				"512M":       512 << 20,
+				"2g":         2 << 30,
+				"2G":         2 << 30,
+				"1t":         1 << 40,
+				"2Gi":        2 << 30,
+				"1500Mi":     1500 << 20,
+			} {
+				value, err := ParseMemorySize(size)
+				Ω(err).ShouldNot(HaveOccurred(), size)
+				Ω(value).Should(Equal(expected), size)
+			}
+		})
+
+		It("should reject invalid sizes without panicking", func() {
+			for _, size := range []string{"", "g", "1.5.g", "-1g", "ten", "1x", "1e-99999999", "1E999"} {
+				_, err := ParseMemorySize(size)
+				Ω(err).Should(HaveOccurred(), size)
+			}
+		})
+	})
+
 	Context("RemoveString", func() {
 		var opts []string
 		BeforeEach(func() {
@@ -159,6 +206,16 @@ var _ = Describe("pravegacluster", func() {
 		})
 	})
 
+	Context("ZoneTopologySpreadConstraint", func() {
+		constraint := ZoneTopologySpreadConstraint("segstore", "pravega")
+		It("should spread across zones", func() {
+			Ω(constraint.TopologyKey).To(Equal(ZoneTopologyKey))
+			Ω(constraint.LabelSelector.MatchLabels["component"]).To(Equal("segstore"))
+			Ω(HasTopologySpreadConstraint([]corev1.TopologySpreadConstraint{constraint}, ZoneTopologyKey)).To(BeTrue())
+			Ω(HasTopologySpreadConstraint(nil, ZoneTopologyKey)).To(BeFalse())
+		})
+	})
+
 	Context("DownwardAPIEnv()", func() {
 		env := DownwardAPIEnv()
 		It("should not be nil", func() {
@@ -342,3 +399,17 @@ var _ = Describe("pravegacluster", func() {
 		})
 	})
 })
+
+// FuzzParseMemorySize checks that the memory sizes never panic nor overflow. Run with:
+// go test ./pkg/util -run '^$' -fuzz FuzzParseMemorySize
+func FuzzParseMemorySize(f *testing.F) {
+	for _, seed := range []string{"1073741824", "1g", "1024M", "1Gi", "2621440k", "-1g", "9223372036854775807k", "1e3", "1e-99999999", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, size string) {
+		value, err := ParseMemorySize(size)
+		if err == nil && value < 0 {
+			t.Errorf("negative memory size %d parsed from %q", value, size)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// ZnodeArchiveVersion is the version of the format of the metadata archives
+const ZnodeArchiveVersion = 1
+
+// ZnodeStore is the subset of the ZooKeeper client used to back up and restore znodes
+type ZnodeStore interface {
+	Children(path string) ([]string, *zk.Stat, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	GetACL(path string) ([]zk.ACL, *zk.Stat, error)
+	Exists(path string) (bool, *zk.Stat, error)
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	SetACL(path string, acl []zk.ACL, version int32) (*zk.Stat, error)
+}
+
+// ZnodeArchive is a portable copy of the metadata of a Pravega cluster. Paths are relative
+// to the root of the cluster, so that the archive can be restored under another cluster name
+type ZnodeArchive struct {
+	FormatVersion int    `json:"formatVersion"`
+	ClusterName   string `json:"clusterName"`
+	CreatedTime   string `json:"createdTime"`
+	// Znodes are listed parents first, the root of the cluster having an empty path
+	Znodes []ArchivedZnode `json:"znodes"`
+}
+
+// ArchivedZnode is the data, ACL and versions of a znode
+type ArchivedZnode struct {
+	Path string `json:"path"`
+	// Data is base64 encoded in the archive
+	Data     []byte   `json:"data,omitempty"`
+	ACL      []zk.ACL `json:"acl"`
+	Version  int32    `json:"version"`
+	CVersion int32    `json:"cversion"`
+	AVersion int32    `json:"aversion"`
+}
+
+func clusterZnodeRoot(clusterName string) string {
+	return fmt.Sprintf("/%s/%s", PravegaPath, clusterName)
+}
+
+// BackupZnodes copies the znodes of the cluster, as listed by ListSubTreeBFS, in an archive.
+// Ephemeral znodes belong to the sessions of the running pods and are skipped
+func BackupZnodes(conn ZnodeStore, clusterName string) (*ZnodeArchive, error) {
+	root := clusterZnodeRoot(clusterName)
+	exist, _, err := conn.Exists(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if zookeeper path exists: %v", err)
+	}
+	if !exist {
+		return nil, fmt.Errorf("zookeeper path (%s) does not exist", root)
+	}
+	tree, err := ListSubTreeBFS(conn, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct BFS tree: %v", err)
+	}
+
+	archive := &ZnodeArchive{
+		FormatVersion: ZnodeArchiveVersion,
+		ClusterName:   clusterName,
+		CreatedTime:   time.Now().UTC().Format(time.RFC3339),
+	}
+	paths := []string{root}
+	for e := tree.Front(); e != nil; e = e.Next() {
+		paths = append(paths, e.Value.(string))
+	}
+	ephemeral := map[string]bool{}
+	for _, path := range paths {
+		if parent := path[:strings.LastIndex(path, "/")]; ephemeral[parent] {
+			ephemeral[path] = true
+			continue
+		}
+		data, stat, err := conn.Get(path)
+		if err == zk.ErrNoNode {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get znode (%s): %v", path, err)
+		}
+		if stat.EphemeralOwner != 0 {
+			ephemeral[path] = true
+			continue
+		}
+		acl, _, err := conn.GetACL(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the ACL of znode (%s): %v", path, err)
+		}
+		archive.Znodes = append(archive.Znodes, ArchivedZnode{
+			Path:     strings.TrimPrefix(path, root),
+			Data:     data,
+			ACL:      acl,
+			Version:  stat.Version,
+			CVersion: stat.Cversion,
+			AVersion: stat.Aversion,
+		})
+	}
+	return archive, nil
+}
+
+// RestoreZnodes replays the archive under the root of the cluster, which must not have
+// any child. ZooKeeper assigns new versions to the created znodes. The znodes are created
+// with the open ACL and get their archived ACL once their children are created, since the
+// ACL may only grant the creation of children to the SASL identity of the Pravega pods
+func RestoreZnodes(conn ZnodeStore, archive *ZnodeArchive, clusterName string) (int, error) {
+	if archive.FormatVersion != ZnodeArchiveVersion {
+		return 0, fmt.Errorf("unsupported archive format version %d", archive.FormatVersion)
+	}
+	root := clusterZnodeRoot(clusterName)
+	exist, _, err := conn.Exists(root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if zookeeper path exists: %v", err)
+	}
+	if exist {
+		children, _, err := conn.Children(root)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list znode (%s): %v", root, err)
+		}
+		if len(children) > 0 {
+			return 0, fmt.Errorf("zookeeper path (%s) is not empty", root)
+		}
+	} else {
+		parent := "/" + PravegaPath
+		_, err = conn.Create(parent, nil, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return 0, fmt.Errorf("failed to create znode (%s): %v", parent, err)
+		}
+	}
+
+	restored := 0
+	var created []ArchivedZnode
+	for _, znode := range archive.Znodes {
+		path := root + znode.Path
+		_, err = conn.Create(path, znode.Data, 0, zk.WorldACL(zk.PermAll))
+		if err == zk.ErrNodeExists && znode.Path == "" {
+			// the empty root of the cluster
+			continue
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to create znode (%s): %v", path, err)
+		}
+		created = append(created, znode)
+		restored++
+	}
+	// The znodes are listed parents first
+	for i := len(created) - 1; i >= 0; i-- {
+		if len(created[i].ACL) == 0 {
+			continue
+		}
+		path := root + created[i].Path
+		if _, err = conn.SetACL(path, created[i].ACL, -1); err != nil {
+			return restored, fmt.Errorf("failed to set the ACL of znode (%s): %v", path, err)
+		}
+	}
+	return restored, nil
+}
+
+// BackupClusterMetadata writes the archive of the metadata of the cluster
+func BackupClusterMetadata(zkConfig *ZookeeperConfig, clusterName string, w io.Writer) (int, error) {
+	conn, err := zkConfig.Connect()
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to zookeeper: %v", err)
+	}
+	defer conn.Close()
+	archive, err := BackupZnodes(conn, clusterName)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(archive); err != nil {
+		return 0, fmt.Errorf("failed to write the archive: %v", err)
+	}
+	return len(archive.Znodes), nil
+}
+
+// RestoreClusterMetadata replays an archive written by BackupClusterMetadata into the
+// metadata of the cluster
+func RestoreClusterMetadata(zkConfig *ZookeeperConfig, clusterName string, r io.Reader) (int, error) {
+	archive := &ZnodeArchive{}
+	if err := json.NewDecoder(r).Decode(archive); err != nil {
+		return 0, fmt.Errorf("failed to read the archive: %v", err)
+	}
+	conn, err := zkConfig.Connect()
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to zookeeper: %v", err)
+	}
+	defer conn.Close()
+	return RestoreZnodes(conn, archive, clusterName)
+}
@@ -0,0 +1,252 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+type memZnode struct {
+	data []byte
+	acl  []zk.ACL
+	stat zk.Stat
+}
+
+// memZnodeStore is an in memory ZnodeStore
+type memZnodeStore map[string]*memZnode
+
+func (m memZnodeStore) Children(path string) ([]string, *zk.Stat, error) {
+	node, ok := m[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	var children []string
+	for p := range m {
+		if strings.HasPrefix(p, path+"/") && !strings.Contains(p[len(path)+1:], "/") {
+			children = append(children, p[len(path)+1:])
+		}
+	}
+	sort.Strings(children)
+	return children, &node.stat, nil
+}
+
+func (m memZnodeStore) Get(path string) ([]byte, *zk.Stat, error) {
+	node, ok := m[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return node.data, &node.stat, nil
+}
+
+func (m memZnodeStore) GetACL(path string) ([]zk.ACL, *zk.Stat, error) {
+	node, ok := m[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return node.acl, &node.stat, nil
+}
+
+func (m memZnodeStore) Exists(path string) (bool, *zk.Stat, error) {
+	node, ok := m[path]
+	if !ok {
+		return false, nil, nil
+	}
+	return true, &node.stat, nil
+}
+
+func (m memZnodeStore) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	if _, ok := m[path]; ok {
+		return "", zk.ErrNodeExists
+	}
+	if parent := path[:strings.LastIndex(path, "/")]; parent != "" {
+		if _, ok := m[parent]; !ok {
+			return "", zk.ErrNoNode
+		}
+	}
+	m[path] = &memZnode{data: data, acl: acl}
+	return path, nil
+}
+
+func (m memZnodeStore) SetACL(path string, acl []zk.ACL, version int32) (*zk.Stat, error) {
+	node, ok := m[path]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	node.acl = acl
+	return &node.stat, nil
+}
+
+func (m memZnodeStore) Delete(path string, version int32) error {
+	if _, ok := m[path]; !ok {
+		return zk.ErrNoNode
+	}
+	delete(m, path)
+	return nil
+}
+
+func (m memZnodeStore) Close() {}
+
+// aclZnodeStore enforces the ACLs of the znodes for a session authenticated as the identity
+type aclZnodeStore struct {
+	memZnodeStore
+	scheme string
+	id     string
+}
+
+func (s aclZnodeStore) allowed(path string, perm int32) bool {
+	node, ok := s.memZnodeStore[path]
+	if !ok {
+		return true
+	}
+	for _, acl := range node.acl {
+		if acl.Perms&perm == 0 {
+			continue
+		}
+		if acl.Scheme == "world" && acl.ID == "anyone" || acl.Scheme == s.scheme && acl.ID == s.id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s aclZnodeStore) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	if !s.allowed(path[:strings.LastIndex(path, "/")], zk.PermCreate) {
+		return "", zk.ErrNoAuth
+	}
+	return s.memZnodeStore.Create(path, data, flags, acl)
+}
+
+func (s aclZnodeStore) SetACL(path string, acl []zk.ACL, version int32) (*zk.Stat, error) {
+	if !s.allowed(path, zk.PermAdmin) {
+		return nil, zk.ErrNoAuth
+	}
+	return s.memZnodeStore.SetACL(path, acl, version)
+}
+
+func (s aclZnodeStore) Delete(path string, version int32) error {
+	if !s.allowed(path[:strings.LastIndex(path, "/")], zk.PermDelete) {
+		return zk.ErrNoAuth
+	}
+	return s.memZnodeStore.Delete(path, version)
+}
+
+var _ = Describe("zookeeper backup", func() {
+	var (
+		source  memZnodeStore
+		archive *ZnodeArchive
+		err     error
+		digest  = []zk.ACL{{Perms: zk.PermAll, Scheme: "digest", ID: "pravega:hash"}}
+	)
+
+	BeforeEach(func() {
+		open := zk.WorldACL(zk.PermAll)
+		source = memZnodeStore{
+			"/pravega":                         {acl: open},
+			"/pravega/old":                     {acl: open},
+			"/pravega/old/cluster":             {data: []byte("config"), acl: digest, stat: zk.Stat{Version: 7, Cversion: 2}},
+			"/pravega/old/cluster/members":     {acl: open},
+			"/pravega/old/cluster/members/ss1": {acl: open, stat: zk.Stat{EphemeralOwner: 42}},
+			"/pravega/old/streams":             {data: []byte{0, 1, 2}, acl: open, stat: zk.Stat{Version: 3}},
+			"/pravega/old/bookkeeper":          {acl: open},
+			"/pravega/old/bookkeeper/ledgers":  {acl: open},
+		}
+		archive, err = BackupZnodes(source, "old")
+	})
+
+	Context("BackupZnodes", func() {
+		It("should archive the subtree with relative paths, skipping bookkeeper and ephemeral znodes", func() {
+			Ω(err).Should(BeNil())
+			var paths []string
+			for _, znode := range archive.Znodes {
+				paths = append(paths, znode.Path)
+			}
+			Ω(paths).To(Equal([]string{"", "/cluster", "/streams", "/cluster/members"}))
+		})
+
+		It("should keep the data, ACL and versions", func() {
+			Ω(archive.Znodes[1].Data).To(Equal([]byte("config")))
+			Ω(archive.Znodes[1].ACL).To(Equal(digest))
+			Ω(archive.Znodes[1].Version).To(BeEquivalentTo(7))
+			Ω(archive.Znodes[1].CVersion).To(BeEquivalentTo(2))
+			Ω(archive.Znodes[2].Data).To(Equal([]byte{0, 1, 2}))
+		})
+
+		It("should fail when the cluster has no metadata", func() {
+			_, err = BackupZnodes(source, "missing")
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("RestoreZnodes", func() {
+		var (
+			target   memZnodeStore
+			restored int
+		)
+
+		BeforeEach(func() {
+			// the archive goes through its serialized form
+			buf := &bytes.Buffer{}
+			Ω(json.NewEncoder(buf).Encode(archive)).Should(Succeed())
+			archive = &ZnodeArchive{}
+			Ω(json.NewDecoder(buf).Decode(archive)).Should(Succeed())
+
+			target = memZnodeStore{}
+			restored, err = RestoreZnodes(target, archive, "new")
+		})
+
+		It("should replay the archive under the new cluster name", func() {
+			Ω(err).Should(BeNil())
+			Ω(restored).To(Equal(4))
+			Ω(target).To(HaveKey("/pravega/new/cluster/members"))
+			Ω(target["/pravega/new/cluster"].data).To(Equal([]byte("config")))
+			Ω(target["/pravega/new/cluster"].acl).To(Equal(digest))
+			Ω(target["/pravega/new/streams"].data).To(Equal([]byte{0, 1, 2}))
+		})
+
+		It("should refuse to restore over existing metadata", func() {
+			_, err = RestoreZnodes(target, archive, "new")
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).To(ContainSubstring("is not empty"))
+		})
+
+		It("should restore into an existing empty root", func() {
+			target = memZnodeStore{"/pravega": {}, "/pravega/new": {}}
+			restored, err = RestoreZnodes(target, archive, "new")
+			Ω(err).Should(BeNil())
+			Ω(restored).To(Equal(3))
+		})
+
+		It("should restore the znodes only writable by the SASL identity of the pods", func() {
+			sasl := []zk.ACL{{Perms: zk.PermAll, Scheme: "sasl", ID: "pravega"}}
+			for i := range archive.Znodes {
+				archive.Znodes[i].ACL = sasl
+			}
+			store := aclZnodeStore{memZnodeStore: memZnodeStore{}, scheme: ZookeeperAuthScheme, id: "pravega:hash"}
+			restored, err = RestoreZnodes(store, archive, "new")
+			Ω(err).Should(BeNil())
+			Ω(restored).To(Equal(4))
+			Ω(store.memZnodeStore["/pravega/new"].acl).To(Equal(sasl))
+			Ω(store.memZnodeStore["/pravega/new/cluster/members"].acl).To(Equal(sasl))
+		})
+
+		It("should reject unknown archive formats", func() {
+			archive.FormatVersion = 2
+			_, err = RestoreZnodes(memZnodeStore{}, archive, "new")
+			Ω(err).ShouldNot(BeNil())
+		})
+	})
+})
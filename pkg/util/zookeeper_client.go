@@ -0,0 +1,200 @@
+/**
+ * Copyright (c) 2018 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+const (
+	// ZookeeperTLSCaKey is the key of the CA certificate in the ZooKeeper TLS secret
+	ZookeeperTLSCaKey = "ca.crt"
+	// ZookeeperAuthUsernameKey and ZookeeperAuthPasswordKey are the keys of the
+	// credentials in the ZooKeeper auth secret
+	ZookeeperAuthUsernameKey = "username"
+	ZookeeperAuthPasswordKey = "password"
+
+	// ZookeeperAuthScheme is the scheme the operator authenticates with. The Go client does
+	// not support SASL, so ZooKeeper identifies the operator as digest:<username>:<hash>
+	// while it identifies the Pravega pods, which use the DigestLoginModule of their JAAS
+	// configuration, as sasl:<username>
+	ZookeeperAuthScheme = "digest"
+
+	zookeeperSessionTimeout = 5 * time.Second
+)
+
+// ZookeeperConfig is the configuration of the connection of the operator to ZooKeeper
+type ZookeeperConfig struct {
+	// Servers are the "hostname:port" of the ensemble
+	Servers []string
+	// Chroot prefixes the paths of the znodes, empty when at the root
+	Chroot string
+	// TLS enables TLS when set
+	TLS *tls.Config
+	// Digest is the "username:password" of the digest authentication, if any. They are the
+	// credentials of the SASL authentication of the Pravega pods
+	Digest string
+}
+
+// ZookeeperConn is a connection to ZooKeeper, relative to its chroot
+type ZookeeperConn interface {
+	ZnodeStore
+	Delete(path string, version int32) error
+	Close()
+}
+
+// ParseZookeeperUri returns the configuration of a connection string like
+// "host1:2181,host2:2181/chroot"
+func ParseZookeeperUri(uri string) *ZookeeperConfig {
+	c := &ZookeeperConfig{}
+	if i := strings.Index(uri, "/"); i >= 0 {
+		uri, c.Chroot = uri[:i], uri[i:]
+	}
+	for _, server := range strings.Split(uri, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			c.Servers = append(c.Servers, server)
+		}
+	}
+	return c
+}
+
+// SetTLSCa enables TLS, verifying the servers against the PEM encoded CA certificates
+func (c *ZookeeperConfig) SetTLSCa(ca []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no valid PEM certificate in %s", ZookeeperTLSCaKey)
+	}
+	c.TLS = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// SetDigest enables the digest authentication
+func (c *ZookeeperConfig) SetDigest(username string, password string) error {
+	if username == "" || password == "" {
+		return fmt.Errorf("%s and %s cannot be empty", ZookeeperAuthUsernameKey, ZookeeperAuthPasswordKey)
+	}
+	c.Digest = username + ":" + password
+	return nil
+}
+
+// LoadSecretFiles loads the TLS and auth secrets mounted in the directories, which are
+// skipped when empty
+func (c *ZookeeperConfig) LoadSecretFiles(tlsDir string, authDir string) error {
+	if tlsDir != "" {
+		ca, err := os.ReadFile(filepath.Join(tlsDir, ZookeeperTLSCaKey))
+		if err != nil {
+			return err
+		}
+		if err = c.SetTLSCa(ca); err != nil {
+			return err
+		}
+	}
+	if authDir != "" {
+		username, err := os.ReadFile(filepath.Join(authDir, ZookeeperAuthUsernameKey))
+		if err != nil {
+			return err
+		}
+		password, err := os.ReadFile(filepath.Join(authDir, ZookeeperAuthPasswordKey))
+		if err != nil {
+			return err
+		}
+		return c.SetDigest(strings.TrimSpace(string(username)), strings.TrimSpace(string(password)))
+	}
+	return nil
+}
+
+// Connect opens an authenticated connection to ZooKeeper
+func (c *ZookeeperConfig) Connect() (ZookeeperConn, error) {
+	if len(c.Servers) == 0 {
+		return nil, fmt.Errorf("no zookeeper server")
+	}
+	dialer := zk.Dialer(net.DialTimeout)
+	if c.TLS != nil {
+		dialer = c.dialTLS
+	}
+	conn, _, err := zk.Connect(c.Servers, zookeeperSessionTimeout, zk.WithDialer(dialer))
+	if err != nil {
+		return nil, err
+	}
+	if c.Digest != "" {
+		if err = conn.AddAuth(ZookeeperAuthScheme, []byte(c.Digest)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+	if c.Chroot == "" {
+		return conn, nil
+	}
+	return &chrootConn{Conn: conn, chroot: c.Chroot}, nil
+}
+
+func (c *ZookeeperConfig) dialTLS(network string, address string, timeout time.Duration) (net.Conn, error) {
+	config := c.TLS.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, config)
+}
+
+// chrootConn prefixes the paths with the chroot, which the client does not support
+type chrootConn struct {
+	*zk.Conn
+	chroot string
+}
+
+func (c *chrootConn) path(path string) string {
+	if path == "/" {
+		return c.chroot
+	}
+	return c.chroot + path
+}
+
+func (c *chrootConn) Children(path string) ([]string, *zk.Stat, error) {
+	return c.Conn.Children(c.path(path))
+}
+
+func (c *chrootConn) Get(path string) ([]byte, *zk.Stat, error) {
+	return c.Conn.Get(c.path(path))
+}
+
+func (c *chrootConn) GetACL(path string) ([]zk.ACL, *zk.Stat, error) {
+	return c.Conn.GetACL(c.path(path))
+}
+
+func (c *chrootConn) Exists(path string) (bool, *zk.Stat, error) {
+	return c.Conn.Exists(c.path(path))
+}
+
+func (c *chrootConn) Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	created, err := c.Conn.Create(c.path(path), data, flags, acl)
+	return strings.TrimPrefix(created, c.chroot), err
+}
+
+func (c *chrootConn) SetACL(path string, acl []zk.ACL, version int32) (*zk.Stat, error) {
+	return c.Conn.SetACL(c.path(path), acl, version)
+}
+
+func (c *chrootConn) Delete(path string, version int32) error {
+	return c.Conn.Delete(c.path(path), version)
+}
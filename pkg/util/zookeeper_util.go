@@ -13,7 +13,6 @@ package util
 import (
 	"container/list"
 	"fmt"
-	"time"
 
 	"github.com/samuel/go-zookeeper/zk"
 )
@@ -25,14 +24,18 @@ const (
 )
 
 // Delete all znodes related to a specific Pravega cluster
-func DeleteAllZnodes(zkUri string, clusterName string) (err error) {
-	host := []string{zkUri}
-	conn, _, err := zk.Connect(host, time.Second*5)
+func DeleteAllZnodes(zkConfig *ZookeeperConfig, clusterName string) (err error) {
+	conn, err := zkConfig.Connect()
 	if err != nil {
 		return fmt.Errorf("failed to connect to zookeeper: %v", err)
 	}
 	defer conn.Close()
-	root := fmt.Sprintf("/%s/%s", PravegaPath, clusterName)
+	return DeleteZnodes(conn, clusterName)
+}
+
+// DeleteZnodes deletes the znodes of the cluster, children first
+func DeleteZnodes(conn ZookeeperConn, clusterName string) error {
+	root := clusterZnodeRoot(clusterName)
 	exist, _, err := conn.Exists(root)
 	if err != nil {
 		return fmt.Errorf("failed to check if zookeeper path exists: %v", err)
@@ -46,9 +49,13 @@ func DeleteAllZnodes(zkUri string, clusterName string) (err error) {
 		}
 
 		for tree.Len() != 0 {
-			err := conn.Delete(tree.Back().Value.(string), -1)
+			path := tree.Back().Value.(string)
+			err := conn.Delete(path, -1)
+			if err == zk.ErrNoAuth {
+				return fmt.Errorf("failed to delete znode (%s): %v, its parent must grant the delete permission to world:anyone or to the %s identity of the operator", path, err, ZookeeperAuthScheme)
+			}
 			if err != nil {
-				return fmt.Errorf("failed to delete znode (%s): %v", tree.Back().Value.(string), err)
+				return fmt.Errorf("failed to delete znode (%s): %v", path, err)
 			}
 			tree.Remove(tree.Back())
 		}
@@ -60,7 +67,7 @@ func DeleteAllZnodes(zkUri string, clusterName string) (err error) {
 }
 
 // Construct a BFS tree
-func ListSubTreeBFS(conn *zk.Conn, root string) (*list.List, error) {
+func ListSubTreeBFS(conn ZnodeStore, root string) (*list.List, error) {
 	queue := list.New()
 	tree := list.New()
 	queue.PushBack(root)
@@ -10,18 +10,92 @@
 package util
 
 import (
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/samuel/go-zookeeper/zk"
 )
 
 var _ = Describe("zookeeperutil", func() {
 	Context("DeleteAllZnodes", func() {
 		var err error
 		BeforeEach(func() {
-			err = DeleteAllZnodes("zookeeper-client:2181", "pravega")
+			err = DeleteAllZnodes(ParseZookeeperUri("zookeeper-client:2181"), "pravega")
 		})
 		It("should not be nil", func() {
 			Ω(err).ShouldNot(BeNil())
 		})
 	})
+
+	Context("DeleteZnodes", func() {
+		var store aclZnodeStore
+
+		BeforeEach(func() {
+			open := zk.WorldACL(zk.PermAll)
+			store = aclZnodeStore{
+				memZnodeStore: memZnodeStore{
+					"/pravega":                        {acl: open},
+					"/pravega/old":                    {acl: open},
+					"/pravega/old/cluster":            {acl: open},
+					"/pravega/old/cluster/members":    {acl: open},
+					"/pravega/old/bookkeeper":         {acl: open},
+					"/pravega/old/bookkeeper/ledgers": {acl: open},
+				},
+				scheme: ZookeeperAuthScheme,
+				id:     "pravega:hash",
+			}
+		})
+
+		It("should delete the znodes of the cluster but the bookkeeper ones", func() {
+			Ω(DeleteZnodes(store, "old")).Should(Succeed())
+			Ω(store.memZnodeStore).To(HaveKey("/pravega/old/bookkeeper/ledgers"))
+			Ω(store.memZnodeStore).NotTo(HaveKey("/pravega/old/cluster"))
+			Ω(store.memZnodeStore).NotTo(HaveKey("/pravega/old/cluster/members"))
+		})
+
+		It("should delete the znodes granted to the digest identity of the operator", func() {
+			store.memZnodeStore["/pravega/old/cluster"].acl = []zk.ACL{{Perms: zk.PermAll, Scheme: "digest", ID: "pravega:hash"}}
+			Ω(DeleteZnodes(store, "old")).Should(Succeed())
+		})
+
+		It("should explain that the SASL identity of the pods is not the one of the operator", func() {
+			store.memZnodeStore["/pravega/old/cluster"].acl = []zk.ACL{{Perms: zk.PermAll, Scheme: "sasl", ID: "pravega"}}
+			err := DeleteZnodes(store, "old")
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).To(ContainSubstring("/pravega/old/cluster/members"))
+			Ω(err.Error()).To(ContainSubstring("digest identity of the operator"))
+		})
+	})
+
+	Context("ZookeeperConfig", func() {
+		It("should parse an ensemble with a chroot", func() {
+			c := ParseZookeeperUri("zk-0:2181,zk-1:2181/prod")
+			Ω(c.Servers).To(Equal([]string{"zk-0:2181", "zk-1:2181"}))
+			Ω(c.Chroot).To(Equal("/prod"))
+		})
+
+		It("should fail to connect without servers", func() {
+			_, err := ParseZookeeperUri("").Connect()
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("should load the digest from the secret files", func() {
+			dir, err := os.MkdirTemp("", "zookeeper-auth")
+			Ω(err).Should(BeNil())
+			defer os.RemoveAll(dir)
+			Ω(os.WriteFile(filepath.Join(dir, ZookeeperAuthUsernameKey), []byte("pravega\n"), 0600)).Should(Succeed())
+			Ω(os.WriteFile(filepath.Join(dir, ZookeeperAuthPasswordKey), []byte("secret"), 0600)).Should(Succeed())
+			c := ParseZookeeperUri("zk-0:2181")
+			Ω(c.LoadSecretFiles("", dir)).Should(Succeed())
+			Ω(c.Digest).To(Equal("pravega:secret"))
+			Ω(c.TLS).To(BeNil())
+		})
+
+		It("should reject an invalid CA certificate", func() {
+			c := ParseZookeeperUri("zk-0:2181")
+			Ω(c.SetTLSCa([]byte("not a certificate"))).ShouldNot(Succeed())
+		})
+	})
 })
@@ -0,0 +1,151 @@
+#!/usr/bin/env bash
+# Prints the RBAC of the operator for the namespaces it watches: a Role per namespace when
+# it watches a list of namespaces or the ones matching a selector, a ClusterRole otherwise.
+# The rules are the ones of the pravega-operator Role of config/rbac/rbac.yaml
+set -e
+set -o pipefail
+set -u
+
+usage() {
+	echo "Usage: $0 -o <operator-namespace> [-n <namespace,...>] [-l <namespace-selector>]"
+	exit 1
+}
+
+operator_namespace=""
+namespaces=""
+selector=""
+while getopts "o:n:l:" opt; do
+	case $opt in
+	o) operator_namespace=$OPTARG ;;
+	n) namespaces=$OPTARG ;;
+	l) selector=$OPTARG ;;
+	*) usage ;;
+	esac
+done
+[ -n "$operator_namespace" ] || usage
+
+rbac_file="$(dirname "$0")/../config/rbac/rbac.yaml"
+
+# rules prints the rules of the pravega-operator Role
+rules() {
+	awk '/^---/ { inrole = 0; printing = 0; next }
+	     /^kind: Role$/ { inrole = 1 }
+	     inrole && /^rules:/ { printing = 1 }
+	     /^$/ { printing = 0 }
+	     inrole && printing { print }' "$rbac_file"
+}
+
+# Resolves the namespaces matching the selector, restricted to the listed ones if any
+if [ -n "$selector" ]; then
+	selected=$(kubectl get namespaces -l "$selector" -o jsonpath='{.items[*].metadata.name}')
+	if [ -n "$namespaces" ]; then
+		listed=" ${namespaces//,/ } "
+		filtered=""
+		for ns in $selected; do
+			if [[ "$listed" == *" $ns "* ]]; then
+				filtered="$filtered $ns"
+			fi
+		done
+		selected=$filtered
+	fi
+	if [ -z "${selected// /}" ]; then
+		echo "Error: no namespace matches the selector $selector" >&2
+		exit 1
+	fi
+	namespaces=${selected// /,}
+fi
+
+cat <<EOF
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: pravega-operator
+  namespace: $operator_namespace
+EOF
+
+if [ -z "$namespaces" ]; then
+	cat <<EOF
+---
+kind: ClusterRole
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator
+$(rules)
+---
+kind: ClusterRoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator
+subjects:
+- kind: ServiceAccount
+  name: pravega-operator
+  namespace: $operator_namespace
+roleRef:
+  kind: ClusterRole
+  name: pravega-operator
+  apiGroup: rbac.authorization.k8s.io
+EOF
+fi
+
+# The namespace of the operator holds the leader election Lease and lock
+for ns in $(echo "$operator_namespace,$namespaces" | tr ',' '\n' | sed '/^$/d' | sort -u); do
+	if [ -z "$namespaces" ] && [ "$ns" != "$operator_namespace" ]; then
+		continue
+	fi
+	cat <<EOF
+---
+kind: Role
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator
+  namespace: $ns
+$(rules)
+---
+kind: RoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator
+  namespace: $ns
+subjects:
+- kind: ServiceAccount
+  name: pravega-operator
+  namespace: $operator_namespace
+roleRef:
+  kind: Role
+  name: pravega-operator
+  apiGroup: rbac.authorization.k8s.io
+EOF
+done
+
+# Nodes are read to publish the addresses of the Segment Stores, and namespaces to
+# resolve the selector
+cat <<EOF
+---
+kind: ClusterRole
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator-cluster-reader
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - nodes
+  - namespaces
+  verbs:
+  - get
+  - list
+  - watch
+---
+kind: ClusterRoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: pravega-operator-cluster-reader
+subjects:
+- kind: ServiceAccount
+  name: pravega-operator
+  namespace: $operator_namespace
+roleRef:
+  kind: ClusterRole
+  name: pravega-operator-cluster-reader
+  apiGroup: rbac.authorization.k8s.io
+EOF
@@ -87,10 +87,9 @@ var _ = Describe("Configmap changes", func() {
 			time.Sleep(60 * time.Second)
 
 			// Check configmap is  Updated
-			jvmOptsController = append(jvmOptsController, "bookkeeper.ack.quorum.size=2")
 			err = pravega_e2eutil.CheckConfigMapUpdated(&t, k8sClient, pravega, c_cm, "JAVA_OPTS", jvmOptsController)
 			Expect(err).NotTo(HaveOccurred())
-			jvmOptsSegmentStore = append(jvmOptsSegmentStore, "pravegaservice.service.listener.port=443")
+			jvmOptsSegmentStore = append(jvmOptsSegmentStore, "bookkeeper.ack.quorum.size=2", "pravegaservice.service.listener.port=443")
 			err = pravega_e2eutil.CheckConfigMapUpdated(&t, k8sClient, pravega, ss_cm, "JAVA_OPTS", jvmOptsSegmentStore)
 			Expect(err).NotTo(HaveOccurred())
 